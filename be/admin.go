@@ -0,0 +1,28 @@
+// admin.go
+package main
+
+import (
+	"net/http"
+)
+
+// AdminHandler обслуживает служебные операции над производными структурами
+type AdminHandler struct {
+	storage      Storage
+	searchEngine *SearchEngine
+}
+
+func NewAdminHandler(storage Storage, searchEngine *SearchEngine) *AdminHandler {
+	return &AdminHandler{storage: storage, searchEngine: searchEngine}
+}
+
+// RebuildCaches перестраивает поисковый индекс и ссылки на файлы из storage,
+// например после изменения файлов документов в обход API
+func (h *AdminHandler) RebuildCaches(w http.ResponseWriter, r *http.Request) {
+	stats, err := h.searchEngine.Rebuild(h.storage)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, r, stats)
+}
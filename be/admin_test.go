@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestRebuildCachesPicksUpOutOfBandChanges(t *testing.T) {
+	gs := newTestStorage(t)
+	doc := mustCreate(t, gs, "", "Manual", "original text")
+	engine := NewSearchEngine([]string{"english"})
+	if err := engine.LoadFromStorage(gs); err != nil {
+		t.Fatal(err)
+	}
+
+	// Правка файла в обход API
+	filePath := filepath.Join(gs.docsDir, doc.Path, "Manual.md")
+	if err := os.WriteFile(filePath, []byte("zebra ![x](/api/file/img.png)"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, total, _ := engine.Search("zebra", 1, 10); total != 0 {
+		t.Fatalf("search found out-of-band change before rebuild")
+	}
+	if refs := engine.FileReferences("img.png"); len(refs) != 0 {
+		t.Fatalf("file references before rebuild = %v", refs)
+	}
+
+	h := NewAdminHandler(gs, engine)
+	rec := serve(h.RebuildCaches, "POST", "/api/admin/rebuild-caches", nil, nil)
+	var stats RebuildStats
+	if err := json.NewDecoder(rec.Body).Decode(&stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Documents != 1 || stats.FileReferences != 1 || stats.Terms == 0 {
+		t.Fatalf("stats = %+v", stats)
+	}
+
+	if _, total, _ := engine.Search("zebra", 1, 10); total != 1 {
+		t.Fatalf("search after rebuild found %d documents, want 1", total)
+	}
+	if _, total, _ := engine.Search("original", 1, 10); total != 0 {
+		t.Fatalf("stale term still indexed after rebuild")
+	}
+	if refs := engine.FileReferences("img.png"); !reflect.DeepEqual(refs, []string{doc.Path}) {
+		t.Fatalf("file references after rebuild = %v", refs)
+	}
+}
+
+func TestGetIDCollisions(t *testing.T) {
+	gs := newTestStorage(t)
+	first := mustCreate(t, gs, "", "Notes", "")
+	second := mustCreate(t, gs, "", "Notes", "")
+	mustCreate(t, gs, "", "Unique", "")
+	parent := mustCreate(t, gs, "", "Parent", "")
+	mustCreate(t, gs, parent.Path, "Plan", "")
+	nested := mustCreate(t, gs, parent.Path, "Plan", "")
+
+	h := NewAdminHandler(gs, NewSearchEngine([]string{"english"}))
+	rec := serve(h.GetIDCollisions, "GET", "/api/admin/collisions", nil, nil)
+	var groups []CollisionGroup
+	if err := json.NewDecoder(rec.Body).Decode(&groups); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(groups) != 2 {
+		t.Fatalf("groups = %+v, want 2", groups)
+	}
+	root := groups[0]
+	if root.ParentPath != "" || root.BaseID != "notes" || len(root.Documents) != 2 {
+		t.Fatalf("root group = %+v", root)
+	}
+	if root.Documents[0].Path != first.Path || root.Documents[0].SuggestedID != "" {
+		t.Fatalf("original document = %+v", root.Documents[0])
+	}
+	if root.Documents[1].Path != second.Path || root.Documents[1].SuggestedID != "notes_2" {
+		t.Fatalf("suffixed document = %+v", root.Documents[1])
+	}
+
+	child := groups[1]
+	if child.ParentPath != parent.Path || child.BaseID != "plan" || child.Documents[1].Path != nested.Path {
+		t.Fatalf("nested group = %+v", child)
+	}
+}
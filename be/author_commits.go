@@ -0,0 +1,225 @@
+// author_commits.go
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// systemAuthor подписывает коммиты, автор которых неизвестен
+var systemAuthor = CoAuthor{Name: "Document System", Email: "docs@system"}
+
+// PendingCommit - коммит, созданный при пакетной фиксации отложенных правок
+type PendingCommit struct {
+	CommitHash string   `json:"commitHash"`
+	Author     CoAuthor `json:"author"`
+	Files      []string `json:"files"`
+}
+
+// requestAuthor читает автора правки из заголовков X-Author-Name и X-Author-Email.
+// Без заголовков автор неизвестен и ok=false.
+func requestAuthor(r *http.Request) (author CoAuthor, ok bool, err error) {
+	author = CoAuthor{
+		Name:  strings.TrimSpace(r.Header.Get("X-Author-Name")),
+		Email: strings.TrimSpace(r.Header.Get("X-Author-Email")),
+	}
+	if author.Name == "" && author.Email == "" {
+		return CoAuthor{}, false, nil
+	}
+	if err := validateCoAuthors([]CoAuthor{author}); err != nil {
+		return CoAuthor{}, false, err
+	}
+	return author, true, nil
+}
+
+// commitAuthor выбирает автора коммита: поле author из тела запроса важнее
+// заголовков X-Author-Name и X-Author-Email. nil - коммит от имени системы.
+func commitAuthor(r *http.Request, fromBody *CoAuthor) (*CoAuthor, error) {
+	if fromBody != nil {
+		author := CoAuthor{Name: strings.TrimSpace(fromBody.Name), Email: strings.TrimSpace(fromBody.Email)}
+		if err := validateCoAuthors([]CoAuthor{author}); err != nil {
+			return nil, err
+		}
+		return &author, nil
+	}
+	author, ok, err := requestAuthor(r)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return &author, nil
+}
+
+func (h *DocumentHandler) createDocument(author *CoAuthor, parentPath, title, content string, coAuthors []CoAuthor) (Document, error) {
+	if gitStorage, ok := h.storage.(*GitStorage); ok && author != nil {
+		return gitStorage.CreateDocumentAs(*author, parentPath, title, content, coAuthors...)
+	}
+	return h.storage.CreateDocument(parentPath, title, content, coAuthors...)
+}
+
+func (h *DocumentHandler) updateDocument(author *CoAuthor, message, docPath, title, content string, commitChanges bool, coAuthors []CoAuthor) (Document, error) {
+	if gitStorage, ok := h.storage.(*GitStorage); ok && (author != nil || message != "") {
+		commitAuthor := systemAuthor
+		if author != nil {
+			commitAuthor = *author
+		}
+		return gitStorage.UpdateDocumentAs(commitAuthor, message, docPath, title, content, commitChanges, coAuthors...)
+	}
+	return h.storage.UpdateDocument(docPath, title, content, commitChanges, coAuthors...)
+}
+
+func (h *DocumentHandler) deleteDocument(author *CoAuthor, docPath string) error {
+	if gitStorage, ok := h.storage.(*GitStorage); ok && author != nil {
+		return gitStorage.DeleteDocumentAs(*author, docPath)
+	}
+	return h.storage.DeleteDocument(docPath)
+}
+
+// TrackPendingAuthor запоминает автора незакоммиченных правок документов.
+// При пакетной фиксации изменения этих документов уйдут в отдельный коммит автора.
+func (gs *GitStorage) TrackPendingAuthor(author CoAuthor, docPaths ...string) {
+	gs.pendingMu.Lock()
+	defer gs.pendingMu.Unlock()
+
+	if gs.pendingAuthors == nil {
+		gs.pendingAuthors = make(map[string]CoAuthor)
+	}
+	for _, docPath := range docPaths {
+		gs.pendingAuthors[docPath] = author
+	}
+}
+
+// pendingAuthor ищет автора документа или ближайшего предка: при смене заголовка
+// каталог переименовывается вместе с вложенными документами.
+// Вызывается под gs.pendingMu.
+func (gs *GitStorage) pendingAuthor(docPath string) (CoAuthor, bool) {
+	for p := docPath; p != "." && p != ""; p = path.Dir(p) {
+		if author, ok := gs.pendingAuthors[p]; ok {
+			return author, true
+		}
+	}
+	return CoAuthor{}, false
+}
+
+// forgetPendingAuthors забывает авторов документов, правки которых закоммичены.
+// Вызывается под gs.pendingMu.
+func (gs *GitStorage) forgetPendingAuthors(scope commitScope) {
+	for docPath := range gs.pendingAuthors {
+		if scope.includesDoc(docPath) {
+			delete(gs.pendingAuthors, docPath)
+		}
+	}
+}
+
+// CommitPending фиксирует все незакоммиченные изменения, группируя их по авторам:
+// правки каждого автора становятся отдельным коммитом от его имени, изменения
+// без известного автора - последним коммитом от имени системы.
+func (gs *GitStorage) CommitPending(message string) ([]PendingCommit, error) {
+	defer gs.invalidateStatus()
+
+	// Отложенные правки уходят своими коммитами
+	if err := gs.Flush(); err != nil {
+		return nil, err
+	}
+
+	gs.pendingMu.Lock()
+	defer gs.pendingMu.Unlock()
+	gs.commitMu.Lock()
+	defer gs.commitMu.Unlock()
+
+	w, err := gs.repo.Worktree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get worktree: %w", err)
+	}
+	status, err := w.Status()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get status: %w", err)
+	}
+
+	groups := make(map[CoAuthor][]string)
+	for file := range status {
+		author := systemAuthor
+		if strings.HasPrefix(file, "docs/") {
+			if a, ok := gs.pendingAuthor(strings.TrimPrefix(path.Dir(file), "docs/")); ok {
+				author = a
+			}
+		}
+		groups[author] = append(groups[author], file)
+	}
+
+	authors := make([]CoAuthor, 0, len(groups))
+	for author := range groups {
+		if author != systemAuthor {
+			authors = append(authors, author)
+		}
+	}
+	sort.Slice(authors, func(i, j int) bool { return authors[i].Email < authors[j].Email })
+	if groups[systemAuthor] != nil {
+		authors = append(authors, systemAuthor)
+	}
+
+	commits := []PendingCommit{}
+	for _, author := range authors {
+		files := groups[author]
+		sort.Strings(files)
+		for _, file := range files {
+			if status[file].Worktree == git.Deleted {
+				_, err = w.Remove(file)
+			} else {
+				_, err = w.Add(file)
+			}
+			if err != nil {
+				return commits, fmt.Errorf("failed to stage %s: %w", file, err)
+			}
+		}
+
+		hash, err := w.Commit(message, &git.CommitOptions{
+			Author: &object.Signature{Name: author.Name, Email: author.Email, When: time.Now()},
+		})
+		if err != nil {
+			return commits, fmt.Errorf("failed to commit changes of %s: %w", author, err)
+		}
+		gitCommitsTotal.Inc()
+		commits = append(commits, PendingCommit{CommitHash: hash.String(), Author: author, Files: files})
+	}
+
+	gs.pendingAuthors = nil
+	return commits, nil
+}
+
+// CommitPending фиксирует отложенные правки, по коммиту на автора
+func (h *DocumentHandler) CommitPending(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Message string `json:"message"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if strings.TrimSpace(req.Message) == "" {
+		req.Message = "Commit pending changes"
+	}
+
+	gitStorage, ok := h.storage.(*GitStorage)
+	if !ok {
+		http.Error(w, "batch commit only available with git storage", http.StatusNotImplemented)
+		return
+	}
+
+	commits, err := gitStorage.CommitPending(req.Message)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, r, commits)
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/gorilla/mux"
+)
+
+func updateAs(t *testing.T, h *DocumentHandler, docPath, title, content string, author CoAuthor) *httptest.ResponseRecorder {
+	t.Helper()
+	body, _ := json.Marshal(map[string]any{"title": title, "content": content, "commit_changes": false})
+	req := httptest.NewRequest("PUT", "/api/document/"+docPath, strings.NewReader(string(body)))
+	req.Header.Set("X-Author-Name", author.Name)
+	req.Header.Set("X-Author-Email", author.Email)
+	rec := httptest.NewRecorder()
+	h.UpdateDocument(rec, mux.SetURLVars(req, map[string]string{"rest": docPath}))
+	return rec
+}
+
+func TestCommitPendingGroupsByAuthor(t *testing.T) {
+	h, gs, engine := newTestDocumentHandler(t)
+	a := mustCreate(t, gs, "", "Alpha", "a1")
+	b := mustCreate(t, gs, "", "Beta", "b1")
+	for _, doc := range []Document{a, b} {
+		if err := engine.IndexDocument(doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Файлы сервера вне docs/ не относятся к правкам авторов
+	if _, err := gs.CommitPending("Server state"); err != nil {
+		t.Fatal(err)
+	}
+
+	alice := CoAuthor{Name: "Alice", Email: "alice@example.com"}
+	bob := CoAuthor{Name: "Bob", Email: "bob@example.com"}
+	if resp := updateAs(t, h, a.Path, a.Title, "a2", alice); resp.Code != http.StatusOK {
+		t.Fatalf("alice update status = %d, body %s", resp.Code, resp.Body)
+	}
+	if resp := updateAs(t, h, b.Path, b.Title, "b2", bob); resp.Code != http.StatusOK {
+		t.Fatalf("bob update status = %d, body %s", resp.Code, resp.Body)
+	}
+	before := countCommits(t, gs)
+
+	resp := serve(h.CommitPending, "POST", "/api/commit", strings.NewReader(`{"message":"Save work"}`), nil)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("commit status = %d, body %s", resp.Code, resp.Body)
+	}
+	var commits []PendingCommit
+	if err := json.NewDecoder(resp.Body).Decode(&commits); err != nil {
+		t.Fatal(err)
+	}
+	if len(commits) != 2 || countCommits(t, gs) != before+2 {
+		t.Fatalf("commits = %+v, want one per author", commits)
+	}
+
+	want := map[string]string{alice.Email: "docs/" + a.Path + "/Alpha.md", bob.Email: "docs/" + b.Path + "/Beta.md"}
+	for _, pc := range commits {
+		commit, err := gs.repo.CommitObject(plumbing.NewHash(pc.CommitHash))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if commit.Author.Email != pc.Author.Email || commit.Message != "Save work" {
+			t.Errorf("commit %s by %s with message %q", pc.CommitHash, commit.Author.Email, commit.Message)
+		}
+		stats, err := commit.Stats()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(stats) != 1 || stats[0].Name != want[pc.Author.Email] {
+			t.Errorf("commit of %s touches %v, want only %s", pc.Author.Email, stats, want[pc.Author.Email])
+		}
+	}
+
+	// Все изменения зафиксированы, повторный вызов ничего не коммитит
+	resp = serve(h.CommitPending, "POST", "/api/commit", nil, nil)
+	if resp.Code != http.StatusOK || strings.TrimSpace(resp.Body.String()) != "[]" {
+		t.Errorf("second commit: status %d, body %s", resp.Code, resp.Body)
+	}
+}
+
+func TestCommitPendingUnknownAuthorUsesSystem(t *testing.T) {
+	_, gs, _ := newTestDocumentHandler(t)
+	a := mustCreate(t, gs, "", "Alpha", "a1")
+	if _, err := gs.UpdateDocument(a.Path, a.Title, "a2", false); err != nil {
+		t.Fatal(err)
+	}
+
+	commits, err := gs.CommitPending("Save")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(commits) != 1 || commits[0].Author != systemAuthor {
+		t.Errorf("commits = %+v, want one system commit", commits)
+	}
+}
+
+func TestUpdateDocumentRejectsInvalidAuthor(t *testing.T) {
+	h, gs, _ := newTestDocumentHandler(t)
+	a := mustCreate(t, gs, "", "Alpha", "a1")
+
+	resp := updateAs(t, h, a.Path, a.Title, "a2", CoAuthor{Name: "Mallory", Email: "not an email"})
+	if resp.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", resp.Code)
+	}
+}
+
+func TestDocumentCommitsUseRequestAuthor(t *testing.T) {
+	h, gs, _ := newTestDocumentHandler(t)
+	alice := CoAuthor{Name: "Alice", Email: "alice@example.com"}
+	bob := CoAuthor{Name: "Bob", Email: "bob@example.com"}
+
+	headAuthor := func() CoAuthor {
+		t.Helper()
+		ref, err := gs.repo.Head()
+		if err != nil {
+			t.Fatal(err)
+		}
+		commit, err := gs.repo.CommitObject(ref.Hash())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return CoAuthor{Name: commit.Author.Name, Email: commit.Author.Email}
+	}
+	send := func(handler http.HandlerFunc, method, target, body string, vars map[string]string, author *CoAuthor) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if author != nil {
+			req.Header.Set("X-Author-Name", author.Name)
+			req.Header.Set("X-Author-Email", author.Email)
+		}
+		if vars != nil {
+			req = mux.SetURLVars(req, vars)
+		}
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec
+	}
+
+	// Автор из заголовков
+	rec := send(h.CreateDocument, "POST", "/api/document", `{"title":"Doc","content":"one"}`, nil, &alice)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("create status = %d, body %s", rec.Code, rec.Body)
+	}
+	var doc Document
+	if err := json.NewDecoder(rec.Body).Decode(&doc); err != nil {
+		t.Fatal(err)
+	}
+	if got := headAuthor(); got != alice {
+		t.Errorf("create author = %v, want %v", got, alice)
+	}
+
+	// Поле author в теле важнее заголовков
+	vars := map[string]string{"rest": doc.Path}
+	rec = send(h.UpdateDocument, "PUT", "/api/document/"+doc.Path,
+		`{"title":"Doc","content":"two","commit_changes":true,"author":{"name":"Bob","email":"bob@example.com"}}`, vars, &alice)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("update status = %d, body %s", rec.Code, rec.Body)
+	}
+	if got := headAuthor(); got != bob {
+		t.Errorf("update author = %v, want %v", got, bob)
+	}
+
+	// Без автора коммит от имени системы
+	rec = send(h.UpdateDocument, "PUT", "/api/document/"+doc.Path, `{"title":"Doc","content":"three","commit_changes":true}`, vars, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("anonymous update status = %d, body %s", rec.Code, rec.Body)
+	}
+	if got := headAuthor(); got != systemAuthor {
+		t.Errorf("anonymous update author = %v, want %v", got, systemAuthor)
+	}
+
+	rec = send(h.UpdateDocument, "PUT", "/api/document/"+doc.Path,
+		`{"title":"Doc","content":"four","commit_changes":true,"author":{"name":"Eve","email":"nope"}}`, vars, nil)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid body author: status = %d, want 400", rec.Code)
+	}
+
+	rec = send(h.DeleteDocument, "DELETE", "/api/document/"+doc.Path, "", vars, &bob)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("delete status = %d, body %s", rec.Code, rec.Body)
+	}
+	if got := headAuthor(); got != bob {
+		t.Errorf("delete author = %v, want %v", got, bob)
+	}
+}
+
+func TestUpdateDocumentCommitMessage(t *testing.T) {
+	h, gs, engine := newTestDocumentHandler(t)
+	mustCreate(t, gs, "", "Other", "")
+	doc := mustCreate(t, gs, "", "Doc", "one")
+	if err := engine.IndexDocument(doc); err != nil {
+		t.Fatal(err)
+	}
+	vars := map[string]string{"rest": doc.Path}
+
+	update := func(body string) {
+		t.Helper()
+		rec := serve(h.UpdateDocument, "PUT", "/api/document/"+doc.Path, strings.NewReader(body), vars)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("update status = %d, body %s", rec.Code, rec.Body)
+		}
+	}
+	update(`{"title":"Doc","content":"two","commit_changes":true,"commit_message":"  Fix typo\r\nin the\n\nintro  "}`)
+	update(`{"title":"Doc","content":"three","commit_changes":true,"commit_message":" \n "}`)
+
+	history, err := gs.GetDocumentHistory(context.Background(), doc.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var messages []string
+	for _, c := range history.History {
+		messages = append(messages, c.Message)
+	}
+	want := []string{"Update document: " + doc.Path, "Fix typo in the intro", "Create document: " + doc.Path}
+	if strings.Join(messages, "|") != strings.Join(want, "|") {
+		t.Errorf("messages = %q, want %q", messages, want)
+	}
+}
+
+func TestImmediateCommitKeepsOtherAuthorsPending(t *testing.T) {
+	h, gs, engine := newTestDocumentHandler(t)
+	a := mustCreate(t, gs, "", "Alpha", "a1")
+	b := mustCreate(t, gs, "", "Beta", "b1")
+	for _, doc := range []Document{a, b} {
+		if err := engine.IndexDocument(doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Файлы сервера вне docs/ не относятся к правкам авторов
+	if _, err := gs.CommitPending("Server state"); err != nil {
+		t.Fatal(err)
+	}
+
+	alice := CoAuthor{Name: "Alice", Email: "alice@example.com"}
+	bob := CoAuthor{Name: "Bob", Email: "bob@example.com"}
+	if resp := updateAs(t, h, b.Path, b.Title, "b2", bob); resp.Code != http.StatusOK {
+		t.Fatalf("bob update status = %d, body %s", resp.Code, resp.Body)
+	}
+	if _, err := gs.UpdateDocumentAs(alice, "", a.Path, a.Title, "a2", true); err != nil {
+		t.Fatal(err)
+	}
+
+	// Коммит Алисы содержит только ее документ
+	head, err := gs.repo.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit, err := gs.repo.CommitObject(head.Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats, err := commit.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if commit.Author.Email != alice.Email || len(stats) != 1 || stats[0].Name != "docs/"+a.Path+"/Alpha.md" {
+		t.Fatalf("alice commit by %s touches %v", commit.Author.Email, stats)
+	}
+
+	// Правка Боба дождалась пакетной фиксации и ушла от его имени
+	commits, err := gs.CommitPending("Save work")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(commits) != 1 || commits[0].Author != bob || len(commits[0].Files) != 1 || commits[0].Files[0] != "docs/"+b.Path+"/Beta.md" {
+		t.Errorf("pending commits = %+v, want bob's Beta", commits)
+	}
+}
@@ -0,0 +1,140 @@
+// backlinks.go
+package main
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/mux"
+)
+
+// DocumentLinks - обратные ссылки между документами: кто ссылается на путь.
+// Обновляется при индексации документов, как и FileReferences. Ссылки хранятся
+// по пути цели, поэтому после перемещения документа ссылки на старый путь
+// остаются под старым путем и считаются битыми.
+type DocumentLinks struct {
+	mu       sync.RWMutex
+	byTarget map[string]map[string]bool // путь цели -> пути ссылающихся документов
+	bySource map[string][]string        // путь документа -> пути целей
+}
+
+func NewDocumentLinks() *DocumentLinks {
+	return &DocumentLinks{
+		byTarget: make(map[string]map[string]bool),
+		bySource: make(map[string][]string),
+	}
+}
+
+// Update заменяет исходящие ссылки документа на ссылки из его текущего содержимого
+func (dl *DocumentLinks) Update(docPath, content string) {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+
+	dl.remove(docPath)
+
+	targets := extractDocumentLinks(content)
+	if len(targets) == 0 {
+		return
+	}
+	for _, target := range targets {
+		if dl.byTarget[target] == nil {
+			dl.byTarget[target] = make(map[string]bool)
+		}
+		dl.byTarget[target][docPath] = true
+	}
+	dl.bySource[docPath] = targets
+}
+
+// Remove удаляет исходящие ссылки документа
+func (dl *DocumentLinks) Remove(docPath string) {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+
+	dl.remove(docPath)
+}
+
+func (dl *DocumentLinks) remove(docPath string) {
+	for _, target := range dl.bySource[docPath] {
+		delete(dl.byTarget[target], docPath)
+		if len(dl.byTarget[target]) == 0 {
+			delete(dl.byTarget, target)
+		}
+	}
+	delete(dl.bySource, docPath)
+}
+
+// Sources возвращает отсортированные пути документов, ссылающихся на target
+func (dl *DocumentLinks) Sources(target string) []string {
+	dl.mu.RLock()
+	defer dl.mu.RUnlock()
+
+	paths := make([]string, 0, len(dl.byTarget[target]))
+	for docPath := range dl.byTarget[target] {
+		if docPath != target {
+			paths = append(paths, docPath)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Targets возвращает пути документов, на которые ссылается source, в порядке ссылок
+func (dl *DocumentLinks) Targets(source string) []string {
+	dl.mu.RLock()
+	defer dl.mu.RUnlock()
+
+	paths := make([]string, 0, len(dl.bySource[source]))
+	for _, target := range dl.bySource[source] {
+		if target != source {
+			paths = append(paths, target)
+		}
+	}
+	return paths
+}
+
+// Backlinks возвращает пути документов, ссылающихся на документ
+func (se *SearchEngine) Backlinks(docPath string) []string {
+	se.mu.RLock()
+	links := se.links
+	se.mu.RUnlock()
+
+	return links.Sources(strings.Trim(docPath, "/"))
+}
+
+// OutgoingLinks возвращает пути документов, на которые ссылается документ
+func (se *SearchEngine) OutgoingLinks(docPath string) []string {
+	se.mu.RLock()
+	links := se.links
+	se.mu.RUnlock()
+
+	return links.Targets(strings.Trim(docPath, "/"))
+}
+
+// GetBacklinks возвращает документы, которые ссылаются на документ. Если документа
+// по этому пути нет (например, его переместили), найденные ссылки битые: broken=true
+func (h *DocumentHandler) GetBacklinks(w http.ResponseWriter, r *http.Request) {
+	docPath := strings.Trim(mux.Vars(r)["rest"], "/")
+	if docPath == "" {
+		http.Error(w, "document path is required", http.StatusBadRequest)
+		return
+	}
+
+	docs := []ShortDocument{}
+	for _, source := range h.search.Backlinks(docPath) {
+		doc, err := h.storage.GetDocument(source)
+		if err != nil {
+			log.Printf("Warning: failed to load document %q linking to %q: %v", source, docPath, err)
+			continue
+		}
+		docs = append(docs, *documentToShort(&doc))
+	}
+
+	writeJSON(w, r, struct {
+		Path      string          `json:"path"`
+		Broken    bool            `json:"broken"`
+		Documents []ShortDocument `json:"documents"`
+	}{Path: docPath, Broken: documentMissing(h.storage, docPath), Documents: docs})
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+type backlinksResponse struct {
+	Path      string          `json:"path"`
+	Broken    bool            `json:"broken"`
+	Documents []ShortDocument `json:"documents"`
+}
+
+func getBacklinks(t *testing.T, h *DocumentHandler, docPath string) backlinksResponse {
+	t.Helper()
+	rec := serve(h.GetBacklinks, "GET", "/api/backlinks/"+docPath, nil, map[string]string{"rest": docPath})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	var resp backlinksResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestGetBacklinks(t *testing.T) {
+	h, gs, engine := newTestDocumentHandler(t)
+	target := mustCreate(t, gs, "", "Target", "")
+	folder := mustCreate(t, gs, "", "Folder", "")
+	source := mustCreate(t, gs, "", "Source", "See [target](/doc/"+target.Path+")")
+	mustCreate(t, gs, "", "Code", "`[target](/doc/"+target.Path+")`")
+	if err := engine.LoadFromStorage(gs); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.IndexDocument(draftDocument(Draft{ID: "d1", Title: "Draft", Content: "[t](/doc/" + target.Path + ")"})); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := getBacklinks(t, h, target.Path)
+	if resp.Broken || len(resp.Documents) != 1 || resp.Documents[0].Path != source.Path {
+		t.Fatalf("backlinks = %+v", resp)
+	}
+
+	// Перемещенный документ: ссылки на старый путь становятся битыми
+	if r, _ := applyTx(t, h, []TxOperation{{Op: TxMove, Path: target.Path, Target: folder.Path}}); r.StatusCode != http.StatusOK {
+		t.Fatalf("move status = %d", r.StatusCode)
+	}
+	moved := folder.Path + "/" + target.ID
+	if resp := getBacklinks(t, h, moved); resp.Broken || len(resp.Documents) != 0 {
+		t.Errorf("backlinks of moved document = %+v", resp)
+	}
+	resp = getBacklinks(t, h, target.Path)
+	if !resp.Broken || len(resp.Documents) != 1 || resp.Documents[0].Path != source.Path {
+		t.Errorf("backlinks of old path = %+v", resp)
+	}
+
+	// Ссылка исправлена - индекс обновляется при переиндексации источника
+	updated, err := gs.UpdateDocument(source.Path, "Source", "See [target](/document/"+moved+")", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.IndexDocument(updated); err != nil {
+		t.Fatal(err)
+	}
+	if resp := getBacklinks(t, h, moved); len(resp.Documents) != 1 || resp.Documents[0].Path != source.Path {
+		t.Errorf("backlinks after fix = %+v", resp)
+	}
+	if resp := getBacklinks(t, h, target.Path); len(resp.Documents) != 0 {
+		t.Errorf("old path still has backlinks: %+v", resp)
+	}
+
+	if err := engine.DeleteDocument(source.Path); err != nil {
+		t.Fatal(err)
+	}
+	if resp := getBacklinks(t, h, moved); len(resp.Documents) != 0 {
+		t.Errorf("backlinks after delete = %+v", resp)
+	}
+}
@@ -0,0 +1,208 @@
+// changes.go
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+)
+
+const (
+	ChangeCreated  = "created"
+	ChangeModified = "modified"
+	ChangeDeleted  = "deleted"
+	ChangeMoved    = "moved"
+)
+
+// DocumentChange - итоговое изменение документа за период
+type DocumentChange struct {
+	Path       string    `json:"path"`
+	OldPath    string    `json:"oldPath,omitempty"` // для moved - путь на начало периода
+	Type       string    `json:"type"`
+	Title      string    `json:"title"`
+	CommitHash string    `json:"commitHash"` // последний коммит, менявший документ
+	Date       time.Time `json:"date"`
+}
+
+type ChangesResponse struct {
+	Since   time.Time        `json:"since"`
+	Until   time.Time        `json:"until"` // время последнего учтенного коммита, since для следующего запроса
+	Changes []DocumentChange `json:"changes"`
+}
+
+// GetChangesSince сворачивает закоммиченные с момента since изменения в одно
+// изменение на документ: созданный и затем удаленный документ не попадает в
+// результат, созданный и затем измененный остается created. Граница включительная,
+// поэтому при повторе запроса с until последний коммит вернется еще раз.
+func (gs *GitStorage) GetChangesSince(ctx context.Context, since time.Time) (ChangesResponse, error) {
+	resp := ChangesResponse{Since: since, Until: since, Changes: []DocumentChange{}}
+
+	if _, err := gs.repo.Head(); errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return resp, nil // Еще нет ни одного коммита
+	}
+
+	cIter, err := gs.repo.Log(&git.LogOptions{Order: git.LogOrderCommitterTime})
+	if err != nil {
+		return resp, fmt.Errorf("failed to get git log: %w", err)
+	}
+
+	var commits []*object.Commit
+	err = cIter.ForEach(func(c *object.Commit) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if c.Committer.When.Before(since) {
+			return storer.ErrStop
+		}
+		commits = append(commits, c)
+		return nil
+	})
+	if err != nil {
+		return resp, fmt.Errorf("error processing commit history: %w", err)
+	}
+	if len(commits) == 0 {
+		return resp, nil
+	}
+	resp.Until = commits[0].Committer.When
+
+	// Применяем коммиты от старых к новым
+	state := make(map[string]*DocumentChange)
+	for i := len(commits) - 1; i >= 0; i-- {
+		if err := gs.applyCommitChanges(ctx, commits[i], state); err != nil {
+			return resp, err
+		}
+	}
+
+	for _, change := range state {
+		resp.Changes = append(resp.Changes, *change)
+	}
+	sort.Slice(resp.Changes, func(i, j int) bool {
+		a, b := resp.Changes[i], resp.Changes[j]
+		if !a.Date.Equal(b.Date) {
+			return a.Date.Before(b.Date)
+		}
+		return a.Path < b.Path
+	})
+	return resp, nil
+}
+
+// applyCommitChanges добавляет изменения документов из коммита к накопленному состоянию
+func (gs *GitStorage) applyCommitChanges(ctx context.Context, c *object.Commit, state map[string]*DocumentChange) error {
+	currentTree, err := c.Tree()
+	if err != nil {
+		return err
+	}
+	parentTree := &object.Tree{}
+	if c.NumParents() > 0 {
+		parent, err := c.Parent(0)
+		if err != nil {
+			return err
+		}
+		if parentTree, err = parent.Tree(); err != nil {
+			return err
+		}
+	}
+
+	changes, err := object.DiffTreeWithOptions(ctx, parentTree, currentTree, object.DefaultDiffTreeOptions)
+	if err != nil {
+		return err
+	}
+
+	record := func(docPath, kind, title, oldPath string) {
+		state[docPath] = &DocumentChange{
+			Path:       docPath,
+			OldPath:    oldPath,
+			Type:       kind,
+			Title:      title,
+			CommitHash: c.Hash.String(),
+			Date:       c.Committer.When,
+		}
+	}
+
+	for _, change := range changes {
+		from, to := change.From.Name, change.To.Name
+		if !isDocumentFile(from) && !isDocumentFile(to) {
+			continue
+		}
+		fromPath := strings.TrimPrefix(path.Dir(from), "docs/")
+		toPath := strings.TrimPrefix(path.Dir(to), "docs/")
+		prev := state[fromPath]
+
+		switch {
+		case from == "":
+			kind := ChangeCreated
+			if prev != nil && prev.Type == ChangeDeleted {
+				kind = ChangeModified // Удален и создан заново за период
+			}
+			record(toPath, kind, trimMD(path.Base(to)), "")
+
+		case to == "":
+			delete(state, fromPath)
+			if prev == nil || prev.Type != ChangeCreated {
+				oldPath := fromPath
+				if prev != nil && prev.OldPath != "" {
+					oldPath = prev.OldPath
+				}
+				record(oldPath, ChangeDeleted, trimMD(path.Base(from)), "")
+			}
+
+		case fromPath == toPath:
+			// Правка текста или смена заголовка без смены каталога
+			kind, oldPath := ChangeModified, ""
+			if prev != nil {
+				kind, oldPath = prev.Type, prev.OldPath
+			}
+			record(toPath, kind, trimMD(path.Base(to)), oldPath)
+
+		default:
+			delete(state, fromPath)
+			kind, oldPath := ChangeMoved, fromPath
+			if prev != nil && prev.Type == ChangeCreated {
+				kind, oldPath = ChangeCreated, ""
+			} else if prev != nil && prev.OldPath != "" {
+				oldPath = prev.OldPath
+			}
+			if oldPath == toPath {
+				kind, oldPath = ChangeModified, "" // Перемещен и возвращен обратно
+			}
+			record(toPath, kind, trimMD(path.Base(to)), oldPath)
+		}
+	}
+	return nil
+}
+
+// GetChanges отдает изменения документов с момента since (RFC 3339) для синхронизации клиентов
+func (h *DocumentHandler) GetChanges(w http.ResponseWriter, r *http.Request) {
+	since, err := time.Parse(time.RFC3339, r.URL.Query().Get("since"))
+	if err != nil {
+		http.Error(w, "Invalid since parameter: use an RFC 3339 timestamp", http.StatusBadRequest)
+		return
+	}
+
+	gitStorage, ok := h.storage.(*GitStorage)
+	if !ok {
+		http.Error(w, "history feature only available with git storage", http.StatusNotImplemented)
+		return
+	}
+
+	ctx, done := h.operations.Begin(r.Context())
+	defer done()
+
+	changes, err := gitStorage.GetChangesSince(ctx, since)
+	if err != nil {
+		http.Error(w, err.Error(), operationStatus(ctx))
+		return
+	}
+
+	writeJSON(w, r, changes)
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestGetChangesSince(t *testing.T) {
+	gs := newTestStorage(t)
+	edited := mustCreate(t, gs, "", "Edited", "v1")
+	moved := mustCreate(t, gs, "", "Moved", "")
+	gone := mustCreate(t, gs, "", "Gone", "")
+	folder := mustCreate(t, gs, "", "Folder", "")
+
+	// Время коммитов в git хранится с точностью до секунды
+	time.Sleep(time.Until(time.Now().Truncate(time.Second).Add(time.Second)))
+	since := time.Now().Truncate(time.Second)
+
+	created := mustCreate(t, gs, "", "Created", "new")
+	if _, err := gs.UpdateDocument(edited.Path, edited.Title, "v2", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := gs.MoveDocument(moved.Path, folder.Path); err != nil {
+		t.Fatal(err)
+	}
+	if err := gs.DeleteDocument(gone.Path); err != nil {
+		t.Fatal(err)
+	}
+	temp := mustCreate(t, gs, "", "Temp", "")
+	if err := gs.DeleteDocument(temp.Path); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := gs.GetChangesSince(context.Background(), since)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(map[string]DocumentChange)
+	for _, change := range resp.Changes {
+		got[change.Path] = change
+	}
+	want := map[string]DocumentChange{
+		created.Path:                   {Type: ChangeCreated},
+		edited.Path:                    {Type: ChangeModified},
+		folder.Path + "/" + moved.Path: {Type: ChangeMoved, OldPath: moved.Path},
+		gone.Path:                      {Type: ChangeDeleted},
+	}
+	if len(got) != len(want) {
+		t.Errorf("changes = %+v, want %d entries", resp.Changes, len(want))
+	}
+	for p, w := range want {
+		g, ok := got[p]
+		if !ok {
+			t.Errorf("missing change for %s", p)
+			continue
+		}
+		if g.Type != w.Type || g.OldPath != w.OldPath {
+			t.Errorf("%s: got %s (old %q), want %s (old %q)", p, g.Type, g.OldPath, w.Type, w.OldPath)
+		}
+	}
+	if resp.Until.Before(since) {
+		t.Errorf("until %v is before since %v", resp.Until, since)
+	}
+
+	later, err := gs.GetChangesSince(context.Background(), time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(later.Changes) != 0 {
+		t.Errorf("changes in the future = %+v, want none", later.Changes)
+	}
+}
+
+func TestGetChangesHandler(t *testing.T) {
+	h, gs, _ := newTestDocumentHandler(t)
+	doc := mustCreate(t, gs, "", "Doc", "")
+
+	resp := serve(h.GetChanges, "GET", "/api/changes?since=yesterday", nil, nil)
+	if resp.Code != http.StatusBadRequest {
+		t.Errorf("invalid since status = %d, want 400", resp.Code)
+	}
+
+	since := time.Now().Add(-time.Hour).Format(time.RFC3339)
+	resp = serve(h.GetChanges, "GET", "/api/changes?since="+url.QueryEscape(since), nil, nil)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", resp.Code, resp.Body)
+	}
+	var body ChangesResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Changes) != 1 || body.Changes[0].Path != doc.Path || body.Changes[0].Type != ChangeCreated {
+		t.Errorf("changes = %+v, want created %s", body.Changes, doc.Path)
+	}
+}
@@ -0,0 +1,57 @@
+// coauthors.go
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+)
+
+var ErrInvalidCoAuthor = errors.New("invalid co-author")
+
+// CoAuthor - соавтор изменения, попадает в коммит трейлером Co-authored-by
+type CoAuthor struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+func (ca CoAuthor) String() string {
+	return fmt.Sprintf("%s <%s>", ca.Name, ca.Email)
+}
+
+// validateCoAuthors проверяет, что имена и адреса можно безопасно записать в трейлер
+func validateCoAuthors(coAuthors []CoAuthor) error {
+	for _, ca := range coAuthors {
+		name := strings.TrimSpace(ca.Name)
+		if name == "" || strings.ContainsAny(name, "<>\r\n") {
+			return fmt.Errorf("%w: bad name %q", ErrInvalidCoAuthor, ca.Name)
+		}
+		addr, err := mail.ParseAddress(ca.Email)
+		if err != nil || addr.Address != ca.Email || addr.Name != "" {
+			return fmt.Errorf("%w: bad email %q", ErrInvalidCoAuthor, ca.Email)
+		}
+	}
+	return nil
+}
+
+// withCoAuthors дописывает к сообщению коммита трейлеры соавторов, пропуская повторы
+func withCoAuthors(message string, coAuthors []CoAuthor) string {
+	if len(coAuthors) == 0 {
+		return message
+	}
+
+	var b strings.Builder
+	b.WriteString(strings.TrimRight(message, "\n"))
+	b.WriteString("\n\n")
+	seen := make(map[string]bool)
+	for _, ca := range coAuthors {
+		key := strings.ToLower(ca.Email)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		fmt.Fprintf(&b, "Co-authored-by: %s <%s>\n", strings.TrimSpace(ca.Name), ca.Email)
+	}
+	return b.String()
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func headMessage(t *testing.T, gs *GitStorage) string {
+	t.Helper()
+	commit, err := gs.repo.CommitObject(headHash(t, gs))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return commit.Message
+}
+
+func TestCoAuthorTrailersInCommitMessage(t *testing.T) {
+	h, gs, _ := newTestDocumentHandler(t)
+
+	rec := serve(h.CreateDocument, "POST", "/api/document", strings.NewReader(
+		`{"title":"Pair","content":"x","co_authors":[{"name":"Ann Lee","email":"ann@example.com"}]}`), nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("create status = %d, body %s", rec.Code, rec.Body)
+	}
+	var doc Document
+	if err := json.NewDecoder(rec.Body).Decode(&doc); err != nil {
+		t.Fatal(err)
+	}
+	want := "Create document: " + doc.Path + "\n\nCo-authored-by: Ann Lee <ann@example.com>\n"
+	if got := headMessage(t, gs); got != want {
+		t.Fatalf("create message = %q, want %q", got, want)
+	}
+
+	rec = serve(h.UpdateDocument, "PUT", "/api/document/"+doc.Path, strings.NewReader(
+		`{"title":"Pair","content":"y","commit_changes":true,"co_authors":[
+			{"name":"Ann Lee","email":"ann@example.com"},
+			{"name":"Bob","email":"bob@example.com"},
+			{"name":"Ann again","email":"ANN@example.com"}]}`), map[string]string{"rest": doc.Path})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("update status = %d, body %s", rec.Code, rec.Body)
+	}
+	msg := headMessage(t, gs)
+	for _, trailer := range []string{"Co-authored-by: Ann Lee <ann@example.com>", "Co-authored-by: Bob <bob@example.com>"} {
+		if !strings.Contains(msg, trailer) {
+			t.Errorf("update message %q missing %q", msg, trailer)
+		}
+	}
+	if strings.Contains(msg, "Ann again") {
+		t.Errorf("duplicate co-author email not skipped: %q", msg)
+	}
+}
+
+func TestCoAuthorValidation(t *testing.T) {
+	for _, ca := range []CoAuthor{
+		{Name: "", Email: "a@example.com"},
+		{Name: "Evil <x@y>", Email: "a@example.com"},
+		{Name: "Line\nBreak", Email: "a@example.com"},
+		{Name: "Ann", Email: "not-an-email"},
+		{Name: "Ann", Email: "Ann <a@example.com>"},
+	} {
+		if err := validateCoAuthors([]CoAuthor{ca}); !errors.Is(err, ErrInvalidCoAuthor) {
+			t.Errorf("validateCoAuthors(%+v) = %v, want ErrInvalidCoAuthor", ca, err)
+		}
+	}
+
+	h, gs, _ := newTestDocumentHandler(t)
+	mustCreate(t, gs, "", "Existing", "")
+	before := countCommits(t, gs)
+	rec := serve(h.CreateDocument, "POST", "/api/document", strings.NewReader(
+		`{"title":"Bad","content":"x","co_authors":[{"name":"Ann","email":"nope"}]}`), nil)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+	if after := countCommits(t, gs); after != before {
+		t.Fatalf("commits = %d, want %d", after, before)
+	}
+}
@@ -0,0 +1,120 @@
+// collisions.go
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"regexp"
+	"sort"
+)
+
+// collisionSuffix соответствует суффиксу, который generateID добавляет при совпадении ID
+var collisionSuffix = regexp.MustCompile(`^(.+)\((\d+)\)$`)
+
+type CollisionDocument struct {
+	ID          string `json:"id"`
+	Title       string `json:"title"`
+	Path        string `json:"path"`
+	SuggestedID string `json:"suggestedId,omitempty"`
+}
+
+// CollisionGroup - документы одной папки с общим базовым ID
+type CollisionGroup struct {
+	ParentPath string              `json:"parentPath"`
+	BaseID     string              `json:"baseId"`
+	Documents  []CollisionDocument `json:"documents"`
+}
+
+// FindIDCollisions находит документы с суффиксом коллизии вида id(1),
+// группирует их с исходным документом и предлагает ID без суффикса.
+// Дерево документов не изменяется.
+func (gs *GitStorage) FindIDCollisions() ([]CollisionGroup, error) {
+	groups := []CollisionGroup{}
+	if err := gs.findIDCollisions("", &groups); err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+func (gs *GitStorage) findIDCollisions(parentPath string, groups *[]CollisionGroup) error {
+	docs, err := gs.getDocuments(gs.fullPath(parentPath), parentPath)
+	if err != nil {
+		return err
+	}
+
+	taken := make(map[string]bool)
+	byBase := make(map[string][]CollisionDocument)
+	var bases []string
+	for _, doc := range docs {
+		taken[doc.ID] = true
+		base := doc.ID
+		if m := collisionSuffix.FindStringSubmatch(doc.ID); m != nil {
+			base = m[1]
+		}
+		if _, ok := byBase[base]; !ok {
+			bases = append(bases, base)
+		}
+		byBase[base] = append(byBase[base], CollisionDocument{ID: doc.ID, Title: doc.Title, Path: doc.Path})
+	}
+
+	sort.Strings(bases)
+	for _, base := range bases {
+		group := byBase[base]
+		hasSuffix := false
+		for i := range group {
+			if group[i].ID != base {
+				hasSuffix = true
+				group[i].SuggestedID = suggestID(group[i].Title, base, taken)
+			}
+		}
+		if !hasSuffix {
+			continue
+		}
+		sort.Slice(group, func(i, j int) bool { return group[i].ID < group[j].ID })
+		*groups = append(*groups, CollisionGroup{ParentPath: parentPath, BaseID: base, Documents: group})
+	}
+
+	for _, doc := range docs {
+		if err := gs.findIDCollisions(path.Join(parentPath, doc.ID), groups); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// suggestID предлагает свободный ID без скобок: из текущего названия,
+// а если он занят - с числовым окончанием через подчеркивание
+func suggestID(title, base string, taken map[string]bool) string {
+	candidate := idFromTitle(title)
+	if candidate == "" {
+		candidate = base
+	}
+	if !taken[candidate] && candidate != "root" {
+		taken[candidate] = true
+		return candidate
+	}
+	for n := 2; ; n++ {
+		id := fmt.Sprintf("%s_%d", candidate, n)
+		if !taken[id] {
+			taken[id] = true
+			return id
+		}
+	}
+}
+
+func (h *AdminHandler) GetIDCollisions(w http.ResponseWriter, r *http.Request) {
+	gitStorage, ok := h.storage.(*GitStorage)
+	if !ok {
+		http.Error(w, "collision report only available with git storage", http.StatusNotImplemented)
+		return
+	}
+
+	groups, err := gitStorage.FindIDCollisions()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, r, groups)
+}
@@ -0,0 +1,157 @@
+// commit_batch.go
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// deferredCommit - отложенный коммит правок одного документа. Коммит делается,
+// когда документ не меняется commitDelay, или при Flush.
+type deferredCommit struct {
+	timer     *time.Timer
+	seq       int      // номер последней правки, старые таймеры не коммитят
+	paths     []string // пути документа до и после переименований
+	author    CoAuthor
+	message   string // последнее сообщение от клиента, пусто - стандартное
+	coAuthors []CoAuthor
+}
+
+// deferCommit откладывает коммит правки документа oldPath, который теперь лежит
+// по newPath. Каждая новая правка документа откладывает коммит заново.
+func (gs *GitStorage) deferCommit(oldPath, newPath string, author CoAuthor, message string, coAuthors []CoAuthor) {
+	gs.deferredMu.Lock()
+	defer gs.deferredMu.Unlock()
+
+	if gs.deferred == nil {
+		gs.deferred = make(map[string]*deferredCommit)
+	}
+	dc, ok := gs.deferred[oldPath]
+	if ok {
+		dc.timer.Stop()
+		delete(gs.deferred, oldPath)
+	} else {
+		dc = &deferredCommit{paths: []string{oldPath}}
+	}
+	if newPath != oldPath {
+		dc.paths = append(dc.paths, newPath)
+	}
+	dc.author = author
+	if message != "" {
+		dc.message = message
+	}
+	dc.coAuthors = append(dc.coAuthors, coAuthors...)
+	dc.seq++
+	gs.deferred[newPath] = dc
+
+	seq := dc.seq
+	dc.timer = time.AfterFunc(gs.commitDelay, func() {
+		gs.deferredMu.Lock()
+		if gs.deferred[newPath] != dc || dc.seq != seq {
+			gs.deferredMu.Unlock()
+			return
+		}
+		delete(gs.deferred, newPath)
+		gs.deferredMu.Unlock()
+
+		if err := gs.commitDeferred(newPath, dc); err != nil {
+			log.Printf("Warning: deferred commit of %s failed: %v", newPath, err)
+		}
+	})
+}
+
+// hasDeferredCommit сообщает, ждут ли правки документа отложенного коммита
+func (gs *GitStorage) hasDeferredCommit(docPath string) bool {
+	gs.deferredMu.Lock()
+	defer gs.deferredMu.Unlock()
+	_, ok := gs.deferred[docPath]
+	return ok
+}
+
+// Flush сразу коммитит все отложенные правки, по коммиту на документ
+func (gs *GitStorage) Flush() error {
+	gs.deferredMu.Lock()
+	pending := gs.deferred
+	gs.deferred = nil
+	gs.deferredMu.Unlock()
+
+	docPaths := make([]string, 0, len(pending))
+	for docPath, dc := range pending {
+		dc.timer.Stop()
+		docPaths = append(docPaths, docPath)
+	}
+	sort.Strings(docPaths)
+
+	var errs []error
+	for _, docPath := range docPaths {
+		if err := gs.commitDeferred(docPath, pending[docPath]); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", docPath, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// commitDeferred коммитит изменения файлов документа по всем его путям. Если их
+// уже забрал другой коммит, ничего не делает.
+func (gs *GitStorage) commitDeferred(docPath string, dc *deferredCommit) error {
+	defer gs.invalidateStatus()
+
+	gs.commitMu.Lock()
+	defer gs.commitMu.Unlock()
+
+	w, err := gs.repo.Worktree()
+	if err != nil {
+		return fmt.Errorf("failed to get worktree: %w", err)
+	}
+	staged, err := dc.scope().stage(w)
+	if err != nil {
+		return err
+	}
+	if !staged {
+		return nil
+	}
+
+	message := dc.message
+	if message == "" {
+		message = fmt.Sprintf("Update document: %s", docPath)
+	}
+	_, err = w.Commit(withCoAuthors(message, dc.coAuthors), &git.CommitOptions{
+		Author: &object.Signature{Name: dc.author.Name, Email: dc.author.Email, When: time.Now()},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to commit changes: %w", err)
+	}
+	gitCommitsTotal.Inc()
+	return nil
+}
+
+// scope - файлы документа по всем его путям. Вложенные документы коммитятся
+// отдельно, кроме случая, когда документ переименован вместе с ними.
+func (dc *deferredCommit) scope() commitScope {
+	if len(dc.paths) > 1 {
+		return treeScope(dc.paths...)
+	}
+	return docScope(dc.paths...)
+}
+
+// FlushCommits сразу коммитит отложенные правки
+func (h *DocumentHandler) FlushCommits(w http.ResponseWriter, r *http.Request) {
+	gitStorage, ok := h.storage.(*GitStorage)
+	if !ok {
+		http.Error(w, "commits only available with git storage", http.StatusNotImplemented)
+		return
+	}
+
+	if err := gitStorage.Flush(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// headFiles возвращает файлы, измененные коммитом HEAD
+func headFiles(t *testing.T, gs *GitStorage) []string {
+	t.Helper()
+	head, err := gs.repo.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit, err := gs.repo.CommitObject(head.Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats, err := commit.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make([]string, 0, len(stats))
+	for _, stat := range stats {
+		files = append(files, stat.Name)
+	}
+	sort.Strings(files)
+	return files
+}
+
+// dirtyDocs возвращает незакоммиченные файлы документов. Файлы сервера вне
+// docs/ коммитами документов не забираются.
+func dirtyDocs(t *testing.T, gs *GitStorage) []string {
+	t.Helper()
+	status, err := gs.worktreeStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var files []string
+	for file := range status {
+		if strings.HasPrefix(file, "docs/") {
+			files = append(files, file)
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+// headMessages возвращает сообщения последних n коммитов, начиная с HEAD
+func headMessages(t *testing.T, gs *GitStorage, n int) []string {
+	t.Helper()
+	ref, err := gs.repo.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit, err := gs.repo.CommitObject(ref.Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var messages []string
+	for len(messages) < n {
+		messages = append(messages, strings.TrimSpace(commit.Message))
+		if commit.NumParents() == 0 {
+			break
+		}
+		if commit, err = commit.Parent(0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return messages
+}
+
+func TestDeferredCommitAfterQuietPeriod(t *testing.T) {
+	gs := newTestStorage(t)
+	gs.skipUncommitted = true // отложенная правка видна и без проверки статуса
+	doc := mustCreate(t, gs, "", "Doc", "one")
+	gs.commitDelay = 50 * time.Millisecond
+	before := countCommits(t, gs)
+
+	for _, content := range []string{"two", "three"} {
+		updated, err := gs.UpdateDocument(doc.Path, "Doc", content, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !updated.Uncommitted {
+			t.Errorf("update to %q not reported as uncommitted", content)
+		}
+	}
+	if got := countCommits(t, gs); got != before {
+		t.Fatalf("commits right after edits = %d, want %d", got, before)
+	}
+	if got, err := gs.GetDocument(doc.Path); err != nil || !got.Uncommitted || got.Content != "three" {
+		t.Fatalf("pending document = %+v, %v", got, err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for countCommits(t, gs) == before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(100 * time.Millisecond) // второго коммита быть не должно
+	if got := countCommits(t, gs); got != before+1 {
+		t.Fatalf("commits after quiet period = %d, want %d", got, before+1)
+	}
+	if got, err := gs.GetDocument(doc.Path); err != nil || got.Uncommitted {
+		t.Errorf("committed document = %+v, %v", got, err)
+	}
+}
+
+func TestFlushDeferredCommits(t *testing.T) {
+	h, gs, _ := newTestDocumentHandler(t)
+	a := mustCreate(t, gs, "", "A", "a1")
+	b := mustCreate(t, gs, "", "B", "b1")
+	child := mustCreate(t, gs, b.Path, "Child", "c")
+	gs.commitDelay = time.Hour
+	before := countCommits(t, gs)
+
+	if _, err := gs.UpdateDocumentAs(systemAuthor, "Rewrite intro", a.Path, "A", "a2", true); err != nil {
+		t.Fatal(err)
+	}
+	// Переименование уносит с собой вложенный документ
+	renamed, err := gs.UpdateDocument(b.Path, "Beta", "b2", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gs.UpdateDocument(renamed.Path, "Beta", "b3", true); err != nil {
+		t.Fatal(err)
+	}
+	if got := countCommits(t, gs); got != before {
+		t.Fatalf("commits before flush = %d, want %d", got, before)
+	}
+
+	rec := serve(h.FlushCommits, "POST", "/api/commit/flush", nil, nil)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("flush status = %d, body %s", rec.Code, rec.Body)
+	}
+	want := []string{"Update document: " + renamed.Path, "Rewrite intro"}
+	if got := headMessages(t, gs, 2); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("messages = %q, want %q", got, want)
+	}
+	if got := countCommits(t, gs); got != before+2 {
+		t.Errorf("commits after flush = %d, want %d", got, before+2)
+	}
+	if dirty := dirtyDocs(t, gs); len(dirty) != 0 {
+		t.Errorf("documents after flush: %v", dirty)
+	}
+	if _, err := gs.GetDocument(renamed.Path + "/" + child.ID); err != nil {
+		t.Errorf("child of renamed document: %v", err)
+	}
+
+	// Другой коммит сначала фиксирует отложенную правку отдельно
+	if _, err := gs.UpdateDocument(a.Path, "A", "a3", true); err != nil {
+		t.Fatal(err)
+	}
+	mustCreate(t, gs, "", "C", "")
+	want = []string{"Create document: c", "Update document: " + a.Path}
+	if got := headMessages(t, gs, 2); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("messages = %q, want %q", got, want)
+	}
+}
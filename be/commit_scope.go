@@ -0,0 +1,105 @@
+// commit_scope.go
+package main
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+)
+
+// commitScope - документы, изменения которых входят в коммит. Остальные
+// незакоммиченные правки рабочего дерева, например отложенные правки других
+// авторов, в коммит не попадают.
+type commitScope struct {
+	docs  []string // файлы самих документов без вложенных
+	trees []string // документы вместе с вложенными: перенос, переименование, удаление
+}
+
+func cleanScopePath(p string) string {
+	return strings.Trim(path.Clean("/"+p), "/")
+}
+
+// docScope - только файлы документов docPaths
+func docScope(docPaths ...string) commitScope {
+	var s commitScope
+	for _, p := range docPaths {
+		s.docs = append(s.docs, cleanScopePath(p))
+	}
+	return s
+}
+
+// treeScope - документы docPaths вместе с вложенными
+func treeScope(docPaths ...string) commitScope {
+	var s commitScope
+	for _, p := range docPaths {
+		s.trees = append(s.trees, cleanScopePath(p))
+	}
+	return s
+}
+
+// updateScope - правка документа, который лежал по oldPath, а теперь по newPath.
+// При смене названия каталог переименовывается вместе с вложенными документами.
+func updateScope(oldPath, newPath string) commitScope {
+	if cleanScopePath(oldPath) != cleanScopePath(newPath) {
+		return treeScope(oldPath, newPath)
+	}
+	return docScope(newPath)
+}
+
+// add объединяет области коммита
+func (s commitScope) add(other commitScope) commitScope {
+	s.docs = append(s.docs, other.docs...)
+	s.trees = append(s.trees, other.trees...)
+	return s
+}
+
+// includesDoc сообщает, входит ли документ docPath в область
+func (s commitScope) includesDoc(docPath string) bool {
+	for _, p := range s.docs {
+		if docPath == p {
+			return true
+		}
+	}
+	for _, p := range s.trees {
+		if isSubPath(docPath, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// includes сообщает, относится ли файл репозитория к документам области
+func (s commitScope) includes(file string) bool {
+	if !strings.HasPrefix(file, "docs/") {
+		return false
+	}
+	return s.includesDoc(path.Dir(strings.TrimPrefix(file, "docs/")))
+}
+
+// stage добавляет в индекс измененные файлы области. Возвращает false, если
+// изменений нет.
+func (s commitScope) stage(w *git.Worktree) (bool, error) {
+	status, err := w.Status()
+	if err != nil {
+		return false, fmt.Errorf("failed to get status: %w", err)
+	}
+
+	staged := false
+	for file, fileStatus := range status {
+		if !s.includes(file) {
+			continue
+		}
+		if fileStatus.Worktree == git.Deleted {
+			_, err = w.Remove(file)
+		} else {
+			_, err = w.Add(file)
+		}
+		if err != nil {
+			return false, fmt.Errorf("failed to stage %s: %w", file, err)
+		}
+		staged = true
+	}
+	return staged, nil
+}
@@ -0,0 +1,141 @@
+// copy.go
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path"
+
+	"github.com/gorilla/mux"
+)
+
+// CopyDocument копирует документ в targetPath под новым ID и коммитит копию.
+// Пустой title - заголовок источника. С recursive копируются и все потомки,
+// иначе только сам документ. Источник не меняется. Возвращает копию и пути
+// всех созданных документов, начиная с нее.
+func (gs *GitStorage) CopyDocument(sourcePath, targetPath, title string, recursive bool) (Document, []string, error) {
+	gs.txMu.Lock()
+	defer gs.txMu.Unlock()
+	defer gs.invalidateStatus()
+
+	sourcePath, err := gs.cleanDocPath(sourcePath)
+	if err != nil {
+		return Document{}, nil, err
+	}
+	if targetPath != "" {
+		if targetPath, err = gs.cleanDocPath(targetPath); err != nil {
+			return Document{}, nil, err
+		}
+	}
+	source, err := gs.GetDocument(sourcePath)
+	if err != nil {
+		return Document{}, nil, err
+	}
+	if _, err := os.Stat(gs.fullPath(targetPath)); os.IsNotExist(err) {
+		return Document{}, nil, fmt.Errorf("%w: %s", ErrParentNotFound, targetPath)
+	}
+
+	// Поддерево собирается до создания копии: при копировании внутрь самого
+	// себя копия не должна попасть в обход
+	docs := []Document{source}
+	if recursive {
+		docs = docs[:0]
+		if err := walkDocumentTree(gs, source, func(doc Document) error {
+			docs = append(docs, doc)
+			return nil
+		}); err != nil {
+			return Document{}, nil, err
+		}
+	}
+
+	if title == "" {
+		title = source.Title
+	}
+	root, err := gs.createDocument(targetPath, title, source.Content)
+	if err != nil {
+		return Document{}, nil, err
+	}
+	rollback := func() {
+		if err := os.RemoveAll(gs.fullPath(root.Path)); err != nil {
+			log.Printf("Warning: failed to roll back copy %s: %v", root.Path, err)
+		}
+	}
+
+	created := []string{root.Path}
+	copies := map[string]string{sourcePath: root.Path} // путь источника -> путь копии
+	for _, doc := range docs[1:] {
+		parent, ok := copies[path.Dir(doc.Path)]
+		if !ok {
+			continue // в обходе родитель всегда идет раньше потомков
+		}
+		copied, err := gs.createDocument(parent, doc.Title, doc.Content)
+		if err != nil {
+			rollback()
+			return Document{}, nil, err
+		}
+		copies[doc.Path] = copied.Path
+		created = append(created, copied.Path)
+	}
+
+	if err := gs.commitChanges(fmt.Sprintf("Copy document %s to %s", sourcePath, root.Path), docScope(created...)); err != nil {
+		rollback()
+		return Document{}, nil, fmt.Errorf("failed to commit changes, copy was rolled back: %w", err)
+	}
+
+	doc, err := gs.GetDocument(root.Path)
+	return doc, created, err
+}
+
+// CopyDocument создает копию документа (и с recursive - его потомков) в targetPath
+func (h *DocumentHandler) CopyDocument(w http.ResponseWriter, r *http.Request) {
+	sourcePath := mux.Vars(r)["rest"]
+
+	var req struct {
+		TargetPath string `json:"targetPath"`
+		Title      string `json:"title"`
+		Recursive  bool   `json:"recursive"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	gitStorage, ok := h.storage.(*GitStorage)
+	if !ok {
+		http.Error(w, "copy only available with git storage", http.StatusNotImplemented)
+		return
+	}
+
+	doc, created, err := gitStorage.CopyDocument(sourcePath, req.TargetPath, req.Title, req.Recursive)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, ErrDocumentNotFound), errors.Is(err, ErrParentNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, ErrTitleConflict):
+			status = http.StatusConflict
+		case errors.Is(err, ErrInvalidPath), errors.Is(err, ErrDepthExceeded):
+			status = http.StatusBadRequest
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	// Копия уже закоммичена, поэтому ошибки индексации только логируем
+	for _, copyPath := range created {
+		copied, err := h.storage.GetDocument(copyPath)
+		if err != nil {
+			log.Printf("Warning: failed to load copied document %s: %v", copyPath, err)
+			continue
+		}
+		if err := h.search.IndexDocument(copied); err != nil {
+			log.Printf("Warning: failed to index %s: %v", copyPath, err)
+		}
+	}
+
+	writeJSON(w, r, doc)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func copyDocument(t *testing.T, h *DocumentHandler, source, body string) (int, Document) {
+	t.Helper()
+	rec := serve(h.CopyDocument, "POST", "/api/document/"+source+"/copy", strings.NewReader(body), map[string]string{"rest": source})
+	var doc Document
+	if rec.Code == http.StatusOK {
+		if err := json.NewDecoder(rec.Body).Decode(&doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return rec.Code, doc
+}
+
+func TestCopyDocument(t *testing.T) {
+	h, gs, engine := newTestDocumentHandler(t)
+	source := mustCreate(t, gs, "", "Template", "aardvark")
+	child := mustCreate(t, gs, source.Path, "Child", "axolotl")
+	mustCreate(t, gs, child.Path, "Grandchild", "narwhal")
+	target := mustCreate(t, gs, "", "Target", "")
+	if err := engine.LoadFromStorage(gs); err != nil {
+		t.Fatal(err)
+	}
+
+	commits := countCommits(t, gs)
+	code, doc := copyDocument(t, h, source.Path, `{"targetPath":"`+target.Path+`"}`)
+	if code != http.StatusOK {
+		t.Fatalf("status = %d", code)
+	}
+	if doc.Path != target.Path+"/"+source.ID || doc.Title != "Template" || doc.Content != "aardvark" || len(doc.Children) != 0 {
+		t.Fatalf("copy = %+v", doc)
+	}
+	if got := countCommits(t, gs); got != commits+1 {
+		t.Errorf("commits = %d, want %d", got, commits+1)
+	}
+	if src, err := gs.GetDocument(source.Path); err != nil || src.Content != "aardvark" || len(src.Children) != 1 {
+		t.Errorf("source changed: %+v, %v", src, err)
+	}
+	if docs, _, _ := engine.Search("aardvark", 1, 10); len(docs) != 2 {
+		t.Errorf("search after copy = %+v", docs)
+	}
+
+	// Рекурсивная копия рядом с источником получает новый ID
+	code, doc = copyDocument(t, h, source.Path, `{"recursive":true,"title":"Copy"}`)
+	if code != http.StatusOK {
+		t.Fatalf("recursive: status = %d", code)
+	}
+	if doc.Path == source.Path || doc.Title != "Copy" || len(doc.Children) != 1 {
+		t.Fatalf("recursive copy = %+v", doc)
+	}
+	copiedChild, err := gs.GetDocument(doc.Path + "/" + doc.Children[0].ID)
+	if err != nil || copiedChild.Content != "axolotl" || len(copiedChild.Children) != 1 {
+		t.Fatalf("copied child = %+v, %v", copiedChild, err)
+	}
+	if docs, _, _ := engine.Search("narwhal", 1, 10); len(docs) != 2 {
+		t.Errorf("search for copied grandchild = %+v", docs)
+	}
+
+	// Копия внутрь собственного поддерева не копирует саму себя
+	code, doc = copyDocument(t, h, source.Path, `{"targetPath":"`+child.Path+`","recursive":true}`)
+	if code != http.StatusOK {
+		t.Fatalf("into own subtree: status = %d", code)
+	}
+	if len(doc.Children) != 1 {
+		t.Fatalf("copy into own subtree = %+v", doc)
+	}
+	if nested, err := gs.GetDocument(doc.Path + "/" + doc.Children[0].ID); err != nil || len(nested.Children) != 1 {
+		t.Fatalf("copy into own subtree child = %+v, %v", nested, err)
+	}
+
+	if code, _ := copyDocument(t, h, "missing", `{}`); code != http.StatusNotFound {
+		t.Errorf("missing source: status = %d", code)
+	}
+	if code, _ := copyDocument(t, h, source.Path, `{"targetPath":"missing"}`); code != http.StatusNotFound {
+		t.Errorf("missing target: status = %d", code)
+	}
+}
+
+func TestCopyDocumentCommitsOnlyCopies(t *testing.T) {
+	h, gs, engine := newTestDocumentHandler(t)
+	source := mustCreate(t, gs, "", "Template", "aardvark")
+	mustCreate(t, gs, source.Path, "Child", "axolotl")
+	target := mustCreate(t, gs, "", "Target", "")
+	if err := engine.LoadFromStorage(gs); err != nil {
+		t.Fatal(err)
+	}
+	// Правка цели ждет пакетной фиксации
+	if _, err := gs.UpdateDocument(target.Path, "Target", "pending", false); err != nil {
+		t.Fatal(err)
+	}
+
+	code, doc := copyDocument(t, h, source.Path, `{"targetPath":"`+target.Path+`","recursive":true}`)
+	if code != http.StatusOK {
+		t.Fatalf("status = %d", code)
+	}
+	want := []string{"docs/" + doc.Path + "/Template.md", "docs/" + doc.Path + "/" + doc.Children[0].ID + "/Child.md"}
+	if got := headFiles(t, gs); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("copy commit touches %v, want %v", got, want)
+	}
+	if got := dirtyDocs(t, gs); strings.Join(got, ",") != "docs/"+target.Path+"/Target.md" {
+		t.Errorf("uncommitted documents = %v, want the pending edit of the target", got)
+	}
+}
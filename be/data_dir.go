@@ -0,0 +1,27 @@
+// data_dir.go
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// prepareDataDir создает каталог данных и проверяет, что в него можно писать,
+// чтобы сервер не запускался с каталогом, в который не сохранить документы
+func prepareDataDir(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("cannot create data directory %s: %w", dir, err)
+	}
+	f, err := os.CreateTemp(dir, ".write-check-*")
+	if err != nil {
+		return fmt.Errorf("data directory %s is not writable: %w", dir, err)
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// uploadDirIn возвращает каталог загруженных файлов внутри каталога данных
+func uploadDirIn(dataDir string) string {
+	return filepath.Join(dataDir, "uploads")
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPrepareDataDir(t *testing.T) {
+	root := t.TempDir()
+
+	dir := filepath.Join(root, "nested", "data")
+	if err := prepareDataDir(dir); err != nil {
+		t.Fatalf("prepareDataDir(%s) = %v", dir, err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 0 {
+		t.Errorf("data dir entries = %v, %v; want empty after write check", entries, err)
+	}
+	if got := uploadDirIn(dir); got != filepath.Join(dir, "uploads") {
+		t.Errorf("uploadDirIn = %s", got)
+	}
+
+	// Путь занят файлом - каталог не создать
+	file := filepath.Join(root, "file")
+	if err := os.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := prepareDataDir(filepath.Join(file, "data")); err == nil || !strings.Contains(err.Error(), "cannot create data directory") {
+		t.Errorf("under a file: err = %v", err)
+	}
+
+	if os.Geteuid() == 0 {
+		return // root пишет и в каталоги без прав на запись
+	}
+	readonly := filepath.Join(root, "readonly")
+	if err := os.Mkdir(readonly, 0555); err != nil {
+		t.Fatal(err)
+	}
+	if err := prepareDataDir(readonly); err == nil || !strings.Contains(err.Error(), "not writable") {
+		t.Errorf("read-only dir: err = %v", err)
+	}
+}
@@ -0,0 +1,81 @@
+// delete_subtree.go
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+)
+
+// DeleteSubtree удаляет документ вместе со всеми потомками одним коммитом и
+// возвращает пути удаленных документов, начиная с самого документа
+func (gs *GitStorage) DeleteSubtree(docPath string) ([]string, error) {
+	defer gs.invalidateStatus()
+
+	docPath, err := gs.cleanDocPath(docPath)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := gs.GetDocument(docPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var deleted []string
+	if err := walkDocumentTree(gs, doc, func(d Document) error {
+		deleted = append(deleted, d.Path)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := os.RemoveAll(gs.fullPath(docPath)); err != nil {
+		return nil, err
+	}
+	message := fmt.Sprintf("Delete document: %s", docPath)
+	if len(deleted) > 1 {
+		message = fmt.Sprintf("Delete document with %d descendants: %s", len(deleted)-1, docPath)
+	}
+	if err := gs.commitChanges(message, treeScope(docPath)); err != nil {
+		return nil, fmt.Errorf("failed to commit changes: %w", err)
+	}
+
+	return deleted, nil
+}
+
+// deleteSubtree обслуживает DELETE с recursive=true: удаляет поддерево и убирает
+// каждый удаленный документ из индекса, избранного и последних просмотренных
+func (h *DocumentHandler) deleteSubtree(w http.ResponseWriter, r *http.Request, docPath string) {
+	gitStorage, ok := h.storage.(*GitStorage)
+	if !ok {
+		http.Error(w, "recursive delete only available with git storage", http.StatusNotImplemented)
+		return
+	}
+
+	deleted, err := gitStorage.DeleteSubtree(docPath)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, ErrDocumentNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, ErrInvalidPath):
+			status = http.StatusBadRequest
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	for _, p := range deleted {
+		if err := h.search.DeleteDocument(p); err != nil {
+			log.Printf("Warning: failed to remove %s from search index: %v", p, err)
+		}
+		h.meta.RemoveFromFavorites(p)
+		h.meta.RemoveFromLastViewed(p)
+	}
+
+	writeJSON(w, r, struct {
+		Deleted []string `json:"deleted"`
+	}{Deleted: deleted})
+}
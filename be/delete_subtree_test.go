@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestDeleteDocumentRecursive(t *testing.T) {
+	h, gs, engine := newTestDocumentHandler(t)
+	parent := mustCreate(t, gs, "", "Parent", "aardvark")
+	child := mustCreate(t, gs, parent.Path, "Child", "axolotl")
+	grandchild := mustCreate(t, gs, child.Path, "Grandchild", "narwhal")
+	sibling := mustCreate(t, gs, "", "Sibling", "platypus")
+	if err := engine.LoadFromStorage(gs); err != nil {
+		t.Fatal(err)
+	}
+	h.meta.AddToFavorites(documentToShort(&grandchild))
+	h.meta.AddToFavorites(documentToShort(&sibling))
+	h.meta.UpdateViewedMeta(documentToShort(&child))
+
+	vars := map[string]string{"rest": parent.Path}
+	if rec := serve(h.DeleteDocument, "DELETE", "/api/document/"+parent.Path, nil, vars); rec.Code != http.StatusBadRequest {
+		t.Fatalf("non-recursive delete of a document with children: status = %d", rec.Code)
+	}
+
+	commits := countCommits(t, gs)
+	rec := serve(h.DeleteDocument, "DELETE", "/api/document/"+parent.Path+"?recursive=true", nil, vars)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		Deleted []string `json:"deleted"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{parent.Path, child.Path, grandchild.Path}; !reflect.DeepEqual(resp.Deleted, want) {
+		t.Fatalf("deleted = %v, want %v", resp.Deleted, want)
+	}
+	if got := countCommits(t, gs); got != commits+1 {
+		t.Errorf("commits = %d, want %d", got, commits+1)
+	}
+
+	if _, err := os.Stat(gs.fullPath(parent.Path)); !os.IsNotExist(err) {
+		t.Errorf("parent still exists: %v", err)
+	}
+	if _, err := gs.GetDocument(sibling.Path); err != nil {
+		t.Errorf("sibling was deleted: %v", err)
+	}
+	for _, query := range []string{"aardvark", "axolotl", "narwhal"} {
+		if docs, _, _ := engine.Search(query, 1, 10); len(docs) != 0 {
+			t.Errorf("search %q = %+v after delete", query, docs)
+		}
+	}
+	if docs, _, _ := engine.Search("platypus", 1, 10); len(docs) != 1 {
+		t.Errorf("sibling removed from index")
+	}
+	if h.meta.IsFavorite(grandchild.Path) || !h.meta.IsFavorite(sibling.Path) {
+		t.Errorf("favorites = %+v", h.meta.GetFavorites())
+	}
+	for _, viewed := range h.meta.GetLastViewedDocuments() {
+		if viewed.Path == child.Path {
+			t.Errorf("deleted document still in last viewed")
+		}
+	}
+
+	rec = serve(h.DeleteDocument, "DELETE", "/api/document/missing?recursive=true", nil, map[string]string{"rest": "missing"})
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("missing document: status = %d", rec.Code)
+	}
+}
+
+func TestDeleteSubtreeCommitsOnlyDeletedDocuments(t *testing.T) {
+	_, gs, _ := newTestDocumentHandler(t)
+	parent := mustCreate(t, gs, "", "Parent", "aardvark")
+	child := mustCreate(t, gs, parent.Path, "Child", "axolotl")
+	sibling := mustCreate(t, gs, "", "Sibling", "platypus")
+	// Правка соседа ждет пакетной фиксации
+	if _, err := gs.UpdateDocument(sibling.Path, "Sibling", "pending", false); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := gs.DeleteSubtree(parent.Path); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"docs/" + parent.Path + "/Parent.md", "docs/" + child.Path + "/Child.md"}
+	if got := headFiles(t, gs); !reflect.DeepEqual(got, want) {
+		t.Errorf("delete commit touches %v, want %v", got, want)
+	}
+	if got := dirtyDocs(t, gs); !reflect.DeepEqual(got, []string{"docs/" + sibling.Path + "/Sibling.md"}) {
+		t.Errorf("uncommitted documents = %v, want the pending edit of the sibling", got)
+	}
+}
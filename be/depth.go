@@ -0,0 +1,72 @@
+// depth.go
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+)
+
+var ErrDepthExceeded = errors.New("document nesting depth exceeded")
+
+// pathDepth возвращает число сегментов пути документа, "" - корень
+func pathDepth(docPath string) int {
+	if docPath == "" {
+		return 0
+	}
+	return strings.Count(docPath, "/") + 1
+}
+
+// checkDepth проверяет, что поддерево высотой height (1 - документ без детей)
+// поместится под parentPath, не превысив dt.maxDepth. 0 - без ограничения.
+func (dt *docTree) checkDepth(parentPath string, height int) error {
+	if dt.maxDepth <= 0 {
+		return nil
+	}
+	if depth := pathDepth(parentPath) + height; depth > dt.maxDepth {
+		return fmt.Errorf("%w: depth %d, limit %d", ErrDepthExceeded, depth, dt.maxDepth)
+	}
+	return nil
+}
+
+// subtreeHeight возвращает число уровней в поддереве документа, включая сам документ
+func (dt *docTree) subtreeHeight(docPath string) (int, error) {
+	root := dt.fullPath(docPath)
+	height := 0
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if p != root && isIgnoredName(d.Name()) {
+			return filepath.SkipDir
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		level := 1
+		if rel != "." {
+			level = pathDepth(filepath.ToSlash(rel)) + 1
+		}
+		height = max(height, level)
+		return nil
+	})
+	return height, err
+}
+
+// checkMoveDepth проверяет, что документ со всеми потомками поместится под targetPath
+func (dt *docTree) checkMoveDepth(sourcePath, targetPath string) error {
+	if dt.maxDepth <= 0 {
+		return nil
+	}
+	height, err := dt.subtreeHeight(sourcePath)
+	if err != nil {
+		return err
+	}
+	return dt.checkDepth(targetPath, height)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestCreateDocumentDepthLimit(t *testing.T) {
+	h, gs, _ := newTestDocumentHandler(t)
+	gs.maxDepth = 3
+
+	a := mustCreate(t, gs, "", "A", "")
+	b := mustCreate(t, gs, a.Path, "B", "")
+	c := mustCreate(t, gs, b.Path, "C", "") // ровно на пределе
+	if got := pathDepth(c.Path); got != 3 {
+		t.Fatalf("depth of %s = %d, want 3", c.Path, got)
+	}
+
+	if _, err := gs.CreateDocument(c.Path, "D", ""); !errors.Is(err, ErrDepthExceeded) {
+		t.Errorf("create beyond limit: err = %v, want ErrDepthExceeded", err)
+	}
+
+	body, _ := json.Marshal(map[string]string{"parentPath": c.Path, "title": "D"})
+	resp := serve(h.CreateDocument, "POST", "/api/document", strings.NewReader(string(body)), nil)
+	if resp.Code != http.StatusBadRequest {
+		t.Errorf("handler status = %d, want 400", resp.Code)
+	}
+}
+
+func TestMoveDocumentDepthLimit(t *testing.T) {
+	h, gs, _ := newTestDocumentHandler(t)
+	gs.maxDepth = 3
+
+	a := mustCreate(t, gs, "", "A", "")
+	b := mustCreate(t, gs, a.Path, "B", "")
+	tree := mustCreate(t, gs, "", "Tree", "")
+	mustCreate(t, gs, tree.Path, "Leaf", "")
+	single := mustCreate(t, gs, "", "Single", "")
+
+	// Документ с ребенком под B дал бы глубину 4
+	if err := gs.MoveDocument(tree.Path, b.Path); !errors.Is(err, ErrDepthExceeded) {
+		t.Errorf("move subtree beyond limit: err = %v, want ErrDepthExceeded", err)
+	}
+	body, _ := json.Marshal(map[string]string{"targetPath": b.Path})
+	resp := serve(h.MoveDocument, "POST", "/api/document/"+tree.Path+"/move", strings.NewReader(string(body)), map[string]string{"rest": tree.Path})
+	if resp.Code != http.StatusBadRequest {
+		t.Errorf("handler status = %d, want 400", resp.Code)
+	}
+
+	results, err := gs.MoveDocuments([]DocumentMove{{Source: tree.Path, Target: b.Path}}, false)
+	if !errors.Is(err, ErrInvalidMove) || !strings.Contains(results[0].Error, ErrDepthExceeded.Error()) {
+		t.Errorf("batch move beyond limit: err = %v, results %+v", err, results)
+	}
+
+	// Одиночный документ под B оказывается ровно на пределе
+	if err := gs.MoveDocument(single.Path, b.Path); err != nil {
+		t.Errorf("move at limit: %v", err)
+	}
+}
+
+func TestDepthUnlimitedByDefault(t *testing.T) {
+	gs := newTestStorage(t)
+	parent := ""
+	for i := 0; i < 20; i++ {
+		parent = mustCreate(t, gs, parent, "Level", "").Path
+	}
+	if got := pathDepth(parent); got != 20 {
+		t.Errorf("depth = %d, want 20", got)
+	}
+}
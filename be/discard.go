@@ -0,0 +1,107 @@
+// discard.go
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+var ErrNotCommitted = fmt.Errorf("document has no committed version")
+
+// DiscardChanges возвращает файлы документа к состоянию последнего коммита.
+// Дочерние документы не затрагиваются. Для документа без изменений ничего не делает.
+func (gs *GitStorage) DiscardChanges(docPath string) (Document, error) {
+	defer gs.invalidateStatus()
+
+	docPath, err := gs.cleanDocPath(docPath)
+	if err != nil {
+		return Document{}, err
+	}
+
+	doc, err := gs.GetDocument(docPath)
+	if err != nil {
+		return Document{}, err
+	}
+	if !doc.Uncommitted {
+		return doc, nil
+	}
+
+	head, err := gs.repo.Head()
+	if err != nil {
+		return Document{}, fmt.Errorf("failed to get HEAD: %w", err)
+	}
+	commit, err := gs.repo.CommitObject(head.Hash())
+	if err != nil {
+		return Document{}, fmt.Errorf("failed to get HEAD commit: %w", err)
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return Document{}, fmt.Errorf("failed to get HEAD tree: %w", err)
+	}
+
+	relDocsDir, err := filepath.Rel(gs.baseDir, gs.docsDir)
+	if err != nil {
+		return Document{}, err
+	}
+	relDir := path.Join(filepath.ToSlash(relDocsDir), docPath)
+	docTree, err := tree.Tree(relDir)
+	if errors.Is(err, object.ErrDirectoryNotFound) {
+		return Document{}, ErrNotCommitted
+	}
+	if err != nil {
+		return Document{}, err
+	}
+
+	w, err := gs.repo.Worktree()
+	if err != nil {
+		return Document{}, fmt.Errorf("failed to get worktree: %w", err)
+	}
+
+	// Восстанавливаем файлы документа из HEAD
+	committed := make(map[string]bool)
+	for _, entry := range docTree.Entries {
+		if !entry.Mode.IsFile() {
+			continue
+		}
+		committed[entry.Name] = true
+
+		file, err := docTree.TreeEntryFile(&entry)
+		if err != nil {
+			return Document{}, err
+		}
+		content, err := file.Contents()
+		if err != nil {
+			return Document{}, err
+		}
+		if err := os.WriteFile(filepath.Join(gs.docsDir, filepath.FromSlash(docPath), entry.Name), []byte(content), 0644); err != nil {
+			return Document{}, err
+		}
+		if _, err := w.Add(path.Join(relDir, entry.Name)); err != nil {
+			return Document{}, fmt.Errorf("failed to reset index: %w", err)
+		}
+	}
+
+	// Удаляем файлы, которых нет в коммите (например, после незакоммиченной смены названия)
+	files, err := os.ReadDir(filepath.Join(gs.docsDir, filepath.FromSlash(docPath)))
+	if err != nil {
+		return Document{}, err
+	}
+	for _, f := range files {
+		if f.IsDir() || committed[f.Name()] {
+			continue
+		}
+		if _, err := w.Remove(path.Join(relDir, f.Name())); err != nil {
+			if err := os.Remove(filepath.Join(gs.docsDir, filepath.FromSlash(docPath), f.Name())); err != nil {
+				return Document{}, err
+			}
+		}
+	}
+
+	gs.invalidateStatus()
+	return gs.GetDocument(docPath)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestDiscardDocumentChanges(t *testing.T) {
+	h, gs, engine := newTestDocumentHandler(t)
+	doc := mustCreate(t, gs, "", "Guide", "committed walrus")
+	if err := engine.IndexDocument(doc); err != nil {
+		t.Fatal(err)
+	}
+
+	edited, err := gs.UpdateDocument(doc.Path, "Guide", "draft penguin", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine.DeleteDocument(doc.Path)
+	engine.IndexDocument(edited)
+	if current, _ := gs.GetDocument(doc.Path); !current.Uncommitted {
+		t.Fatal("document is not marked uncommitted after edit")
+	}
+
+	rec := serve(h.DiscardDocumentChanges, "POST", "/api/document/"+doc.Path+"/discard", nil, map[string]string{"rest": doc.Path})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+	var reverted Document
+	if err := json.NewDecoder(rec.Body).Decode(&reverted); err != nil {
+		t.Fatal(err)
+	}
+	if reverted.Content != "committed walrus" || reverted.Uncommitted {
+		t.Fatalf("reverted = %q (uncommitted %v), want HEAD content", reverted.Content, reverted.Uncommitted)
+	}
+	if current, _ := gs.GetDocument(doc.Path); current.Content != "committed walrus" || current.Uncommitted {
+		t.Fatalf("stored document = %q (uncommitted %v)", current.Content, current.Uncommitted)
+	}
+
+	if _, total, _ := engine.Search("penguin", 1, 10); total != 0 {
+		t.Fatal("discarded content still indexed")
+	}
+	if _, total, _ := engine.Search("walrus", 1, 10); total != 1 {
+		t.Fatal("committed content not indexed after discard")
+	}
+
+	// Повторная отмена для чистого документа ничего не меняет
+	commits := countCommits(t, gs)
+	rec = serve(h.DiscardDocumentChanges, "POST", "/api/document/"+doc.Path+"/discard", nil, map[string]string{"rest": doc.Path})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("no-op status = %d, body %s", rec.Code, rec.Body)
+	}
+	if got := countCommits(t, gs); got != commits {
+		t.Fatalf("commits = %d, want %d", got, commits)
+	}
+}
+
+func TestDiscardDocumentChangesNotFound(t *testing.T) {
+	h, gs, _ := newTestDocumentHandler(t)
+	mustCreate(t, gs, "", "Existing", "")
+
+	rec := serve(h.DiscardDocumentChanges, "POST", "/api/document/missing/discard", nil, map[string]string{"rest": "missing"})
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
@@ -0,0 +1,58 @@
+// doc_stats.go
+package main
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// readingWordsPerMinute - средняя скорость чтения, одна для всех языков
+const readingWordsPerMinute = 200
+
+// DocumentStats - вычисляемые характеристики содержимого документа
+type DocumentStats struct {
+	WordCount      int `json:"wordCount"`
+	ReadingMinutes int `json:"readingMinutes"` // округляется вверх, 0 - пустой документ
+}
+
+func computeDocumentStats(content string) DocumentStats {
+	words := len(strings.Fields(content))
+	return DocumentStats{
+		WordCount:      words,
+		ReadingMinutes: (words + readingWordsPerMinute - 1) / readingWordsPerMinute,
+	}
+}
+
+// documentStatsCache хранит статистику по пути документа. Запись действительна,
+// пока у документа те же время изменения и длина содержимого.
+type documentStatsCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedDocumentStats
+}
+
+type cachedDocumentStats struct {
+	modified time.Time
+	size     int
+	stats    DocumentStats
+}
+
+func newDocumentStatsCache() *documentStatsCache {
+	return &documentStatsCache{entries: make(map[string]cachedDocumentStats)}
+}
+
+// Get возвращает статистику документа, пересчитывая ее только после изменений
+func (c *documentStatsCache) Get(doc Document) DocumentStats {
+	c.mu.Lock()
+	entry, ok := c.entries[doc.Path]
+	c.mu.Unlock()
+	if ok && entry.modified.Equal(doc.Modified) && entry.size == len(doc.Content) {
+		return entry.stats
+	}
+
+	stats := computeDocumentStats(doc.Content)
+	c.mu.Lock()
+	c.entries[doc.Path] = cachedDocumentStats{modified: doc.Modified, size: len(doc.Content), stats: stats}
+	c.mu.Unlock()
+	return stats
+}
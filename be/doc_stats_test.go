@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestComputeDocumentStats(t *testing.T) {
+	for _, tc := range []struct {
+		content string
+		want    DocumentStats
+	}{
+		{"", DocumentStats{}},
+		{"  Привет,\n мир  hello ", DocumentStats{WordCount: 3, ReadingMinutes: 1}},
+		{strings.Repeat("word ", 200), DocumentStats{WordCount: 200, ReadingMinutes: 1}},
+		{strings.Repeat("word ", 201), DocumentStats{WordCount: 201, ReadingMinutes: 2}},
+	} {
+		if got := computeDocumentStats(tc.content); got != tc.want {
+			t.Errorf("computeDocumentStats(%.20q) = %+v, want %+v", tc.content, got, tc.want)
+		}
+	}
+}
+
+func TestDocumentStatsCache(t *testing.T) {
+	cache := newDocumentStatsCache()
+	modified := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	doc := Document{Path: "a", Content: "one two three", Modified: modified}
+	if got := cache.Get(doc); got.WordCount != 3 {
+		t.Fatalf("stats = %+v", got)
+	}
+
+	// Та же версия документа берется из кэша, не пересчитывается
+	cache.entries["a"] = cachedDocumentStats{modified: modified, size: len(doc.Content), stats: DocumentStats{WordCount: 42}}
+	if got := cache.Get(doc); got.WordCount != 42 {
+		t.Errorf("cached stats = %+v", got)
+	}
+
+	doc.Content, doc.Modified = "one two", modified.Add(time.Second)
+	if got := cache.Get(doc); got.WordCount != 2 {
+		t.Errorf("stats after change = %+v", got)
+	}
+}
+
+func TestGetDocumentStats(t *testing.T) {
+	h, gs, _ := newTestDocumentHandler(t)
+	doc := mustCreate(t, gs, "", "Doc", strings.Repeat("слово word ", 150))
+
+	get := func(target string) Document {
+		t.Helper()
+		rec := serve(h.GetDocument, "GET", target, nil, map[string]string{"rest": doc.Path})
+		var got Document
+		if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+
+	got := get("/api/document/" + doc.Path)
+	if got.Stats == nil || *got.Stats != (DocumentStats{WordCount: 300, ReadingMinutes: 2}) {
+		t.Errorf("stats = %+v", got.Stats)
+	}
+	if got := get("/api/document/" + doc.Path + "?stats=false"); got.Stats != nil {
+		t.Errorf("stats=false returned %+v", got.Stats)
+	}
+}
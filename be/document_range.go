@@ -0,0 +1,101 @@
+// document_range.go
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	ErrInvalidRange        = errors.New("invalid range: use bytes=start-end, bytes=start- or bytes=-suffix")
+	ErrRangeNotSatisfiable = errors.New("range not satisfiable")
+)
+
+// parseByteRange разбирает один диапазон bytes=a-b, bytes=a- или bytes=-n
+// для содержимого длиной size и возвращает включительные границы
+func parseByteRange(spec string, size int) (start, end int, err error) {
+	spec, ok := strings.CutPrefix(strings.TrimSpace(spec), "bytes=")
+	if !ok || strings.Contains(spec, ",") {
+		return 0, 0, ErrInvalidRange
+	}
+	first, last, ok := strings.Cut(spec, "-")
+	if !ok {
+		return 0, 0, ErrInvalidRange
+	}
+
+	if first == "" {
+		// Последние n байт
+		n, err := strconv.Atoi(last)
+		if err != nil || n < 0 {
+			return 0, 0, ErrInvalidRange
+		}
+		if n == 0 || size == 0 {
+			return 0, 0, ErrRangeNotSatisfiable
+		}
+		return max(size-n, 0), size - 1, nil
+	}
+
+	start, err = strconv.Atoi(first)
+	if err != nil || start < 0 {
+		return 0, 0, ErrInvalidRange
+	}
+	end = size - 1
+	if last != "" {
+		if end, err = strconv.Atoi(last); err != nil || end < start {
+			return 0, 0, ErrInvalidRange
+		}
+		end = min(end, size-1)
+	}
+	if start >= size {
+		return 0, 0, ErrRangeNotSatisfiable
+	}
+	return start, end, nil
+}
+
+// alignRange сдвигает границы так, чтобы не разрезать символ UTF-8: символ
+// относится к тому диапазону, в который попал его первый байт. Последовательные
+// запросы по возвращенному Content-Range не теряют и не повторяют символы.
+func alignRange(content string, start, end int) (int, int) {
+	for start < len(content) && !utf8.RuneStart(content[start]) {
+		start++
+	}
+	end++ // исключительная граница
+	for end < len(content) && !utf8.RuneStart(content[end]) {
+		end++
+	}
+	return start, end
+}
+
+// writeDocumentRange отдает документ с частью содержимого, заданной spec,
+// со статусом 206 и заголовком Content-Range в байтах содержимого
+func writeDocumentRange(w http.ResponseWriter, r *http.Request, doc Document, spec string) {
+	size := len(doc.Content)
+	start, end, err := parseByteRange(spec, size)
+	if errors.Is(err, ErrRangeNotSatisfiable) {
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+		http.Error(w, err.Error(), http.StatusRequestedRangeNotSatisfiable)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	start, end = alignRange(doc.Content, start, end)
+	if start >= end {
+		// Диапазон целиком внутри последнего символа, его начало в предыдущей части
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+		http.Error(w, ErrRangeNotSatisfiable.Error(), http.StatusRequestedRangeNotSatisfiable)
+		return
+	}
+	doc.Content = doc.Content[start:end]
+
+	w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end-1, size))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusPartialContent)
+	writeJSON(w, r, doc)
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func getDocumentRange(t *testing.T, h *DocumentHandler, docPath, spec string) (int, string, Document) {
+	t.Helper()
+	rec := serve(h.GetDocument, "GET", "/api/document/"+docPath+"?range="+spec, nil, map[string]string{"rest": docPath})
+	var doc Document
+	if rec.Code == http.StatusPartialContent {
+		if err := json.NewDecoder(rec.Body).Decode(&doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return rec.Code, rec.Header().Get("Content-Range"), doc
+}
+
+func TestGetDocumentRange(t *testing.T) {
+	h, gs, _ := newTestDocumentHandler(t)
+	content := strings.Repeat("0123456789", 10)
+	doc := mustCreate(t, gs, "", "Big", content)
+
+	tests := []struct {
+		spec, wantRange, wantContent string
+	}{
+		{"bytes=0-9", "bytes 0-9/100", "0123456789"},
+		{"bytes=95-", "bytes 95-99/100", "56789"},
+		{"bytes=-3", "bytes 97-99/100", "789"},
+		{"bytes=90-500", "bytes 90-99/100", "0123456789"},
+	}
+	for _, tt := range tests {
+		code, contentRange, got := getDocumentRange(t, h, doc.Path, tt.spec)
+		if code != http.StatusPartialContent {
+			t.Fatalf("%s: status = %d, want %d", tt.spec, code, http.StatusPartialContent)
+		}
+		if contentRange != tt.wantRange || got.Content != tt.wantContent {
+			t.Errorf("%s: Content-Range %q content %q, want %q %q", tt.spec, contentRange, got.Content, tt.wantRange, tt.wantContent)
+		}
+		if got.Title != "Big" {
+			t.Errorf("%s: title = %q", tt.spec, got.Title)
+		}
+	}
+
+	// Без диапазона документ отдается целиком
+	rec := serve(h.GetDocument, "GET", "/api/document/"+doc.Path, nil, map[string]string{"rest": doc.Path})
+	if rec.Code != http.StatusOK || rec.Header().Get("Accept-Ranges") != "bytes" {
+		t.Errorf("full document: status = %d, Accept-Ranges %q", rec.Code, rec.Header().Get("Accept-Ranges"))
+	}
+}
+
+func TestGetDocumentRangeErrors(t *testing.T) {
+	h, gs, _ := newTestDocumentHandler(t)
+	doc := mustCreate(t, gs, "", "Small", "short text")
+
+	code, contentRange, _ := getDocumentRange(t, h, doc.Path, "bytes=100-200")
+	if code != http.StatusRequestedRangeNotSatisfiable || contentRange != "bytes */10" {
+		t.Errorf("out of range: status = %d, Content-Range %q", code, contentRange)
+	}
+
+	for _, spec := range []string{"0-9", "bytes=5-2", "bytes=a-b", "bytes=0-1,3-4", "bytes=-x"} {
+		if code, _, _ := getDocumentRange(t, h, doc.Path, spec); code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want %d", spec, code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestGetDocumentRangeUTF8(t *testing.T) {
+	h, gs, _ := newTestDocumentHandler(t)
+	content := "привет, мир! " + strings.Repeat("ёжик ", 30)
+	doc := mustCreate(t, gs, "", "Unicode", content)
+
+	// Загрузка частями по 7 байт: каждая часть - валидный UTF-8,
+	// а склеенные части совпадают с исходным текстом
+	var assembled strings.Builder
+	for next := 0; next < len(content); {
+		code, contentRange, got := getDocumentRange(t, h, doc.Path, "bytes="+strconv.Itoa(next)+"-"+strconv.Itoa(next+6))
+		if code != http.StatusPartialContent {
+			t.Fatalf("chunk at %d: status = %d", next, code)
+		}
+		if !utf8.ValidString(got.Content) {
+			t.Fatalf("chunk at %d is not valid UTF-8: %q", next, got.Content)
+		}
+		var start, end, size int
+		if _, err := fmt.Sscanf(contentRange, "bytes %d-%d/%d", &start, &end, &size); err != nil {
+			t.Fatalf("Content-Range %q: %v", contentRange, err)
+		}
+		if start != assembled.Len() || end-start+1 != len(got.Content) || size != len(content) {
+			t.Fatalf("Content-Range %q for %d bytes after %d", contentRange, len(got.Content), assembled.Len())
+		}
+		assembled.WriteString(got.Content)
+		next = end + 1
+	}
+	if assembled.String() != content {
+		t.Errorf("assembled = %q", assembled.String())
+	}
+
+	// Начало внутри символа сдвигается к следующему символу
+	code, contentRange, got := getDocumentRange(t, h, doc.Path, "bytes=1-4")
+	if code != http.StatusPartialContent || got.Content != "ри" || contentRange != "bytes 2-5/"+strconv.Itoa(len(content)) {
+		t.Errorf("mid-rune range: %d %q %q", code, contentRange, got.Content)
+	}
+}
@@ -0,0 +1,108 @@
+// draft_publish.go
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// ErrDraftTargetMissing - документ, правки к которому хранит черновик, удален
+var ErrDraftTargetMissing = errors.New("draft target document no longer exists")
+
+// publishDraft применяет черновик: обновляет документ DocumentPath или создает
+// новый в Path. Под editMu, чтобы не разойтись с параллельными правками.
+func (h *DocumentHandler) publishDraft(w http.ResponseWriter, r *http.Request, draft *Draft, author *CoAuthor) (Document, string, bool) {
+	h.editMu.Lock()
+	defer h.editMu.Unlock()
+
+	if draft.DocumentPath == "" {
+		doc, err := h.createDocument(author, draft.Path, draft.Title, draft.Content, nil)
+		if errors.Is(err, mkDirErr) {
+			err = fmt.Errorf("%w: parent %s", ErrDraftTargetMissing, draft.Path)
+		}
+		return doc, "", h.publishError(w, err)
+	}
+
+	current, err := h.storage.GetDocument(draft.DocumentPath)
+	if errors.Is(err, ErrDocumentNotFound) {
+		err = fmt.Errorf("%w: %s", ErrDraftTargetMissing, draft.DocumentPath)
+	}
+	if !h.publishError(w, err) {
+		return Document{}, "", false
+	}
+	if !h.checkIfMatch(w, r, draft.DocumentPath) {
+		return Document{}, "", false
+	}
+
+	title := draft.Title
+	if title == "" {
+		title = current.Title
+	}
+	doc, err := h.updateDocument(author, "", draft.DocumentPath, title, draft.Content, true, nil)
+	return doc, draft.DocumentPath, h.publishError(w, err)
+}
+
+// publishError отвечает клиенту ошибкой публикации. Возвращает true, если ошибки нет.
+func (h *DocumentHandler) publishError(w http.ResponseWriter, err error) bool {
+	if err == nil {
+		return true
+	}
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, ErrDraftTargetMissing), errors.Is(err, ErrTitleConflict):
+		status = http.StatusConflict
+	case errors.Is(err, ErrDepthExceeded):
+		status = http.StatusBadRequest
+	}
+	http.Error(w, err.Error(), status)
+	return false
+}
+
+// PublishDraft применяет черновик {id} к документам и удаляет его.
+// Если документ черновика удален, черновик остается и возвращается 409.
+func (h *DocumentHandler) PublishDraft(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	draft, err := h.draftStorage.GetDraft(id)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrDraftNotFound) {
+			status = http.StatusNotFound
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	author, err := commitAuthor(r, nil)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	doc, oldPath, ok := h.publishDraft(w, r, draft, author)
+	if !ok {
+		return
+	}
+
+	// Документ уже сохранен, поэтому ошибки индекса и удаления черновика только логируем
+	if oldPath != "" {
+		if err := h.search.DeleteDocument(oldPath); err != nil {
+			log.Printf("Warning: failed to remove %s from search index: %v", oldPath, err)
+		}
+	}
+	if err := h.search.IndexDocument(doc); err != nil {
+		log.Printf("Warning: failed to index published draft %s: %v", id, err)
+	}
+	if err := h.draftStorage.DeleteDraft(id); err != nil {
+		log.Printf("Warning: failed to delete published draft %s: %v", id, err)
+	}
+	h.search.DeleteDocument(draftSearchPath(id))
+
+	if saved, err := h.storage.GetDocument(doc.Path); err == nil {
+		w.Header().Set("ETag", documentETag(saved))
+	}
+	doc.Favorite = h.meta.IsFavorite(doc.Path)
+	writeJSON(w, r, doc)
+}
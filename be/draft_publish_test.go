@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func publish(t *testing.T, h *DocumentHandler, id string) (int, Document) {
+	t.Helper()
+	rec := serve(h.PublishDraft, "POST", "/api/draft/"+id+"/publish", nil, map[string]string{"id": id})
+	var doc Document
+	if rec.Code == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return rec.Code, doc
+}
+
+func TestPublishDraftUpdatesDocument(t *testing.T) {
+	h, gs, engine := newTestDocumentHandler(t)
+	doc := mustCreate(t, gs, "", "Doc", "old text")
+	if err := engine.IndexDocument(doc); err != nil {
+		t.Fatal(err)
+	}
+	draft := Draft{ID: "edit", Content: "new text", DocumentPath: doc.Path}
+	if err := h.draftStorage.SetDraft(draft); err != nil {
+		t.Fatal(err)
+	}
+	h.indexDraft(draft)
+
+	code, published := publish(t, h, "edit")
+	if code != http.StatusOK {
+		t.Fatalf("status = %d", code)
+	}
+	// Без названия в черновике название документа не меняется
+	if published.Path != doc.Path || published.Title != "Doc" || published.Content != "new text" {
+		t.Errorf("published = %+v", published)
+	}
+	saved, err := gs.GetDocument(doc.Path)
+	if err != nil || saved.Content != "new text" || saved.Uncommitted {
+		t.Errorf("saved = %+v, %v", saved, err)
+	}
+
+	if _, err := h.draftStorage.GetDraft("edit"); !errors.Is(err, ErrDraftNotFound) {
+		t.Errorf("draft still present: %v", err)
+	}
+	for _, d := range engine.draftDocuments() {
+		if d.ID == "edit" {
+			t.Error("published draft is still in the search index")
+		}
+	}
+	if results, _, err := engine.Search("new", 1, 10); err != nil || len(results) != 1 || results[0].Path != doc.Path {
+		t.Errorf("search after publish = %+v, %v", results, err)
+	}
+}
+
+func TestPublishDraftCreatesDocument(t *testing.T) {
+	h, gs, _ := newTestDocumentHandler(t)
+	parent := mustCreate(t, gs, "", "Parent", "")
+	if err := h.draftStorage.SetDraft(Draft{ID: "new", Title: "Child", Content: "text", Path: parent.Path}); err != nil {
+		t.Fatal(err)
+	}
+
+	code, published := publish(t, h, "new")
+	if code != http.StatusOK {
+		t.Fatalf("status = %d", code)
+	}
+	if published.Path != "parent/child" || published.Title != "Child" {
+		t.Errorf("published = %+v", published)
+	}
+	if _, err := gs.GetDocument("parent/child"); err != nil {
+		t.Error(err)
+	}
+	if code, _ := publish(t, h, "new"); code != http.StatusNotFound {
+		t.Errorf("second publish status = %d, want 404", code)
+	}
+}
+
+func TestPublishDraftForDeletedDocument(t *testing.T) {
+	h, gs, _ := newTestDocumentHandler(t)
+	doc := mustCreate(t, gs, "", "Doc", "text")
+	if err := h.draftStorage.SetDraft(Draft{ID: "edit", Content: "changed", DocumentPath: doc.Path}); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.draftStorage.SetDraft(Draft{ID: "child", Title: "Child", Content: "x", Path: doc.Path}); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.RemoveAll(filepath.Join(gs.docsDir, doc.Path)); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []string{"edit", "child"} {
+		if code, _ := publish(t, h, id); code != http.StatusConflict {
+			t.Errorf("%s: status = %d, want 409", id, code)
+		}
+		// Черновик не теряется
+		if _, err := h.draftStorage.GetDraft(id); err != nil {
+			t.Errorf("%s: draft lost: %v", id, err)
+		}
+	}
+}
@@ -4,21 +4,41 @@ package main
 import (
 	"encoding/json"
 	"errors"
+	"log"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 )
 
 type Draft struct {
-	ID        string    `json:"id"`
-	Title     string    `json:"title"`
-	Content   string    `json:"content"`
-	Path      string    `json:"path"`
-	CreatedAt time.Time `json:"created_at"`
+	ID      string `json:"id"`
+	Title   string `json:"title"`
+	Content string `json:"content"`
+	Path    string `json:"path"`
+	// Документ, правки к которому хранит черновик. Пусто - черновик нового
+	// документа с родителем Path.
+	DocumentPath string    `json:"documentPath,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"` // время последнего SetDraft
 }
 
+// lastChange - время последнего изменения черновика; у черновиков,
+// сохраненных до появления UpdatedAt, это время создания
+func (d Draft) lastChange() time.Time {
+	if d.UpdatedAt.IsZero() {
+		return d.CreatedAt
+	}
+	return d.UpdatedAt
+}
+
+var ErrDraftNotFound = errors.New("draft not found")
+
 type DraftStorage struct {
 	draftsDir string
+	locks     keyedMutex // по ID черновика
 }
 
 func NewDraftStorage(baseDir string) (*DraftStorage, error) {
@@ -30,10 +50,16 @@ func NewDraftStorage(baseDir string) (*DraftStorage, error) {
 }
 
 func (ds *DraftStorage) GetDraft(id string) (*Draft, error) {
+	defer ds.locks.Lock(id)()
+	return ds.readDraft(id)
+}
+
+// readDraft читает черновик. Вызывается под блокировкой id.
+func (ds *DraftStorage) readDraft(id string) (*Draft, error) {
 	data, err := os.ReadFile(filepath.Join(ds.draftsDir, id+".json"))
 	if err != nil {
 		if os.IsNotExist(err) {
-			return nil, errors.New("draft not found")
+			return nil, ErrDraftNotFound
 		}
 		return nil, err
 	}
@@ -63,30 +89,295 @@ func (ds *DraftStorage) GetAllDrafts() ([]Draft, error) {
 		}
 	}
 
+	// Сначала недавно измененные
+	sort.SliceStable(drafts, func(i, j int) bool {
+		return drafts[i].lastChange().After(drafts[j].lastChange())
+	})
 	return drafts, nil
 }
 
+// GetDrafts возвращает найденные черновики в порядке ids.
+// Отсутствующие и поврежденные черновики не прерывают выборку, а перечисляются отдельно.
+func (ds *DraftStorage) GetDrafts(ids []string) (drafts []Draft, missing []string, invalid []string) {
+	drafts = []Draft{}
+	missing = []string{}
+	invalid = []string{}
+	for _, id := range ids {
+		if id == "" || strings.ContainsAny(id, `/\`) || id == "." || id == ".." {
+			missing = append(missing, id)
+			continue
+		}
+
+		draft, err := ds.GetDraft(id)
+		if errors.Is(err, ErrDraftNotFound) {
+			missing = append(missing, id)
+			continue
+		}
+		if err != nil {
+			invalid = append(invalid, id)
+			continue
+		}
+		drafts = append(drafts, *draft)
+	}
+	return drafts, missing, invalid
+}
+
+// CountDrafts возвращает число сохраненных черновиков без чтения их содержимого
+func (ds *DraftStorage) CountDrafts() (int, error) {
+	files, err := os.ReadDir(ds.draftsDir)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, f := range files {
+		if filepath.Ext(f.Name()) == ".json" {
+			count++
+		}
+	}
+	return count, nil
+}
+
 func (ds *DraftStorage) SetDraft(draft Draft) error {
 	if draft.ID == "" {
 		return errors.New("draft ID cannot be empty")
 	}
 
-	if draft.CreatedAt.IsZero() {
-		draft.CreatedAt = time.Now()
+	defer ds.locks.Lock(draft.ID)()
+
+	// При повторном сохранении время создания берется из сохраненного черновика
+	now := time.Now()
+	if existing, err := ds.readDraft(draft.ID); err == nil && !existing.CreatedAt.IsZero() {
+		draft.CreatedAt = existing.CreatedAt
+	} else if draft.CreatedAt.IsZero() {
+		draft.CreatedAt = now
 	}
+	draft.UpdatedAt = now
 
 	data, err := json.Marshal(draft)
 	if err != nil {
 		return err
 	}
+	return writeFileAtomic(filepath.Join(ds.draftsDir, draft.ID+".json"), data)
+}
+
+// writeFileAtomic пишет данные во временный файл рядом с целевым и переименовывает его,
+// поэтому читатели видят либо старое, либо новое содержимое целиком
+func writeFileAtomic(filePath string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(filePath), "."+filepath.Base(filePath)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
 
-	return os.WriteFile(filepath.Join(ds.draftsDir, draft.ID+".json"), data, 0644)
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filePath)
 }
 
+// DeleteDraft перемещает черновик в корзину, откуда его можно восстановить до очистки
 func (ds *DraftStorage) DeleteDraft(id string) error {
-	err := os.Remove(filepath.Join(ds.draftsDir, id+".json"))
+	defer ds.locks.Lock(id)()
+	return ds.trashDraft(id)
+}
+
+// trashDraft перемещает черновик в корзину. Вызывается под блокировкой id.
+func (ds *DraftStorage) trashDraft(id string) error {
+	if err := os.MkdirAll(ds.trashDir(), 0755); err != nil {
+		return err
+	}
+	trashPath := filepath.Join(ds.trashDir(), id+".json")
+	err := os.Rename(filepath.Join(ds.draftsDir, id+".json"), trashPath)
 	if os.IsNotExist(err) {
-		return errors.New("draft not found")
+		return ErrDraftNotFound
 	}
-	return err
+	if err != nil {
+		return err
+	}
+
+	// Время изменения файла в корзине - момент удаления, от него считается срок хранения
+	now := time.Now()
+	return os.Chtimes(trashPath, now, now)
+}
+
+var ErrDraftExists = errors.New("draft already exists")
+
+// TrashedDraft - удаленный черновик в корзине
+type TrashedDraft struct {
+	Draft
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+func (ds *DraftStorage) trashDir() string {
+	return filepath.Join(ds.draftsDir, ".trash")
+}
+
+// GetTrash возвращает удаленные черновики, начиная с последних
+func (ds *DraftStorage) GetTrash() ([]TrashedDraft, error) {
+	files, err := os.ReadDir(ds.trashDir())
+	if os.IsNotExist(err) {
+		return []TrashedDraft{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	trashed := []TrashedDraft{}
+	for _, f := range files {
+		if filepath.Ext(f.Name()) != ".json" {
+			continue
+		}
+		info, err := f.Info()
+		if err != nil {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(ds.trashDir(), f.Name()))
+		if err != nil {
+			continue
+		}
+		var draft Draft
+		if err := json.Unmarshal(data, &draft); err != nil {
+			continue // skip corrupted drafts
+		}
+		trashed = append(trashed, TrashedDraft{Draft: draft, DeletedAt: info.ModTime()})
+	}
+	sort.Slice(trashed, func(i, j int) bool { return trashed[i].DeletedAt.After(trashed[j].DeletedAt) })
+
+	return trashed, nil
+}
+
+// RestoreDraft возвращает черновик из корзины
+func (ds *DraftStorage) RestoreDraft(id string) (*Draft, error) {
+	unlock := ds.locks.Lock(id)
+	target := filepath.Join(ds.draftsDir, id+".json")
+	if _, err := os.Stat(target); err == nil {
+		unlock()
+		return nil, ErrDraftExists
+	}
+
+	err := os.Rename(filepath.Join(ds.trashDir(), id+".json"), target)
+	unlock()
+	if os.IsNotExist(err) {
+		return nil, ErrDraftNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ds.GetDraft(id)
+}
+
+// PurgeTrash удаляет из корзины черновики, пролежавшие дольше ttl
+func (ds *DraftStorage) PurgeTrash(ttl time.Duration) (int, error) {
+	files, err := os.ReadDir(ds.trashDir())
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, f := range files {
+		info, err := f.Info()
+		if err != nil || time.Since(info.ModTime()) < ttl {
+			continue
+		}
+		if err := os.Remove(filepath.Join(ds.trashDir(), f.Name())); err == nil {
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+// PruneOlderThan переносит в корзину черновики, которые не менялись дольше d,
+// и возвращает их ID
+func (ds *DraftStorage) PruneOlderThan(d time.Duration) ([]string, error) {
+	files, err := os.ReadDir(ds.draftsDir)
+	if err != nil {
+		return nil, err
+	}
+
+	cutoff := time.Now().Add(-d)
+	pruned := []string{}
+	for _, f := range files {
+		if filepath.Ext(f.Name()) != ".json" {
+			continue
+		}
+		id := strings.TrimSuffix(f.Name(), ".json")
+
+		// Проверка и удаление под одной блокировкой, чтобы не удалить
+		// черновик, сохраненный между ними
+		unlock := ds.locks.Lock(id)
+		draft, err := ds.readDraft(id)
+		if err == nil && draft.lastChange().Before(cutoff) {
+			err = ds.trashDraft(id)
+			if err == nil {
+				log.Printf("Pruned stale draft %s (%q), last changed %s", id, draft.Title, draft.lastChange().Format(time.RFC3339))
+				pruned = append(pruned, id)
+			}
+		}
+		unlock()
+		if err != nil && !errors.Is(err, ErrDraftNotFound) {
+			log.Printf("Warning: failed to prune draft %s: %v", id, err)
+		}
+	}
+	return pruned, nil
+}
+
+// StartDraftPruner периодически убирает черновики старше ttl и сообщает их ID
+// в onPrune. Возвращает функцию остановки.
+func (ds *DraftStorage) StartDraftPruner(ttl, interval time.Duration, onPrune func(ids []string)) func() {
+	stop := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				ids, err := ds.PruneOlderThan(ttl)
+				if err != nil {
+					log.Printf("Warning: failed to prune stale drafts: %v", err)
+				}
+				if len(ids) > 0 && onPrune != nil {
+					onPrune(ids)
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() { once.Do(func() { close(stop) }) }
+}
+
+// StartTrashCleaner периодически очищает корзину. Возвращает функцию остановки.
+func (ds *DraftStorage) StartTrashCleaner(ttl, interval time.Duration) func() {
+	stop := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := ds.PurgeTrash(ttl); err != nil {
+					log.Printf("Warning: failed to purge drafts trash: %v", err)
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() { once.Do(func() { close(stop) }) }
 }
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestGetDraftsBatch(t *testing.T) {
+	h, _, _ := newTestDocumentHandler(t)
+	for _, id := range []string{"one", "two"} {
+		if err := h.draftStorage.SetDraft(Draft{ID: id, Title: "Draft " + id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(h.draftStorage.draftsDir, "broken.json"), []byte("{not json"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := serve(h.GetDraftsBatch, "POST", "/api/drafts/batch",
+		strings.NewReader(`{"ids":["two","missing","broken","one","../drafts/one"]}`), nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+
+	var resp struct {
+		Drafts   []Draft  `json:"drafts"`
+		NotFound []string `json:"not_found"`
+		Invalid  []string `json:"invalid"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+
+	var ids []string
+	for _, d := range resp.Drafts {
+		ids = append(ids, d.ID)
+	}
+	if !reflect.DeepEqual(ids, []string{"two", "one"}) {
+		t.Fatalf("drafts = %v, want [two one]", ids)
+	}
+	if !reflect.DeepEqual(resp.NotFound, []string{"missing", "../drafts/one"}) {
+		t.Fatalf("not_found = %v", resp.NotFound)
+	}
+	if !reflect.DeepEqual(resp.Invalid, []string{"broken"}) {
+		t.Fatalf("invalid = %v", resp.Invalid)
+	}
+}
+
+func TestGetDraftsBatchRejectsTooManyIDs(t *testing.T) {
+	h, _, _ := newTestDocumentHandler(t)
+	ids := make([]string, maxDraftBatch+1)
+	for i := range ids {
+		ids[i] = "x"
+	}
+	body, _ := json.Marshal(map[string][]string{"ids": ids})
+
+	rec := serve(h.GetDraftsBatch, "POST", "/api/drafts/batch", strings.NewReader(string(body)), nil)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestDraftTrashRestore(t *testing.T) {
+	h, _, _ := newTestDocumentHandler(t)
+	if err := h.draftStorage.SetDraft(Draft{ID: "keep", Title: "Keep me", Content: "text"}); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := serve(h.DeleteDraftDocument, "DELETE", "/api/draft/keep", nil, map[string]string{"rest": "keep"})
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("delete status = %d", rec.Code)
+	}
+	if _, err := h.draftStorage.GetDraft("keep"); err != ErrDraftNotFound {
+		t.Fatalf("draft still available after delete: %v", err)
+	}
+	if drafts, _ := h.draftStorage.GetAllDrafts(); len(drafts) != 0 {
+		t.Fatalf("trashed draft listed among drafts: %+v", drafts)
+	}
+
+	rec = serve(h.GetDraftsTrash, "GET", "/api/drafts/trash", nil, nil)
+	var trashed []TrashedDraft
+	if err := json.NewDecoder(rec.Body).Decode(&trashed); err != nil {
+		t.Fatal(err)
+	}
+	if len(trashed) != 1 || trashed[0].ID != "keep" || trashed[0].DeletedAt.IsZero() {
+		t.Fatalf("trash = %+v", trashed)
+	}
+
+	rec = serve(h.RestoreDraftFromTrash, "POST", "/api/drafts/trash/restore/keep", nil, map[string]string{"id": "keep"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("restore status = %d, body %s", rec.Code, rec.Body)
+	}
+	draft, err := h.draftStorage.GetDraft("keep")
+	if err != nil || draft.Content != "text" {
+		t.Fatalf("restored draft = %+v, %v", draft, err)
+	}
+
+	// Восстановление не перезаписывает существующий черновик
+	rec = serve(h.RestoreDraftFromTrash, "POST", "/api/drafts/trash/restore/keep", nil, map[string]string{"id": "keep"})
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("restore over existing draft status = %d, want %d", rec.Code, http.StatusConflict)
+	}
+
+	rec = serve(h.RestoreDraftFromTrash, "POST", "/api/drafts/trash/restore/missing", nil, map[string]string{"id": "missing"})
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("missing restore status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestDraftTrashPurge(t *testing.T) {
+	ds, err := NewDraftStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"old", "fresh"} {
+		if err := ds.SetDraft(Draft{ID: id}); err != nil {
+			t.Fatal(err)
+		}
+		if err := ds.DeleteDraft(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	past := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(filepath.Join(ds.trashDir(), "old.json"), past, past); err != nil {
+		t.Fatal(err)
+	}
+
+	purged, err := ds.PurgeTrash(time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if purged != 1 {
+		t.Fatalf("purged = %d, want 1", purged)
+	}
+	if _, err := ds.RestoreDraft("old"); err != ErrDraftNotFound {
+		t.Fatalf("expired draft restore err = %v, want ErrDraftNotFound", err)
+	}
+	if _, err := ds.RestoreDraft("fresh"); err != nil {
+		t.Fatalf("fresh draft restore: %v", err)
+	}
+}
+
+func TestDraftConcurrentWrites(t *testing.T) {
+	h, _, _ := newTestDocumentHandler(t)
+	ds := h.draftStorage
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 25; j++ {
+				content := strings.Repeat(fmt.Sprintf("writer %d rev %d\n", i, j), 100*(i%4+1))
+				if err := ds.SetDraft(Draft{ID: "shared", Title: "Shared", Content: content}); err != nil {
+					t.Error(err)
+					return
+				}
+				if _, err := ds.GetDraft("shared"); err != nil {
+					t.Errorf("GetDraft during writes: %v", err)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	data, err := os.ReadFile(filepath.Join(ds.draftsDir, "shared.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var draft Draft
+	if err := json.Unmarshal(data, &draft); err != nil {
+		t.Fatalf("draft file is not valid JSON: %v", err)
+	}
+	if draft.ID != "shared" || !strings.HasPrefix(draft.Content, "writer ") {
+		t.Fatalf("unexpected draft %+v", draft.ID)
+	}
+
+	files, err := os.ReadDir(ds.draftsDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		if strings.HasSuffix(f.Name(), ".tmp") {
+			t.Fatalf("temporary file left behind: %s", f.Name())
+		}
+	}
+	if len(ds.locks.locks) != 0 {
+		t.Fatalf("locks not released: %d", len(ds.locks.locks))
+	}
+}
+
+func TestSetDraftTracksUpdatedAt(t *testing.T) {
+	ds, err := NewDraftStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Черновик из старой версии без updated_at
+	legacy := []byte(`{"id":"legacy","title":"Legacy","created_at":"2020-01-01T00:00:00Z"}`)
+	if err := os.WriteFile(filepath.Join(ds.draftsDir, "legacy.json"), legacy, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ds.SetDraft(Draft{ID: "a", Title: "A", Content: "one"}); err != nil {
+		t.Fatal(err)
+	}
+	first, err := ds.GetDraft("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.CreatedAt.IsZero() || !first.UpdatedAt.Equal(first.CreatedAt) {
+		t.Errorf("new draft: created %v, updated %v", first.CreatedAt, first.UpdatedAt)
+	}
+
+	if err := ds.SetDraft(Draft{ID: "b", Title: "B"}); err != nil {
+		t.Fatal(err)
+	}
+	// Автосохранение без created_at и с чужим created_at не сбрасывает время создания
+	for _, created := range []time.Time{{}, time.Now().Add(time.Hour)} {
+		if err := ds.SetDraft(Draft{ID: "a", Title: "A", Content: "two", CreatedAt: created}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	second, err := ds.GetDraft("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !second.CreatedAt.Equal(first.CreatedAt) || !second.UpdatedAt.After(first.UpdatedAt) || second.Content != "two" {
+		t.Errorf("after autosave: created %v (was %v), updated %v (was %v)",
+			second.CreatedAt, first.CreatedAt, second.UpdatedAt, first.UpdatedAt)
+	}
+
+	drafts, err := ds.GetAllDrafts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, d := range drafts {
+		ids = append(ids, d.ID)
+	}
+	if strings.Join(ids, ",") != "a,b,legacy" {
+		t.Errorf("drafts order = %v, want a,b,legacy", ids)
+	}
+}
+
+func TestPruneOlderThan(t *testing.T) {
+	ds, err := NewDraftStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-48 * time.Hour).UTC().Format(time.RFC3339)
+	files := map[string]string{
+		"stale":   `{"id":"stale","title":"Stale","created_at":"` + old + `","updated_at":"` + old + `"}`,
+		"legacy":  `{"id":"legacy","title":"Legacy","created_at":"` + old + `"}`,
+		"revived": `{"id":"revived","title":"Revived","created_at":"` + old + `","updated_at":"` + time.Now().UTC().Format(time.RFC3339) + `"}`,
+	}
+	for id, data := range files {
+		if err := os.WriteFile(filepath.Join(ds.draftsDir, id+".json"), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ds.SetDraft(Draft{ID: "fresh", Title: "Fresh"}); err != nil {
+		t.Fatal(err)
+	}
+
+	pruned, err := ds.PruneOlderThan(24 * time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(pruned, ",") != "legacy,stale" {
+		t.Errorf("pruned = %v, want legacy,stale", pruned)
+	}
+	drafts, err := ds.GetAllDrafts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(drafts) != 2 {
+		t.Errorf("remaining drafts = %+v", drafts)
+	}
+	// Удаленные черновики можно восстановить из корзины
+	if _, err := ds.RestoreDraft("stale"); err != nil {
+		t.Errorf("restore pruned draft: %v", err)
+	}
+}
+
+func TestStartDraftPruner(t *testing.T) {
+	ds, err := NewDraftStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ds.SetDraft(Draft{ID: "a", Title: "A"}); err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(chan []string, 1)
+	stop := ds.StartDraftPruner(0, 10*time.Millisecond, func(ids []string) { got <- ids })
+	defer stop()
+
+	select {
+	case ids := <-got:
+		if len(ids) != 1 || ids[0] != "a" {
+			t.Errorf("pruned = %v", ids)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("pruner did not run")
+	}
+	stop()
+}
@@ -0,0 +1,55 @@
+// etag.go
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// documentETag - версия сохраненного документа для If-Match: хеш названия,
+// содержимого и времени изменения
+func documentETag(doc Document) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%d", doc.Title, doc.Content, doc.Modified.UnixNano())
+	return `"` + hex.EncodeToString(h.Sum(nil))[:16] + `"`
+}
+
+// etagMatches проверяет заголовок If-Match по RFC 9110: список версий или "*"
+func etagMatches(ifMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// checkIfMatch сравнивает If-Match с текущей версией документа. При расхождении
+// отвечает 409 с текущим документом и возвращает false.
+func (h *DocumentHandler) checkIfMatch(w http.ResponseWriter, r *http.Request, docPath string) bool {
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" {
+		return true
+	}
+
+	current, err := h.storage.GetDocument(docPath)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return false
+	}
+	etag := documentETag(current)
+	if etagMatches(ifMatch, etag) {
+		return true
+	}
+
+	current.Favorite = h.meta.IsFavorite(docPath)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusConflict)
+	writeJSON(w, r, current)
+	return false
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestUpdateDocumentIfMatch(t *testing.T) {
+	h, gs, engine := newTestDocumentHandler(t)
+	doc := mustCreate(t, gs, "", "Doc", "one")
+	if err := engine.IndexDocument(doc); err != nil {
+		t.Fatal(err)
+	}
+	vars := map[string]string{"rest": doc.Path}
+
+	update := func(content, ifMatch string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest("PUT", "/api/document/"+doc.Path, strings.NewReader(`{"title":"Doc","content":"`+content+`"}`))
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		rec := httptest.NewRecorder()
+		h.UpdateDocument(rec, mux.SetURLVars(req, vars))
+		return rec
+	}
+
+	rec := serve(h.GetDocument, "GET", "/api/document/"+doc.Path, nil, vars)
+	etag := rec.Header().Get("ETag")
+	if rec.Code != http.StatusOK || etag == "" {
+		t.Fatalf("GET: status = %d, ETag = %q", rec.Code, etag)
+	}
+
+	// Первый редактор сохраняет свою версию
+	rec = update("alice", etag)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("matching If-Match: status = %d, body %s", rec.Code, rec.Body)
+	}
+	newETag := rec.Header().Get("ETag")
+	if newETag == "" || newETag == etag {
+		t.Errorf("ETag after update = %q, was %q", newETag, etag)
+	}
+
+	// Второй редактор начинал с той же версии и получает конфликт
+	rec = update("bob", etag)
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("stale If-Match: status = %d, body %s", rec.Code, rec.Body)
+	}
+	var current Document
+	if err := json.NewDecoder(rec.Body).Decode(&current); err != nil {
+		t.Fatal(err)
+	}
+	if current.Content != "alice" || rec.Header().Get("ETag") != newETag {
+		t.Errorf("conflict response: content %q, ETag %q, want alice and %q", current.Content, rec.Header().Get("ETag"), newETag)
+	}
+	if got, _ := gs.GetDocument(doc.Path); got.Content != "alice" {
+		t.Errorf("stale update was written: %q", got.Content)
+	}
+
+	for _, ifMatch := range []func(etag string) string{
+		func(string) string { return "*" },
+		func(etag string) string { return `"other", ` + etag },
+		func(etag string) string { return "W/" + etag },
+		func(string) string { return "" },
+	} {
+		header := ifMatch(newETag)
+		rec = update("carol", header)
+		if rec.Code != http.StatusOK {
+			t.Errorf("If-Match %q: status = %d", header, rec.Code)
+		}
+		newETag = rec.Header().Get("ETag")
+	}
+}
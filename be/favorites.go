@@ -0,0 +1,66 @@
+// favorites.go
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+)
+
+var ErrInvalidFavoritesOrder = errors.New("invalid favorites order")
+
+// ReorderFavorites расставляет избранное в порядке paths. Избранное, не
+// упомянутое в paths, сохраняет прежний порядок и идет следом.
+func (m *Metadata) ReorderFavorites(paths []string) ([]*ShortDocument, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	byPath := make(map[string]*ShortDocument, len(m.Favorites))
+	for _, f := range m.Favorites {
+		byPath[f.Path] = f
+	}
+
+	reordered := make([]*ShortDocument, 0, len(m.Favorites))
+	listed := make(map[string]bool, len(paths))
+	for _, p := range paths {
+		f, ok := byPath[p]
+		if !ok {
+			return nil, fmt.Errorf("%w: %q is not in favorites", ErrInvalidFavoritesOrder, p)
+		}
+		if listed[p] {
+			return nil, fmt.Errorf("%w: %q is listed twice", ErrInvalidFavoritesOrder, p)
+		}
+		listed[p] = true
+		reordered = append(reordered, f)
+	}
+	for _, f := range m.Favorites {
+		if !listed[f.Path] {
+			reordered = append(reordered, f)
+		}
+	}
+
+	m.Favorites = reordered
+	m.changedFlag = true
+	log.Printf("Metadata.ReorderFavorites: %d favorites reordered", len(reordered))
+	return copyShortDocuments(reordered), nil
+}
+
+// ReorderFavorites меняет порядок избранного, тело {"paths": [...]}
+func (h *DocumentHandler) ReorderFavorites(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Paths []string `json:"paths"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	favorites, err := h.meta.ReorderFavorites(req.Paths)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, r, favorites)
+}
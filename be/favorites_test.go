@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestReorderFavorites(t *testing.T) {
+	dir := t.TempDir()
+	md, err := NewMetadata(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{"a", "b", "c", "d"} {
+		md.AddToFavorites(&ShortDocument{ID: p, Title: p, Path: p})
+	}
+	before := md.GetFavorites()
+	paths := func(docs []*ShortDocument) string {
+		var got []string
+		for _, d := range docs {
+			got = append(got, d.Path)
+		}
+		return strings.Join(got, ",")
+	}
+
+	// Неупомянутые остаются в конце в прежнем порядке
+	got, err := md.ReorderFavorites([]string{"c", "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if paths(got) != "c,a,b,d" || paths(md.GetFavorites()) != "c,a,b,d" {
+		t.Errorf("reordered = %s, want c,a,b,d", paths(got))
+	}
+	if paths(before) != "a,b,c,d" {
+		t.Errorf("earlier result changed to %s", paths(before))
+	}
+
+	for _, bad := range [][]string{{"a", "x"}, {"b", "b"}} {
+		if _, err := md.ReorderFavorites(bad); err == nil {
+			t.Errorf("ReorderFavorites(%v) succeeded", bad)
+		}
+	}
+	if paths(md.GetFavorites()) != "c,a,b,d" {
+		t.Errorf("rejected request changed order to %s", paths(md.GetFavorites()))
+	}
+
+	if err := md.SaveOnDisk(); err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err := NewMetadata(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if paths(reloaded.GetFavorites()) != "c,a,b,d" {
+		t.Errorf("reloaded order = %s", paths(reloaded.GetFavorites()))
+	}
+}
+
+func TestReorderFavoritesHandler(t *testing.T) {
+	h, _, _ := newTestDocumentHandler(t)
+	for _, p := range []string{"a", "b"} {
+		h.meta.AddToFavorites(&ShortDocument{ID: p, Title: p, Path: p})
+	}
+
+	rec := serve(h.ReorderFavorites, "POST", "/api/favorites/reorder", strings.NewReader(`{"paths":["b","a"]}`), nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	var docs []ShortDocument
+	if err := json.Unmarshal(rec.Body.Bytes(), &docs); err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != 2 || docs[0].Path != "b" || docs[1].Path != "a" {
+		t.Errorf("docs = %+v", docs)
+	}
+
+	rec = serve(h.ReorderFavorites, "POST", "/api/favorites/reorder", strings.NewReader(`{"paths":["missing"]}`), nil)
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "missing") {
+		t.Errorf("unknown path: status = %d, body = %s", rec.Code, rec.Body)
+	}
+}
+
+func TestPruneMissingDropsDeletedDocuments(t *testing.T) {
+	h, gs, _ := newTestDocumentHandler(t)
+	keep := mustCreate(t, gs, "", "Keep", "keep")
+	gone := mustCreate(t, gs, "", "Gone", "gone")
+	for _, doc := range []Document{keep, gone} {
+		short := documentToShort(&doc)
+		h.meta.AddToFavorites(short)
+		h.meta.UpdateViewedMeta(short)
+	}
+
+	// Удаление в обход API, как после ручной правки репозитория
+	if err := os.RemoveAll(filepath.Join(gs.docsDir, filepath.FromSlash(gone.Path))); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := h.meta.PruneMissing(documentExists(gs)); n != 1 {
+		t.Errorf("pruned = %d, want 1", n)
+	}
+	if favs := h.meta.GetFavorites(); len(favs) != 1 || favs[0].Path != keep.Path {
+		t.Errorf("favorites = %+v", favs)
+	}
+	if last := h.meta.GetLastViewedDocuments(); len(last) != 1 || last[0].Path != keep.Path {
+		t.Errorf("last viewed = %+v", last)
+	}
+	if most := h.meta.GetMostViewedDocuments(10); len(most) != 1 || most[0].Path != keep.Path {
+		t.Errorf("most viewed = %+v", most)
+	}
+	if n := h.meta.PruneMissing(documentExists(gs)); n != 0 {
+		t.Errorf("second pass pruned %d", n)
+	}
+
+	rec := serve(h.GetFavorites, "GET", "/api/favorites", nil, nil)
+	if strings.Contains(rec.Body.String(), gone.Path) {
+		t.Errorf("favorites still list the deleted document: %s", rec.Body)
+	}
+}
+
+func TestGetFavoritesConcurrentWithChanges(t *testing.T) {
+	md, err := NewMetadata(t.TempDir(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	md.AddToFavorites(&ShortDocument{ID: "base", Title: "base", Path: "base"})
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				// Чтение после снятия блокировки не должно гоняться с изменениями
+				for _, f := range md.GetFavorites() {
+					_ = f.Path + f.Title
+				}
+				for _, d := range md.GetLastViewedDocuments() {
+					_ = d.Path
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < 200; i++ {
+		p := fmt.Sprintf("doc%d", i%7)
+		doc := &ShortDocument{ID: p, Title: p, Path: p}
+		md.AddToFavorites(doc)
+		md.UpdateViewedMeta(doc)
+		doc.Title = "changed by caller"
+		if i%3 == 0 {
+			md.RemoveFromFavorites(p)
+		}
+		if i%5 == 0 {
+			md.RelocatePaths(p, p+"-moved")
+		}
+	}
+	close(stop)
+	wg.Wait()
+
+	favs := md.GetFavorites()
+	favs[0].Title = "changed by reader"
+	for _, f := range md.GetFavorites() {
+		if strings.HasPrefix(f.Title, "changed by") {
+			t.Errorf("favorite %s shares memory with a caller: %q", f.Path, f.Title)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+// file_refs.go
+package main
+
+import (
+	"regexp"
+	"sort"
+	"sync"
+)
+
+// fileRefPattern находит ссылки на загруженные файлы вида /api/file/<hash>
+var fileRefPattern = regexp.MustCompile(`/api/file/([A-Za-z0-9_-]+(?:\.[A-Za-z0-9]+)?)`)
+
+// FileReferences хранит связи загруженных файлов с документами, которые на них ссылаются.
+// Обновляется при индексации документов, поэтому всегда соответствует их текущему содержимому.
+type FileReferences struct {
+	mu     sync.RWMutex
+	byFile map[string]map[string]bool // файл -> пути документов
+	byDoc  map[string][]string        // путь документа -> файлы
+}
+
+func NewFileReferences() *FileReferences {
+	return &FileReferences{
+		byFile: make(map[string]map[string]bool),
+		byDoc:  make(map[string][]string),
+	}
+}
+
+// Update заменяет связи документа на ссылки из его текущего содержимого
+func (fr *FileReferences) Update(docPath, content string) {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	fr.remove(docPath)
+
+	files := extractFileReferences(content)
+	if len(files) == 0 {
+		return
+	}
+	for _, file := range files {
+		if fr.byFile[file] == nil {
+			fr.byFile[file] = make(map[string]bool)
+		}
+		fr.byFile[file][docPath] = true
+	}
+	fr.byDoc[docPath] = files
+}
+
+// Remove удаляет все связи документа
+func (fr *FileReferences) Remove(docPath string) {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	fr.remove(docPath)
+}
+
+func (fr *FileReferences) remove(docPath string) {
+	for _, file := range fr.byDoc[docPath] {
+		delete(fr.byFile[file], docPath)
+		if len(fr.byFile[file]) == 0 {
+			delete(fr.byFile, file)
+		}
+	}
+	delete(fr.byDoc, docPath)
+}
+
+// Documents возвращает отсортированные пути документов, ссылающихся на файл
+func (fr *FileReferences) Documents(file string) []string {
+	fr.mu.RLock()
+	defer fr.mu.RUnlock()
+
+	paths := make([]string, 0, len(fr.byFile[file]))
+	for docPath := range fr.byFile[file] {
+		paths = append(paths, docPath)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Len возвращает число файлов, на которые есть ссылки
+func (fr *FileReferences) Len() int {
+	fr.mu.RLock()
+	defer fr.mu.RUnlock()
+
+	return len(fr.byFile)
+}
+
+// extractFileReferences возвращает уникальные имена файлов, упомянутых в содержимом
+func extractFileReferences(content string) []string {
+	var files []string
+	seen := make(map[string]bool)
+	for _, match := range fileRefPattern.FindAllStringSubmatch(content, -1) {
+		if !seen[match[1]] {
+			seen[match[1]] = true
+			files = append(files, match[1])
+		}
+	}
+	return files
+}
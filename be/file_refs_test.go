@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExtractFileReferences(t *testing.T) {
+	content := "![a](/api/file/abc123.png?size=100x100) and [b](http://localhost:8080/api/file/def_456.pdf)\n" +
+		"again ![a](/api/file/abc123.png)"
+	got := extractFileReferences(content)
+	want := []string{"abc123.png", "def_456.pdf"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("extractFileReferences = %v, want %v", got, want)
+	}
+}
+
+func fileReferencePaths(t *testing.T, h *DocumentHandler, hash string) []string {
+	t.Helper()
+	rec := serve(h.GetFileReferences, "GET", "/api/file/"+hash+"/references", nil, map[string]string{"hash": hash})
+	var resp struct {
+		File      string          `json:"file"`
+		Documents []ShortDocument `json:"documents"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.File != hash {
+		t.Fatalf("file = %q, want %q", resp.File, hash)
+	}
+	return shortPaths(resp.Documents)
+}
+
+func TestFileReferencesFollowDocumentEdits(t *testing.T) {
+	h, _, _ := newTestDocumentHandler(t)
+
+	rec := serve(h.CreateDocument, "POST", "/api/document", strings.NewReader(
+		`{"title":"A","content":"![img](/api/file/abc.png)"}`), nil)
+	var a Document
+	if err := json.NewDecoder(rec.Body).Decode(&a); err != nil {
+		t.Fatal(err)
+	}
+	rec = serve(h.CreateDocument, "POST", "/api/document", strings.NewReader(
+		`{"title":"B","content":"see /api/file/abc.png"}`), nil)
+	var b Document
+	if err := json.NewDecoder(rec.Body).Decode(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := fileReferencePaths(t, h, "abc.png"); !reflect.DeepEqual(got, sortedPaths(a.Path, b.Path)) {
+		t.Fatalf("references after create = %v", got)
+	}
+
+	// Правка убирает ссылку из документа A
+	serve(h.UpdateDocument, "PUT", "/api/document/"+a.Path, strings.NewReader(
+		`{"title":"A","content":"no images","commit_changes":true}`), map[string]string{"rest": a.Path})
+	if got := fileReferencePaths(t, h, "abc.png"); !reflect.DeepEqual(got, []string{b.Path}) {
+		t.Fatalf("references after edit = %v, want [%s]", got, b.Path)
+	}
+
+	serve(h.DeleteDocument, "DELETE", "/api/document/"+b.Path, nil, map[string]string{"rest": b.Path})
+	if got := fileReferencePaths(t, h, "abc.png"); len(got) != 0 {
+		t.Fatalf("references after delete = %v, want none", got)
+	}
+}
+
+func sortedPaths(paths ...string) []string {
+	if paths[0] > paths[1] {
+		paths[0], paths[1] = paths[1], paths[0]
+	}
+	return paths
+}
@@ -0,0 +1,118 @@
+// frontmatter.go
+package main
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const frontMatterDelimiter = "---"
+
+// frontMatter - YAML-блок в начале .md файла с метаданными документа.
+// Блок распознается только при наличии одного из ключей frontMatterKeys,
+// поэтому документы, которые просто начинаются с "---", остаются как есть.
+// Остальные ключи блока сохраняются в Extra, чтобы не терять их при перезаписи.
+type frontMatter struct {
+	Title  string         `yaml:"title,omitempty"`
+	Tags   []string       `yaml:"tags"` // пишется всегда: по нему распознается блок с одними Extra
+	Author string         `yaml:"author,omitempty"`
+	Date   string         `yaml:"date,omitempty"`
+	Extra  map[string]any `yaml:",inline"`
+}
+
+// frontMatterKeys - ключи, по которым блок в начале файла считается frontmatter
+var frontMatterKeys = []string{"title", "tags", "author", "date"}
+
+func (fm frontMatter) isEmpty() bool {
+	return fm.Title == "" && len(fm.Tags) == 0 && fm.Author == "" && fm.Date == "" && len(fm.Extra) == 0
+}
+
+// apply переносит поля frontmatter в документ. Название из frontmatter
+// заменяет взятое из имени файла.
+func (fm frontMatter) apply(doc *Document) {
+	if fm.Title != "" {
+		doc.Title = fm.Title
+	}
+	doc.Tags = fm.Tags
+	doc.Author = fm.Author
+	doc.Date = fm.Date
+}
+
+// splitFrontMatter отделяет frontmatter от тела документа.
+// Если блока нет, он некорректен или в нем нет известных ключей, весь текст
+// считается телом.
+func splitFrontMatter(data string) (frontMatter, string) {
+	var fm frontMatter
+
+	firstLine, rest, ok := strings.Cut(data, "\n")
+	if !ok || strings.TrimRight(firstLine, "\r") != frontMatterDelimiter {
+		return fm, data
+	}
+
+	var block strings.Builder
+	for {
+		line, tail, found := strings.Cut(rest, "\n")
+		if strings.TrimRight(line, "\r") == frontMatterDelimiter {
+			var keys map[string]yaml.Node
+			if err := yaml.Unmarshal([]byte(block.String()), &keys); err != nil {
+				return frontMatter{}, data
+			}
+			if !slices.ContainsFunc(frontMatterKeys, func(key string) bool {
+				_, ok := keys[key]
+				return ok
+			}) {
+				return frontMatter{}, data
+			}
+			if err := yaml.Unmarshal([]byte(block.String()), &fm); err != nil {
+				return frontMatter{}, data
+			}
+			return fm, tail
+		}
+		if !found {
+			return frontMatter{}, data
+		}
+		block.WriteString(line)
+		block.WriteString("\n")
+		rest = tail
+	}
+}
+
+// joinFrontMatter собирает содержимое файла из frontmatter и тела документа
+func joinFrontMatter(fm frontMatter, body string) (string, error) {
+	if fm.isEmpty() {
+		return body, nil
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(frontMatterDelimiter + "\n")
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(fm); err != nil {
+		return "", err
+	}
+	if err := encoder.Close(); err != nil {
+		return "", err
+	}
+	buf.WriteString(frontMatterDelimiter + "\n")
+	buf.WriteString(body)
+
+	return buf.String(), nil
+}
+
+// normalizeTags убирает пустые теги и дубликаты, сохраняя порядок
+func normalizeTags(tags []string) []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		out = append(out, tag)
+	}
+	return out
+}
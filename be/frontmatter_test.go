@@ -0,0 +1,130 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestSplitFrontMatter(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		wantTags []string
+		wantBody string
+	}{
+		{
+			name:     "no front matter",
+			data:     "# Title\ntext",
+			wantBody: "# Title\ntext",
+		},
+		{
+			name:     "tags",
+			data:     "---\ntags:\n  - a\n  - b\n---\nbody",
+			wantTags: []string{"a", "b"},
+			wantBody: "body",
+		},
+		{
+			name:     "thematic break with key value line",
+			data:     "---\nKey: value\n---\nbody",
+			wantBody: "---\nKey: value\n---\nbody",
+		},
+		{
+			name:     "thematic break with text",
+			data:     "---\nsome paragraph\n\nmore text",
+			wantBody: "---\nsome paragraph\n\nmore text",
+		},
+		{
+			name:     "unclosed block",
+			data:     "---\ntags: [a]\nbody",
+			wantBody: "---\ntags: [a]\nbody",
+		},
+		{
+			name:     "invalid yaml",
+			data:     "---\ntags: [a\n---\nbody",
+			wantBody: "---\ntags: [a\n---\nbody",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fm, body := splitFrontMatter(tt.data)
+			if !slices.Equal(fm.Tags, tt.wantTags) {
+				t.Errorf("tags = %v, want %v", fm.Tags, tt.wantTags)
+			}
+			if body != tt.wantBody {
+				t.Errorf("body = %q, want %q", body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestJoinFrontMatterRoundTrip(t *testing.T) {
+	fm, body := splitFrontMatter("---\ntags: [a]\nauthor: me\nlayout: wide\n---\nbody")
+	fm.Tags = nil
+
+	// Без тегов блок с другими ключами все равно должен распознаваться
+	data, err := joinFrontMatter(fm, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fm, body = splitFrontMatter(data)
+	if body != "body" || fm.Author != "me" || fm.Extra["layout"] != "wide" {
+		t.Fatalf("round trip lost data: %q, %+v", body, fm)
+	}
+
+	data, err = joinFrontMatter(frontMatter{}, "plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data != "plain" {
+		t.Fatalf("data = %q, want %q", data, "plain")
+	}
+}
+
+func TestSplitFrontMatterDocumentFields(t *testing.T) {
+	fm, body := splitFrontMatter("---\ntitle: Notes\nauthor: Ann\ndate: 2024-03-01\n---\nbody")
+	if body != "body" {
+		t.Errorf("body = %q", body)
+	}
+	if fm.Title != "Notes" || fm.Author != "Ann" || fm.Date != "2024-03-01" || len(fm.Tags) != 0 {
+		t.Errorf("front matter = %+v", fm)
+	}
+}
+
+func TestFrontMatterFieldsInDocument(t *testing.T) {
+	gs := newTestStorage(t)
+	content := "---\nauthor: Ann\ndate: 2024-03-01\nlayout: wide\n---\ntext"
+	created := mustCreate(t, gs, "", "Doc", content)
+	if created.Content != "text" || created.Author != "Ann" || created.Date != "2024-03-01" {
+		t.Errorf("created = %+v", created)
+	}
+
+	doc, err := gs.GetDocument(created.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Content != "text" || doc.Author != "Ann" || doc.Date != "2024-03-01" {
+		t.Errorf("document = %+v", doc)
+	}
+
+	// Правка содержимого сохраняет блок целиком
+	if _, err := gs.UpdateDocument(doc.Path, doc.Title, "new text", true); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(gs.docsDir, doc.Path, doc.Title+".md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fm, body := splitFrontMatter(string(data))
+	if body != "new text" || fm.Author != "Ann" || fm.Date != "2024-03-01" || fm.Extra["layout"] != "wide" {
+		t.Errorf("file after update = %q", data)
+	}
+
+	// Документы без frontmatter не меняются
+	plain := mustCreate(t, gs, "", "Plain", "# Heading\ntext")
+	if doc, err := gs.GetDocument(plain.Path); err != nil || doc.Content != "# Heading\ntext" || doc.Author != "" {
+		t.Errorf("plain document = %+v, %v", doc, err)
+	}
+}
@@ -0,0 +1,56 @@
+// git_status.go
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+)
+
+// slowStatusThreshold - после этого времени вычисление статуса считается медленным
+const slowStatusThreshold = time.Second
+
+// worktreeStatus возвращает статус рабочего дерева. При заданном statusTTL результат
+// переиспользуется до истечения срока или до записи через хранилище: w.Status()
+// обходит все дерево и на больших репозиториях занимает секунды. Возвращаемую
+// карту нельзя изменять. Для записи нужен свежий статус, там вызывается w.Status().
+func (gs *GitStorage) worktreeStatus() (git.Status, error) {
+	gs.statusMu.Lock()
+	defer gs.statusMu.Unlock()
+
+	if gs.statusTTL > 0 && gs.status != nil && time.Since(gs.statusAt) < gs.statusTTL {
+		return gs.status, nil
+	}
+
+	w, err := gs.repo.Worktree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get worktree: %w", err)
+	}
+
+	start := time.Now()
+	status, err := w.Status()
+	elapsed := time.Since(start)
+	gs.statusCalls++
+	gitStatusDuration.Observe(elapsed.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get git status: %w", err)
+	}
+	if elapsed > slowStatusThreshold {
+		log.Printf("Warning: git status took %v; consider --git-status-ttl or --skip-uncommitted-check", elapsed.Round(time.Millisecond))
+	}
+
+	if gs.statusTTL > 0 {
+		gs.status, gs.statusAt = status, time.Now()
+	}
+	return status, nil
+}
+
+// invalidateStatus сбрасывает закешированный статус после изменения рабочего дерева
+func (gs *GitStorage) invalidateStatus() {
+	gs.statusMu.Lock()
+	defer gs.statusMu.Unlock()
+
+	gs.status = nil
+}
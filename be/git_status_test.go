@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestStatusCacheReusesStatus(t *testing.T) {
+	gs := newTestStorage(t)
+	doc := mustCreate(t, gs, "", "Cached", "text")
+
+	gs.statusCalls = 0
+	for i := 0; i < 5; i++ {
+		if _, err := gs.GetDocument(doc.Path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if gs.statusCalls != 5 {
+		t.Errorf("without cache: status computed %d times, want 5", gs.statusCalls)
+	}
+
+	gs.statusTTL = time.Hour
+	gs.statusCalls = 0
+	for i := 0; i < 5; i++ {
+		if _, err := gs.GetDocument(doc.Path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if gs.statusCalls != 1 {
+		t.Errorf("with cache: status computed %d times, want 1", gs.statusCalls)
+	}
+
+	gs.statusTTL = time.Millisecond
+	time.Sleep(5 * time.Millisecond)
+	if _, err := gs.GetDocument(doc.Path); err != nil {
+		t.Fatal(err)
+	}
+	if gs.statusCalls != 2 {
+		t.Errorf("after expiry: status computed %d times, want 2", gs.statusCalls)
+	}
+}
+
+func TestStatusCacheInvalidatedOnWrite(t *testing.T) {
+	gs := newTestStorage(t)
+	gs.statusTTL = time.Hour
+	doc := mustCreate(t, gs, "", "Draft", "text")
+
+	got, err := gs.GetDocument(doc.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Uncommitted {
+		t.Fatal("freshly committed document reported as uncommitted")
+	}
+
+	// Отложенная правка без коммита должна сразу стать видна
+	if _, err := gs.UpdateDocument(doc.Path, doc.Title, "edited", false); err != nil {
+		t.Fatal(err)
+	}
+	got, err = gs.GetDocument(doc.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Uncommitted {
+		t.Error("cached status hid the uncommitted edit")
+	}
+
+	if _, err := gs.CommitPending("save"); err != nil {
+		t.Fatal(err)
+	}
+	got, err = gs.GetDocument(doc.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Uncommitted {
+		t.Error("cached status still reports the committed edit")
+	}
+}
+
+func TestSkipUncommittedCheck(t *testing.T) {
+	gs := newTestStorage(t)
+	doc := mustCreate(t, gs, "", "Fast", "text")
+	if _, err := gs.UpdateDocument(doc.Path, doc.Title, "edited", false); err != nil {
+		t.Fatal(err)
+	}
+
+	gs.skipUncommitted = true
+	gs.statusCalls = 0
+	got, err := gs.GetDocument(doc.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gs.statusCalls != 0 {
+		t.Errorf("status computed %d times with the check disabled", gs.statusCalls)
+	}
+	if got.Uncommitted || got.Content != "edited" {
+		t.Errorf("got uncommitted=%v content=%q", got.Uncommitted, got.Content)
+	}
+}
+
+func BenchmarkGetDocumentStatus(b *testing.B) {
+	for _, ttl := range []time.Duration{0, time.Minute} {
+		b.Run(fmt.Sprintf("ttl=%v", ttl), func(b *testing.B) {
+			gs, err := NewGitStorage(b.TempDir())
+			if err != nil {
+				b.Fatal(err)
+			}
+			var docs []Document
+			for i := 0; i < 50; i++ {
+				doc, err := gs.CreateDocument("", fmt.Sprintf("Doc %d", i), "content")
+				if err != nil {
+					b.Fatal(err)
+				}
+				docs = append(docs, doc)
+			}
+			gs.statusTTL = ttl
+			gs.statusCalls = 0
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := gs.GetDocument(docs[i%len(docs)].Path); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(gs.statusCalls)/float64(b.N), "status-calls/op")
+		})
+	}
+}
@@ -0,0 +1,204 @@
+// gitsync.go
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+)
+
+// remoteName - удаленный репозиторий, с которым синхронизируется хранилище
+const remoteName = "origin"
+
+var (
+	ErrNoRemote        = errors.New("git remote is not configured")
+	ErrRemoteAuth      = errors.New("git remote rejected the credentials")
+	ErrRemoteDiverged  = errors.New("local and remote histories have diverged, resolve the conflict manually")
+	ErrSyncUncommitted = errors.New("uncommitted changes, commit them before syncing")
+)
+
+// SyncResult - итог синхронизации с удаленным репозиторием
+type SyncResult struct {
+	Pulled bool `json:"pulled"` // пришли новые коммиты
+	Pushed bool `json:"pushed"` // отправлены локальные коммиты
+}
+
+// SetRemote настраивает удаленный репозиторий для Pull и Push. Логин и пароль
+// (или токен) нужны для HTTP, пустые - без авторизации.
+func (gs *GitStorage) SetRemote(url, username, password string) error {
+	if existing, err := gs.repo.Remote(remoteName); err == nil {
+		if urls := existing.Config().URLs; len(urls) == 1 && urls[0] == url {
+			gs.setRemoteAuth(username, password)
+			return nil
+		}
+		if err := gs.repo.DeleteRemote(remoteName); err != nil {
+			return fmt.Errorf("failed to replace remote: %w", err)
+		}
+	}
+	if _, err := gs.repo.CreateRemote(&config.RemoteConfig{Name: remoteName, URLs: []string{url}}); err != nil {
+		return fmt.Errorf("failed to configure remote: %w", err)
+	}
+	gs.setRemoteAuth(username, password)
+	return nil
+}
+
+func (gs *GitStorage) setRemoteAuth(username, password string) {
+	gs.remoteAuth = nil
+	if username != "" || password != "" {
+		gs.remoteAuth = &githttp.BasicAuth{Username: username, Password: password}
+	}
+}
+
+// currentBranch возвращает ветку HEAD, в пустом репозитории - master
+func (gs *GitStorage) currentBranch() (plumbing.ReferenceName, error) {
+	head, err := gs.repo.Reference(plumbing.HEAD, false)
+	if err != nil {
+		return "", err
+	}
+	if head.Type() == plumbing.SymbolicReference {
+		return head.Target(), nil
+	}
+	return plumbing.Master, nil
+}
+
+// Pull забирает новые коммиты удаленного репозитория. Поддерживается только
+// перемотка вперед: при разошедшейся истории возвращается ErrRemoteDiverged.
+func (gs *GitStorage) Pull() (bool, error) {
+	defer gs.invalidateStatus()
+
+	if _, err := gs.repo.Remote(remoteName); err != nil {
+		return false, ErrNoRemote
+	}
+	// Отложенные правки коммитятся, иначе перемотка упрется в грязное дерево
+	if err := gs.Flush(); err != nil {
+		return false, err
+	}
+
+	gs.txMu.Lock()
+	defer gs.txMu.Unlock()
+	gs.commitMu.Lock()
+	defer gs.commitMu.Unlock()
+
+	branch, err := gs.currentBranch()
+	if err != nil {
+		return false, err
+	}
+	w, err := gs.repo.Worktree()
+	if err != nil {
+		return false, fmt.Errorf("failed to get worktree: %w", err)
+	}
+	err = w.Pull(&git.PullOptions{
+		RemoteName:    remoteName,
+		ReferenceName: branch,
+		SingleBranch:  true,
+		Auth:          gs.remoteAuth,
+	})
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, git.NoErrAlreadyUpToDate),
+		errors.Is(err, transport.ErrEmptyRemoteRepository),
+		errors.Is(err, plumbing.ErrReferenceNotFound):
+		return false, nil
+	}
+	return false, remoteError("pull", err)
+}
+
+// Push отправляет локальные коммиты текущей ветки в удаленный репозиторий
+func (gs *GitStorage) Push() (bool, error) {
+	if _, err := gs.repo.Remote(remoteName); err != nil {
+		return false, ErrNoRemote
+	}
+
+	gs.commitMu.Lock()
+	defer gs.commitMu.Unlock()
+
+	branch, err := gs.currentBranch()
+	if err != nil {
+		return false, err
+	}
+	if _, err := gs.repo.Reference(branch, false); errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return false, nil // коммитов еще нет
+	}
+	err = gs.repo.Push(&git.PushOptions{
+		RemoteName: remoteName,
+		RefSpecs:   []config.RefSpec{config.RefSpec(branch + ":" + branch)},
+		Auth:       gs.remoteAuth,
+	})
+	if errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return false, nil
+	}
+	if err != nil {
+		return false, remoteError("push", err)
+	}
+	return true, nil
+}
+
+// Sync забирает изменения удаленного репозитория и отправляет локальные
+func (gs *GitStorage) Sync() (SyncResult, error) {
+	var result SyncResult
+	var err error
+	if result.Pulled, err = gs.Pull(); err != nil {
+		return result, err
+	}
+	result.Pushed, err = gs.Push()
+	return result, err
+}
+
+// remoteError переводит типичные ошибки go-git в понятные ошибки синхронизации
+func remoteError(op string, err error) error {
+	switch {
+	case errors.Is(err, transport.ErrAuthenticationRequired), errors.Is(err, transport.ErrAuthorizationFailed):
+		return fmt.Errorf("%s: %w: %v", op, ErrRemoteAuth, err)
+	case errors.Is(err, git.ErrUnstagedChanges), errors.Is(err, git.ErrWorktreeNotClean):
+		return fmt.Errorf("%s: %w", op, ErrSyncUncommitted)
+	case errors.Is(err, git.ErrNonFastForwardUpdate), errors.Is(err, git.ErrForceNeeded),
+		strings.Contains(err.Error(), "non-fast-forward"):
+		return fmt.Errorf("%s: %w: %v", op, ErrRemoteDiverged, err)
+	}
+	return fmt.Errorf("%s failed: %w", op, err)
+}
+
+// SyncGit синхронизирует хранилище с удаленным репозиторием: сначала pull, потом
+// push. Если пришли новые коммиты, поисковый индекс перестраивается.
+func (h *DocumentHandler) SyncGit(w http.ResponseWriter, r *http.Request) {
+	gitStorage, ok := h.storage.(*GitStorage)
+	if !ok {
+		http.Error(w, "sync only available with git storage", http.StatusNotImplemented)
+		return
+	}
+
+	result, err := gitStorage.Sync()
+	if result.Pulled {
+		if engine, ok := h.search.(*SearchEngine); ok {
+			if _, err := engine.Rebuild(h.storage); err != nil {
+				log.Printf("Warning: failed to reindex after pull: %v", err)
+			}
+		}
+		// Pull мог удалить документы из избранного и истории просмотров
+		h.meta.PruneMissing(documentExists(h.storage))
+	}
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, ErrNoRemote):
+			status = http.StatusPreconditionFailed
+		case errors.Is(err, ErrRemoteAuth):
+			status = http.StatusBadGateway
+		case errors.Is(err, ErrRemoteDiverged), errors.Is(err, ErrSyncUncommitted):
+			status = http.StatusConflict
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	writeJSON(w, r, result)
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+)
+
+func syncVia(t *testing.T, h *DocumentHandler) (int, SyncResult, string) {
+	t.Helper()
+	rec := serve(h.SyncGit, "POST", "/api/git/sync", nil, nil)
+	var result SyncResult
+	if rec.Code == http.StatusOK {
+		if err := json.NewDecoder(rec.Body).Decode(&result); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return rec.Code, result, rec.Body.String()
+}
+
+func TestSyncGit(t *testing.T) {
+	remote := t.TempDir()
+	if _, err := git.PlainInit(remote, true); err != nil {
+		t.Fatal(err)
+	}
+
+	hA, a, _ := newTestDocumentHandler(t)
+	if code, _, body := syncVia(t, hA); code != http.StatusPreconditionFailed {
+		t.Fatalf("sync without remote: status = %d, body %s", code, body)
+	}
+
+	hB, b, engineB := newTestDocumentHandler(t)
+	for _, gs := range []*GitStorage{a, b} {
+		if err := gs.SetRemote(remote, "", ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Повторная настройка с тем же адресом ничего не ломает
+	if err := a.SetRemote(remote, "", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	doc := mustCreate(t, a, "", "Shared", "zebra")
+	if code, result, body := syncVia(t, hA); code != http.StatusOK || result.Pulled || !result.Pushed {
+		t.Fatalf("first push: status = %d, result = %+v, body %s", code, result, body)
+	}
+
+	// Второй экземпляр получает документ и находит его поиском
+	if code, result, body := syncVia(t, hB); code != http.StatusOK || !result.Pulled || result.Pushed {
+		t.Fatalf("pull: status = %d, result = %+v, body %s", code, result, body)
+	}
+	if got, err := b.GetDocument(doc.Path); err != nil || got.Content != "zebra" {
+		t.Fatalf("pulled document = %+v, %v", got, err)
+	}
+	if docs, _, _ := engineB.Search("zebra", 1, 10); len(docs) != 1 {
+		t.Errorf("pulled document not indexed: %+v", docs)
+	}
+	if code, result, _ := syncVia(t, hB); code != http.StatusOK || result.Pulled || result.Pushed {
+		t.Errorf("sync without changes: status = %d, result = %+v", code, result)
+	}
+
+	// Правка на B уходит в A
+	if _, err := b.UpdateDocument(doc.Path, "Shared", "yak", true); err != nil {
+		t.Fatal(err)
+	}
+	if code, result, _ := syncVia(t, hB); code != http.StatusOK || !result.Pushed {
+		t.Fatalf("push from B: status = %d, result = %+v", code, result)
+	}
+	if code, result, _ := syncVia(t, hA); code != http.StatusOK || !result.Pulled {
+		t.Fatalf("pull into A: status = %d, result = %+v", code, result)
+	}
+	if got, _ := a.GetDocument(doc.Path); got.Content != "yak" {
+		t.Errorf("A content = %q, want yak", got.Content)
+	}
+
+	// Разошедшаяся история - конфликт с понятной ошибкой
+	mustCreate(t, a, "", "From A", "")
+	if code, _, _ := syncVia(t, hA); code != http.StatusOK {
+		t.Fatalf("push from A: status = %d", code)
+	}
+	mustCreate(t, b, "", "From B", "")
+	if code, _, body := syncVia(t, hB); code != http.StatusConflict || !strings.Contains(body, "diverged") {
+		t.Errorf("diverged sync: status = %d, body %s", code, body)
+	}
+}
+
+func TestRemoteError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want error
+	}{
+		{transport.ErrAuthenticationRequired, ErrRemoteAuth},
+		{transport.ErrAuthorizationFailed, ErrRemoteAuth},
+		{git.ErrNonFastForwardUpdate, ErrRemoteDiverged},
+		{errors.New("non-fast-forward update: refs/heads/master"), ErrRemoteDiverged},
+		{git.ErrUnstagedChanges, ErrSyncUncommitted},
+	}
+	for _, tt := range tests {
+		if err := remoteError("sync", tt.err); !errors.Is(err, tt.want) {
+			t.Errorf("remoteError(%v) = %v, want %v", tt.err, err, tt.want)
+		}
+	}
+}
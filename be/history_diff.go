@@ -0,0 +1,222 @@
+// history_diff.go
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/utils/diff"
+	"github.com/gorilla/mux"
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+var ErrInvalidCommit = errors.New("invalid commit hash")
+
+// diffContextLines - число строк контекста вокруг изменений, как у git diff
+const diffContextLines = 3
+
+// DocumentDiff - изменения .md файла документа между двумя версиями
+type DocumentDiff struct {
+	From    string `json:"from"`
+	To      string `json:"to"` // пусто - рабочая копия
+	Diff    string `json:"diff"`
+	Added   int    `json:"added"`
+	Deleted int    `json:"deleted"`
+}
+
+// DiffDocument сравнивает .md файл документа в коммитах from и to, пустой to -
+// рабочая копия. Если в одной из версий документа нет, она считается пустой.
+func (gs *GitStorage) DiffDocument(docPath, from, to string) (DocumentDiff, error) {
+	docPath = strings.Trim(docPath, "/")
+	fromName, fromContent, fromOK, err := gs.documentFileAt(docPath, from)
+	if err != nil {
+		return DocumentDiff{}, err
+	}
+
+	var toName, toContent string
+	toOK := false
+	if to == "" {
+		if title, err := gs.getTitle(docPath); err == nil {
+			data, err := os.ReadFile(filepath.Join(gs.fullPath(docPath), title+".md"))
+			if err != nil {
+				return DocumentDiff{}, err
+			}
+			toName, toContent, toOK = title+".md", string(data), true
+		}
+	} else if toName, toContent, toOK, err = gs.documentFileAt(docPath, to); err != nil {
+		return DocumentDiff{}, err
+	}
+
+	if !fromOK && !toOK {
+		return DocumentDiff{}, fmt.Errorf("%w: %s", ErrDocumentNotFound, docPath)
+	}
+
+	fromLabel, toLabel := "/dev/null", "/dev/null"
+	if fromOK {
+		fromLabel = "a/" + path.Join(docPath, fromName)
+	}
+	if toOK {
+		toLabel = "b/" + path.Join(docPath, toName)
+	}
+	text, added, deleted := unifiedDiff(fromLabel, toLabel, fromContent, toContent)
+	return DocumentDiff{From: from, To: to, Diff: text, Added: added, Deleted: deleted}, nil
+}
+
+// documentFileAt возвращает имя и содержимое .md файла документа в коммите,
+// ok=false - в этом коммите документа нет
+func (gs *GitStorage) documentFileAt(docPath, commitID string) (name, content string, ok bool, err error) {
+	if !plumbing.IsHash(commitID) {
+		return "", "", false, fmt.Errorf("%w: %q", ErrInvalidCommit, commitID)
+	}
+	commit, err := gs.repo.CommitObject(plumbing.NewHash(commitID))
+	if err != nil {
+		return "", "", false, fmt.Errorf("commit not found: %w", err)
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return "", "", false, fmt.Errorf("failed to get commit tree: %w", err)
+	}
+
+	subTree, err := tree.Tree(path.Join("docs", docPath))
+	if errors.Is(err, object.ErrDirectoryNotFound) {
+		return "", "", false, nil
+	}
+	if err != nil {
+		return "", "", false, fmt.Errorf("failed to get document subtree: %w", err)
+	}
+	for _, entry := range subTree.Entries {
+		if entry.Mode.IsFile() && strings.HasSuffix(entry.Name, ".md") {
+			file, err := subTree.TreeEntryFile(&entry)
+			if err != nil {
+				return "", "", false, err
+			}
+			content, err := file.Contents()
+			return entry.Name, content, err == nil, err
+		}
+	}
+	return "", "", false, nil
+}
+
+type diffLine struct {
+	op   byte // ' ', '-' или '+'
+	text string
+}
+
+// unifiedDiff строит построчный unified diff с заголовками файлов и считает
+// добавленные и удаленные строки. Для одинакового содержимого diff пустой.
+func unifiedDiff(fromLabel, toLabel, from, to string) (string, int, int) {
+	var lines []diffLine
+	added, deleted := 0, 0
+	for _, d := range diff.Do(from, to) {
+		op := byte(' ')
+		switch d.Type {
+		case diffmatchpatch.DiffInsert:
+			op = '+'
+		case diffmatchpatch.DiffDelete:
+			op = '-'
+		}
+		for _, text := range strings.SplitAfter(d.Text, "\n") {
+			if text == "" {
+				continue
+			}
+			lines = append(lines, diffLine{op: op, text: text})
+			switch op {
+			case '+':
+				added++
+			case '-':
+				deleted++
+			}
+		}
+	}
+	if added == 0 && deleted == 0 {
+		return "", 0, 0
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", fromLabel, toLabel)
+
+	// Номера строк старой и новой версии перед lines[i]
+	oldLine, newLine := make([]int, len(lines)+1), make([]int, len(lines)+1)
+	for i, l := range lines {
+		oldLine[i+1], newLine[i+1] = oldLine[i], newLine[i]
+		if l.op != '+' {
+			oldLine[i+1]++
+		}
+		if l.op != '-' {
+			newLine[i+1]++
+		}
+	}
+
+	for i := 0; i < len(lines); {
+		if lines[i].op == ' ' {
+			i++
+			continue
+		}
+		// Фрагмент тянется, пока между изменениями не больше 2*diffContextLines строк контекста
+		start := max(i-diffContextLines, 0)
+		end := i
+		for j := i; j < len(lines) && j-end <= 2*diffContextLines; j++ {
+			if lines[j].op != ' ' {
+				end = j
+			}
+		}
+		end = min(end+1+diffContextLines, len(lines))
+
+		oldStart, oldCount := oldLine[start], oldLine[end]-oldLine[start]
+		newStart, newCount := newLine[start], newLine[end]-newLine[start]
+		if oldCount > 0 {
+			oldStart++
+		}
+		if newCount > 0 {
+			newStart++
+		}
+		fmt.Fprintf(&b, "@@ -%d,%d +%d,%d @@\n", oldStart, oldCount, newStart, newCount)
+		for _, l := range lines[start:end] {
+			b.WriteByte(l.op)
+			b.WriteString(l.text)
+			if !strings.HasSuffix(l.text, "\n") {
+				b.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		i = end
+	}
+	return b.String(), added, deleted
+}
+
+// GetDocumentDiff возвращает unified diff документа между коммитами from и to
+// (без to - рабочая копия)
+func (h *DocumentHandler) GetDocumentDiff(w http.ResponseWriter, r *http.Request) {
+	gitStorage, ok := h.storage.(*GitStorage)
+	if !ok {
+		http.Error(w, "history feature only available with git storage", http.StatusNotImplemented)
+		return
+	}
+
+	from := r.URL.Query().Get("from")
+	if from == "" {
+		http.Error(w, "query parameter 'from' is required", http.StatusBadRequest)
+		return
+	}
+
+	result, err := gitStorage.DiffDocument(mux.Vars(r)["rest"], from, r.URL.Query().Get("to"))
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, ErrInvalidCommit):
+			status = http.StatusBadRequest
+		case errors.Is(err, ErrDocumentNotFound), errors.Is(err, plumbing.ErrObjectNotFound):
+			status = http.StatusNotFound
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	writeJSON(w, r, result)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	from := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm\nn\n"
+	to := "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm\nn\nadded"
+	got, added, deleted := unifiedDiff("a/x.md", "b/x.md", from, to)
+	want := "--- a/x.md\n+++ b/x.md\n" +
+		"@@ -1,5 +1,5 @@\n a\n-b\n+B\n c\n d\n e\n" +
+		"@@ -12,3 +12,4 @@\n l\n m\n n\n+added\n\\ No newline at end of file\n"
+	if got != want {
+		t.Errorf("diff =\n%s\nwant\n%s", got, want)
+	}
+	if added != 2 || deleted != 1 {
+		t.Errorf("added, deleted = %d, %d", added, deleted)
+	}
+
+	if got, added, deleted := unifiedDiff("a", "b", "same\n", "same\n"); got != "" || added != 0 || deleted != 0 {
+		t.Errorf("diff of equal content = %q, %d, %d", got, added, deleted)
+	}
+	got, added, _ = unifiedDiff("/dev/null", "b/x.md", "", "one\ntwo\n")
+	if !strings.Contains(got, "@@ -0,0 +1,2 @@\n+one\n+two\n") || added != 2 {
+		t.Errorf("diff of new file = %q", got)
+	}
+}
+
+func TestGetDocumentDiff(t *testing.T) {
+	h, gs, _ := newTestDocumentHandler(t)
+	doc := mustCreate(t, gs, "", "Doc", "first\nsecond\n")
+	head := func() string {
+		ref, err := gs.repo.Head()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ref.Hash().String()
+	}
+	first := head()
+	if _, err := gs.UpdateDocument(doc.Path, "Doc", "first\nchanged\n", true); err != nil {
+		t.Fatal(err)
+	}
+	second := head()
+	if _, err := gs.UpdateDocument(doc.Path, "Doc", "first\nchanged\nuncommitted\n", false); err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(query string) (int, DocumentDiff) {
+		t.Helper()
+		rec := serve(h.GetDocumentDiff, "GET", "/api/history/diff/"+doc.Path+query, nil, map[string]string{"rest": doc.Path})
+		var result DocumentDiff
+		if rec.Code == http.StatusOK {
+			if err := json.NewDecoder(rec.Body).Decode(&result); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return rec.Code, result
+	}
+
+	code, result := get("?from=" + first + "&to=" + second)
+	if code != http.StatusOK {
+		t.Fatalf("status = %d", code)
+	}
+	if result.Added != 1 || result.Deleted != 1 || !strings.Contains(result.Diff, "-second\n+changed\n") ||
+		!strings.Contains(result.Diff, "--- a/"+doc.Path+"/Doc.md\n") {
+		t.Errorf("diff between commits = %+v", result)
+	}
+
+	// Без to - сравнение с рабочей копией
+	code, result = get("?from=" + second)
+	if code != http.StatusOK || result.Added != 1 || result.Deleted != 0 || !strings.Contains(result.Diff, "+uncommitted\n") {
+		t.Errorf("diff against working copy = %d, %+v", code, result)
+	}
+
+	for query, want := range map[string]int{
+		"":                                 http.StatusBadRequest,
+		"?from=zzz":                        http.StatusBadRequest,
+		"?from=" + strings.Repeat("0", 40): http.StatusNotFound,
+	} {
+		if code, _ := get(query); code != want {
+			t.Errorf("%q: status = %d, want %d", query, code, want)
+		}
+	}
+}
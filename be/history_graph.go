@@ -0,0 +1,174 @@
+// history_graph.go
+package main
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+type GraphCommit struct {
+	CommitHash string    `json:"commitHash"`
+	Parents    []string  `json:"parents"` // Nearest ancestors that also touch the document
+	Date       time.Time `json:"date"`
+	Message    string    `json:"message"`
+	FilePath   string    `json:"filePath"`
+}
+
+type DocumentGraphResponse struct {
+	Commits []GraphCommit `json:"commits"`
+}
+
+// GetDocumentGraph возвращает коммиты документа (с учетом переименований) вместе
+// с родителями, упрощенными до ближайших коммитов, которые тоже меняли документ,
+// как это делает git log --parents для пути.
+func (gs *GitStorage) GetDocumentGraph(ctx context.Context, docPath string) (DocumentGraphResponse, error) {
+	history, err := gs.GetDocumentHistory(ctx, docPath)
+	if err != nil {
+		return DocumentGraphResponse{}, err
+	}
+
+	// Обход истории сравнивает коммит с предыдущим в порядке обхода, а не с родителем,
+	// поэтому на ветвящейся истории перепроверяем каждый коммит по его родителям
+	var candidates []CommitHistory
+	relevant := make(map[plumbing.Hash]bool, len(history.History))
+	for _, c := range history.History {
+		hash := plumbing.NewHash(c.CommitHash)
+		touches, err := gs.commitTouchesDocument(hash, c.FilePath)
+		if err != nil {
+			return DocumentGraphResponse{}, err
+		}
+		if touches {
+			relevant[hash] = true
+			candidates = append(candidates, c)
+		}
+	}
+
+	commits := make([]GraphCommit, 0, len(candidates))
+	for _, c := range candidates {
+		commit, err := gs.repo.CommitObject(plumbing.NewHash(c.CommitHash))
+		if err != nil {
+			return DocumentGraphResponse{}, fmt.Errorf("commit not found: %w", err)
+		}
+
+		parents := []string{}
+		seen := make(map[plumbing.Hash]bool)
+		for _, parent := range commit.ParentHashes {
+			nearest, err := gs.nearestRelevantCommits(ctx, parent, relevant)
+			if err != nil {
+				return DocumentGraphResponse{}, err
+			}
+			for _, hash := range nearest {
+				if !seen[hash] {
+					seen[hash] = true
+					parents = append(parents, hash.String())
+				}
+			}
+		}
+
+		commits = append(commits, GraphCommit{
+			CommitHash: c.CommitHash,
+			Parents:    parents,
+			Date:       c.Date,
+			Message:    c.Message,
+			FilePath:   c.FilePath,
+		})
+	}
+
+	return DocumentGraphResponse{Commits: commits}, nil
+}
+
+// commitTouchesDocument проверяет, что файл документа в коммите отличается от его
+// версии в каждом из родителей (слияние без изменений документа пропускается)
+func (gs *GitStorage) commitTouchesDocument(hash plumbing.Hash, docPath string) (bool, error) {
+	commit, err := gs.repo.CommitObject(hash)
+	if err != nil {
+		return false, fmt.Errorf("commit not found: %w", err)
+	}
+
+	current, err := gs.documentBlobHash(commit, docPath)
+	if err != nil {
+		return false, err
+	}
+
+	for _, parentHash := range commit.ParentHashes {
+		parent, err := gs.repo.CommitObject(parentHash)
+		if err != nil {
+			return false, fmt.Errorf("commit not found: %w", err)
+		}
+		previous, err := gs.documentBlobHash(parent, docPath)
+		if err != nil {
+			return false, err
+		}
+		if previous == current {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// documentBlobHash возвращает хеш .md файла документа в коммите или нулевой хеш
+func (gs *GitStorage) documentBlobHash(commit *object.Commit, docPath string) (plumbing.Hash, error) {
+	tree, err := commit.Tree()
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("failed to get commit tree: %w", err)
+	}
+
+	dir, err := tree.Tree(path.Join("docs", docPath))
+	if err != nil {
+		return plumbing.ZeroHash, nil
+	}
+	for _, entry := range dir.Entries {
+		if entry.Mode.IsFile() && strings.HasSuffix(entry.Name, ".md") {
+			return entry.Hash, nil
+		}
+	}
+
+	return plumbing.ZeroHash, nil
+}
+
+// nearestRelevantCommits ищет ближайших предков start (включая его самого) из relevant.
+// Линейная история проходится без очереди, обход в ширину нужен только на слияниях.
+func (gs *GitStorage) nearestRelevantCommits(ctx context.Context, start plumbing.Hash, relevant map[plumbing.Hash]bool) ([]plumbing.Hash, error) {
+	var found []plumbing.Hash
+	visited := make(map[plumbing.Hash]bool)
+	queue := []plumbing.Hash{start}
+
+	for len(queue) > 0 {
+		hash := queue[0]
+		queue = queue[1:]
+
+		for {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			if visited[hash] {
+				break
+			}
+			visited[hash] = true
+
+			if relevant[hash] {
+				found = append(found, hash)
+				break
+			}
+
+			commit, err := gs.repo.CommitObject(hash)
+			if err != nil {
+				return nil, fmt.Errorf("commit not found: %w", err)
+			}
+			if len(commit.ParentHashes) == 0 {
+				break
+			}
+			queue = append(queue, commit.ParentHashes[1:]...)
+			hash = commit.ParentHashes[0]
+		}
+	}
+
+	return found, nil
+}
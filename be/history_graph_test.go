@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+func commitFile(t *testing.T, gs *GitStorage, relPath, content string, parents ...plumbing.Hash) plumbing.Hash {
+	t.Helper()
+	w, err := gs.repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(gs.baseDir, relPath), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Add(relPath); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := w.Commit("edit "+content, &git.CommitOptions{
+		Author:  &object.Signature{Name: "test", Email: "test@test", When: time.Now()},
+		Parents: parents,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
+
+func headHash(t *testing.T, gs *GitStorage) plumbing.Hash {
+	t.Helper()
+	head, err := gs.repo.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return head.Hash()
+}
+
+func TestGetDocumentGraphWithMerge(t *testing.T) {
+	gs := newTestStorage(t)
+	mustCreate(t, gs, "", "Other", "")
+	a := mustCreate(t, gs, "", "A", "base")
+	base := headHash(t, gs)
+	head, _ := gs.repo.Head()
+	file := "docs/a/A.md"
+
+	x := commitFile(t, gs, file, "x")
+
+	// Параллельная ветка от base
+	w, _ := gs.repo.Worktree()
+	if err := w.Checkout(&git.CheckoutOptions{Hash: base, Force: true}); err != nil {
+		t.Fatal(err)
+	}
+	y := commitFile(t, gs, file, "y")
+	// Коммит, не трогающий документ, должен схлопнуться в графе
+	commitFile(t, gs, "docs/other/Other.md", "unrelated")
+	yTip := headHash(t, gs)
+
+	if err := w.Checkout(&git.CheckoutOptions{Branch: head.Name(), Force: true}); err != nil {
+		t.Fatal(err)
+	}
+	commitFile(t, gs, "docs/other/Other.md", "tip")
+	merge := commitFile(t, gs, file, "merged", headHash(t, gs), yTip)
+
+	graph, err := gs.GetDocumentGraph(context.Background(), a.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parents := make(map[string][]string)
+	for _, c := range graph.Commits {
+		parents[c.CommitHash] = c.Parents
+	}
+
+	assertParents := func(name string, hash plumbing.Hash, want ...plumbing.Hash) {
+		t.Helper()
+		got, ok := parents[hash.String()]
+		if !ok {
+			t.Fatalf("%s commit missing from graph: %v", name, parents)
+		}
+		if len(got) != len(want) {
+			t.Fatalf("%s parents = %v, want %v", name, got, want)
+		}
+		for i := range want {
+			if got[i] != want[i].String() {
+				t.Fatalf("%s parents = %v, want %v", name, got, want)
+			}
+		}
+	}
+
+	assertParents("merge", merge, x, y)
+	assertParents("x", x, base)
+	assertParents("y", y, base)
+	if len(graph.Commits) != 4 {
+		t.Fatalf("graph has %d commits, want 4", len(graph.Commits))
+	}
+}
@@ -0,0 +1,84 @@
+// home.go
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// homeDocument возвращает домашний документ: заданный через API, затем флагом --home,
+// а если они не заданы или документ удален - первый корневой документ
+func (h *DocumentHandler) homeDocument() (Document, error) {
+	for _, homePath := range []string{h.meta.GetHomePath(), h.homePath} {
+		if homePath == "" {
+			continue
+		}
+		doc, err := h.storage.GetDocument(homePath)
+		if err == nil {
+			return doc, nil
+		}
+		if !errors.Is(err, ErrDocumentNotFound) {
+			return Document{}, err
+		}
+		log.Printf("Warning: home document %q not found, falling back", homePath)
+	}
+
+	roots, err := h.storage.GetRootDocuments()
+	if err != nil {
+		return Document{}, err
+	}
+	if len(roots) == 0 {
+		return Document{}, ErrDocumentNotFound
+	}
+	return h.storage.GetDocument(roots[0].Path)
+}
+
+// GetHome отдает домашний документ, чтобы SPA могло показать его при первой загрузке
+func (h *DocumentHandler) GetHome(w http.ResponseWriter, r *http.Request) {
+	doc, err := h.homeDocument()
+	if errors.Is(err, ErrDocumentNotFound) {
+		http.Error(w, "no documents", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	doc.Favorite = h.meta.IsFavorite(doc.Path)
+	writeJSON(w, r, doc)
+}
+
+// SetHome назначает домашний документ, пустой path сбрасывает назначение
+func (h *DocumentHandler) SetHome(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Path string `json:"path"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req.Path = strings.Trim(req.Path, "/")
+
+	if req.Path != "" {
+		if _, err := h.storage.GetDocument(req.Path); err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, ErrDocumentNotFound) {
+				status = http.StatusNotFound
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+	}
+
+	h.meta.SetHomePath(req.Path)
+	if err := h.meta.SaveOnDisk(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
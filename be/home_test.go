@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestHomeDocument(t *testing.T) {
+	h, gs, _ := newTestDocumentHandler(t)
+
+	getHome := func() Document {
+		t.Helper()
+		rec := serve(h.GetHome, "GET", "/api/home", nil, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET /api/home: status = %d, body %s", rec.Code, rec.Body)
+		}
+		var doc Document
+		if err := json.NewDecoder(rec.Body).Decode(&doc); err != nil {
+			t.Fatal(err)
+		}
+		return doc
+	}
+	setHome := func(path string) int {
+		t.Helper()
+		body, _ := json.Marshal(map[string]string{"path": path})
+		return serve(h.SetHome, "PUT", "/api/home", strings.NewReader(string(body)), nil).Code
+	}
+
+	if rec := serve(h.GetHome, "GET", "/api/home", nil, nil); rec.Code != http.StatusNotFound {
+		t.Fatalf("empty wiki: status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+
+	mustCreate(t, gs, "", "Alpha", "alpha")
+	beta := mustCreate(t, gs, "", "Beta", "beta")
+	child := mustCreate(t, gs, beta.Path, "Welcome", "welcome text")
+
+	roots, err := gs.GetRootDocuments()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc := getHome(); doc.Path != roots[0].Path {
+		t.Errorf("unset home = %q, want first root %q", doc.Path, roots[0].Path)
+	}
+
+	h.homePath = beta.Path
+	if doc := getHome(); doc.Path != beta.Path {
+		t.Errorf("configured home = %q, want %q", doc.Path, beta.Path)
+	}
+
+	if code := setHome(child.Path); code != http.StatusNoContent {
+		t.Fatalf("set home: status = %d", code)
+	}
+	if doc := getHome(); doc.Path != child.Path || doc.Content != "welcome text" {
+		t.Errorf("home = %q (%q), want %q", doc.Path, doc.Content, child.Path)
+	}
+
+	// Назначение сохраняется в файле метаданных
+	saved, err := loadMetadata(h.meta.Filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.HomePath != child.Path {
+		t.Errorf("persisted home = %q, want %q", saved.HomePath, child.Path)
+	}
+
+	if code := setHome("missing"); code != http.StatusNotFound {
+		t.Errorf("set missing home: status = %d, want %d", code, http.StatusNotFound)
+	}
+	if rec := serve(h.SetHome, "PUT", "/api/home", strings.NewReader("{"), nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("bad body: status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+
+	// Сброс возвращает к документу из флага, а затем к первому корневому
+	if code := setHome(""); code != http.StatusNoContent {
+		t.Fatalf("reset home: status = %d", code)
+	}
+	if doc := getHome(); doc.Path != beta.Path {
+		t.Errorf("after reset home = %q, want %q", doc.Path, beta.Path)
+	}
+	h.homePath = ""
+	if doc := getHome(); doc.Path != roots[0].Path {
+		t.Errorf("after reset home = %q, want %q", doc.Path, roots[0].Path)
+	}
+}
+
+func TestHomeDocumentDeletedFallsBack(t *testing.T) {
+	h, gs, _ := newTestDocumentHandler(t)
+	first := mustCreate(t, gs, "", "First", "first")
+	second := mustCreate(t, gs, "", "Second", "second")
+
+	h.meta.SetHomePath(second.Path)
+	if err := gs.DeleteDocument(second.Path); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := serve(h.GetHome, "GET", "/api/home", nil, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+	var doc Document
+	if err := json.NewDecoder(rec.Body).Decode(&doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Path != first.Path {
+		t.Errorf("home = %q, want %q", doc.Path, first.Path)
+	}
+}
@@ -0,0 +1,276 @@
+// import.go
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+var ErrImportSource = errors.New("cannot read import source")
+
+// ImportReport - результат импорта репозитория
+type ImportReport struct {
+	SourceCommit string         `json:"sourceCommit"`     // коммит исходного репозитория
+	Commit       string         `json:"commit,omitempty"` // коммит импорта, пусто - ничего не импортировано
+	Imported     []ImportedFile `json:"imported"`
+	Skipped      []SkippedFile  `json:"skipped"`
+}
+
+// ImportedFile - файл или каталог исходного репозитория и созданный из него документ
+type ImportedFile struct {
+	Source string `json:"source"`
+	Path   string `json:"path"`
+}
+
+// SkippedFile - файл, который не удалось перенести в дерево документов
+type SkippedFile struct {
+	Source string `json:"source"`
+	Reason string `json:"reason"`
+}
+
+// Файлы, содержимое которых становится содержимым документа каталога
+var importIndexFiles = []string{"index.md", "readme.md"}
+
+// ImportRepository переносит markdown-файлы из HEAD git-репозитория source
+// (или его подкаталога subtree) в документ target одним коммитом.
+// Файл name.md становится документом name, каталог - документом с потомками,
+// содержимое которого берется из его index.md, README.md или соседнего name.md.
+// Остальные файлы попадают в Skipped. Если коммит не удался, созданные документы удаляются.
+func (gs *GitStorage) ImportRepository(source, subtree, target string) (ImportReport, error) {
+	gs.txMu.Lock()
+	defer gs.txMu.Unlock()
+	defer gs.invalidateStatus()
+
+	report := ImportReport{Imported: []ImportedFile{}, Skipped: []SkippedFile{}}
+
+	repo, err := git.PlainOpen(source)
+	if err != nil {
+		return report, fmt.Errorf("%w: %v", ErrImportSource, err)
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return report, fmt.Errorf("%w: %v", ErrImportSource, err)
+	}
+	commit, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return report, fmt.Errorf("%w: %v", ErrImportSource, err)
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return report, fmt.Errorf("%w: %v", ErrImportSource, err)
+	}
+	subtree = strings.Trim(subtree, "/")
+	if subtree != "" {
+		if tree, err = tree.Tree(subtree); err != nil {
+			return report, fmt.Errorf("%w: subtree %q: %v", ErrImportSource, subtree, err)
+		}
+	}
+	report.SourceCommit = head.Hash().String()
+
+	if err := gs.checkTxPath(target); err != nil {
+		return report, err
+	}
+	if _, err := os.Stat(gs.fullPath(target)); os.IsNotExist(err) {
+		return report, fmt.Errorf("%w: %s", ErrParentNotFound, target)
+	}
+
+	created, err := gs.importTree(tree, subtree, target, "", &report)
+	rollback := func() {
+		for _, docPath := range created {
+			if err := os.RemoveAll(gs.fullPath(docPath)); err != nil {
+				log.Printf("Warning: failed to roll back import of %s: %v", docPath, err)
+			}
+		}
+	}
+	if err != nil {
+		rollback()
+		return report, err
+	}
+	if len(report.Imported) == 0 {
+		return report, nil
+	}
+
+	message := fmt.Sprintf("Import %d documents from %s at %s", len(report.Imported), source, head.Hash().String()[:7])
+	if subtree != "" {
+		message = fmt.Sprintf("Import %d documents from %s/%s at %s", len(report.Imported), source, subtree, head.Hash().String()[:7])
+	}
+	if err := gs.commitChanges(message, treeScope(created...)); err != nil {
+		rollback()
+		return report, fmt.Errorf("failed to commit changes, import was rolled back: %w", err)
+	}
+	if ref, err := gs.repo.Head(); err == nil {
+		report.Commit = ref.Hash().String()
+	}
+
+	return report, nil
+}
+
+// importTree создает документы для содержимого каталога репозитория и возвращает
+// пути созданных документов для отката. Файл skip уже стал содержимым родителя
+func (gs *GitStorage) importTree(tree *object.Tree, srcDir, parentPath, skip string, report *ImportReport) ([]string, error) {
+	var created []string
+	dirs := make(map[string]bool)
+	for _, entry := range tree.Entries {
+		if entry.Mode == filemode.Dir {
+			dirs[entry.Name] = true
+		}
+	}
+
+	for _, entry := range tree.Entries {
+		src := path.Join(srcDir, entry.Name)
+		if entry.Name == skip || strings.HasPrefix(entry.Name, ".") {
+			continue // .git, .github и прочие служебные файлы
+		}
+
+		switch {
+		case entry.Mode == filemode.Dir:
+			subtree, err := tree.Tree(entry.Name)
+			if err != nil {
+				return created, err
+			}
+			// Содержимое каталога - его index.md или README.md, иначе соседний name.md
+			indexFile := importIndexFile(subtree)
+			contentTree, contentFile := subtree, indexFile
+			if indexFile == "" {
+				contentTree, contentFile = tree, entry.Name+".md"
+			}
+			content, err := importFileContent(contentTree, contentFile)
+			if err != nil {
+				return created, err
+			}
+
+			doc, err := gs.createDocument(parentPath, entry.Name, content)
+			if err != nil {
+				report.Skipped = append(report.Skipped, SkippedFile{Source: src, Reason: err.Error() + "; directory contents were not imported"})
+				continue
+			}
+			created = append(created, doc.Path)
+			report.Imported = append(report.Imported, ImportedFile{Source: src, Path: doc.Path})
+
+			if _, err := gs.importTree(subtree, src, doc.Path, indexFile, report); err != nil {
+				return created, err
+			}
+
+		case entry.Mode == filemode.Submodule:
+			report.Skipped = append(report.Skipped, SkippedFile{Source: src, Reason: "submodules are not supported"})
+
+		case entry.Mode == filemode.Symlink:
+			report.Skipped = append(report.Skipped, SkippedFile{Source: src, Reason: "symbolic links are not supported"})
+
+		case entry.Mode.IsFile() && strings.EqualFold(path.Ext(entry.Name), ".md"):
+			title := strings.TrimSuffix(entry.Name, path.Ext(entry.Name))
+			if dirs[title] {
+				subtree, err := tree.Tree(title)
+				if err != nil {
+					return created, err
+				}
+				if importIndexFile(subtree) != "" {
+					report.Skipped = append(report.Skipped, SkippedFile{Source: src, Reason: "directory " + title + " has its own index file"})
+				}
+				continue // иначе это содержимое каталога с тем же именем
+			}
+			content, err := importFileContent(tree, entry.Name)
+			if err != nil {
+				return created, err
+			}
+			doc, err := gs.createDocument(parentPath, title, content)
+			if err != nil {
+				report.Skipped = append(report.Skipped, SkippedFile{Source: src, Reason: err.Error()})
+				continue
+			}
+			created = append(created, doc.Path)
+			report.Imported = append(report.Imported, ImportedFile{Source: src, Path: doc.Path})
+
+		default:
+			report.Skipped = append(report.Skipped, SkippedFile{Source: src, Reason: "not a markdown file"})
+		}
+	}
+	return created, nil
+}
+
+// importIndexFile возвращает имя index.md или README.md каталога, пусто - если их нет
+func importIndexFile(tree *object.Tree) string {
+	for _, name := range importIndexFiles {
+		for _, entry := range tree.Entries {
+			if entry.Mode.IsFile() && strings.EqualFold(entry.Name, name) {
+				return entry.Name
+			}
+		}
+	}
+	return ""
+}
+
+// importFileContent читает файл каталога репозитория, отсутствующий файл - пустое содержимое
+func importFileContent(tree *object.Tree, name string) (string, error) {
+	if name == "" {
+		return "", nil
+	}
+	file, err := tree.File(name)
+	if errors.Is(err, object.ErrFileNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return file.Contents()
+}
+
+// ImportRepository импортирует markdown-файлы git-репозитория на сервере
+func (h *AdminHandler) ImportRepository(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Source  string `json:"source"`
+		Subtree string `json:"subtree"`
+		Target  string `json:"target"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Source == "" {
+		http.Error(w, "source is required", http.StatusBadRequest)
+		return
+	}
+
+	gitStorage, ok := h.storage.(*GitStorage)
+	if !ok {
+		http.Error(w, "import only available with git storage", http.StatusNotImplemented)
+		return
+	}
+
+	report, err := gitStorage.ImportRepository(req.Source, req.Subtree, req.Target)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, ErrImportSource), errors.Is(err, ErrInvalidPath):
+			status = http.StatusBadRequest
+		case errors.Is(err, ErrParentNotFound):
+			status = http.StatusNotFound
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	// Изменения уже закоммичены, поэтому ошибки индексации только логируем
+	for _, imported := range report.Imported {
+		doc, err := h.storage.GetDocument(imported.Path)
+		if err != nil {
+			log.Printf("Warning: failed to load imported document %s: %v", imported.Path, err)
+			continue
+		}
+		if err := h.searchEngine.IndexDocument(doc); err != nil {
+			log.Printf("Warning: failed to index %s: %v", imported.Path, err)
+		}
+	}
+
+	writeJSON(w, r, report)
+}
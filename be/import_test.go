@@ -0,0 +1,212 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// newSourceRepo создает git-репозиторий с файлами files одним коммитом
+func newSourceRepo(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		full := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Add("."); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Commit("wiki", &git.CommitOptions{
+		Author: &object.Signature{Name: "Wiki", Email: "wiki@example.com", When: time.Now()},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// Незакоммиченные файлы не импортируются
+	if err := os.WriteFile(filepath.Join(dir, "draft.md"), []byte("draft"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+var sourceWiki = map[string]string{
+	"README.md":                  "# Wiki",
+	"guide.md":                   "guide text",
+	"setup/index.md":             "setup overview",
+	"setup/install.md":           "install steps",
+	"setup/advanced/tuning.md":   "tuning knobs",
+	"api.md":                     "api overview",
+	"api/v1.md":                  "version one",
+	"ops/README.md":              "ops overview",
+	"ops.md":                     "ops duplicate",
+	"images/logo.png":            "png",
+	".github/workflows/ci.yml":   "on: push",
+	"setup/advanced/diagram.svg": "<svg/>",
+}
+
+func TestImportRepository(t *testing.T) {
+	source := newSourceRepo(t, sourceWiki)
+	gs := newTestStorage(t)
+	mustCreate(t, gs, "", "Existing", "")
+	commits := countCommits(t, gs)
+
+	report, err := gs.ImportRepository(source, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := countCommits(t, gs); got != commits+1 {
+		t.Fatalf("commits = %d, want %d", got, commits+1)
+	}
+	if report.Commit == "" || len(report.SourceCommit) != 40 {
+		t.Errorf("report commits = %q, %q", report.Commit, report.SourceCommit)
+	}
+
+	paths := map[string]string{}
+	for _, imported := range report.Imported {
+		paths[imported.Source] = imported.Path
+	}
+	tests := []struct {
+		source, title, content, parent string
+	}{
+		{"README.md", "README", "# Wiki", ""},
+		{"guide.md", "guide", "guide text", ""},
+		{"setup", "setup", "setup overview", ""},
+		{"setup/install.md", "install", "install steps", "setup"},
+		{"setup/advanced", "advanced", "", "setup"},
+		{"setup/advanced/tuning.md", "tuning", "tuning knobs", "setup/advanced"},
+		{"api", "api", "api overview", ""},
+		{"api/v1.md", "v1", "version one", "api"},
+		{"ops", "ops", "ops overview", ""},
+		{"images", "images", "", ""},
+	}
+	if len(report.Imported) != len(tests) {
+		t.Errorf("imported %d documents, want %d: %+v", len(report.Imported), len(tests), report.Imported)
+	}
+	for _, tt := range tests {
+		docPath, ok := paths[tt.source]
+		if !ok {
+			t.Errorf("%s was not imported", tt.source)
+			continue
+		}
+		doc, err := gs.GetDocument(docPath)
+		if err != nil {
+			t.Errorf("%s: %v", docPath, err)
+			continue
+		}
+		if doc.Title != tt.title || doc.Content != tt.content {
+			t.Errorf("%s: title %q, content %q", tt.source, doc.Title, doc.Content)
+		}
+		if parent := path.Dir(docPath); tt.parent == "" && parent != "." || tt.parent != "" && parent != paths[tt.parent] {
+			t.Errorf("%s: parent of %s, want %s", tt.source, docPath, paths[tt.parent])
+		}
+	}
+
+	skipped := map[string]string{}
+	for _, s := range report.Skipped {
+		skipped[s.Source] = s.Reason
+	}
+	for _, source := range []string{"ops.md", "images/logo.png", "setup/advanced/diagram.svg"} {
+		if skipped[source] == "" {
+			t.Errorf("%s is not reported as skipped: %+v", source, report.Skipped)
+		}
+	}
+	if len(skipped) != 3 {
+		t.Errorf("skipped = %+v", report.Skipped)
+	}
+
+	status, err := gs.worktreeStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status.IsClean() {
+		t.Errorf("worktree is dirty after import: %v", status)
+	}
+}
+
+func TestImportRepositorySubtree(t *testing.T) {
+	source := newSourceRepo(t, sourceWiki)
+	_, gs, engine := newTestDocumentHandler(t)
+	target := mustCreate(t, gs, "", "Migrated", "")
+	admin := NewAdminHandler(gs, engine)
+
+	body := `{"source": "` + source + `", "subtree": "setup", "target": "` + target.Path + `"}`
+	rec := serve(admin.ImportRepository, "POST", "/api/admin/import", strings.NewReader(body), nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	var report ImportReport
+	if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Imported) != 4 {
+		t.Fatalf("imported = %+v", report.Imported)
+	}
+	for _, imported := range report.Imported {
+		if !strings.HasPrefix(imported.Path, target.Path+"/") || !strings.HasPrefix(imported.Source, "setup/") {
+			t.Errorf("imported %+v outside of the target", imported)
+		}
+	}
+
+	docs, _, _ := engine.Search("tuning knobs", 1, 10)
+	if len(docs) != 1 {
+		t.Errorf("imported documents are not indexed: %v", docPaths(docs))
+	}
+
+	for body, want := range map[string]int{
+		`{"source": "` + t.TempDir() + `"}`:                  http.StatusBadRequest,
+		`{"source": "` + source + `", "subtree": "missing"}`: http.StatusBadRequest,
+		`{"source": "` + source + `", "target": "missing"}`:  http.StatusNotFound,
+		`{"subtree": "setup"}`:                               http.StatusBadRequest,
+	} {
+		if rec := serve(admin.ImportRepository, "POST", "/api/admin/import", strings.NewReader(body), nil); rec.Code != want {
+			t.Errorf("%s: status %d, want %d", body, rec.Code, want)
+		}
+	}
+}
+
+func TestImportRepositoryCommitsOnlyImportedDocuments(t *testing.T) {
+	source := newSourceRepo(t, sourceWiki)
+	gs := newTestStorage(t)
+	target := mustCreate(t, gs, "", "Migrated", "")
+	// Правка цели ждет пакетной фиксации
+	if _, err := gs.UpdateDocument(target.Path, "Migrated", "pending", false); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := gs.ImportRepository(source, "setup", target.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Imported) == 0 {
+		t.Fatal("nothing imported")
+	}
+	for _, file := range headFiles(t, gs) {
+		if !strings.HasPrefix(file, "docs/"+target.Path+"/") || path.Dir(file) == "docs/"+target.Path {
+			t.Errorf("import commit touches %s outside the imported documents", file)
+		}
+	}
+	if got := dirtyDocs(t, gs); len(got) != 1 || got[0] != "docs/"+target.Path+"/Migrated.md" {
+		t.Errorf("uncommitted documents = %v, want the pending edit of the target", got)
+	}
+}
@@ -0,0 +1,84 @@
+// includes.go
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// maxIncludeDepth ограничивает вложенность {{include:...}}
+const maxIncludeDepth = 5
+
+var includeDirective = regexp.MustCompile(`\{\{include:\s*([^}\s]+)\s*\}\}`)
+
+var (
+	ErrIncludeCycle = errors.New("include cycle detected")
+	ErrIncludeDepth = errors.New("include depth limit exceeded")
+)
+
+// resolveIncludes подставляет вместо {{include:path}} содержимое указанных документов.
+// Директивы внутри блоков кода не трогаются, отсутствующий документ заменяется
+// HTML-комментарием, а цикл или превышение вложенности возвращают ошибку.
+func resolveIncludes(storage Storage, docPath, content string) (string, error) {
+	return includeResolver{storage: storage}.resolve(content, []string{strings.Trim(docPath, "/")})
+}
+
+type includeResolver struct {
+	storage Storage
+}
+
+func (ir includeResolver) resolve(content string, stack []string) (string, error) {
+	lines := strings.Split(content, "\n")
+
+	var fence string
+	for i, line := range lines {
+		if marker := codeFenceMarker(line); marker != "" {
+			if fence == "" {
+				fence = marker
+			} else if strings.HasPrefix(strings.TrimSpace(line), fence) {
+				fence = ""
+			}
+			continue
+		}
+		if fence != "" || !strings.Contains(line, "{{include:") {
+			continue
+		}
+
+		var resolveErr error
+		lines[i] = includeDirective.ReplaceAllStringFunc(line, func(directive string) string {
+			if resolveErr != nil {
+				return directive
+			}
+			target := strings.Trim(includeDirective.FindStringSubmatch(directive)[1], "/")
+
+			for _, p := range stack {
+				if p == target {
+					resolveErr = fmt.Errorf("%w: %s -> %s", ErrIncludeCycle, strings.Join(stack, " -> "), target)
+					return directive
+				}
+			}
+			if len(stack) > maxIncludeDepth {
+				resolveErr = fmt.Errorf("%w: %s", ErrIncludeDepth, strings.Join(stack, " -> "))
+				return directive
+			}
+
+			doc, err := ir.storage.GetDocument(target)
+			if err != nil {
+				return fmt.Sprintf("<!-- include not found: %s -->", target)
+			}
+			included, err := ir.resolve(strings.TrimRight(doc.Content, "\n"), append(stack[:len(stack):len(stack)], target))
+			if err != nil {
+				resolveErr = err
+				return directive
+			}
+			return included
+		})
+		if resolveErr != nil {
+			return "", resolveErr
+		}
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
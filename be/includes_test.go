@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestResolveIncludesSimple(t *testing.T) {
+	_, gs, _ := newTestDocumentHandler(t)
+	snippet := mustCreate(t, gs, "", "Snippet", "shared text")
+	doc := mustCreate(t, gs, "", "Page", "before\n{{include:"+snippet.Path+"}}\nafter")
+
+	got, err := resolveIncludes(gs, doc.Path, doc.Content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "before\nshared text\nafter"; got != want {
+		t.Errorf("resolved = %q, want %q", got, want)
+	}
+}
+
+func TestResolveIncludesNested(t *testing.T) {
+	_, gs, _ := newTestDocumentHandler(t)
+	inner := mustCreate(t, gs, "", "Inner", "inner")
+	middle := mustCreate(t, gs, "", "Middle", "middle [{{include:"+inner.Path+"}}]")
+	doc := mustCreate(t, gs, "", "Outer", "outer: {{include:"+middle.Path+"}}")
+
+	got, err := resolveIncludes(gs, doc.Path, doc.Content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "outer: middle [inner]"; got != want {
+		t.Errorf("resolved = %q, want %q", got, want)
+	}
+}
+
+func TestResolveIncludesCycle(t *testing.T) {
+	_, gs, _ := newTestDocumentHandler(t)
+	a := mustCreate(t, gs, "", "A", "")
+	b := mustCreate(t, gs, "", "B", "{{include:"+a.Path+"}}")
+	if _, err := gs.UpdateDocument(a.Path, "A", "{{include:"+b.Path+"}}", true); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := resolveIncludes(gs, a.Path, "{{include:"+b.Path+"}}")
+	if !errors.Is(err, ErrIncludeCycle) {
+		t.Errorf("err = %v, want ErrIncludeCycle", err)
+	}
+}
+
+func TestResolveIncludesDepthLimit(t *testing.T) {
+	_, gs, _ := newTestDocumentHandler(t)
+	content := "leaf"
+	var last Document
+	for i := 0; i <= maxIncludeDepth+1; i++ {
+		last = mustCreate(t, gs, "", "Level "+strings.Repeat("x", i+1), content)
+		content = "{{include:" + last.Path + "}}"
+	}
+
+	_, err := resolveIncludes(gs, last.Path, last.Content)
+	if !errors.Is(err, ErrIncludeDepth) {
+		t.Errorf("err = %v, want ErrIncludeDepth", err)
+	}
+}
+
+func TestResolveIncludesSkipsCodeAndMissing(t *testing.T) {
+	_, gs, _ := newTestDocumentHandler(t)
+	content := "```\n{{include:nowhere}}\n```\n{{include:nowhere}}"
+
+	got, err := resolveIncludes(gs, "page", content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "```\n{{include:nowhere}}\n```\n<!-- include not found: nowhere -->"; got != want {
+		t.Errorf("resolved = %q, want %q", got, want)
+	}
+}
+
+func TestGetDocumentRenderIncludes(t *testing.T) {
+	h, gs, _ := newTestDocumentHandler(t)
+	snippet := mustCreate(t, gs, "", "Snippet", "shared")
+	raw := "see {{include:" + snippet.Path + "}}"
+	doc := mustCreate(t, gs, "", "Page", raw)
+
+	decode := func(target string) Document {
+		t.Helper()
+		resp := serve(h.GetDocument, "GET", target, nil, map[string]string{"rest": doc.Path})
+		if resp.Code != http.StatusOK {
+			t.Fatalf("GET %s status = %d, body %s", target, resp.Code, resp.Body)
+		}
+		var got Document
+		if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+
+	if got := decode("/api/document/" + doc.Path); got.Content != raw {
+		t.Errorf("raw content = %q, want directives intact %q", got.Content, raw)
+	}
+	if got := decode("/api/document/" + doc.Path + "?render=true"); got.Content != "see shared" {
+		t.Errorf("rendered content = %q, want %q", got.Content, "see shared")
+	}
+
+	self := mustCreate(t, gs, "", "Self", "")
+	if _, err := gs.UpdateDocument(self.Path, "Self", "{{include:"+self.Path+"}}", true); err != nil {
+		t.Fatal(err)
+	}
+	resp := serve(h.GetDocument, "GET", "/api/document/"+self.Path+"?render=true", nil, map[string]string{"rest": self.Path})
+	if resp.Code != http.StatusUnprocessableEntity {
+		t.Errorf("cyclic render status = %d, want 422", resp.Code)
+	}
+}
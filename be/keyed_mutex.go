@@ -0,0 +1,43 @@
+// keyed_mutex.go
+package main
+
+import "sync"
+
+// keyedMutex - набор мьютексов по ключу. Мьютекс создается при первой блокировке
+// и удаляется, когда его больше никто не держит и не ждет.
+type keyedMutex struct {
+	mu    sync.Mutex
+	locks map[string]*refMutex
+}
+
+type refMutex struct {
+	sync.Mutex
+	refs int
+}
+
+// Lock блокирует ключ и возвращает функцию разблокировки
+func (km *keyedMutex) Lock(key string) func() {
+	km.mu.Lock()
+	if km.locks == nil {
+		km.locks = make(map[string]*refMutex)
+	}
+	m, ok := km.locks[key]
+	if !ok {
+		m = &refMutex{}
+		km.locks[key] = m
+	}
+	m.refs++
+	km.mu.Unlock()
+
+	m.Lock()
+	return func() {
+		m.Unlock()
+
+		km.mu.Lock()
+		m.refs--
+		if m.refs == 0 {
+			delete(km.locks, key)
+		}
+		km.mu.Unlock()
+	}
+}
@@ -0,0 +1,95 @@
+// links.go
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// wikiLinkPattern находит ссылки на документы вики вида [текст](/doc/<путь>)
+// или [текст](/document/<путь>) вместе с текстом ссылки
+var wikiLinkPattern = regexp.MustCompile(`\[([^\]]*)\]\(\s*<?/doc(?:ument)?/([^)\s>#?]+)`)
+
+// BrokenLink - ссылка из документа Source на несуществующий документ Target
+type BrokenLink struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Text   string `json:"text"`
+	Line   int    `json:"line"`
+}
+
+// forEachWikiLink вызывает fn для каждой ссылки на документ в содержимом.
+// Ссылки в блоках и фрагментах кода пропускаются, line нумеруется с 1.
+func forEachWikiLink(content string, fn func(line int, text, target string)) {
+	var fence string
+	for i, line := range strings.Split(content, "\n") {
+		if marker := codeFenceMarker(line); marker != "" {
+			if fence == "" {
+				fence = marker
+			} else if strings.HasPrefix(strings.TrimSpace(line), fence) {
+				fence = ""
+			}
+			continue
+		}
+		if fence != "" {
+			continue
+		}
+
+		text := inlineCodeRegex.ReplaceAllString(line, "")
+		for _, m := range wikiLinkPattern.FindAllStringSubmatch(text, -1) {
+			target, err := url.PathUnescape(m[2])
+			if err != nil {
+				target = m[2]
+			}
+			fn(i+1, m[1], strings.Trim(target, "/"))
+		}
+	}
+}
+
+// extractDocumentLinks возвращает уникальные пути документов, на которые ссылается содержимое
+func extractDocumentLinks(content string) []string {
+	var targets []string
+	seen := make(map[string]bool)
+	forEachWikiLink(content, func(_ int, _, target string) {
+		if target != "" && !seen[target] {
+			seen[target] = true
+			targets = append(targets, target)
+		}
+	})
+	return targets
+}
+
+// findBrokenLinks обходит все документы и возвращает ссылки, цель которых
+// не находится в storage
+func findBrokenLinks(storage Storage) ([]BrokenLink, error) {
+	broken := []BrokenLink{}
+	missing := make(map[string]bool)
+	err := walkDocuments(storage, func(doc Document) error {
+		forEachWikiLink(doc.Content, func(line int, text, target string) {
+			gone, checked := missing[target]
+			if !checked {
+				gone = documentMissing(storage, target)
+				missing[target] = gone
+			}
+			if gone {
+				broken = append(broken, BrokenLink{Source: doc.Path, Target: target, Text: text, Line: line})
+			}
+		})
+		return nil
+	})
+	return broken, err
+}
+
+// GetBrokenLinks возвращает ссылки на документы, которых больше нет,
+// например после перемещения или удаления
+func (h *DocumentHandler) GetBrokenLinks(w http.ResponseWriter, r *http.Request) {
+	broken, err := findBrokenLinks(h.storage)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, r, broken)
+}
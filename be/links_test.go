@@ -0,0 +1,35 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestGetBrokenLinks(t *testing.T) {
+	h, gs, _ := newTestDocumentHandler(t)
+	target := mustCreate(t, gs, "", "Target", "")
+	gone := mustCreate(t, gs, "", "Gone", "")
+	parent := mustCreate(t, gs, "", "Parent", "")
+	content := "See [target](/doc/" + target.Path + ") and [old page](/doc/" + gone.Path + ").\n" +
+		"`[code](/doc/missing)`\n" +
+		"```\n[fenced](/document/missing)\n```\n" +
+		"Also [moved](/document/" + parent.Path + "/nowhere#section)"
+	source := mustCreate(t, gs, parent.Path, "Source", content)
+	if err := gs.DeleteDocument(gone.Path); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := serve(h.GetBrokenLinks, "GET", "/api/links/broken", nil, nil)
+	var broken []BrokenLink
+	if err := json.NewDecoder(rec.Body).Decode(&broken); err != nil {
+		t.Fatal(err)
+	}
+	want := []BrokenLink{
+		{Source: source.Path, Target: gone.Path, Text: "old page", Line: 1},
+		{Source: source.Path, Target: parent.Path + "/nowhere", Text: "moved", Line: 6},
+	}
+	if !reflect.DeepEqual(broken, want) {
+		t.Fatalf("broken = %+v, want %+v", broken, want)
+	}
+}
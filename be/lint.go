@@ -0,0 +1,140 @@
+// lint.go
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	LintError   = "error"
+	LintWarning = "warning"
+)
+
+// LintIssue - замечание линтера к строке документа (нумерация с 1)
+type LintIssue struct {
+	Line     int    `json:"line"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+// docLinkPattern находит ссылки на документы вики вида [текст](/doc/<путь>)
+var docLinkPattern = regexp.MustCompile(`\]\(\s*<?/doc/([^)\s>#?]+)`)
+
+// lintMarkdown проверяет разметку: незакрытые блоки кода, синтаксис ссылок,
+// пропуски уровней заголовков, ссылки на несуществующие документы и загрузки.
+// Содержимое блоков и фрагментов кода не проверяется.
+func lintMarkdown(content string, storage Storage, uploadDir string) []LintIssue {
+	issues := []LintIssue{}
+	report := func(line int, severity, format string, args ...any) {
+		issues = append(issues, LintIssue{Line: line, Severity: severity, Message: fmt.Sprintf(format, args...)})
+	}
+
+	var fence string
+	fenceLine := 0
+	prevLevel := 0
+	for i, line := range strings.Split(content, "\n") {
+		n := i + 1
+		line = strings.TrimRight(line, "\r")
+
+		if marker := codeFenceMarker(line); marker != "" {
+			if fence == "" {
+				fence, fenceLine = marker, n
+			} else if strings.HasPrefix(strings.TrimSpace(line), fence) {
+				fence = ""
+			}
+			continue
+		}
+		if fence != "" {
+			continue
+		}
+
+		if match := atxHeadingRegex.FindStringSubmatch(line); match != nil {
+			level := len(match[1])
+			if prevLevel > 0 && level > prevLevel+1 {
+				report(n, LintWarning, "heading level skips from h%d to h%d", prevLevel, level)
+			}
+			prevLevel = level
+		}
+
+		text := inlineCodeRegex.ReplaceAllString(line, "")
+		lintLinkSyntax(text, n, report)
+
+		for _, m := range docLinkPattern.FindAllStringSubmatch(text, -1) {
+			target, err := url.PathUnescape(m[1])
+			if err != nil {
+				target = m[1]
+			}
+			if documentMissing(storage, strings.Trim(target, "/")) {
+				report(n, LintError, "link to non-existent document %q", target)
+			}
+		}
+		for _, m := range includeDirective.FindAllStringSubmatch(text, -1) {
+			if documentMissing(storage, strings.Trim(m[1], "/")) {
+				report(n, LintError, "include of non-existent document %q", m[1])
+			}
+		}
+		for _, file := range extractFileReferences(text) {
+			if _, err := os.Stat(filepath.Join(uploadDir, file)); err != nil {
+				report(n, LintError, "reference to missing upload %q", file)
+			}
+		}
+	}
+
+	if fence != "" {
+		report(fenceLine, LintError, "code fence %s is never closed", fence)
+	}
+	return issues
+}
+
+// lintLinkSyntax ищет ссылки с незакрытыми скобками и пустым адресом
+func lintLinkSyntax(text string, line int, report func(int, string, string, ...any)) {
+	for i := 0; i < len(text); i++ {
+		if !strings.HasPrefix(text[i:], "](") {
+			continue
+		}
+		if !strings.Contains(text[:i], "[") {
+			report(line, LintError, "link destination without link text")
+		}
+		rest := text[i+2:]
+		end := strings.IndexByte(rest, ')')
+		switch {
+		case end < 0:
+			report(line, LintError, "link destination is not closed with )")
+			return
+		case strings.TrimSpace(rest[:end]) == "":
+			report(line, LintWarning, "link has an empty destination")
+		}
+		i += 2 + end
+	}
+}
+
+// documentMissing сообщает, что документа точно нет; прочие ошибки хранилища
+// не считаются замечанием к тексту
+func documentMissing(storage Storage, docPath string) bool {
+	if docPath == "" {
+		return true
+	}
+	_, err := storage.GetDocument(docPath)
+	return errors.Is(err, ErrDocumentNotFound)
+}
+
+// LintDocument проверяет markdown перед сохранением и возвращает список замечаний
+func (h *DocumentHandler) LintDocument(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Content string `json:"content"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, r, lintMarkdown(req.Content, h.storage, h.uploadDir))
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func lint(t *testing.T, h *DocumentHandler, content string) []LintIssue {
+	t.Helper()
+	body, _ := json.Marshal(map[string]string{"content": content})
+	rec := serve(h.LintDocument, "POST", "/api/lint", strings.NewReader(string(body)), nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+	var issues []LintIssue
+	if err := json.NewDecoder(rec.Body).Decode(&issues); err != nil {
+		t.Fatal(err)
+	}
+	return issues
+}
+
+func TestLintUnclosedCodeFence(t *testing.T) {
+	h, _, _ := newTestDocumentHandler(t)
+	content := "# Title\n\n```go\nfunc main() {\n## not a heading\n[broken](\n"
+
+	want := []LintIssue{{Line: 3, Severity: LintError, Message: "code fence ``` is never closed"}}
+	if got := lint(t, h, content); !reflect.DeepEqual(got, want) {
+		t.Errorf("issues = %+v, want %+v", got, want)
+	}
+}
+
+func TestLintDanglingInternalLink(t *testing.T) {
+	h, gs, _ := newTestDocumentHandler(t)
+	h.uploadDir = t.TempDir()
+	target := mustCreate(t, gs, "", "Target", "text")
+	if err := os.WriteFile(filepath.Join(h.uploadDir, "abc123.png"), []byte("png"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	content := strings.Join([]string{
+		"See [target](/doc/" + target.Path + "#intro) and [gone](/doc/missing/page).",
+		"![ok](/api/file/abc123.png) ![lost](/api/file/nothere.png)",
+		"{{include: missing}}",
+		"Inline `[not](/doc/checked)` code is ignored.",
+	}, "\n")
+
+	want := []LintIssue{
+		{Line: 1, Severity: LintError, Message: `link to non-existent document "missing/page"`},
+		{Line: 2, Severity: LintError, Message: `reference to missing upload "nothere.png"`},
+		{Line: 3, Severity: LintError, Message: `include of non-existent document "missing"`},
+	}
+	if got := lint(t, h, content); !reflect.DeepEqual(got, want) {
+		t.Errorf("issues = %+v, want %+v", got, want)
+	}
+}
+
+func TestLintHeadingsAndLinkSyntax(t *testing.T) {
+	h, _, _ := newTestDocumentHandler(t)
+	content := strings.Join([]string{
+		"# Title",
+		"### Skipped",
+		"## Back",
+		"### Fine",
+		"[open](http://example.com",
+		"[empty]() and [good](http://example.com)",
+	}, "\n")
+
+	want := []LintIssue{
+		{Line: 2, Severity: LintWarning, Message: "heading level skips from h1 to h3"},
+		{Line: 5, Severity: LintError, Message: "link destination is not closed with )"},
+		{Line: 6, Severity: LintWarning, Message: "link has an empty destination"},
+	}
+	if got := lint(t, h, content); !reflect.DeepEqual(got, want) {
+		t.Errorf("issues = %+v, want %+v", got, want)
+	}
+
+	if got := lint(t, h, "# Clean\n\nText with [a link](http://example.com).\n"); len(got) != 0 {
+		t.Errorf("clean document: %+v", got)
+	}
+}
@@ -2,11 +2,13 @@
 package main
 
 import (
+	"context"
 	"crypto/sha256"
 	"embed"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
 	"github.com/disintegration/imaging"
 	"github.com/gorilla/mux"
@@ -19,12 +21,15 @@ import (
 	"log"
 	"mime"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
 	"path"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 )
@@ -33,11 +38,15 @@ type Document struct {
 	ID          string          `json:"id"`
 	Title       string          `json:"title"`
 	Content     string          `json:"content,omitempty"`
+	Tags        []string        `json:"tags,omitempty"`
+	Author      string          `json:"author,omitempty"` // из frontmatter
+	Date        string          `json:"date,omitempty"`   // из frontmatter, как записано в файле
 	Path        string          `json:"path,omitempty"`
 	Children    []ShortDocument `json:"children,omitempty"`
 	Modified    time.Time       `json:"modified"`
 	Uncommitted bool            `json:"uncommitted"`
 	Favorite    bool            `json:"favorite"`
+	Stats       *DocumentStats  `json:"stats,omitempty"` // только в ответе GetDocument
 }
 
 type ShortDocument struct {
@@ -56,21 +65,33 @@ func documentToShort(in *Document) *ShortDocument {
 	}
 }
 
+type RelatedDocuments struct {
+	Ancestors []ShortDocument `json:"ancestors"` // от корня к родителю
+	Siblings  []ShortDocument `json:"siblings"`  // без самого документа
+	Children  []ShortDocument `json:"children"`
+	// Связи по ссылкам из индекса поиска, заполняются обработчиком
+	LinkedFrom []ShortDocument `json:"linkedFrom"` // документы, ссылающиеся на документ
+	LinksTo    []ShortDocument `json:"linksTo"`    // документы, на которые ссылается документ
+}
+
 type SearchResults struct {
-	Results     []Document `json:"results"`
-	Total       int        `json:"total"`
-	CurrentPage int        `json:"currentPage"`
-	TotalPages  int        `json:"totalPages"`
-	PageSize    int        `json:"pageSize"`
+	Results     []SearchResult `json:"results"`
+	Total       int            `json:"total"`
+	CurrentPage int            `json:"currentPage"`
+	TotalPages  int            `json:"totalPages"`
+	PageSize    int            `json:"pageSize"`
+	Debug       *SearchDebug   `json:"debug,omitempty"`      // только при debug=true
+	Suggestion  string         `json:"suggestion,omitempty"` // исправленный запрос, если ничего не найдено
 }
 
 type Storage interface {
 	GetRootDocuments() ([]ShortDocument, error)
 	GetRelatedDocuments(path string) (map[string][]ShortDocument, error)
+	GetGroupedRelatedDocuments(path string) (RelatedDocuments, error)
 	GetDocument(path string) (Document, error)
 	GetChildDocuments(parentPath string) ([]ShortDocument, error)
-	CreateDocument(parentPath, title, content string) (Document, error)
-	UpdateDocument(path, title, content string, commitChanges bool) (Document, error)
+	CreateDocument(parentPath, title, content string, coAuthors ...CoAuthor) (Document, error)
+	UpdateDocument(path, title, content string, commitChanges bool, coAuthors ...CoAuthor) (Document, error)
 	DeleteDocument(path string) error
 	MoveDocument(sourcePath, targetPath string) error
 }
@@ -78,84 +99,289 @@ type Storage interface {
 type SearchIndex interface {
 	IndexDocument(doc Document) error
 	DeleteDocument(docPath string) error
+	FileReferences(file string) []string
+	Backlinks(docPath string) []string
+	OutgoingLinks(docPath string) []string
+}
+
+// loadSearchIndex строит индекс при запуске. В строгом режиме ошибка возвращается,
+// иначе только логируется и сервер работает с пустым или неполным индексом.
+func loadSearchIndex(engine *SearchEngine, storage Storage, strict bool) error {
+	err := engine.LoadFromStorage(storage)
+	if err == nil || strict {
+		return err
+	}
+	log.Printf("Warning: Failed to initialize search index: %v", err)
+	return nil
 }
 
 //go:embed static/*
 var staticFiles embed.FS
 
 func main() {
+	maxQueryLength := flag.Int("search-max-query-length", 512, "maximum length of a search query in bytes")
+	maxQueryTerms := flag.Int("search-max-terms", 32, "maximum number of terms in a search query")
+	sanitizeHTML := flag.Bool("sanitize-html", false, "strip dangerous raw HTML from document content on save")
+	sanitizeAllowedTags := flag.String("sanitize-allowed-tags", strings.Join(defaultAllowedTags, ","), "comma-separated HTML tags kept by --sanitize-html")
+	searchLowMemory := flag.Bool("search-low-memory", false, "keep only index terms in memory and read search results from storage")
+	searchMaxIndexBytes := flag.Int("search-max-index-bytes", 0, "approximate search index size that triggers a warning, 0 for no limit")
+	searchTitleBoost := flag.Float64("search-title-boost", DefaultTitleBoost, "weight of title words relative to content words in search ranking")
+	searchOmitContent := flag.Bool("search-omit-content", false, "return only snippets instead of full content in search results")
+	searchCapLowMemory := flag.Bool("search-cap-low-memory", false, "switch to --search-low-memory when --search-max-index-bytes is exceeded")
+	maxDepth := flag.Int("max-depth", 0, "maximum document nesting depth in path segments, 0 for no limit")
+	uniqueTitles := flag.Bool("unique-titles", false, "reject documents whose title is already used by a sibling")
+	gitStatusTTL := flag.Duration("git-status-ttl", 0, "reuse the git worktree status for this long between writes, 0 to compute it on every read")
+	skipUncommitted := flag.Bool("skip-uncommitted-check", false, "do not report uncommitted changes when reading documents")
+	pretty := flag.Bool("pretty", false, "indent JSON responses for debugging")
+	watchDocs := flag.Bool("watch-docs", false, "reindex documents changed on disk outside the API")
+	watchDebounce := flag.Duration("watch-debounce", 500*time.Millisecond, "delay before reindexing documents changed on disk")
+	treeMaxNodes := flag.Int("tree-max-nodes", defaultTreeMaxNodes, "maximum number of documents returned by the tree endpoint")
+	draftTrashTTL := flag.Duration("draft-trash-ttl", 24*time.Hour, "how long deleted drafts stay restorable")
+	draftPrune := flag.Bool("draft-prune", false, "move drafts not changed for --draft-ttl to the drafts trash")
+	draftTTL := flag.Duration("draft-ttl", 30*24*time.Hour, "how long an unchanged draft is kept when --draft-prune is set")
+	pdfFont := flag.String("pdf-font", "", "TrueType font used for PDF export, needed for non-Latin text")
+	homePath := flag.String("home", "", "path of the default home document, used until one is set via the API")
+	strictIndex := flag.Bool("strict-index", false, "exit at startup if the search index cannot be built")
+	metricsPath := flag.String("metrics-path", "/metrics", "path of the Prometheus metrics endpoint, empty to disable")
+	importRepo := flag.String("import-repo", "", "import markdown files from this git repository and exit")
+	importSubtree := flag.String("import-subtree", "", "directory of --import-repo to import, empty for the whole repository")
+	importTarget := flag.String("import-target", "", "document that receives the documents imported by --import-repo, empty for the root")
+	defaultLanguages := "english,russian"
+	if env := os.Getenv("OKIDOKI_LANGS"); env != "" {
+		defaultLanguages = env
+	}
+	searchLanguages := flag.String("search-languages", defaultLanguages, "comma-separated stemming languages of the search index, defaults to $OKIDOKI_LANGS")
+	defaultDataDir := "data"
+	if env := os.Getenv("OKIDOKI_DATA_DIR"); env != "" {
+		defaultDataDir = env
+	}
+	dataDir := flag.String("data-dir", defaultDataDir, "directory with documents, drafts, metadata and uploads, defaults to $OKIDOKI_DATA_DIR")
+	commitDelay := flag.Duration("commit-delay", 0, "commit document edits after this long without further edits to the document, 0 to commit every edit")
+	gitRemote := flag.String("git-remote", "", "URL of a git repository to sync documents with via POST /api/git/sync")
+	gitRemoteUser := flag.String("git-remote-user", "", "user name for --git-remote, the password or token is read from $OKIDOKI_GIT_PASSWORD")
+	storageBackend := flag.String("storage", "git", "document storage: git commits every change, file keeps plain files without history")
+	spaFallbackAll := flag.Bool("spa-fallback-all", false, "serve index.html for every missing static path, not only for page navigations")
+	flag.Parse()
+	prettyJSON = *pretty
+
+	languages, err := parseSearchLanguages(*searchLanguages)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := prepareDataDir(*dataDir); err != nil {
+		log.Fatal(err)
+	}
+
 	// Создаем канал для перехвата сигналов
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
-	storage, err := NewGitStorage("data")
+	storage, tree, err := openStorage(*storageBackend, *dataDir)
 	if err != nil {
 		log.Fatal(err)
 	}
+	tree.uniqueTitles = *uniqueTitles
+	tree.maxDepth = *maxDepth
+	gitStorage, isGit := storage.(*GitStorage)
+	if isGit {
+		gitStorage.statusTTL = *gitStatusTTL
+		gitStorage.skipUncommitted = *skipUncommitted
+		gitStorage.commitDelay = *commitDelay
+		if *gitRemote != "" {
+			if err := gitStorage.SetRemote(*gitRemote, *gitRemoteUser, os.Getenv("OKIDOKI_GIT_PASSWORD")); err != nil {
+				log.Fatal(err)
+			}
+		}
+	} else if *gitRemote != "" {
+		log.Fatal("--git-remote requires --storage=git")
+	}
 
-	draftStorage, err := NewDraftStorage("data")
+	if *importRepo != "" {
+		if !isGit {
+			log.Fatal("--import-repo requires --storage=git")
+		}
+		report, err := gitStorage.ImportRepository(*importRepo, *importSubtree, *importTarget)
+		if err != nil {
+			log.Fatalf("Import failed: %v", err)
+		}
+		for _, skipped := range report.Skipped {
+			log.Printf("Skipped %s: %s", skipped.Source, skipped.Reason)
+		}
+		log.Printf("Imported %d documents from %s", len(report.Imported), *importRepo)
+		return
+	}
+
+	draftStorage, err := NewDraftStorage(*dataDir)
 	if err != nil {
 		log.Fatal(err)
 	}
+	stopTrashCleaner := draftStorage.StartTrashCleaner(*draftTrashTTL, time.Hour)
+	defer stopTrashCleaner()
+	if *draftPrune {
+		if _, err := draftStorage.PruneOlderThan(*draftTTL); err != nil {
+			log.Printf("Warning: failed to prune stale drafts: %v", err)
+		}
+	}
 
-	md, err := NewMetadata("data", 0)
+	md, err := NewMetadata(*dataDir, 0)
 	if err != nil {
 		log.Fatal(err)
 	}
 	defer md.Stop()
+	if n := md.PruneMissing(documentExists(storage)); n > 0 {
+		log.Printf("Removed %d deleted documents from favorites and view history", n)
+	}
 
 	// Initialize search engine
-	searchEngine := NewSearchEngine([]string{"english", "russian"})
-	if err := searchEngine.LoadFromStorage(storage); err != nil {
-		log.Printf("Warning: Failed to initialize search index: %v", err)
+	searchEngine := NewSearchEngine(languages, WithTitleBoost(*searchTitleBoost))
+	if *searchLowMemory {
+		searchEngine.EnableLowMemory(storage)
+	}
+	if *searchMaxIndexBytes > 0 {
+		var fallback Storage
+		if *searchCapLowMemory {
+			fallback = storage
+		}
+		searchEngine.SetMemoryCap(*searchMaxIndexBytes, fallback)
+	}
+	if err := loadSearchIndex(searchEngine, storage, *strictIndex); err != nil {
+		log.Fatalf("Failed to initialize search index: %v", err)
+	}
+	if drafts, err := draftStorage.GetAllDrafts(); err != nil {
+		log.Printf("Warning: failed to load drafts for search: %v", err)
+	} else if err := searchEngine.IndexDrafts(drafts); err != nil {
+		log.Printf("Warning: failed to index drafts: %v", err)
+	}
+	if *draftPrune {
+		stopDraftPruner := draftStorage.StartDraftPruner(*draftTTL, time.Hour, func(ids []string) {
+			for _, id := range ids {
+				searchEngine.DeleteDocument(draftSearchPath(id))
+			}
+		})
+		defer stopDraftPruner()
+	}
+	if *watchDocs {
+		watcher, err := NewDocumentWatcher(tree.docsDir, storage, searchEngine, *watchDebounce)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer watcher.Close()
 	}
 
 	// Create handlers
 	documentHandler := NewDocumentHandler(storage, searchEngine, md, draftStorage)
+	if *sanitizeHTML {
+		documentHandler.sanitizer = NewContentSanitizer(strings.Split(*sanitizeAllowedTags, ","))
+	}
+	documentHandler.uploadDir = uploadDirIn(*dataDir)
+	documentHandler.treeMaxNodes = *treeMaxNodes
+	documentHandler.pdfFont = *pdfFont
+	documentHandler.homePath = strings.Trim(*homePath, "/")
 	searchHandler := NewSearchHandler(searchEngine)
+	searchHandler.maxQueryLength = *maxQueryLength
+	searchHandler.maxQueryTerms = *maxQueryTerms
+	searchHandler.omitContent = *searchOmitContent
+	searchHandler.storage = storage
+	adminHandler := NewAdminHandler(storage, searchEngine)
 
 	r := mux.NewRouter()
+	r.Use(metricsMiddleware)
+
+	// Метрики вне /api: авторизация и прочие middleware /api их не затрагивают
+	if *metricsPath != "" {
+		r.Handle(*metricsPath, NewMetricsHandler(searchEngine, draftStorage)).Methods("GET")
+	}
 
 	// API routes
 	apiRouter := r.PathPrefix("/api").Subrouter()
 	{
 		// Document routes
 		apiRouter.HandleFunc("/documents", documentHandler.GetRootDocuments).Methods("GET")
+		apiRouter.HandleFunc("/tree", documentHandler.GetTree).Methods("GET")
+		apiRouter.HandleFunc("/documents/move-batch", documentHandler.MoveDocumentsBatch).Methods("POST")
+		apiRouter.HandleFunc("/tx", documentHandler.ApplyTransaction).Methods("POST")
 		apiRouter.HandleFunc("/documents/{rest:.*}", documentHandler.GetChildDocuments).Methods("GET")
+		apiRouter.HandleFunc("/document/{rest:.*}/siblings", documentHandler.GetDocumentSiblings).Methods("GET")
+		apiRouter.HandleFunc("/document/{rest:.*}/thumbnail", documentHandler.GetDocumentThumbnail).Methods("GET")
 		apiRouter.HandleFunc("/document/{rest:.*}", documentHandler.GetDocument).Methods("GET")
 		apiRouter.HandleFunc("/document", documentHandler.CreateDocument).Methods("POST")
 		apiRouter.HandleFunc("/document/{rest:.*}", documentHandler.UpdateDocument).Methods("PUT")
 		apiRouter.HandleFunc("/document/{rest:.*}", documentHandler.DeleteDocument).Methods("DELETE")
 		apiRouter.HandleFunc("/document/{rest:.*}/move", documentHandler.MoveDocument).Methods("POST")
+		apiRouter.HandleFunc("/document/{rest:.*}/copy", documentHandler.CopyDocument).Methods("POST")
+		apiRouter.HandleFunc("/document/{rest:.*}/discard", documentHandler.DiscardDocumentChanges).Methods("POST")
 		apiRouter.HandleFunc("/related/{rest:.*}", documentHandler.GetRelatedDocuments).Methods("GET")
+		apiRouter.HandleFunc("/backlinks/{rest:.*}", documentHandler.GetBacklinks).Methods("GET")
+		apiRouter.HandleFunc("/outline/{rest:.*}", documentHandler.GetDocumentOutline).Methods("GET")
+		apiRouter.HandleFunc("/toc/{rest:.*}", documentHandler.GetDocumentTOC).Methods("GET")
+		apiRouter.HandleFunc("/render/{rest:.*}", documentHandler.RenderDocument).Methods("GET")
+
+		// Tags
+		apiRouter.HandleFunc("/tags", documentHandler.GetTags).Methods("GET")
+		apiRouter.HandleFunc("/tags/{tag}", documentHandler.GetTagDocuments).Methods("GET")
+		apiRouter.HandleFunc("/tags/bulk", documentHandler.BulkUpdateTags).Methods("POST")
 
 		// Search route
 		apiRouter.HandleFunc("/search", searchHandler.SearchDocuments).Methods("GET")
+		apiRouter.HandleFunc("/search/terms", searchHandler.GetTerms).Methods("GET")
+		apiRouter.HandleFunc("/search/count", searchHandler.CountDocuments).Methods("GET")
+		apiRouter.HandleFunc("/search/reindex", searchHandler.Reindex).Methods("POST")
+
+		// Admin routes
+		apiRouter.HandleFunc("/admin/rebuild-caches", adminHandler.RebuildCaches).Methods("POST")
+		apiRouter.HandleFunc("/admin/collisions", adminHandler.GetIDCollisions).Methods("GET")
+		apiRouter.HandleFunc("/admin/import", adminHandler.ImportRepository).Methods("POST")
 
 		// History route
 		apiRouter.HandleFunc("/history/tree/{rest:.*}", documentHandler.GetDocumentHistory).Methods("GET")
+		apiRouter.HandleFunc("/history/graph/{rest:.*}", documentHandler.GetDocumentGraph).Methods("GET")
+		apiRouter.HandleFunc("/history/diff/{rest:.*}", documentHandler.GetDocumentDiff).Methods("GET")
 		apiRouter.HandleFunc("/history/doc/{rest:.*}/{commit_id}", documentHandler.GetHistoricalDocument).Methods("GET")
 		apiRouter.HandleFunc("/history/restore/{rest:.*}", documentHandler.RestoreHistoricalDocument).Methods("POST")
+		apiRouter.HandleFunc("/history/revert/{rest:.*}", documentHandler.RevertDocument).Methods("POST")
+		apiRouter.HandleFunc("/history/deleted", documentHandler.GetDeletedDocuments).Methods("GET")
+		apiRouter.HandleFunc("/changes", documentHandler.GetChanges).Methods("GET")
+		apiRouter.HandleFunc("/status/summary", documentHandler.GetStatusSummary).Methods("GET")
+		apiRouter.HandleFunc("/commit", documentHandler.CommitPending).Methods("POST")
+		apiRouter.HandleFunc("/commit/flush", documentHandler.FlushCommits).Methods("POST")
+		apiRouter.HandleFunc("/git/sync", documentHandler.SyncGit).Methods("POST")
 
 		// Drafts
 		apiRouter.HandleFunc("/draft/{rest:.*}", documentHandler.GetDraftDocument).Methods("GET")
 		apiRouter.HandleFunc("/drafts", documentHandler.GetAllDraftsDocument).Methods("GET")
+		apiRouter.HandleFunc("/drafts/batch", documentHandler.GetDraftsBatch).Methods("POST")
+		apiRouter.HandleFunc("/drafts/trash", documentHandler.GetDraftsTrash).Methods("GET")
+		apiRouter.HandleFunc("/drafts/trash/restore/{id}", documentHandler.RestoreDraftFromTrash).Methods("POST")
 		apiRouter.HandleFunc("/draft", documentHandler.UpsertDraftDocument).Methods("POST")
+		apiRouter.HandleFunc("/draft/{id}/publish", documentHandler.PublishDraft).Methods("POST")
 		apiRouter.HandleFunc("/draft/{rest:.*}", documentHandler.DeleteDraftDocument).Methods("DELETE")
 
 		// ViewHistory
 		apiRouter.HandleFunc("/views/last", documentHandler.GetLastViews).Methods("GET")
+		apiRouter.HandleFunc("/views/popular", documentHandler.GetMostViewed).Methods("GET")
 
 		// Favorites
 		apiRouter.HandleFunc("/favorite", documentHandler.AddToFavorites).Methods("POST")
 		apiRouter.HandleFunc("/favorite", documentHandler.RemoveFromFavorites).Methods("DELETE")
 		apiRouter.HandleFunc("/favorites", documentHandler.GetFavorites).Methods("GET")
+		apiRouter.HandleFunc("/favorites/reorder", documentHandler.ReorderFavorites).Methods("POST")
+
+		// Markdown linting
+		apiRouter.HandleFunc("/lint", documentHandler.LintDocument).Methods("POST")
+
+		// Links to missing documents
+		apiRouter.HandleFunc("/links/broken", documentHandler.GetBrokenLinks).Methods("GET")
+
+		// Home page
+		apiRouter.HandleFunc("/home", documentHandler.GetHome).Methods("GET")
+		apiRouter.HandleFunc("/home", documentHandler.SetHome).Methods("PUT")
 
 		// image and doc storer
 		//apiRouter.HandleFunc("/v1/upload", documentHandler.HandleUploadOptions).Methods("OPTIONS")
 		apiRouter.HandleFunc("/v1/upload", documentHandler.HandleUpload).Methods("POST")
 		apiRouter.HandleFunc("/bucket", documentHandler.HandleBucketUpload).Methods("POST")
 		apiRouter.HandleFunc("/file/{hash}", documentHandler.HandleFileDownload).Methods("GET")
+		apiRouter.HandleFunc("/file/{hash}/references", documentHandler.GetFileReferences).Methods("GET")
 	}
 
 	spaFS, err := fs.Sub(staticFiles, "static")
@@ -163,54 +389,7 @@ func main() {
 		log.Fatal(err)
 	}
 
-	// Создаем кастомный файловый сервер для SPA
-	spaFileServer := http.FileServer(http.FS(spaFS))
-
-	r.PathPrefix("/").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Пропускаем API-запросы
-		if strings.HasPrefix(r.URL.Path, "/api") {
-			http.NotFound(w, r)
-			return
-		}
-
-		// Проверяем существование файла
-		path := strings.TrimPrefix(r.URL.Path, "/")
-		if path == "" {
-			path = "index.html"
-		}
-
-		_, err := spaFS.Open(path)
-		if err != nil {
-			// Если файл не найден - отдаем index.html
-			index, err := spaFS.Open("index.html")
-			if err != nil {
-				http.NotFound(w, r)
-				return
-			}
-			defer index.Close()
-
-			// Читаем весь файл в память (не идеально для больших файлов)
-			stat, err := index.Stat()
-			if err != nil {
-				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-				return
-			}
-
-			content, err := io.ReadAll(index)
-			if err != nil {
-				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-				return
-			}
-
-			// Устанавливаем правильные заголовки
-			w.Header().Set("Content-Type", "text/html")
-			http.ServeContent(w, r, "index.html", stat.ModTime(), strings.NewReader(string(content)))
-			return
-		}
-
-		// Если файл существует - отдаем его
-		spaFileServer.ServeHTTP(w, r)
-	})
+	r.PathPrefix("/").HandlerFunc(spaHandler(spaFS, *spaFallbackAll))
 
 	// Start server
 	server := &http.Server{
@@ -228,8 +407,30 @@ func main() {
 	// Wait for interrupt signal
 	<-sigChan
 	log.Println("Shutting down server...")
-	if err := server.Shutdown(nil); err != nil {
-		log.Fatal("Server shutdown error:", err)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// Отменяем длительные операции, чтобы они завершились сами, а не по таймауту
+	cancelled, err := documentHandler.operations.Shutdown(shutdownCtx)
+	if err != nil {
+		log.Printf("Warning: long-running operations did not stop in time: %v", err)
+	}
+	log.Printf("Cancelled %d long-running operations", cancelled)
+
+	shutdownErr := server.Shutdown(shutdownCtx)
+	// Запросы больше не приходят, метаданные сохраняются до выхода, даже если
+	// дальше сработает log.Fatal и отложенный md.Stop не выполнится
+	if err := md.SaveOnDisk(); err != nil {
+		log.Printf("Warning: failed to save metadata: %v", err)
+	}
+	// Новых правок больше не будет, отложенные коммиты делаются сразу
+	if isGit {
+		if err := gitStorage.Flush(); err != nil {
+			log.Printf("Warning: failed to commit deferred edits: %v", err)
+		}
+	}
+	if shutdownErr != nil {
+		log.Fatal("Server shutdown error:", shutdownErr)
 	}
 	log.Println("Server stopped")
 }
@@ -239,6 +440,14 @@ type DocumentHandler struct {
 	search       SearchIndex
 	meta         *Metadata
 	draftStorage *DraftStorage
+	operations   *OperationTracker
+	sanitizer    *ContentSanitizer // nil - содержимое сохраняется как есть
+	uploadDir    string
+	treeMaxNodes int
+	pdfFont      string // TTF-шрифт для экспорта в PDF, пусто - встроенный
+	homePath     string // домашний документ по умолчанию, если не задан через API
+	stats        *documentStatsCache
+	editMu       sync.Mutex // проверка If-Match и сохранение документа
 }
 
 func NewDocumentHandler(storage Storage, search SearchIndex, meta *Metadata, draftStorage *DraftStorage) *DocumentHandler {
@@ -247,6 +456,34 @@ func NewDocumentHandler(storage Storage, search SearchIndex, meta *Metadata, dra
 		search:       search,
 		meta:         meta,
 		draftStorage: draftStorage,
+		operations:   NewOperationTracker(),
+		uploadDir:    defaultUploadDir,
+		treeMaxNodes: defaultTreeMaxNodes,
+		stats:        newDocumentStatsCache(),
+	}
+}
+
+// operationStatus возвращает HTTP статус для ошибки длительной операции.
+// Проверяется сам контекст: go-git при отмене возвращает собственные ошибки.
+func operationStatus(ctx context.Context) int {
+	if ctx.Err() != nil {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// prettyJSON включает форматированный вывод JSON во всех ответах (флаг --pretty)
+var prettyJSON bool
+
+// writeJSON кодирует ответ в JSON. Отступы добавляются при --pretty или ?pretty=true.
+func writeJSON(w http.ResponseWriter, r *http.Request, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	if prettyJSON || r.URL.Query().Get("pretty") == "true" {
+		enc.SetIndent("", "  ")
+	}
+	if err := enc.Encode(v); err != nil {
+		log.Printf("Failed to encode response for %s: %v", r.URL.Path, err)
 	}
 }
 
@@ -261,13 +498,78 @@ func (h *DocumentHandler) GetDocumentHistory(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	history, err := gitStorage.GetDocumentHistory(docPath)
+	opts, err := historyOptions(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, done := h.operations.Begin(r.Context())
+	defer done()
+
+	history, err := gitStorage.QueryDocumentHistory(ctx, docPath, opts)
+	if err != nil {
+		http.Error(w, err.Error(), operationStatus(ctx))
+		return
+	}
+
+	writeJSON(w, r, history)
+}
+
+// historyOptions разбирает page, pageSize, since и until. Без page и pageSize
+// возвращается вся история.
+func historyOptions(r *http.Request) (HistoryOptions, error) {
+	query := r.URL.Query()
+	var opts HistoryOptions
+	if query.Has("page") || query.Has("pageSize") {
+		page, err := strconv.Atoi(query.Get("page"))
+		if err != nil || page < 1 {
+			page = 1
+		}
+		pageSize, err := strconv.Atoi(query.Get("pageSize"))
+		if err != nil || pageSize < 1 {
+			pageSize = 20
+		}
+		opts.Page, opts.PageSize = page, pageSize
+	}
+	for _, bound := range []struct {
+		name string
+		dst  *time.Time
+	}{{"since", &opts.Since}, {"until", &opts.Until}} {
+		value := query.Get(bound.name)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return HistoryOptions{}, fmt.Errorf("invalid %s: expected RFC3339 time", bound.name)
+		}
+		*bound.dst = t
+	}
+	return opts, nil
+}
+
+func (h *DocumentHandler) GetDocumentGraph(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	docPath := vars["rest"]
+
+	// Type assertion to get GitStorage
+	gitStorage, ok := h.storage.(*GitStorage)
+	if !ok {
+		http.Error(w, "history feature only available with git storage", http.StatusNotImplemented)
+		return
+	}
+
+	ctx, done := h.operations.Begin(r.Context())
+	defer done()
+
+	graph, err := gitStorage.GetDocumentGraph(ctx, docPath)
+	if err != nil {
+		http.Error(w, err.Error(), operationStatus(ctx))
 		return
 	}
 
-	json.NewEncoder(w).Encode(history)
+	writeJSON(w, r, graph)
 }
 
 func (h *DocumentHandler) GetHistoricalDocument(w http.ResponseWriter, r *http.Request) {
@@ -288,7 +590,43 @@ func (h *DocumentHandler) GetHistoricalDocument(w http.ResponseWriter, r *http.R
 		return
 	}
 
-	json.NewEncoder(w).Encode(doc)
+	writeJSON(w, r, doc)
+}
+
+func (h *DocumentHandler) GetDeletedDocuments(w http.ResponseWriter, r *http.Request) {
+	// Type assertion to get GitStorage
+	gitStorage, ok := h.storage.(*GitStorage)
+	if !ok {
+		http.Error(w, "history feature only available with git storage", http.StatusNotImplemented)
+		return
+	}
+
+	ctx, done := h.operations.Begin(r.Context())
+	defer done()
+
+	deleted, err := gitStorage.GetDeletedDocuments(ctx)
+	if err != nil {
+		http.Error(w, err.Error(), operationStatus(ctx))
+		return
+	}
+
+	writeJSON(w, r, deleted)
+}
+
+func (h *DocumentHandler) GetStatusSummary(w http.ResponseWriter, r *http.Request) {
+	gitStorage, ok := h.storage.(*GitStorage)
+	if !ok {
+		http.Error(w, "history feature only available with git storage", http.StatusNotImplemented)
+		return
+	}
+
+	summary, err := gitStorage.GetStatusSummary()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, r, summary)
 }
 
 func (h *DocumentHandler) RestoreHistoricalDocument(w http.ResponseWriter, r *http.Request) {
@@ -315,30 +653,31 @@ func (h *DocumentHandler) RestoreHistoricalDocument(w http.ResponseWriter, r *ht
 	// Restore the document
 	restoredDoc, err := gitStorage.RestoreHistoricalDocument(currentPath, request.OriginalPath, request.CommitHash)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrParentNotFound) {
+			status = http.StatusConflict
+		}
+		http.Error(w, err.Error(), status)
 		return
 	}
 
-	// Update search index
-	if err := h.search.DeleteDocument(currentPath); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
+	// Update search index, a restored deleted document isn't indexed yet
+	h.search.DeleteDocument(currentPath)
 	if err := h.search.IndexDocument(restoredDoc); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	json.NewEncoder(w).Encode(restoredDoc)
+	writeJSON(w, r, restoredDoc)
 }
 
-func (h *DocumentHandler) GetRootDocuments(w http.ResponseWriter, _ *http.Request) {
+func (h *DocumentHandler) GetRootDocuments(w http.ResponseWriter, r *http.Request) {
 	docs, err := h.storage.GetRootDocuments()
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	json.NewEncoder(w).Encode(docs)
+	writeJSON(w, r, docs)
 }
 
 func (h *DocumentHandler) GetChildDocuments(w http.ResponseWriter, r *http.Request) {
@@ -349,7 +688,7 @@ func (h *DocumentHandler) GetChildDocuments(w http.ResponseWriter, r *http.Reque
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	json.NewEncoder(w).Encode(docs)
+	writeJSON(w, r, docs)
 }
 
 func (h *DocumentHandler) GetDocument(w http.ResponseWriter, r *http.Request) {
@@ -361,48 +700,259 @@ func (h *DocumentHandler) GetDocument(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "pdf" {
+		http.Error(w, "Invalid format parameter: use json or pdf", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("ETag", documentETag(doc))
+
+	// Число слов и время чтения по сохраненному содержимому, stats=false - без них
+	if r.URL.Query().Get("stats") != "false" {
+		stats := h.stats.Get(doc)
+		doc.Stats = &stats
+	}
+
+	// В режиме render директивы {{include:...}} заменяются содержимым документов,
+	// PDF всегда строится по отрендеренному содержимому
+	if r.URL.Query().Get("render") == "true" || format == "pdf" {
+		doc.Content, err = resolveIncludes(h.storage, docPath, doc.Content)
+		if errors.Is(err, ErrIncludeCycle) || errors.Is(err, ErrIncludeDepth) {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
 	doc.Favorite = h.meta.IsFavorite(docPath)
 	h.meta.UpdateViewedMeta(documentToShort(&doc))
 
-	json.NewEncoder(w).Encode(doc)
+	if format == "pdf" {
+		h.writeDocumentPDF(w, doc)
+		return
+	}
+
+	// Большие документы можно загружать частями: ?range=bytes=0-9999 или заголовок Range
+	w.Header().Set("Accept-Ranges", "bytes")
+	spec := r.URL.Query().Get("range")
+	if spec == "" {
+		spec = r.Header.Get("Range")
+	}
+	if spec != "" {
+		writeDocumentRange(w, r, doc, spec)
+		return
+	}
+	writeJSON(w, r, doc)
+}
+
+// DiscardDocumentChanges отменяет незакоммиченные правки документа
+func (h *DocumentHandler) DiscardDocumentChanges(w http.ResponseWriter, r *http.Request) {
+	gitStorage, ok := h.storage.(*GitStorage)
+	if !ok {
+		http.Error(w, "history feature only available with git storage", http.StatusNotImplemented)
+		return
+	}
+
+	vars := mux.Vars(r)
+	docPath := vars["rest"]
+	doc, err := gitStorage.DiscardChanges(docPath)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, ErrDocumentNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, ErrInvalidPath):
+			status = http.StatusBadRequest
+		case errors.Is(err, ErrNotCommitted):
+			status = http.StatusConflict
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	// Документ мог отсутствовать в индексе, важно лишь проиндексировать актуальную версию
+	h.search.DeleteDocument(doc.Path)
+	if err := h.search.IndexDocument(doc); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	doc.Favorite = h.meta.IsFavorite(doc.Path)
+	writeJSON(w, r, doc)
+}
+
+// DocumentSiblings - положение документа среди документов того же родителя
+type DocumentSiblings struct {
+	Siblings []ShortDocument `json:"siblings"`
+	Index    int             `json:"index"`
+	Previous *ShortDocument  `json:"previous"`
+	Next     *ShortDocument  `json:"next"`
+}
+
+// GetDocumentSiblings возвращает соседей документа в порядке отображения
+// вместе с его позицией, предыдущим и следующим документом
+func (h *DocumentHandler) GetDocumentSiblings(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	docPath := strings.Trim(vars["rest"], "/")
+
+	var siblings []ShortDocument
+	var err error
+	if parentPath := path.Dir(docPath); parentPath == "." {
+		siblings, err = h.storage.GetRootDocuments()
+	} else {
+		siblings, err = h.storage.GetChildDocuments(parentPath)
+	}
+	if err != nil && !os.IsNotExist(err) {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	index := slices.IndexFunc(siblings, func(doc ShortDocument) bool { return doc.Path == docPath })
+	if index < 0 {
+		http.Error(w, ErrDocumentNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	resp := DocumentSiblings{Siblings: siblings, Index: index}
+	if index > 0 {
+		resp.Previous = &siblings[index-1]
+	}
+	if index < len(siblings)-1 {
+		resp.Next = &siblings[index+1]
+	}
+
+	writeJSON(w, r, resp)
+}
+
+func (h *DocumentHandler) GetDocumentOutline(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	doc, err := h.storage.GetDocument(vars["rest"])
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrDocumentNotFound) {
+			status = http.StatusNotFound
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	writeJSON(w, r, extractHeadings(doc.Content))
 }
 
+// GetRelatedDocuments возвращает предков, соседей, детей и связанные ссылками
+// документы. Прежняя плоская карта отдается с legacy=true.
 func (h *DocumentHandler) GetRelatedDocuments(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	docPath := vars["rest"]
 
-	related, err := h.storage.GetRelatedDocuments(docPath)
+	if r.URL.Query().Get("legacy") == "true" {
+		related, err := h.storage.GetRelatedDocuments(docPath)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, r, related)
+		return
+	}
+
+	related, err := h.storage.GetGroupedRelatedDocuments(docPath)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrDocumentNotFound) {
+			status = http.StatusNotFound
+		}
+		http.Error(w, err.Error(), status)
 		return
 	}
+	related.LinkedFrom = h.linkedDocuments(h.search.Backlinks(docPath))
+	related.LinksTo = h.linkedDocuments(h.search.OutgoingLinks(docPath))
 
-	json.NewEncoder(w).Encode(related)
+	writeJSON(w, r, related)
+}
+
+// linkedDocuments загружает документы по путям из индекса ссылок. Битые ссылки
+// и черновики пропускаются.
+func (h *DocumentHandler) linkedDocuments(paths []string) []ShortDocument {
+	docs := []ShortDocument{}
+	for _, docPath := range paths {
+		doc, err := h.storage.GetDocument(docPath)
+		if err != nil {
+			if !errors.Is(err, ErrDocumentNotFound) {
+				log.Printf("Warning: failed to load linked document %q: %v", docPath, err)
+			}
+			continue
+		}
+		docs = append(docs, *documentToShort(&doc))
+	}
+	return docs
 }
 
 func (h *DocumentHandler) CreateDocument(w http.ResponseWriter, r *http.Request) {
 	query := r.URL.Query().Get("draft")
 
 	var req struct {
-		ParentPath string `json:"parentPath"`
-		Title      string `json:"title"`
-		Content    string `json:"content"`
+		ParentPath string     `json:"parentPath"`
+		Title      string     `json:"title"`
+		Content    string     `json:"content"`
+		CoAuthors  []CoAuthor `json:"co_authors"`
+		Author     *CoAuthor  `json:"author"`
+		Tags       []string   `json:"tags"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	if h.sanitizer != nil {
+		req.Content = h.sanitizer.Sanitize(req.Content)
+	}
+	// Теги записываются во frontmatter нового файла
+	if tags := normalizeTags(req.Tags); len(tags) > 0 {
+		content, err := joinFrontMatter(frontMatter{Tags: tags}, req.Content)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		req.Content = content
+	}
+
+	if err := validateCoAuthors(req.CoAuthors); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	author, err := commitAuthor(r, req.Author)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	var pathChanged bool
-	doc, err := h.storage.CreateDocument(req.ParentPath, req.Title, req.Content)
+	doc, err := h.createDocument(author, req.ParentPath, req.Title, req.Content, req.CoAuthors)
 	if err != nil {
+		if errors.Is(err, ErrTitleConflict) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if errors.Is(err, ErrDepthExceeded) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		if !errors.Is(err, mkDirErr) {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
-		doc, err = h.storage.CreateDocument("", req.Title, req.Content)
+		doc, err = h.createDocument(author, "", req.Title, req.Content, req.CoAuthors)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			status := http.StatusInternalServerError
+			if errors.Is(err, ErrTitleConflict) {
+				status = http.StatusConflict
+			}
+			http.Error(w, err.Error(), status)
+			return
 		}
 		pathChanged = true
 	}
@@ -423,28 +973,81 @@ func (h *DocumentHandler) CreateDocument(w http.ResponseWriter, r *http.Request)
 		w.WriteHeader(http.StatusAccepted)
 	}
 
-	json.NewEncoder(w).Encode(doc)
+	writeJSON(w, r, doc)
 }
 
 func (h *DocumentHandler) UpdateDocument(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	docPath := vars["rest"]
 	var req struct {
-		Title         string `json:"title"`
-		Content       string `json:"content"`
-		CommitChanges bool   `json:"commit_changes"`
+		Title         string     `json:"title"`
+		Content       string     `json:"content"`
+		CommitChanges bool       `json:"commit_changes"`
+		CoAuthors     []CoAuthor `json:"co_authors"`
+		Author        *CoAuthor  `json:"author"`
+		CommitMessage string     `json:"commit_message"` // вместо стандартного сообщения коммита
+		Tags          *[]string  `json:"tags"`           // nil - теги не меняются
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	if h.sanitizer != nil {
+		req.Content = h.sanitizer.Sanitize(req.Content)
+	}
 
-	doc, err := h.storage.UpdateDocument(docPath, req.Title, req.Content, req.CommitChanges)
+	if err := validateCoAuthors(req.CoAuthors); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	author, err := commitAuthor(r, req.Author)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
+	// Проверка версии и запись выполняются вместе, иначе параллельная правка
+	// может вклиниться между ними
+	h.editMu.Lock()
+	if !h.checkIfMatch(w, r, docPath) {
+		h.editMu.Unlock()
+		return
+	}
+	rollbackTags := func() {}
+	if req.Tags != nil {
+		rollbackTags, err = h.applyTags(docPath, *req.Tags)
+		if err != nil {
+			h.editMu.Unlock()
+			status := http.StatusInternalServerError
+			if errors.Is(err, ErrDocumentNotFound) {
+				status = http.StatusNotFound
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+	}
+	doc, err := h.updateDocument(author, req.CommitMessage, docPath, req.Title, req.Content, req.CommitChanges, req.CoAuthors)
+	if err != nil {
+		rollbackTags()
+	}
+	h.editMu.Unlock()
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrTitleConflict) {
+			status = http.StatusConflict
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	if saved, err := h.storage.GetDocument(doc.Path); err == nil {
+		w.Header().Set("ETag", documentETag(saved))
+	}
+
+	// Отложенные правки запоминаются за автором до пакетного коммита
+	if gitStorage, ok := h.storage.(*GitStorage); ok && author != nil && !req.CommitChanges {
+		gitStorage.TrackPendingAuthor(*author, docPath, doc.Path)
+	}
+
 	if err := h.search.DeleteDocument(docPath); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -457,15 +1060,26 @@ func (h *DocumentHandler) UpdateDocument(w http.ResponseWriter, r *http.Request)
 
 	doc.Favorite = h.meta.IsFavorite(docPath)
 
-	json.NewEncoder(w).Encode(doc)
+	writeJSON(w, r, doc)
 }
 
 func (h *DocumentHandler) DeleteDocument(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	docPath := vars["rest"]
 
-	err := h.storage.DeleteDocument(docPath)
+	// recursive=true удаляет документ вместе с потомками
+	if r.URL.Query().Get("recursive") == "true" {
+		h.deleteSubtree(w, r, docPath)
+		return
+	}
+
+	author, err := commitAuthor(r, nil)
 	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.deleteDocument(author, docPath); err != nil {
 		status := http.StatusInternalServerError
 		if err.Error() == "cannot delete document with children" {
 			status = http.StatusBadRequest
@@ -500,39 +1114,24 @@ func (h *DocumentHandler) MoveDocument(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		status := http.StatusInternalServerError
 		if strings.Contains(err.Error(), "does not exist") ||
-			strings.Contains(err.Error(), "already exists") {
+			strings.Contains(err.Error(), "already exists") ||
+			errors.Is(err, ErrDepthExceeded) || errors.Is(err, ErrMoveIntoSelf) {
 			status = http.StatusBadRequest
 		}
 		http.Error(w, err.Error(), status)
 		return
 	}
 
-	isFavorite := h.meta.IsFavorite(sourcePath)
-	if isFavorite {
-		h.meta.RemoveFromFavorites(sourcePath)
-	}
+	newPath := path.Join(req.TargetPath, filepath.Base(sourcePath))
+	h.relocateSubtree(sourcePath, newPath)
 
-	if err := h.search.DeleteDocument(sourcePath); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	doc, err := h.storage.GetDocument(path.Join(req.TargetPath, filepath.Base(sourcePath)))
+	doc, err := h.storage.GetDocument(newPath)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	if isFavorite {
-		h.meta.AddToFavorites(documentToShort(&doc))
-	}
-
-	if err := h.search.IndexDocument(doc); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	json.NewEncoder(w).Encode(doc)
+	writeJSON(w, r, doc)
 }
 
 func (h *DocumentHandler) GetDraftDocument(w http.ResponseWriter, r *http.Request) {
@@ -542,10 +1141,10 @@ func (h *DocumentHandler) GetDraftDocument(w http.ResponseWriter, r *http.Reques
 		http.Error(w, err.Error(), http.StatusNotFound)
 		return
 	}
-	json.NewEncoder(w).Encode(draft)
+	writeJSON(w, r, draft)
 }
 
-func (h *DocumentHandler) GetAllDraftsDocument(w http.ResponseWriter, _ *http.Request) {
+func (h *DocumentHandler) GetAllDraftsDocument(w http.ResponseWriter, r *http.Request) {
 	drafts, err := h.draftStorage.GetAllDrafts()
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -557,7 +1156,32 @@ func (h *DocumentHandler) GetAllDraftsDocument(w http.ResponseWriter, _ *http.Re
 		drafts = []Draft{}
 	}
 
-	json.NewEncoder(w).Encode(drafts)
+	writeJSON(w, r, drafts)
+}
+
+// maxDraftBatch ограничивает число черновиков в одном пакетном запросе
+const maxDraftBatch = 100
+
+func (h *DocumentHandler) GetDraftsBatch(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		IDs []string `json:"ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(req.IDs) > maxDraftBatch {
+		http.Error(w, fmt.Sprintf("too many ids: %d, maximum is %d", len(req.IDs), maxDraftBatch), http.StatusBadRequest)
+		return
+	}
+
+	drafts, missing, invalid := h.draftStorage.GetDrafts(req.IDs)
+
+	writeJSON(w, r, struct {
+		Drafts   []Draft  `json:"drafts"`
+		NotFound []string `json:"not_found"`
+		Invalid  []string `json:"invalid"`
+	}{drafts, missing, invalid})
 }
 
 func (h *DocumentHandler) UpsertDraftDocument(w http.ResponseWriter, r *http.Request) {
@@ -571,23 +1195,54 @@ func (h *DocumentHandler) UpsertDraftDocument(w http.ResponseWriter, r *http.Req
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	h.indexDraft(draft)
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
+func (h *DocumentHandler) GetDraftsTrash(w http.ResponseWriter, r *http.Request) {
+	trashed, err := h.draftStorage.GetTrash()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, r, trashed)
+}
+
+func (h *DocumentHandler) RestoreDraftFromTrash(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	draft, err := h.draftStorage.RestoreDraft(vars["id"])
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, ErrDraftNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, ErrDraftExists):
+			status = http.StatusConflict
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	h.indexDraft(*draft)
+
+	writeJSON(w, r, draft)
+}
+
 func (h *DocumentHandler) DeleteDraftDocument(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	if err := h.draftStorage.DeleteDraft(vars["rest"]); err != nil {
 		http.Error(w, err.Error(), http.StatusNotFound)
 		return
 	}
+	h.search.DeleteDocument(draftSearchPath(vars["rest"]))
 	w.WriteHeader(http.StatusNoContent)
 }
 
-func (h *DocumentHandler) GetLastViews(w http.ResponseWriter, _ *http.Request) {
+func (h *DocumentHandler) GetLastViews(w http.ResponseWriter, r *http.Request) {
 	docs := h.meta.GetLastViewedDocuments()
 
-	json.NewEncoder(w).Encode(docs)
+	writeJSON(w, r, docs)
 }
 
 func (h *DocumentHandler) AddToFavorites(w http.ResponseWriter, r *http.Request) {
@@ -638,23 +1293,81 @@ func (h *DocumentHandler) RemoveFromFavorites(w http.ResponseWriter, r *http.Req
 func (h *DocumentHandler) GetFavorites(w http.ResponseWriter, r *http.Request) {
 	favorites := h.meta.GetFavorites()
 
-	json.NewEncoder(w).Encode(favorites)
+	writeJSON(w, r, favorites)
 }
 
 type SearchHandler struct {
 	searchEngine *SearchEngine
+
+	// Ограничения на размер запроса, 0 - без ограничений
+	maxQueryLength int
+	maxQueryTerms  int
+
+	// Не отдавать полное содержимое найденных документов, только фрагменты.
+	// Запрос может переопределить параметром content=true|false.
+	omitContent bool
+
+	// Источник документов для переиндексации, nil - переиндексация недоступна
+	storage Storage
 }
 
 func NewSearchHandler(searchEngine *SearchEngine) *SearchHandler {
 	return &SearchHandler{searchEngine: searchEngine}
 }
 
+// validateQuery проверяет запрос на превышение настроенных лимитов
+func (h *SearchHandler) validateQuery(query string) error {
+	if h.maxQueryLength > 0 && len(query) > h.maxQueryLength {
+		return fmt.Errorf("query is too long: maximum length is %d", h.maxQueryLength)
+	}
+	if h.maxQueryTerms > 0 && len(strings.Fields(query)) > h.maxQueryTerms {
+		return fmt.Errorf("query has too many terms: maximum is %d", h.maxQueryTerms)
+	}
+	return nil
+}
+
+// searchOptions читает параметры поиска, общие для выдачи и подсчета
+func (h *SearchHandler) searchOptions(r *http.Request) (SearchOptions, error) {
+	// Языки стемминга для запроса: lang=russian или lang=russian,english
+	var languages []string
+	for _, value := range r.URL.Query()["lang"] {
+		for _, lang := range strings.Split(value, ",") {
+			if lang = strings.ToLower(strings.TrimSpace(lang)); lang != "" {
+				languages = append(languages, lang)
+			}
+		}
+	}
+	if err := h.searchEngine.ValidateLanguages(languages); err != nil {
+		return SearchOptions{}, err
+	}
+
+	sortBy := r.URL.Query().Get("sort")
+	switch sortBy {
+	case "", SortRelevance, SortModified:
+	default:
+		return SearchOptions{}, fmt.Errorf("sort must be %s or %s", SortRelevance, SortModified)
+	}
+
+	return SearchOptions{
+		Languages: languages,
+		Fuzzy:     r.URL.Query().Get("fuzzy") == "true",
+		Path:      r.URL.Query().Get("path"),
+		Any:       r.URL.Query().Get("any") == "true",
+		Drafts:    r.URL.Query().Get("includeDrafts") == "true",
+		Sort:      sortBy,
+	}, nil
+}
+
 func (h *SearchHandler) SearchDocuments(w http.ResponseWriter, r *http.Request) {
 	query := r.URL.Query().Get("q")
 	if query == "" {
 		http.Error(w, "query parameter 'q' is required", http.StatusBadRequest)
 		return
 	}
+	if err := h.validateQuery(query); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	// Get pagination parameters with defaults
 	page, err := strconv.Atoi(r.URL.Query().Get("page"))
@@ -667,32 +1380,148 @@ func (h *SearchHandler) SearchDocuments(w http.ResponseWriter, r *http.Request)
 		pageSize = 10
 	}
 
-	results, total, err := h.searchEngine.Search(query, page, pageSize)
+	opts, err := h.searchOptions(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	omitContent := h.omitContent
+	switch r.URL.Query().Get("content") {
+	case "true":
+		omitContent = false
+	case "false":
+		omitContent = true
+	}
+
+	// fields=short - только идентификатор, название, путь и фрагмент текста
+	present := func(result SearchResult) any {
+		if omitContent {
+			result.Content = ""
+		}
+		return result
+	}
+	short := false
+	switch r.URL.Query().Get("fields") {
+	case "", "full":
+	case "short":
+		short = true
+		present = func(result SearchResult) any { return searchResultToShort(result) }
+	default:
+		http.Error(w, "fields must be full or short", http.StatusBadRequest)
+		return
+	}
+
+	var debug *SearchDebug
+	if r.URL.Query().Get("debug") == "true" {
+		d := h.searchEngine.DebugQuery(query, opts.Languages)
+		debug = &d
+	}
+
+	if r.URL.Query().Get("format") == "ndjson" {
+		h.streamSearchResults(w, query, opts, page, pageSize, present, debug)
+		return
+	}
+
+	results := []SearchResult{}
+	total, err := h.searchEngine.SearchEach(query, opts, page, pageSize, func(result SearchResult) error {
+		if omitContent {
+			result.Content = ""
+		}
+		results = append(results, result)
+		return nil
+	})
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	// Calculate total pages
-	totalPages := total / pageSize
-	if total%pageSize > 0 {
-		totalPages++
+	if short {
+		response := ShortSearchResults{Results: make([]ShortSearchResult, 0, len(results))}
+		for _, result := range results {
+			response.Results = append(response.Results, searchResultToShort(result))
+		}
+		response.SearchMetadata = SearchMetadata{
+			Total:       total,
+			CurrentPage: page,
+			TotalPages:  totalPages(total, pageSize),
+			PageSize:    pageSize,
+			Debug:       debug,
+			Suggestion:  h.suggest(query, opts, total),
+		}
+		writeJSON(w, r, response)
+		return
 	}
 
 	response := SearchResults{
 		Results:     results,
 		Total:       total,
 		CurrentPage: page,
-		TotalPages:  totalPages,
+		TotalPages:  totalPages(total, pageSize),
 		PageSize:    pageSize,
+		Debug:       debug,
+		Suggestion:  h.suggest(query, opts, total),
 	}
 
-	if response.Results == nil {
-		response.Results = []Document{} // Return empty array instead of null
+	writeJSON(w, r, response)
+}
+
+func totalPages(total, pageSize int) int {
+	pages := total / pageSize
+	if total%pageSize > 0 {
+		pages++
 	}
+	return pages
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+// SearchMetadata - последняя строка NDJSON-ответа поиска
+type SearchMetadata struct {
+	Total       int          `json:"total"`
+	CurrentPage int          `json:"currentPage"`
+	TotalPages  int          `json:"totalPages"`
+	PageSize    int          `json:"pageSize"`
+	Debug       *SearchDebug `json:"debug,omitempty"`
+	Suggestion  string       `json:"suggestion,omitempty"`
+}
+
+// suggest считает подсказку только для запросов без результатов
+func (h *SearchHandler) suggest(query string, opts SearchOptions, total int) string {
+	if total > 0 {
+		return ""
+	}
+	return h.searchEngine.Suggest(query, opts.Languages)
+}
+
+// streamSearchResults пишет найденные документы по одному на строку
+// и завершает поток строкой {"metadata": {...}}
+func (h *SearchHandler) streamSearchResults(w http.ResponseWriter, query string, opts SearchOptions, page, pageSize int, present func(SearchResult) any, debug *SearchDebug) {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	flusher, _ := w.(http.Flusher)
+	enc := json.NewEncoder(w)
+
+	total, err := h.searchEngine.SearchEach(query, opts, page, pageSize, func(result SearchResult) error {
+		if err := enc.Encode(present(result)); err != nil {
+			return err
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		// Заголовки уже могли уйти клиенту, поэтому ошибка идет отдельной строкой
+		enc.Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	enc.Encode(map[string]SearchMetadata{"metadata": {
+		Total:       total,
+		CurrentPage: page,
+		TotalPages:  totalPages(total, pageSize),
+		PageSize:    pageSize,
+		Debug:       debug,
+		Suggestion:  h.suggest(query, opts, total),
+	}})
 }
 
 ///////////////////////////////////////////////////////////////
@@ -761,8 +1590,7 @@ func (h *DocumentHandler) HandleUpload(w http.ResponseWriter, r *http.Request) {
 		},
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	writeJSON(w, r, response)
 }
 
 // Вспомогательные функции
@@ -783,8 +1611,10 @@ func generateFileHash(filename string) string {
 }
 
 const (
-	uploadDir     = "./data/uploads" // Директория для сохранения файлов
-	maxUploadSize = 10 << 30         // 1gb
+	defaultUploadDir = "./data/uploads" // Директория для сохранения файлов без --data-dir
+	maxUploadSize    = 10 << 30         // 1gb
+
+	defaultJPEGQuality = jpeg.DefaultQuality
 )
 
 func (h *DocumentHandler) HandleBucketUpload(w http.ResponseWriter, r *http.Request) {
@@ -811,13 +1641,13 @@ func (h *DocumentHandler) HandleBucketUpload(w http.ResponseWriter, r *http.Requ
 	}
 
 	// Создаем директорию для загрузок, если ее нет
-	if err := os.MkdirAll(uploadDir, 0755); err != nil {
+	if err := os.MkdirAll(h.uploadDir, 0755); err != nil {
 		http.Error(w, "Failed to create upload directory", http.StatusInternalServerError)
 		return
 	}
 
 	// Создаем файл на диске
-	filePath := filepath.Join(uploadDir, key)
+	filePath := filepath.Join(h.uploadDir, key)
 	dst, err := os.Create(filePath)
 	if err != nil {
 		http.Error(w, "Failed to create file", http.StatusInternalServerError)
@@ -844,7 +1674,7 @@ func (h *DocumentHandler) HandleFileDownload(w http.ResponseWriter, r *http.Requ
 	vars := mux.Vars(r)
 	hash := vars["hash"]
 
-	filePath := filepath.Join(uploadDir, hash)
+	filePath := filepath.Join(h.uploadDir, hash)
 
 	// Проверяем существование файла
 	if _, err := os.Stat(filePath); os.IsNotExist(err) {
@@ -863,6 +1693,12 @@ func (h *DocumentHandler) HandleFileDownload(w http.ResponseWriter, r *http.Requ
 			return
 		}
 
+		quality, filter, err := parseResizeOptions(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
 		// Читаем исходное изображение
 		file, err := os.Open(filePath)
 		if err != nil {
@@ -878,7 +1714,7 @@ func (h *DocumentHandler) HandleFileDownload(w http.ResponseWriter, r *http.Requ
 		}
 
 		// Создаем новое изображение с нужными размерами
-		resizedImg := imaging.Resize(img, width, height, imaging.Lanczos)
+		resizedImg := imaging.Resize(img, width, height, filter)
 
 		// Определяем Content-Type
 		contentType := mime.TypeByExtension(filepath.Ext(filePath))
@@ -891,7 +1727,7 @@ func (h *DocumentHandler) HandleFileDownload(w http.ResponseWriter, r *http.Requ
 		// Кодируем изображение в ответ
 		switch strings.ToLower(filepath.Ext(filePath)) {
 		case ".jpg", ".jpeg":
-			jpeg.Encode(w, resizedImg, nil)
+			jpeg.Encode(w, resizedImg, &jpeg.Options{Quality: quality})
 		case ".png":
 			png.Encode(w, resizedImg)
 		case ".gif":
@@ -912,3 +1748,55 @@ func (h *DocumentHandler) HandleFileDownload(w http.ResponseWriter, r *http.Requ
 	w.Header().Set("Content-Type", contentType)
 	http.ServeFile(w, r, filePath)
 }
+
+// resizeFilters - допустимые значения параметра filter
+var resizeFilters = map[string]imaging.ResampleFilter{
+	"lanczos": imaging.Lanczos,
+	"box":     imaging.Box,
+	"linear":  imaging.Linear,
+}
+
+// parseResizeOptions читает параметры quality (1-100, для JPEG) и filter.
+// Отсутствующие параметры заменяются значениями по умолчанию.
+func parseResizeOptions(query url.Values) (int, imaging.ResampleFilter, error) {
+	quality := defaultJPEGQuality
+	if q := query.Get("quality"); q != "" {
+		var err error
+		quality, err = strconv.Atoi(q)
+		if err != nil || quality < 1 || quality > 100 {
+			return 0, imaging.ResampleFilter{}, errors.New("Invalid quality parameter: must be 1-100")
+		}
+	}
+
+	filter := imaging.Lanczos
+	if f := query.Get("filter"); f != "" {
+		var ok bool
+		filter, ok = resizeFilters[strings.ToLower(f)]
+		if !ok {
+			return 0, imaging.ResampleFilter{}, errors.New("Invalid filter parameter: use lanczos, box or linear")
+		}
+	}
+
+	return quality, filter, nil
+}
+
+// GetFileReferences возвращает документы, ссылающиеся на загруженный файл
+func (h *DocumentHandler) GetFileReferences(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	hash := vars["hash"]
+
+	docs := []ShortDocument{}
+	for _, docPath := range h.search.FileReferences(hash) {
+		doc, err := h.storage.GetDocument(docPath)
+		if err != nil {
+			log.Printf("Warning: failed to load document %q referencing %q: %v", docPath, hash, err)
+			continue
+		}
+		docs = append(docs, *documentToShort(&doc))
+	}
+
+	writeJSON(w, r, struct {
+		File      string          `json:"file"`
+		Documents []ShortDocument `json:"documents"`
+	}{File: hash, Documents: docs})
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestSearchDocumentsRejectsLongQuery(t *testing.T) {
+	h := NewSearchHandler(NewSearchEngine([]string{"english"}))
+	h.maxQueryLength = 16
+	h.maxQueryTerms = 3
+
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{"too long", strings.Repeat("a", 17)},
+		{"too many terms", "one two three four"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/api/search?q="+url.QueryEscape(tt.query), nil)
+			h.SearchDocuments(rec, req)
+			if rec.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+			}
+		})
+	}
+}
+
+func TestSearchDocumentsAcceptsNormalQuery(t *testing.T) {
+	engine := NewSearchEngine([]string{"english"})
+	engine.IndexDocument(Document{ID: "a", Title: "Kafka", Content: "consumer lag", Path: "a"})
+	h := NewSearchHandler(engine)
+	h.maxQueryLength = 16
+	h.maxQueryTerms = 3
+
+	rec := httptest.NewRecorder()
+	h.SearchDocuments(rec, httptest.NewRequest("GET", "/api/search?q=kafka+lag", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	if !strings.Contains(rec.Body.String(), `"total":1`) {
+		t.Fatalf("unexpected body: %s", rec.Body)
+	}
+}
+
+func newTestDocumentHandler(t *testing.T) (*DocumentHandler, *GitStorage, *SearchEngine) {
+	t.Helper()
+	dir := t.TempDir()
+	gs, err := NewGitStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	drafts, err := NewDraftStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	md, err := NewMetadata(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine := NewSearchEngine([]string{"english"})
+	return NewDocumentHandler(gs, engine, md, drafts), gs, engine
+}
+
+func serve(h http.HandlerFunc, method, target string, body io.Reader, vars map[string]string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(method, target, body)
+	if vars != nil {
+		req = mux.SetURLVars(req, vars)
+	}
+	h(rec, req)
+	return rec
+}
+
+func TestGetRelatedDocumentsResponseShape(t *testing.T) {
+	h, gs, engine := newTestDocumentHandler(t)
+	a := mustCreate(t, gs, "", "A", "")
+	b := mustCreate(t, gs, a.Path, "B", "See [c](/doc/c) and [gone](/doc/missing)")
+	mustCreate(t, gs, "", "C", "Back to [b](/document/"+b.Path+")")
+	if err := engine.LoadFromStorage(gs); err != nil {
+		t.Fatal(err)
+	}
+	vars := map[string]string{"rest": b.Path}
+
+	rec := serve(h.GetRelatedDocuments, "GET", "/api/related/a/b", nil, vars)
+	var grouped RelatedDocuments
+	if err := json.NewDecoder(rec.Body).Decode(&grouped); err != nil {
+		t.Fatal(err)
+	}
+	assertPaths(t, "ancestors", grouped.Ancestors, "a")
+	assertPaths(t, "linkedFrom", grouped.LinkedFrom, "c")
+	// Битая ссылка на missing пропускается
+	assertPaths(t, "linksTo", grouped.LinksTo, "c")
+
+	// Плоская карта, которую читает дерево документов на фронтенде
+	rec = serve(h.GetRelatedDocuments, "GET", "/api/related/a/b?legacy=true", nil, vars)
+	var legacy map[string][]ShortDocument
+	if err := json.NewDecoder(rec.Body).Decode(&legacy); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := legacy["root"]; !ok {
+		t.Fatalf("legacy response has no root key: %v", legacy)
+	}
+
+	rec = serve(h.GetRelatedDocuments, "GET", "/api/related/x", nil, map[string]string{"rest": "x"})
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestSearchDocumentsNDJSON(t *testing.T) {
+	engine := NewSearchEngine([]string{"english"})
+	for _, id := range []string{"a", "b", "c", "d", "e"} {
+		engine.IndexDocument(Document{ID: id, Title: "Kafka " + id, Content: "consumer", Path: id})
+	}
+	srv := httptest.NewServer(http.HandlerFunc(NewSearchHandler(engine).SearchDocuments))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/api/search?q=kafka&format=ndjson&pageSize=2&page=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+
+	var lines []string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines, want 2 documents + metadata: %q", len(lines), lines)
+	}
+
+	for _, line := range lines[:2] {
+		var doc Document
+		if err := json.Unmarshal([]byte(line), &doc); err != nil || doc.ID == "" {
+			t.Fatalf("bad document line %q: %v", line, err)
+		}
+	}
+
+	var meta struct {
+		Metadata SearchMetadata `json:"metadata"`
+	}
+	if err := json.Unmarshal([]byte(lines[2]), &meta); err != nil {
+		t.Fatal(err)
+	}
+	want := SearchMetadata{Total: 5, CurrentPage: 1, TotalPages: 3, PageSize: 2}
+	if meta.Metadata != want {
+		t.Fatalf("metadata = %+v, want %+v", meta.Metadata, want)
+	}
+}
+
+func TestWriteJSONPrettyPrinting(t *testing.T) {
+	h, gs, _ := newTestDocumentHandler(t)
+	mustCreate(t, gs, "", "A", "")
+
+	rec := serve(h.GetRootDocuments, "GET", "/api/documents", nil, nil)
+	if strings.Contains(rec.Body.String(), "\n  ") {
+		t.Fatalf("default output is indented: %s", rec.Body)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+
+	rec = serve(h.GetRootDocuments, "GET", "/api/documents?pretty=true", nil, nil)
+	if !strings.Contains(rec.Body.String(), "[\n  {\n    \"id\"") {
+		t.Fatalf("?pretty=true output is not indented: %s", rec.Body)
+	}
+
+	prettyJSON = true
+	defer func() { prettyJSON = false }()
+	rec = serve(h.GetRootDocuments, "GET", "/api/documents", nil, nil)
+	if !strings.Contains(rec.Body.String(), "\n  ") {
+		t.Fatalf("--pretty output is not indented: %s", rec.Body)
+	}
+}
+
+func TestSearchDocumentsLanguageOverride(t *testing.T) {
+	engine := NewSearchEngine([]string{"english", "russian"})
+	engine.IndexDocument(Document{ID: "a", Title: "A", Content: "running", Path: "a"})
+	engine.IndexDocument(Document{ID: "b", Title: "B", Content: "run", Path: "b"})
+	h := NewSearchHandler(engine)
+
+	search := func(query string) (int, SearchResults) {
+		rec := httptest.NewRecorder()
+		h.SearchDocuments(rec, httptest.NewRequest("GET", "/api/search?"+query, nil))
+		var results SearchResults
+		if rec.Code == http.StatusOK {
+			if err := json.NewDecoder(rec.Body).Decode(&results); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return rec.Code, results
+	}
+
+	// Английский стемминг сводит running к run и находит оба документа
+	if _, all := search("q=running"); all.Total != 2 {
+		t.Fatalf("unrestricted total = %d, want 2", all.Total)
+	}
+	if _, en := search("q=running&lang=english"); en.Total != 2 {
+		t.Fatalf("english total = %d, want 2", en.Total)
+	}
+	_, ru := search("q=running&lang=russian")
+	if ru.Total != 1 || ru.Results[0].ID != "a" {
+		t.Fatalf("russian results = %+v, want only a", ru.Results)
+	}
+
+	if code, _ := search("q=running&lang=german"); code != http.StatusBadRequest {
+		t.Fatalf("unknown language status = %d, want %d", code, http.StatusBadRequest)
+	}
+}
+
+func TestGetDocumentSiblings(t *testing.T) {
+	h, gs, _ := newTestDocumentHandler(t)
+	parent := mustCreate(t, gs, "", "Parent", "")
+	a := mustCreate(t, gs, parent.Path, "A", "")
+	b := mustCreate(t, gs, parent.Path, "B", "")
+	c := mustCreate(t, gs, parent.Path, "C", "")
+
+	siblings := func(docPath string) DocumentSiblings {
+		t.Helper()
+		rec := serve(h.GetDocumentSiblings, "GET", "/api/document/"+docPath+"/siblings", nil, map[string]string{"rest": docPath})
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d, body %s", docPath, rec.Code, rec.Body)
+		}
+		var resp DocumentSiblings
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	pathOf := func(doc *ShortDocument) string {
+		if doc == nil {
+			return ""
+		}
+		return doc.Path
+	}
+
+	tests := []struct {
+		doc        Document
+		index      int
+		prev, next string
+	}{
+		{a, 0, "", b.Path},
+		{b, 1, a.Path, c.Path},
+		{c, 2, b.Path, ""},
+	}
+	for _, tt := range tests {
+		resp := siblings(tt.doc.Path)
+		if len(resp.Siblings) != 3 || resp.Index != tt.index || pathOf(resp.Previous) != tt.prev || pathOf(resp.Next) != tt.next {
+			t.Errorf("%s: index %d prev %q next %q, want %d %q %q",
+				tt.doc.Path, resp.Index, pathOf(resp.Previous), pathOf(resp.Next), tt.index, tt.prev, tt.next)
+		}
+	}
+
+	// Документ верхнего уровня
+	other := mustCreate(t, gs, "", "Other", "")
+	root := siblings(other.Path)
+	if len(root.Siblings) != 2 || root.Previous != nil || pathOf(root.Next) != parent.Path {
+		t.Fatalf("root siblings = %+v", root)
+	}
+
+	rec := serve(h.GetDocumentSiblings, "GET", "/api/document/missing/siblings", nil, map[string]string{"rest": "missing"})
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("missing status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
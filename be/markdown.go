@@ -0,0 +1,123 @@
+// markdown.go
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+type Heading struct {
+	Level  int    `json:"level"`
+	Text   string `json:"text"`
+	Anchor string `json:"anchor"`
+	Line   int    `json:"line"`
+}
+
+var (
+	atxHeadingRegex   = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	inlineLinkRegex   = regexp.MustCompile(`!?\[([^\]]*)\]\([^)]*\)`)
+	inlineMarkupRegex = regexp.MustCompile("[`*_~]+")
+)
+
+// extractHeadings возвращает ATX заголовки документа (# ...) с якорями.
+// Заголовки внутри блоков кода пропускаются.
+func extractHeadings(content string) []Heading {
+	headings := []Heading{}
+	slugs := newSlugger()
+
+	var fence string
+	for i, line := range strings.Split(content, "\n") {
+		line = strings.TrimRight(line, "\r")
+
+		if marker := codeFenceMarker(line); marker != "" {
+			if fence == "" {
+				fence = marker
+			} else if strings.HasPrefix(strings.TrimSpace(line), fence) {
+				fence = ""
+			}
+			continue
+		}
+		if fence != "" {
+			continue
+		}
+
+		match := atxHeadingRegex.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		text := headingText(match[2])
+		headings = append(headings, Heading{
+			Level:  len(match[1]),
+			Text:   text,
+			Anchor: slugs.slug(text),
+			Line:   i + 1,
+		})
+	}
+
+	return headings
+}
+
+// codeFenceMarker возвращает ``` или ~~~, если строка открывает или закрывает блок кода
+func codeFenceMarker(line string) string {
+	trimmed := strings.TrimLeft(line, " ")
+	if len(line)-len(trimmed) > 3 {
+		return ""
+	}
+	for _, marker := range []string{"```", "~~~"} {
+		if strings.HasPrefix(trimmed, marker) {
+			return marker
+		}
+	}
+	return ""
+}
+
+// headingText убирает из заголовка inline разметку, оставляя видимый текст
+func headingText(raw string) string {
+	text := inlineLinkRegex.ReplaceAllString(raw, "$1")
+	text = inlineMarkupRegex.ReplaceAllString(text, "")
+	return strings.TrimSpace(text)
+}
+
+// slugger генерирует якоря заголовков в стиле GitHub. Якорь зависит только от текста
+// заголовка и номера повтора, поэтому не меняется при правках остального документа.
+type slugger struct {
+	used map[string]int
+}
+
+func newSlugger() *slugger {
+	return &slugger{used: make(map[string]int)}
+}
+
+func (s *slugger) slug(text string) string {
+	base := headingSlug(text)
+	slug := base
+	for {
+		count, ok := s.used[slug]
+		if !ok {
+			break
+		}
+		s.used[slug] = count + 1
+		slug = fmt.Sprintf("%s-%d", base, count+1)
+	}
+	s.used[slug] = 0
+	return slug
+}
+
+// headingSlug переводит текст в нижний регистр, убирает пунктуацию и заменяет пробелы на дефисы
+func headingSlug(text string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.IsLetter(r), unicode.IsDigit(r), r == '_', r == '-':
+			b.WriteRune(r)
+		case unicode.IsSpace(r):
+			b.WriteRune('-')
+		}
+	}
+	if b.Len() == 0 {
+		return "section"
+	}
+	return b.String()
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func headingAnchors(headings []Heading) []string {
+	anchors := make([]string, 0, len(headings))
+	for _, h := range headings {
+		anchors = append(anchors, h.Anchor)
+	}
+	return anchors
+}
+
+func TestExtractHeadings(t *testing.T) {
+	content := strings.Join([]string{
+		"# Getting Started",
+		"text",
+		"## Install `okidoki` **now**",
+		"```",
+		"# not a heading",
+		"```",
+		"### [Links](http://example.com) & C++ ###",
+		"#hashtag",
+		"## Привет, мир!",
+	}, "\n")
+
+	headings := extractHeadings(content)
+	want := []Heading{
+		{Level: 1, Text: "Getting Started", Anchor: "getting-started", Line: 1},
+		{Level: 2, Text: "Install okidoki now", Anchor: "install-okidoki-now", Line: 3},
+		{Level: 3, Text: "Links & C++", Anchor: "links--c", Line: 7},
+		{Level: 2, Text: "Привет, мир!", Anchor: "привет-мир", Line: 9},
+	}
+	if len(headings) != len(want) {
+		t.Fatalf("headings = %+v", headings)
+	}
+	for i := range want {
+		if headings[i] != want[i] {
+			t.Errorf("heading %d = %+v, want %+v", i, headings[i], want[i])
+		}
+	}
+}
+
+func TestHeadingAnchorsUnique(t *testing.T) {
+	headings := extractHeadings("# Intro\n## Intro\n## Intro-1\n# Intro\n# !!!")
+	got := headingAnchors(headings)
+	want := []string{"intro", "intro-1", "intro-1-1", "intro-2", "section"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("anchors = %v, want %v", got, want)
+	}
+}
+
+func TestHeadingAnchorsStableAcrossEdits(t *testing.T) {
+	before := extractHeadings("# Intro\ntext\n## Setup\n## Usage")
+	after := extractHeadings("# Intro\nrewritten paragraph\n\nmore text\n## Setup\nnew body\n## Usage")
+
+	if strings.Join(headingAnchors(before), ",") != strings.Join(headingAnchors(after), ",") {
+		t.Fatalf("anchors changed: %v -> %v", headingAnchors(before), headingAnchors(after))
+	}
+}
+
+func TestGetDocumentOutline(t *testing.T) {
+	h, gs, _ := newTestDocumentHandler(t)
+	a := mustCreate(t, gs, "", "A", "# One\n## Two")
+
+	rec := serve(h.GetDocumentOutline, "GET", "/api/outline/a", nil, map[string]string{"rest": a.Path})
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"anchor":"two"`) {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body)
+	}
+
+	rec = serve(h.GetDocumentOutline, "GET", "/api/outline/x", nil, map[string]string{"rest": "x"})
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
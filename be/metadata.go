@@ -2,34 +2,52 @@
 package main
 
 import (
+	"bytes"
 	"encoding/gob"
+	"encoding/json"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/pkg/errors"
 )
 
+// metadataVersion - версия формата файла метаданных. Новые поля добавляются
+// без смены версии: отсутствующие в старом файле поля остаются нулевыми.
+const metadataVersion = 1
+
 type Metadata struct {
-	LastViewedDocs []*ShortDocument
-	Favorites      []*ShortDocument
+	Version        int                       `json:"version"`
+	LastViewedDocs []*ShortDocument          `json:"lastViewed"`
+	Favorites      []*ShortDocument          `json:"favorites"`
+	HomePath       string                    `json:"homePath,omitempty"` // путь домашнего документа, пусто - не задан
+	Views          map[string]*DocumentViews `json:"views"`              // статистика просмотров по путям
 
-	Filename       string
+	Filename       string `json:"-"`
 	checkPeriodMin int
 	changedFlag    bool
 	stopChan       chan struct{}
+	stopOnce       sync.Once
 	mu             sync.Mutex // для безопасного доступа к полям
 }
 
+// Stop останавливает фоновую проверку и сохраняет несохраненные изменения.
+// Повторный вызов только сохраняет изменения.
 func (m *Metadata) Stop() {
 	log.Printf("Metadata.Stop() called")
-	if m.stopChan != nil {
-		log.Printf("Closing stopChan")
-		close(m.stopChan)
+	m.stopOnce.Do(func() {
+		if m.stopChan != nil {
+			log.Printf("Closing stopChan")
+			close(m.stopChan)
+		}
+	})
+	if err := m.saveIfChanged(); err != nil {
+		log.Printf("Metadata.Stop: failed to save metadata: %v", err)
 	}
 	log.Printf("Metadata.Stop() completed")
 }
@@ -48,6 +66,7 @@ func NewMetadata(filename string, checkPeriodMin int) (*Metadata, error) {
 	// Запускаем фоновую проверку изменений
 	if checkPeriodMin > 0 {
 		log.Printf("NewMetadata: starting background change checker with period %d minutes", checkPeriodMin)
+		md.stopChan = make(chan struct{})
 		go md.startChangeChecker()
 	}
 
@@ -66,24 +85,10 @@ func (m *Metadata) startChangeChecker() {
 		select {
 		case <-ticker.C:
 			log.Printf("Metadata.changeChecker: tick received, checking for changes")
-			m.mu.Lock()
-			log.Printf("Metadata.changeChecker: mutex locked")
-
-			if m.changedFlag {
-				log.Printf("Metadata.changeChecker: changes detected, saving to disk")
-				if err := m.SaveOnDisk(); err != nil {
-					log.Printf("Metadata.changeChecker: failed to auto-save metadata: %v", err)
-				} else {
-					log.Printf("Metadata.changeChecker: auto-save completed successfully")
-					m.changedFlag = false
-				}
-			} else {
-				log.Printf("Metadata.changeChecker: no changes detected")
+			if err := m.saveIfChanged(); err != nil {
+				log.Printf("Metadata.changeChecker: failed to auto-save metadata: %v", err)
 			}
 
-			m.mu.Unlock()
-			log.Printf("Metadata.changeChecker: mutex unlocked")
-
 		case <-m.stopChan:
 			log.Printf("Metadata.changeChecker: stop signal received")
 			return // Завершаем горутину
@@ -113,7 +118,8 @@ func (m *Metadata) AddToFavorites(doc *ShortDocument) {
 	}
 
 	m.changedFlag = true
-	m.Favorites = append(m.Favorites, doc)
+	fav := *doc
+	m.Favorites = append(m.Favorites, &fav)
 	log.Printf("Metadata.AddToFavorites: document added to favorites, total favorites: %d", len(m.Favorites))
 }
 
@@ -175,11 +181,28 @@ func (m *Metadata) GetFavorites() []*ShortDocument {
 	}()
 
 	log.Printf("Metadata.GetFavorites: returning %d favorites", len(m.Favorites))
-	return m.Favorites
+	return copyShortDocuments(m.Favorites)
 }
 
+// copyShortDocuments копирует список вместе с документами: вызывающий читает
+// его уже без блокировки, а Metadata меняет свои списки на месте
+func copyShortDocuments(docs []*ShortDocument) []*ShortDocument {
+	out := make([]*ShortDocument, 0, len(docs))
+	for _, d := range docs {
+		if d == nil {
+			continue
+		}
+		c := *d
+		out = append(out, &c)
+	}
+	return out
+}
+
+// maxLastViewed - длина списка последних просмотренных документов
+const maxLastViewed = 5
+
 func (m *Metadata) UpdateViewedMeta(viewed *ShortDocument) {
-	log.Printf("Metadata.UpdateViewedMeta: updating viewed meta for doc ID: %d, Path: %s", viewed.ID, viewed.Path)
+	log.Printf("Metadata.UpdateViewedMeta: updating viewed meta for doc ID: %s, Path: %s", viewed.ID, viewed.Path)
 	callerInfo := getCallerInfo()
 	log.Printf("Metadata.UpdateViewedMeta: called from %s", callerInfo)
 
@@ -192,31 +215,155 @@ func (m *Metadata) UpdateViewedMeta(viewed *ShortDocument) {
 
 	m.changedFlag = true
 
-	if len(m.LastViewedDocs) < 5 {
-		m.LastViewedDocs = append(m.LastViewedDocs, viewed)
-		log.Printf("Metadata.UpdateViewedMeta: added to last viewed (list size: %d)", len(m.LastViewedDocs))
-		return
+	// Уже просмотренный документ переносится в начало, а не добавляется повторно.
+	// Сравнение по пути: ID совпадают у одноименных документов разных родителей.
+	front := *viewed // копия: вызывающий может менять свой документ
+	docs := make([]*ShortDocument, 0, maxLastViewed)
+	docs = append(docs, &front)
+	for _, d := range m.LastViewedDocs {
+		if d != nil && d.Path != viewed.Path && len(docs) < maxLastViewed {
+			docs = append(docs, d)
+		}
 	}
+	m.LastViewedDocs = docs
+	m.recordViewLocked(viewed, time.Now())
+	log.Printf("Metadata.UpdateViewedMeta: document moved to front (list size: %d)", len(m.LastViewedDocs))
+}
 
-	// Поиск существующего документа
-	for i := 0; i < len(m.LastViewedDocs); i++ {
-		if m.LastViewedDocs[i] != nil && m.LastViewedDocs[i].ID == viewed.ID {
-			// Сдвиг элементов вправо для создания места на позиции 0
-			copy(m.LastViewedDocs[1:], m.LastViewedDocs[:i])
-			// Установка viewed на позицию 0
-			m.LastViewedDocs[0] = viewed
-			log.Printf("Metadata.UpdateViewedMeta: existing document moved to front")
-			return
+// RelocatePaths переписывает пути избранного, последних просмотренных и домашнего
+// документа после перемещения oldPath (вместе с потомками) в newPath
+func (m *Metadata) RelocatePaths(oldPath, newPath string) {
+	log.Printf("Metadata.RelocatePaths: %s -> %s", oldPath, newPath)
+
+	m.mu.Lock()
+	log.Printf("Metadata.RelocatePaths: mutex locked")
+	defer func() {
+		m.mu.Unlock()
+		log.Printf("Metadata.RelocatePaths: mutex unlocked")
+	}()
+
+	relocate := func(p string) (string, bool) {
+		if !isSubPath(p, oldPath) {
+			return p, false
+		}
+		return newPath + strings.TrimPrefix(p, oldPath), true
+	}
+	// Записи заменяются копиями: один и тот же документ может быть в обоих списках
+	for _, list := range [][]*ShortDocument{m.Favorites, m.LastViewedDocs} {
+		for i, d := range list {
+			if d == nil {
+				continue
+			}
+			if p, ok := relocate(d.Path); ok {
+				moved := *d
+				moved.Path = p
+				list[i] = &moved
+				m.changedFlag = true
+			}
+		}
+	}
+	if p, ok := relocate(m.HomePath); ok {
+		m.HomePath = p
+		m.changedFlag = true
+	}
+	moved := make(map[string]*DocumentViews)
+	for p, views := range m.Views {
+		if newP, ok := relocate(p); ok {
+			delete(m.Views, p)
+			views.Document.Path = newP
+			moved[newP] = views
+		}
+	}
+	for p, views := range moved {
+		m.Views[p] = views
+		m.changedFlag = true
+	}
+}
+
+// RemoveFromLastViewed убирает удаленный документ из списка последних просмотренных
+func (m *Metadata) RemoveFromLastViewed(path string) {
+	log.Printf("Metadata.RemoveFromLastViewed: removing path: %s", path)
+
+	m.mu.Lock()
+	log.Printf("Metadata.RemoveFromLastViewed: mutex locked")
+	defer func() {
+		m.mu.Unlock()
+		log.Printf("Metadata.RemoveFromLastViewed: mutex unlocked")
+	}()
+
+	kept := m.LastViewedDocs[:0]
+	for _, d := range m.LastViewedDocs {
+		if d != nil && d.Path == path {
+			m.changedFlag = true
+			continue
+		}
+		kept = append(kept, d)
+	}
+	m.LastViewedDocs = kept
+	if _, ok := m.Views[path]; ok {
+		delete(m.Views, path)
+		m.changedFlag = true
+	}
+}
+
+// PruneMissing убирает из избранного, последних просмотренных и статистики
+// просмотров документы, для которых exists возвращает false, например
+// удаленные в обход API. Возвращает число убранных путей.
+func (m *Metadata) PruneMissing(exists func(path string) bool) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	missing := make(map[string]bool)
+	check := func(path string) bool {
+		gone, ok := missing[path]
+		if !ok {
+			gone = !exists(path)
+			missing[path] = gone
+		}
+		return gone
+	}
+	prune := func(list []*ShortDocument) []*ShortDocument {
+		kept := make([]*ShortDocument, 0, len(list))
+		for _, d := range list {
+			if d == nil || check(d.Path) {
+				continue
+			}
+			kept = append(kept, d)
+		}
+		return kept
+	}
+
+	m.Favorites = prune(m.Favorites)
+	m.LastViewedDocs = prune(m.LastViewedDocs)
+	for path := range m.Views {
+		if check(path) {
+			delete(m.Views, path)
+		}
+	}
+
+	pruned := 0
+	for path, gone := range missing {
+		if gone {
+			log.Printf("Metadata.PruneMissing: dropping missing document %s", path)
+			pruned++
 		}
 	}
+	if pruned > 0 {
+		m.changedFlag = true
+	}
+	return pruned
+}
 
-	// Если документ не найден - сдвиг вправо и добавление нового документа
-	copy(m.LastViewedDocs[1:], m.LastViewedDocs[:len(m.LastViewedDocs)-1])
-	m.LastViewedDocs[0] = viewed
-	log.Printf("Metadata.UpdateViewedMeta: new document added to front (list size: %d)", len(m.LastViewedDocs))
+// documentExists проверяет путь по хранилищу. Ошибки, кроме отсутствия
+// документа, не считаются поводом удалять его из метаданных.
+func documentExists(storage Storage) func(path string) bool {
+	return func(path string) bool {
+		_, err := storage.GetDocument(path)
+		return !errors.Is(err, ErrDocumentNotFound)
+	}
 }
 
-func (m *Metadata) GetLastViewedDocuments() []ShortDocument {
+func (m *Metadata) GetLastViewedDocuments() []ViewedDocument {
 	log.Printf("Metadata.GetLastViewedDocuments: called")
 	callerInfo := getCallerInfo()
 	log.Printf("Metadata.GetLastViewedDocuments: called from %s", callerInfo)
@@ -228,14 +375,37 @@ func (m *Metadata) GetLastViewedDocuments() []ShortDocument {
 		log.Printf("Metadata.GetLastViewedDocuments: mutex unlocked")
 	}()
 
-	out := make([]ShortDocument, len(m.LastViewedDocs))
+	out := make([]ViewedDocument, len(m.LastViewedDocs))
 	for i, d := range m.LastViewedDocs {
-		out[i] = *d
+		out[i] = m.viewedDocumentLocked(*d)
 	}
 	log.Printf("Metadata.GetLastViewedDocuments: returning %d documents", len(out))
 	return out
 }
 
+func (m *Metadata) SetHomePath(path string) {
+	log.Printf("Metadata.SetHomePath: setting home path: %s", path)
+
+	m.mu.Lock()
+	log.Printf("Metadata.SetHomePath: mutex locked")
+	defer func() {
+		m.mu.Unlock()
+		log.Printf("Metadata.SetHomePath: mutex unlocked")
+	}()
+
+	m.changedFlag = true
+	m.HomePath = path
+}
+
+func (m *Metadata) GetHomePath() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.HomePath
+}
+
+// SaveOnDisk сохраняет метаданные. Вызов безопасен из любой горутины, в том
+// числе параллельно с фоновой проверкой: запись идет под m.mu.
 func (m *Metadata) SaveOnDisk() error {
 	log.Printf("Metadata.SaveOnDisk: called")
 	callerInfo := getCallerInfo()
@@ -248,66 +418,131 @@ func (m *Metadata) SaveOnDisk() error {
 		log.Printf("Metadata.SaveOnDisk: mutex unlocked")
 	}()
 
+	return m.saveLocked()
+}
+
+// saveIfChanged сохраняет метаданные, только если они менялись после сохранения
+func (m *Metadata) saveIfChanged() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.changedFlag {
+		log.Printf("Metadata.saveIfChanged: no changes detected")
+		return nil
+	}
+	log.Printf("Metadata.saveIfChanged: changes detected, saving to disk")
+	return m.saveLocked()
+}
+
+// saveLocked пишет метаданные во временный файл и подменяет им основной, чтобы
+// прерванная запись не испортила сохраненные данные. Вызывается под m.mu.
+func (m *Metadata) saveLocked() error {
 	log.Printf("Metadata.SaveOnDisk: opening file %s", m.Filename)
-	file, err := os.OpenFile(m.Filename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	file, err := os.CreateTemp(filepath.Dir(m.Filename), filepath.Base(m.Filename)+".tmp-*")
 	if err != nil {
 		log.Printf("Metadata.SaveOnDisk: error opening file: %v", err)
 		return fmt.Errorf("ошибка при открытии файла: %v", err)
 	}
+	defer os.Remove(file.Name())
 	defer file.Close()
 
 	log.Printf("Metadata.SaveOnDisk: encoding data")
-	encoder := gob.NewEncoder(file)
+	m.Version = metadataVersion
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
 	if err := encoder.Encode(m); err != nil {
 		log.Printf("Metadata.SaveOnDisk: encoding error: %v", err)
 		return fmt.Errorf("ошибка при кодировании: %v", err)
 	}
+	// Данные должны попасть на диск до подмены файла
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("ошибка при записи файла: %v", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("ошибка при записи файла: %v", err)
+	}
+	if err := os.Rename(file.Name(), m.Filename); err != nil {
+		return fmt.Errorf("ошибка при замене файла: %v", err)
+	}
 
-	m.changedFlag = true
+	m.changedFlag = false
 	log.Printf("Metadata.SaveOnDisk: completed successfully")
 	return nil
 }
 
+// newMetadataFile создает пустые метаданные и сразу сохраняет их в filename
+func newMetadataFile(filename string) (*Metadata, error) {
+	md := &Metadata{
+		Filename:       filename,
+		LastViewedDocs: make([]*ShortDocument, 0, maxLastViewed),
+		Views:          make(map[string]*DocumentViews),
+	}
+	if err := md.SaveOnDisk(); err != nil {
+		log.Printf("loadMetadata: error saving new metadata: %v", err)
+		return nil, err
+	}
+	log.Printf("loadMetadata: new metadata file created successfully")
+	return md, nil
+}
+
 func loadMetadata(filename string) (*Metadata, error) {
 	log.Printf("loadMetadata: loading from %s", filename)
 
-	file, err := os.Open(filename)
+	data, err := os.ReadFile(filename)
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
 			log.Printf("loadMetadata: file does not exist, creating new metadata file")
-			if _, err := os.Create(filename); err != nil {
-				log.Printf("loadMetadata: error creating file: %v", err)
-				return nil, fmt.Errorf("create metadata file: %v", err)
-			}
-			md := &Metadata{
-				Filename:       filename,
-				LastViewedDocs: make([]*ShortDocument, 0, 5),
-			}
-			if err := md.SaveOnDisk(); err != nil {
-				os.Remove(filename)
-				log.Printf("loadMetadata: error saving new metadata: %v", err)
-				return nil, err
-			}
-			log.Printf("loadMetadata: new metadata file created successfully")
-			return md, nil
+			return newMetadataFile(filename)
 		}
 		log.Printf("loadMetadata: error opening file: %v", err)
 		return nil, fmt.Errorf("ошибка при открытии файла: %v", err)
 	}
-	defer file.Close()
 
 	log.Printf("loadMetadata: decoding existing metadata")
-	decoder := gob.NewDecoder(file)
-	var metadata Metadata
-	if err := decoder.Decode(&metadata); err != nil {
-		log.Printf("loadMetadata: decoding error: %v", err)
-		return nil, fmt.Errorf("ошибка при декодировании: %v", err)
+	metadata, legacy, err := decodeMetadata(data)
+	if err != nil {
+		// Испорченный файл не должен мешать запуску: откладываем его в сторону
+		// для разбора и начинаем с пустых метаданных
+		corrupt := filename + ".corrupt"
+		log.Printf("loadMetadata: WARNING: metadata file is corrupt (%v), moving it to %s and starting fresh", err, corrupt)
+		if err := os.Rename(filename, corrupt); err != nil {
+			return nil, fmt.Errorf("ошибка при переносе испорченного файла: %v", err)
+		}
+		return newMetadataFile(filename)
+	}
+	if metadata.Version > metadataVersion {
+		log.Printf("loadMetadata: WARNING: metadata version %d is newer than supported %d, unknown fields are ignored",
+			metadata.Version, metadataVersion)
 	}
 
 	metadata.Filename = filename // убедимся, что имя файла сохранилось
+	metadata.migrateViews()
+	if legacy {
+		// Однократный перевод старого gob-файла в JSON
+		log.Printf("loadMetadata: converting gob metadata to JSON")
+		if err := metadata.SaveOnDisk(); err != nil {
+			return nil, err
+		}
+	}
 	log.Printf("loadMetadata: metadata loaded successfully, favorites: %d, last viewed: %d",
 		len(metadata.Favorites), len(metadata.LastViewedDocs))
-	return &metadata, nil
+	return metadata, nil
+}
+
+// decodeMetadata разбирает JSON-метаданные, а если файл не похож на JSON -
+// старый gob-формат; legacy сообщает, что файл нужно переписать
+func decodeMetadata(data []byte) (md *Metadata, legacy bool, err error) {
+	md = &Metadata{}
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		if err := json.Unmarshal(trimmed, md); err != nil {
+			return nil, false, err
+		}
+		return md, false, nil
+	}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(md); err != nil {
+		return nil, false, err
+	}
+	return md, true, nil
 }
 
 // getCallerInfo возвращает информацию о caller'е для отладки
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMetadataStopSavesChanges(t *testing.T) {
+	dir := t.TempDir()
+	md, err := NewMetadata(dir, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	md.AddToFavorites(&ShortDocument{ID: "a", Title: "A", Path: "a"})
+	md.SetHomePath("a")
+
+	md.Stop()
+	md.Stop() // повторная остановка не паникует
+
+	reloaded, err := NewMetadata(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reloaded.IsFavorite("a") || reloaded.GetHomePath() != "a" {
+		t.Errorf("metadata after restart: favorites %+v, home %q", reloaded.GetFavorites(), reloaded.GetHomePath())
+	}
+}
+
+func TestMetadataConcurrentSaves(t *testing.T) {
+	md, err := NewMetadata(t.TempDir(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Фоновая проверка и сохранение при остановке могут совпасть по времени
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 10; j++ {
+					md.UpdateViewedMeta(&ShortDocument{ID: "a", Title: "A", Path: "a"})
+					if err := md.saveIfChanged(); err != nil {
+						t.Error(err)
+					}
+					if err := md.SaveOnDisk(); err != nil {
+						t.Error(err)
+					}
+				}
+			}()
+		}
+		wg.Wait()
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("concurrent saves deadlocked")
+	}
+}
+
+func TestMetadataSaveClearsChangedFlag(t *testing.T) {
+	md, err := NewMetadata(t.TempDir(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if md.changedFlag {
+		t.Error("new metadata is dirty after the initial save")
+	}
+
+	md.AddToFavorites(&ShortDocument{ID: "a", Title: "A", Path: "a"})
+	if !md.changedFlag {
+		t.Fatal("change did not set the flag")
+	}
+	if err := md.SaveOnDisk(); err != nil {
+		t.Fatal(err)
+	}
+	if md.changedFlag {
+		t.Error("flag still set after a successful save")
+	}
+}
+
+func TestUpdateViewedMetaMovesToFront(t *testing.T) {
+	md, err := NewMetadata(t.TempDir(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	view := func(p string) {
+		md.UpdateViewedMeta(&ShortDocument{ID: path.Base(p), Title: p, Path: p})
+	}
+	paths := func() string {
+		var got []string
+		for _, d := range md.GetLastViewedDocuments() {
+			got = append(got, d.Path)
+		}
+		return strings.Join(got, ",")
+	}
+
+	for i := 0; i < 3; i++ {
+		view("a")
+	}
+	if got := paths(); got != "a" {
+		t.Fatalf("after three views = %s, want a", got)
+	}
+
+	view("b")
+	view("a")
+	// Одинаковый ID у разных документов не склеивает их
+	view("x/b")
+	if got := paths(); got != "x/b,a,b" {
+		t.Errorf("short list = %s, want x/b,a,b", got)
+	}
+
+	for _, p := range []string{"c", "d", "e", "b"} {
+		view(p)
+	}
+	if got := paths(); got != "b,e,d,c,x/b" {
+		t.Errorf("full list = %s, want b,e,d,c,x/b", got)
+	}
+}
+
+func TestLoadMetadataRecoversFromCorruptFile(t *testing.T) {
+	for name, content := range map[string]string{
+		"garbage":   "not a gob stream",
+		"truncated": "",
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			filename := filepath.Join(dir, "metadata")
+			if err := os.WriteFile(filename, []byte(content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			md, err := NewMetadata(dir, 0)
+			if err != nil {
+				t.Fatalf("NewMetadata: %v", err)
+			}
+			if got := md.GetLastViewedDocuments(); len(got) != 0 {
+				t.Errorf("last viewed = %+v, want empty", got)
+			}
+			saved, err := os.ReadFile(filename + ".corrupt")
+			if err != nil || string(saved) != content {
+				t.Errorf("corrupt copy = %q, %v; want original content", saved, err)
+			}
+
+			md.UpdateViewedMeta(&ShortDocument{ID: "a", Title: "A", Path: "a"})
+			if err := md.SaveOnDisk(); err != nil {
+				t.Fatal(err)
+			}
+			reloaded, err := NewMetadata(dir, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := reloaded.GetLastViewedDocuments(); len(got) != 1 || got[0].Path != "a" {
+				t.Errorf("reloaded = %+v", got)
+			}
+			entries, _ := os.ReadDir(dir)
+			if len(entries) != 2 {
+				t.Errorf("files = %v, want metadata and metadata.corrupt only", entries)
+			}
+		})
+	}
+}
+
+func TestLoadMetadataConvertsGobToJSON(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "metadata")
+	legacy := &Metadata{
+		LastViewedDocs: []*ShortDocument{{ID: "a", Title: "A", Path: "a"}},
+		Favorites:      []*ShortDocument{{ID: "f", Title: "F", Path: "f"}},
+		HomePath:       "a",
+		Filename:       filename,
+	}
+	file, err := os.Create(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := gob.NewEncoder(file).Encode(legacy); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	md, err := NewMetadata(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if md.HomePath != "a" || len(md.Favorites) != 1 || md.Favorites[0].Path != "f" {
+		t.Errorf("converted = %+v", md)
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var onDisk map[string]json.RawMessage
+	if err := json.Unmarshal(data, &onDisk); err != nil {
+		t.Fatalf("file is not JSON after conversion: %v\n%s", err, data)
+	}
+	if string(onDisk["version"]) != "1" || onDisk["favorites"] == nil || onDisk["Filename"] != nil {
+		t.Errorf("unexpected JSON: %s", data)
+	}
+}
+
+func TestLoadMetadataIgnoresUnknownJSONFields(t *testing.T) {
+	dir := t.TempDir()
+	data := `{"version": 2, "favorites": [{"id": "f", "title": "F", "path": "f"}], "somethingNew": {"x": 1}}`
+	if err := os.WriteFile(filepath.Join(dir, "metadata"), []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	md, err := NewMetadata(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(md.Favorites) != 1 || md.Favorites[0].Path != "f" || md.Views == nil {
+		t.Errorf("loaded = %+v", md)
+	}
+}
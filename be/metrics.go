@@ -0,0 +1,154 @@
+// metrics.go
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Счетчики обновляются из обработчиков, поиска и хранилища,
+// а регистрируются в реестре при создании обработчика /metrics
+var (
+	httpRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "okidoki_http_requests_total",
+		Help: "Number of HTTP requests by route, method and status code.",
+	}, []string{"route", "method", "status"})
+
+	httpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "okidoki_http_request_duration_seconds",
+		Help:    "HTTP request duration by route and method.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method"})
+
+	searchQueriesTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "okidoki_search_queries_total",
+		Help: "Number of executed search queries.",
+	})
+
+	searchQueryDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "okidoki_search_query_duration_seconds",
+		Help:    "Search query latency.",
+		Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	})
+
+	gitCommitsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "okidoki_git_commits_total",
+		Help: "Number of commits created in the document repository.",
+	})
+
+	gitStatusDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "okidoki_git_status_duration_seconds",
+		Help:    "Time spent computing the worktree status, cache hits excluded.",
+		Buckets: []float64{.001, .005, .01, .05, .1, .25, .5, 1, 2.5, 5, 10},
+	})
+)
+
+// NewMetricsHandler возвращает обработчик в формате Prometheus
+// с метриками запросов, поиска, коммитов, размером индекса и числом черновиков
+func NewMetricsHandler(searchEngine *SearchEngine, drafts *DraftStorage) http.Handler {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequestsTotal,
+		httpRequestDuration,
+		searchQueriesTotal,
+		searchQueryDuration,
+		gitCommitsTotal,
+		gitStatusDuration,
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "okidoki_search_index_documents",
+			Help: "Number of documents in the search index.",
+		}, func() float64 {
+			documents, _ := searchEngine.IndexSize()
+			return float64(documents)
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "okidoki_search_index_terms",
+			Help: "Number of distinct terms in the search index.",
+		}, func() float64 {
+			_, terms := searchEngine.IndexSize()
+			return float64(terms)
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "okidoki_search_index_postings",
+			Help: "Number of term-document pairs in the search index.",
+		}, func() float64 {
+			return float64(searchEngine.Stats().Postings)
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "okidoki_search_index_content_bytes",
+			Help: "Bytes of document content kept in memory by the search index.",
+		}, func() float64 {
+			return float64(searchEngine.Stats().ContentBytes)
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "okidoki_search_index_approx_bytes",
+			Help: "Approximate memory used by the search index in bytes.",
+		}, func() float64 {
+			return float64(searchEngine.Stats().ApproxBytes)
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "okidoki_drafts",
+			Help: "Number of stored drafts.",
+		}, func() float64 {
+			count, err := drafts.CountDrafts()
+			if err != nil {
+				return 0
+			}
+			return float64(count)
+		}),
+	)
+	return promhttp.HandlerFor(reg, promhttp.HandlerOpts{})
+}
+
+// metricsMiddleware считает запросы и их длительность по шаблону маршрута,
+// чтобы пути документов не порождали отдельные серии
+func metricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := "unmatched"
+		if current := mux.CurrentRoute(r); current != nil {
+			if tpl, err := current.GetPathTemplate(); err == nil {
+				route = tpl
+			}
+		}
+
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		httpRequestsTotal.WithLabelValues(route, r.Method, strconv.Itoa(rec.status)).Inc()
+		httpRequestDuration.WithLabelValues(route, r.Method).Observe(time.Since(start).Seconds())
+	})
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (sr *statusRecorder) WriteHeader(status int) {
+	if !sr.wroteHeader {
+		sr.status = status
+		sr.wroteHeader = true
+	}
+	sr.ResponseWriter.WriteHeader(status)
+}
+
+func (sr *statusRecorder) Write(b []byte) (int, error) {
+	sr.wroteHeader = true
+	return sr.ResponseWriter.Write(b)
+}
+
+func (sr *statusRecorder) Flush() {
+	if f, ok := sr.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestMetricsEndpoint(t *testing.T) {
+	h, gs, engine := newTestDocumentHandler(t)
+	mustCreate(t, gs, "", "Metrics Doc", "searchable content")
+	if err := engine.LoadFromStorage(gs); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.draftStorage.SetDraft(Draft{ID: "d1", Title: "Draft"}); err != nil {
+		t.Fatal(err)
+	}
+
+	r := mux.NewRouter()
+	r.Use(metricsMiddleware)
+	r.Handle("/metrics", NewMetricsHandler(engine, h.draftStorage)).Methods("GET")
+	r.HandleFunc("/api/search", NewSearchHandler(engine).SearchDocuments).Methods("GET")
+	r.HandleFunc("/api/document/{rest:.*}", h.GetDocument).Methods("GET")
+
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	for _, target := range []string{"/api/search?q=searchable", "/api/document/missing"} {
+		resp, err := http.Get(srv.URL + target)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	resp, err := http.Get(srv.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := string(body)
+
+	for _, want := range []string{
+		`okidoki_http_requests_total{method="GET",route="/api/search",status="200"}`,
+		`okidoki_http_requests_total{method="GET",route="/api/document/{rest:.*}",status="500"}`,
+		"okidoki_http_request_duration_seconds_bucket",
+		"okidoki_search_queries_total",
+		"okidoki_search_query_duration_seconds_count",
+		"okidoki_git_commits_total",
+		"okidoki_search_index_documents 1",
+		"okidoki_search_index_postings",
+		"okidoki_search_index_approx_bytes",
+		"okidoki_drafts 1",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("metrics output missing %q", want)
+		}
+	}
+}
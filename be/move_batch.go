@@ -0,0 +1,218 @@
+// move_batch.go
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+var ErrInvalidMove = errors.New("invalid move")
+
+type DocumentMove struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+}
+
+type MoveResult struct {
+	Source  string `json:"source"`
+	Target  string `json:"target"`
+	NewPath string `json:"newPath,omitempty"`
+	Moved   bool   `json:"moved"`
+	Error   string `json:"error,omitempty"`
+}
+
+// MoveDocuments перемещает несколько документов одним коммитом.
+// Сначала проверяются все перемещения: без partial любая ошибка отменяет весь пакет
+// (возвращается ErrInvalidMove и результаты с описанием ошибок), с partial
+// выполняются только корректные. Если коммит не удался, перемещения откатываются.
+func (gs *GitStorage) MoveDocuments(moves []DocumentMove, partial bool) ([]MoveResult, error) {
+	defer gs.invalidateStatus()
+
+	results := make([]MoveResult, len(moves))
+	valid := 0
+	for i, move := range moves {
+		results[i] = MoveResult{Source: move.Source, Target: move.Target}
+		if err := gs.validateMove(moves, i); err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
+		results[i].NewPath = path.Join(move.Target, path.Base(move.Source))
+		valid++
+	}
+
+	if valid < len(moves) && !partial {
+		for i := range results {
+			results[i].NewPath = ""
+		}
+		return results, ErrInvalidMove
+	}
+
+	var done []int
+	rollback := func() {
+		for j := len(done) - 1; j >= 0; j-- {
+			r := results[done[j]]
+			if err := os.Rename(gs.fullPath(r.NewPath), gs.fullPath(r.Source)); err != nil {
+				log.Printf("Warning: failed to roll back move of %s: %v", r.Source, err)
+			}
+			results[done[j]].Moved = false
+		}
+	}
+
+	for i := range results {
+		if results[i].Error != "" {
+			continue
+		}
+		if err := os.Rename(gs.fullPath(results[i].Source), gs.fullPath(results[i].NewPath)); err != nil {
+			rollback()
+			return nil, fmt.Errorf("failed to move %s: %w", results[i].Source, err)
+		}
+		results[i].Moved = true
+		done = append(done, i)
+	}
+
+	if len(done) > 0 {
+		var scope commitScope
+		for _, i := range done {
+			scope = scope.add(treeScope(results[i].Source, results[i].NewPath))
+		}
+		if err := gs.commitChanges(fmt.Sprintf("Move %d documents", len(done)), scope); err != nil {
+			rollback()
+			return nil, fmt.Errorf("failed to commit changes, moves were rolled back: %w", err)
+		}
+	}
+
+	return results, nil
+}
+
+// validateMove проверяет перемещение moves[i] относительно текущего дерева
+// и остальных перемещений пакета
+func (gs *GitStorage) validateMove(moves []DocumentMove, i int) error {
+	source, err := gs.cleanDocPath(moves[i].Source)
+	if err != nil || source != moves[i].Source {
+		return fmt.Errorf("%w: bad source path %q", ErrInvalidMove, moves[i].Source)
+	}
+	target := moves[i].Target
+	if target != "" {
+		if cleaned, err := gs.cleanDocPath(target); err != nil || cleaned != target {
+			return fmt.Errorf("%w: bad target path %q", ErrInvalidMove, target)
+		}
+	}
+
+	if _, err := os.Stat(gs.fullPath(source)); os.IsNotExist(err) {
+		return fmt.Errorf("%w: source document does not exist", ErrInvalidMove)
+	}
+	if _, err := os.Stat(gs.fullPath(target)); os.IsNotExist(err) {
+		return fmt.Errorf("%w: target directory does not exist", ErrInvalidMove)
+	}
+	newPath := path.Join(target, path.Base(source))
+	if _, err := os.Stat(gs.fullPath(newPath)); err == nil {
+		return fmt.Errorf("%w: target document already exists", ErrInvalidMove)
+	}
+	if isSubPath(target, source) {
+		return fmt.Errorf("%w: cannot move a document into itself", ErrInvalidMove)
+	}
+	if err := gs.checkMoveDepth(source, target); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidMove, err)
+	}
+
+	// Перемещения пакета не должны зависеть друг от друга
+	for j, other := range moves {
+		if j == i {
+			continue
+		}
+		if other.Source == source {
+			return fmt.Errorf("%w: document is moved more than once", ErrInvalidMove)
+		}
+		if path.Join(other.Target, path.Base(other.Source)) == newPath {
+			return fmt.Errorf("%w: another move has the same destination", ErrInvalidMove)
+		}
+		if isSubPath(source, other.Source) && source != other.Source {
+			return fmt.Errorf("%w: parent document %s is moved in the same batch", ErrInvalidMove, other.Source)
+		}
+		if isSubPath(target, other.Source) {
+			return fmt.Errorf("%w: target %s is moved in the same batch", ErrInvalidMove, other.Source)
+		}
+	}
+
+	return nil
+}
+
+func (dt *docTree) fullPath(docPath string) string {
+	return filepath.Join(dt.docsDir, filepath.FromSlash(docPath))
+}
+
+// isSubPath сообщает, совпадает ли p с parent или лежит внутри него
+func isSubPath(p, parent string) bool {
+	return p == parent || strings.HasPrefix(p, parent+"/")
+}
+
+func (h *DocumentHandler) MoveDocumentsBatch(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Moves []DocumentMove `json:"moves"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	gitStorage, ok := h.storage.(*GitStorage)
+	if !ok {
+		http.Error(w, "batch move only available with git storage", http.StatusNotImplemented)
+		return
+	}
+
+	results, err := gitStorage.MoveDocuments(req.Moves, r.URL.Query().Get("partial") == "true")
+	if errors.Is(err, ErrInvalidMove) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, r, results)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Изменения уже закоммичены, поэтому ошибки индексации только логируем
+	for _, result := range results {
+		if result.Moved {
+			h.relocateSubtree(result.Source, result.NewPath)
+		}
+	}
+
+	writeJSON(w, r, results)
+}
+
+// relocateSubtree переносит в индексе и метаданных (избранное, просмотры, домашний
+// документ) документ и всех его потомков
+func (h *DocumentHandler) relocateSubtree(oldPath, newPath string) {
+	h.meta.RelocatePaths(oldPath, newPath)
+	h.reindexMoved(oldPath, newPath)
+}
+
+// reindexMoved переиндексирует перемещенный документ и его потомков под новыми путями
+func (h *DocumentHandler) reindexMoved(oldPath, newPath string) {
+	doc, err := h.storage.GetDocument(newPath)
+	if err != nil {
+		log.Printf("Warning: failed to load moved document %s: %v", newPath, err)
+		return
+	}
+
+	if err := h.search.DeleteDocument(oldPath); err != nil {
+		log.Printf("Warning: failed to remove %s from search index: %v", oldPath, err)
+	}
+	if err := h.search.IndexDocument(doc); err != nil {
+		log.Printf("Warning: failed to index %s: %v", newPath, err)
+	}
+
+	for _, child := range doc.Children {
+		h.reindexMoved(path.Join(oldPath, child.ID), path.Join(newPath, child.ID))
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func moveBatch(t *testing.T, h *DocumentHandler, query string, moves []DocumentMove) (int, []MoveResult) {
+	t.Helper()
+	body, _ := json.Marshal(map[string][]DocumentMove{"moves": moves})
+	rec := serve(h.MoveDocumentsBatch, "POST", "/api/documents/move-batch"+query, strings.NewReader(string(body)), nil)
+	var results []MoveResult
+	if err := json.NewDecoder(rec.Body).Decode(&results); err != nil {
+		t.Fatalf("decode %q: %v", rec.Body.String(), err)
+	}
+	return rec.Code, results
+}
+
+func TestMoveDocumentsBatch(t *testing.T) {
+	h, gs, engine := newTestDocumentHandler(t)
+	a := mustCreate(t, gs, "", "A", "")
+	child := mustCreate(t, gs, a.Path, "Child", "platypus")
+	b := mustCreate(t, gs, "", "B", "")
+	target := mustCreate(t, gs, "", "Target", "")
+	if err := engine.LoadFromStorage(gs); err != nil {
+		t.Fatal(err)
+	}
+	h.meta.AddToFavorites(&ShortDocument{ID: child.ID, Title: child.Title, Path: child.Path})
+	commits := countCommits(t, gs)
+
+	code, results := moveBatch(t, h, "", []DocumentMove{
+		{Source: a.Path, Target: target.Path},
+		{Source: b.Path, Target: target.Path},
+	})
+	if code != http.StatusOK {
+		t.Fatalf("status = %d, results %+v", code, results)
+	}
+	if got := countCommits(t, gs); got != commits+1 {
+		t.Fatalf("commits = %d, want %d", got, commits+1)
+	}
+	for _, result := range results {
+		if !result.Moved {
+			t.Fatalf("move not performed: %+v", result)
+		}
+		if _, err := os.Stat(filepath.Join(gs.docsDir, result.NewPath)); err != nil {
+			t.Fatalf("moved document missing: %v", err)
+		}
+	}
+
+	movedChild := target.Path + "/" + a.Path + "/" + child.ID
+	docs, _, _ := engine.Search("platypus", 1, 10)
+	if len(docs) != 1 || docs[0].Path != movedChild {
+		t.Fatalf("search results = %+v, want %s", docs, movedChild)
+	}
+	if !h.meta.IsFavorite(movedChild) || h.meta.IsFavorite(child.Path) {
+		t.Fatal("favorite was not moved with the subtree")
+	}
+}
+
+func TestMoveDocumentsBatchAllOrNothing(t *testing.T) {
+	h, gs, _ := newTestDocumentHandler(t)
+	a := mustCreate(t, gs, "", "A", "")
+	target := mustCreate(t, gs, "", "Target", "")
+	commits := countCommits(t, gs)
+
+	moves := []DocumentMove{
+		{Source: a.Path, Target: target.Path},
+		{Source: "missing", Target: target.Path},
+	}
+	code, results := moveBatch(t, h, "", moves)
+	if code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", code, http.StatusBadRequest)
+	}
+	if results[0].Error != "" || results[1].Error == "" || results[0].Moved {
+		t.Fatalf("results = %+v", results)
+	}
+	if _, err := os.Stat(filepath.Join(gs.docsDir, a.Path)); err != nil {
+		t.Fatal("valid move was applied in a rejected batch")
+	}
+	if got := countCommits(t, gs); got != commits {
+		t.Fatalf("commits = %d, want %d", got, commits)
+	}
+
+	// В частичном режиме выполняется только корректное перемещение
+	code, results = moveBatch(t, h, "?partial=true", moves)
+	if code != http.StatusOK {
+		t.Fatalf("partial status = %d", code)
+	}
+	if !results[0].Moved || results[1].Moved || results[1].Error == "" {
+		t.Fatalf("partial results = %+v", results)
+	}
+	if got := countCommits(t, gs); got != commits+1 {
+		t.Fatalf("commits = %d, want %d", got, commits+1)
+	}
+}
+
+func TestValidateMoveRejectsDependentMoves(t *testing.T) {
+	gs := newTestStorage(t)
+	a := mustCreate(t, gs, "", "A", "")
+	child := mustCreate(t, gs, a.Path, "Child", "")
+	b := mustCreate(t, gs, "", "B", "")
+
+	moves := []DocumentMove{
+		{Source: a.Path, Target: b.Path},
+		{Source: child.Path, Target: ""},
+		{Source: b.Path, Target: a.Path},
+	}
+	for i := range moves {
+		if err := gs.validateMove(moves, i); err == nil {
+			t.Errorf("move %+v accepted, want error", moves[i])
+		}
+	}
+	if err := gs.validateMove([]DocumentMove{{Source: a.Path, Target: child.Path}}, 0); err == nil {
+		t.Error("move into own child accepted")
+	}
+}
+
+func TestMoveDocumentRelocatesDescendants(t *testing.T) {
+	h, gs, engine := newTestDocumentHandler(t)
+	parent := mustCreate(t, gs, "", "Parent", "")
+	child := mustCreate(t, gs, parent.Path, "Child", "")
+	grandchild := mustCreate(t, gs, child.Path, "Grandchild", "narwhal")
+	target := mustCreate(t, gs, "", "Target", "")
+	other := mustCreate(t, gs, "", "Other", "")
+	if err := engine.LoadFromStorage(gs); err != nil {
+		t.Fatal(err)
+	}
+	h.meta.AddToFavorites(documentToShort(&child))
+	h.meta.AddToFavorites(documentToShort(&other))
+	h.meta.UpdateViewedMeta(documentToShort(&grandchild))
+	h.meta.SetHomePath(grandchild.Path)
+
+	body := strings.NewReader(`{"targetPath":"` + target.Path + `"}`)
+	rec := serve(h.MoveDocument, "POST", "/api/document/"+parent.Path+"/move", body, map[string]string{"rest": parent.Path})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+
+	movedChild := target.Path + "/" + child.Path
+	movedGrandchild := target.Path + "/" + grandchild.Path
+	if !h.meta.IsFavorite(movedChild) || h.meta.IsFavorite(child.Path) || !h.meta.IsFavorite(other.Path) {
+		t.Fatalf("favorites = %+v", h.meta.GetFavorites())
+	}
+	for _, fav := range h.meta.GetFavorites() {
+		if _, err := gs.GetDocument(fav.Path); err != nil {
+			t.Errorf("favorite %s does not resolve: %v", fav.Path, err)
+		}
+	}
+	if viewed := h.meta.GetLastViewedDocuments(); len(viewed) != 1 || viewed[0].Path != movedGrandchild {
+		t.Errorf("last viewed = %+v", viewed)
+	}
+	if home := h.meta.GetHomePath(); home != movedGrandchild {
+		t.Errorf("home = %q, want %q", home, movedGrandchild)
+	}
+
+	docs, _, _ := engine.Search("narwhal", 1, 10)
+	if len(docs) != 1 || docs[0].Path != movedGrandchild {
+		t.Errorf("search after move = %+v", docs)
+	}
+}
+
+func TestMoveDocumentsBatchCommitsOnlyMovedDocuments(t *testing.T) {
+	_, gs, _ := newTestDocumentHandler(t)
+	a := mustCreate(t, gs, "", "A", "aardvark")
+	mustCreate(t, gs, a.Path, "Child", "platypus")
+	target := mustCreate(t, gs, "", "Target", "")
+	// Правка цели ждет пакетной фиксации
+	if _, err := gs.UpdateDocument(target.Path, "Target", "pending", false); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := gs.MoveDocuments([]DocumentMove{{Source: a.Path, Target: target.Path}}, false); err != nil {
+		t.Fatal(err)
+	}
+	pending := "docs/" + target.Path + "/Target.md"
+	for _, file := range headFiles(t, gs) {
+		if strings.Contains(file, pending) {
+			t.Errorf("move commit took the pending edit: %v", headFiles(t, gs))
+		}
+	}
+	if got := dirtyDocs(t, gs); len(got) != 1 || got[0] != pending {
+		t.Errorf("uncommitted documents = %v, want the pending edit of the target", got)
+	}
+}
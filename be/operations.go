@@ -0,0 +1,88 @@
+// operations.go
+package main
+
+import (
+	"context"
+	"sync"
+)
+
+// OperationTracker отслеживает длительные операции (обход истории, экспорт),
+// чтобы при остановке сервера отменить их через контекст, а не обрывать.
+type OperationTracker struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu      sync.Mutex
+	active  int
+	stopped bool
+	wg      sync.WaitGroup
+}
+
+func NewOperationTracker() *OperationTracker {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &OperationTracker{
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Begin регистрирует операцию. Возвращенный контекст отменяется вместе с parent
+// или при остановке трекера; done нужно вызвать по завершении операции.
+func (t *OperationTracker) Begin(parent context.Context) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(parent)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.stopped {
+		cancel()
+		return ctx, func() {}
+	}
+
+	stop := context.AfterFunc(t.ctx, cancel)
+	t.active++
+	t.wg.Add(1)
+
+	var once sync.Once
+	return ctx, func() {
+		once.Do(func() {
+			stop()
+			cancel()
+			t.mu.Lock()
+			t.active--
+			t.mu.Unlock()
+			t.wg.Done()
+		})
+	}
+}
+
+// Active возвращает количество выполняющихся операций
+func (t *OperationTracker) Active() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.active
+}
+
+// Shutdown отменяет все активные операции и ждет их завершения, пока не истечет ctx.
+// Возвращает количество отмененных операций.
+func (t *OperationTracker) Shutdown(ctx context.Context) (int, error) {
+	t.mu.Lock()
+	t.stopped = true
+	cancelled := t.active
+	t.mu.Unlock()
+
+	t.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		t.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return cancelled, nil
+	case <-ctx.Done():
+		return cancelled, ctx.Err()
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestOperationTrackerShutdownCancelsOperations(t *testing.T) {
+	tracker := NewOperationTracker()
+
+	started := make(chan struct{})
+	finished := make(chan error, 1)
+	go func() {
+		ctx, done := tracker.Begin(context.Background())
+		defer done()
+		close(started)
+		<-ctx.Done()
+		finished <- ctx.Err()
+	}()
+	<-started
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	cancelled, err := tracker.Shutdown(shutdownCtx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cancelled != 1 {
+		t.Fatalf("cancelled = %d, want 1", cancelled)
+	}
+	if err := <-finished; err != context.Canceled {
+		t.Fatalf("operation ctx err = %v, want %v", err, context.Canceled)
+	}
+	if active := tracker.Active(); active != 0 {
+		t.Fatalf("active = %d, want 0", active)
+	}
+
+	// Операции после остановки сразу получают отмененный контекст
+	ctx, done := tracker.Begin(context.Background())
+	defer done()
+	if ctx.Err() != context.Canceled {
+		t.Fatalf("ctx err after shutdown = %v, want %v", ctx.Err(), context.Canceled)
+	}
+}
+
+func TestOperationTrackerShutdownTimeout(t *testing.T) {
+	tracker := NewOperationTracker()
+	_, done := tracker.Begin(context.Background())
+	defer done()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	// Операция не вызывает done, поэтому Shutdown упирается в таймаут
+	if _, err := tracker.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("err = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestCancelledHistoryWalkReturnsUnavailable(t *testing.T) {
+	h, gs, _ := newTestDocumentHandler(t)
+	a := mustCreate(t, gs, "", "A", "one")
+	if _, err := gs.UpdateDocument(a.Path, "A", "two", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := gs.DeleteDocument(a.Path); err != nil {
+		t.Fatal(err)
+	}
+
+	h.operations.Shutdown(context.Background())
+
+	rec := serve(h.GetDeletedDocuments, "GET", "/api/history/deleted", nil, nil)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("deleted history status = %d, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+
+	b := mustCreate(t, gs, "", "B", "one")
+	if _, err := gs.UpdateDocument(b.Path, "B", "two", true); err != nil {
+		t.Fatal(err)
+	}
+	rec = serve(h.GetDocumentHistory, "GET", "/api/history/tree/b", nil, map[string]string{"rest": b.Path})
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("document history status = %d, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+}
@@ -0,0 +1,424 @@
+// pdf.go
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-pdf/fpdf"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+const (
+	pdfFontFamily = "document"
+	pdfFontSize   = 11.0
+	pdfLineHeight = 5.5
+	pdfIndent     = 6.0
+)
+
+var pdfHeadingSizes = map[atom.Atom]float64{
+	atom.H1: 20, atom.H2: 16, atom.H3: 14, atom.H4: 12, atom.H5: 11, atom.H6: 11,
+}
+
+var whitespaceRun = regexp.MustCompile(`\s+`)
+
+// fpdf понимает только эти форматы изображений
+var pdfImageTypes = map[string]string{"jpeg": "JPG", "png": "PNG", "gif": "GIF"}
+
+// pdfWriter раскладывает HTML отрендеренного документа на страницы PDF.
+// Поддерживаются заголовки, абзацы, выделение, код, списки, цитаты, таблицы,
+// ссылки и изображения из загрузок; прочие элементы выводятся как текст.
+type pdfWriter struct {
+	pdf       *fpdf.Fpdf
+	translate func(string) string
+	family    string // пусто - встроенные шрифты PDF
+	uploadDir string
+
+	bold, italic int
+	mono         bool
+	pre          bool
+	size         float64
+	href         string
+	images       int
+}
+
+// newPDFWriter создает документ A4. Встроенные шрифты PDF покрывают только
+// cp1252, поэтому для кириллицы нужно указать TTF-шрифт в fontFile.
+func newPDFWriter(fontFile, uploadDir string) (*pdfWriter, error) {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pw := &pdfWriter{pdf: pdf, uploadDir: uploadDir, size: pdfFontSize}
+
+	if fontFile != "" {
+		// AddUTF8Font ищет файл относительно каталога шрифтов, поэтому читаем сами
+		font, err := os.ReadFile(fontFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load PDF font: %w", err)
+		}
+		for _, style := range []string{"", "B", "I", "BI"} {
+			pdf.AddUTF8FontFromBytes(pdfFontFamily, style, font)
+		}
+		pw.family = pdfFontFamily
+		pw.translate = func(s string) string { return s }
+	} else {
+		pw.translate = pdf.UnicodeTranslatorFromDescriptor("")
+	}
+
+	pdf.SetAutoPageBreak(true, 15)
+	pdf.AddPage()
+	pw.setFont()
+	return pw, pdf.Error()
+}
+
+func (pw *pdfWriter) setFont() {
+	family := pw.family
+	if family == "" {
+		family = "Helvetica"
+		if pw.mono {
+			family = "Courier"
+		}
+	}
+
+	style := ""
+	if pw.bold > 0 {
+		style += "B"
+	}
+	if pw.italic > 0 {
+		style += "I"
+	}
+	pw.pdf.SetFont(family, style, pw.size)
+	if pw.href != "" {
+		pw.pdf.SetTextColor(0, 0, 200)
+	} else {
+		pw.pdf.SetTextColor(0, 0, 0)
+	}
+}
+
+// WriteDocument выводит заголовок документа и его HTML
+func (pw *pdfWriter) WriteDocument(title, body string) error {
+	pw.pdf.SetTitle(title, true)
+	pw.heading(atom.H1, func() { pw.text(title) })
+
+	root, err := html.Parse(strings.NewReader(body))
+	if err != nil {
+		return err
+	}
+	pw.children(root)
+	return pw.pdf.Error()
+}
+
+func (pw *pdfWriter) Output(w io.Writer) error {
+	return pw.pdf.Output(w)
+}
+
+func (pw *pdfWriter) children(n *html.Node) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		pw.node(c)
+	}
+}
+
+func (pw *pdfWriter) node(n *html.Node) {
+	if n.Type == html.TextNode {
+		pw.text(n.Data)
+		return
+	}
+	if n.Type != html.ElementNode {
+		pw.children(n)
+		return
+	}
+
+	switch n.DataAtom {
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		pw.heading(n.DataAtom, func() { pw.children(n) })
+	case atom.P:
+		pw.block(func() { pw.children(n) })
+	case atom.Strong, atom.B:
+		pw.styled(&pw.bold, func() { pw.children(n) })
+	case atom.Em, atom.I:
+		pw.styled(&pw.italic, func() { pw.children(n) })
+	case atom.Code:
+		pw.mono = true
+		pw.setFont()
+		pw.children(n)
+		pw.mono = false
+		pw.setFont()
+	case atom.Pre:
+		pw.block(func() {
+			pw.pre = true
+			pw.children(n)
+			pw.pre = false
+		})
+	case atom.A:
+		pw.href = attr(n, "href")
+		pw.setFont()
+		pw.children(n)
+		pw.href = ""
+		pw.setFont()
+	case atom.Br:
+		pw.pdf.Ln(pdfLineHeight)
+	case atom.Hr:
+		pw.newLine()
+		left, _, right, _ := pw.pdf.GetMargins()
+		width, _ := pw.pdf.GetPageSize()
+		y := pw.pdf.GetY() + pdfLineHeight/2
+		pw.pdf.Line(left, y, width-right, y)
+		pw.pdf.Ln(pdfLineHeight)
+	case atom.Ul, atom.Ol:
+		pw.list(n)
+	case atom.Blockquote:
+		pw.indented(func() { pw.styled(&pw.italic, func() { pw.children(n) }) })
+	case atom.Table:
+		pw.table(n)
+	case atom.Img:
+		pw.image(attr(n, "src"), attr(n, "alt"))
+	default:
+		pw.children(n)
+	}
+}
+
+// text выводит текст в текущей позиции. Вне <pre> пробелы схлопываются, как в браузере.
+func (pw *pdfWriter) text(s string) {
+	if !pw.pre {
+		s = whitespaceRun.ReplaceAllString(s, " ")
+		// Переводы строк между элементами не должны давать пробел в начале строки
+		if left, _, _, _ := pw.pdf.GetMargins(); pw.pdf.GetX() <= left {
+			s = strings.TrimLeft(s, " ")
+		}
+		if s == "" {
+			return
+		}
+	}
+	if pw.href != "" {
+		pw.pdf.WriteLinkString(pdfLineHeight, pw.translate(s), pw.href)
+		return
+	}
+	pw.pdf.Write(pdfLineHeight, pw.translate(s))
+}
+
+// newLine переходит на новую строку, если текущая не пуста
+func (pw *pdfWriter) newLine() {
+	if left, _, _, _ := pw.pdf.GetMargins(); pw.pdf.GetX() > left {
+		pw.pdf.Ln(pdfLineHeight)
+	}
+}
+
+func (pw *pdfWriter) block(content func()) {
+	pw.newLine()
+	content()
+	pw.newLine()
+	pw.pdf.Ln(pdfLineHeight / 2)
+}
+
+func (pw *pdfWriter) heading(level atom.Atom, content func()) {
+	pw.size = pdfHeadingSizes[level]
+	pw.bold++
+	pw.setFont()
+	pw.newLine()
+	pw.pdf.Ln(pdfLineHeight / 2)
+	content()
+	pw.newLine()
+	pw.pdf.Ln(pdfLineHeight / 2)
+	pw.bold--
+	pw.size = pdfFontSize
+	pw.setFont()
+}
+
+func (pw *pdfWriter) styled(counter *int, content func()) {
+	*counter++
+	pw.setFont()
+	content()
+	*counter--
+	pw.setFont()
+}
+
+func (pw *pdfWriter) indented(content func()) {
+	left, _, _, _ := pw.pdf.GetMargins()
+	pw.newLine()
+	pw.pdf.SetLeftMargin(left + pdfIndent)
+	pw.pdf.SetX(left + pdfIndent)
+	content()
+	pw.newLine()
+	pw.pdf.SetLeftMargin(left)
+	pw.pdf.SetX(left)
+}
+
+func (pw *pdfWriter) list(n *html.Node) {
+	number := 1
+	if start, err := strconv.Atoi(attr(n, "start")); err == nil {
+		number = start
+	}
+	pw.indented(func() {
+		for item := n.FirstChild; item != nil; item = item.NextSibling {
+			if item.DataAtom != atom.Li {
+				continue
+			}
+			marker := "-"
+			if n.DataAtom == atom.Ol {
+				marker = strconv.Itoa(number) + "."
+				number++
+			}
+			pw.newLine()
+			pw.pdf.Write(pdfLineHeight, marker+" ")
+			pw.children(item)
+		}
+	})
+	pw.pdf.Ln(pdfLineHeight / 2)
+}
+
+// table выводит таблицу с колонками равной ширины
+func (pw *pdfWriter) table(n *html.Node) {
+	var rows [][]*html.Node
+	var collect func(*html.Node)
+	collect = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.DataAtom != atom.Tr {
+				collect(c)
+				continue
+			}
+			var cells []*html.Node
+			for cell := c.FirstChild; cell != nil; cell = cell.NextSibling {
+				if cell.DataAtom == atom.Td || cell.DataAtom == atom.Th {
+					cells = append(cells, cell)
+				}
+			}
+			rows = append(rows, cells)
+		}
+	}
+	collect(n)
+
+	columns := 0
+	for _, row := range rows {
+		columns = max(columns, len(row))
+	}
+	if columns == 0 {
+		return
+	}
+
+	pw.newLine()
+	left, _, right, _ := pw.pdf.GetMargins()
+	width, _ := pw.pdf.GetPageSize()
+	cellWidth := (width - left - right) / float64(columns)
+	for _, row := range rows {
+		for _, cell := range row {
+			if cell.DataAtom == atom.Th {
+				pw.bold++
+			}
+			pw.setFont()
+			pw.pdf.CellFormat(cellWidth, pdfLineHeight+1, pw.translate(nodeText(cell)), "1", 0, "L", false, 0, "")
+			if cell.DataAtom == atom.Th {
+				pw.bold--
+			}
+		}
+		pw.setFont()
+		pw.pdf.Ln(-1)
+	}
+	pw.pdf.Ln(pdfLineHeight / 2)
+}
+
+// image встраивает изображение из загрузок. Внешние и неподдерживаемые
+// изображения заменяются подписью.
+func (pw *pdfWriter) image(src, alt string) {
+	data, imageType := pw.loadImage(src)
+	if data == nil {
+		if alt == "" {
+			alt = src
+		}
+		pw.text("[" + alt + "]")
+		return
+	}
+
+	pw.images++
+	name := fmt.Sprintf("image%d", pw.images)
+	info := pw.pdf.RegisterImageOptionsReader(name, fpdf.ImageOptions{ImageType: imageType}, bytes.NewReader(data))
+	if pw.pdf.Err() {
+		return
+	}
+
+	left, _, right, _ := pw.pdf.GetMargins()
+	pageWidth, _ := pw.pdf.GetPageSize()
+	w, h := info.Extent()
+	if maxWidth := pageWidth - left - right; w > maxWidth {
+		w, h = maxWidth, h*maxWidth/w
+	}
+	pw.newLine()
+	pw.pdf.ImageOptions(name, left, 0, w, h, true, fpdf.ImageOptions{ImageType: imageType}, 0, "")
+}
+
+func (pw *pdfWriter) loadImage(src string) ([]byte, string) {
+	match := fileRefPattern.FindStringSubmatch(src)
+	if match == nil || pw.uploadDir == "" {
+		return nil, ""
+	}
+	data, err := os.ReadFile(filepath.Join(pw.uploadDir, match[1]))
+	if err != nil {
+		return nil, ""
+	}
+	_, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || pdfImageTypes[format] == "" {
+		return nil, ""
+	}
+	return data, pdfImageTypes[format]
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// nodeText возвращает текст элемента без разметки
+func nodeText(n *html.Node) string {
+	var sb strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			sb.WriteString(n.Data)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return strings.Join(strings.Fields(sb.String()), " ")
+}
+
+// writeDocumentPDF отдает документ в виде PDF-файла
+func (h *DocumentHandler) writeDocumentPDF(w http.ResponseWriter, doc Document) {
+	body, err := renderMarkdown(doc.Content)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	pw, err := newPDFWriter(h.pdfFont, h.uploadDir)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := pw.WriteDocument(doc.Title, body); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var buf bytes.Buffer
+	if err := pw.Output(&buf); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": doc.Title + ".pdf"}))
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.Write(buf.Bytes())
+}
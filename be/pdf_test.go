@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func getPDF(t *testing.T, h *DocumentHandler, docPath string) []byte {
+	t.Helper()
+	resp := serve(h.GetDocument, "GET", "/api/document/"+docPath+"?format=pdf", nil, map[string]string{"rest": docPath})
+	if resp.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", resp.Code, resp.Body)
+	}
+	if ct := resp.Header().Get("Content-Type"); ct != "application/pdf" {
+		t.Errorf("Content-Type = %q, want application/pdf", ct)
+	}
+	if cd := resp.Header().Get("Content-Disposition"); !strings.HasPrefix(cd, "attachment;") {
+		t.Errorf("Content-Disposition = %q, want attachment", cd)
+	}
+	body := resp.Body.Bytes()
+	if !bytes.HasPrefix(body, []byte("%PDF-")) {
+		t.Fatalf("response does not start with a PDF header: %q", body[:min(len(body), 16)])
+	}
+	if !bytes.Contains(body, []byte("%%EOF")) {
+		t.Error("PDF has no end-of-file marker")
+	}
+	return body
+}
+
+func TestGetDocumentPDF(t *testing.T) {
+	h, gs, _ := newTestDocumentHandler(t)
+	content := "# Heading\n\nSome **bold** and *italic* text with `code`.\n\n" +
+		"- one\n- two\n\n1. first\n2. second\n\n| a | b |\n|---|---|\n| 1 | 2 |\n\n```\nfenced\n```\n"
+	doc := mustCreate(t, gs, "", "Report", content)
+
+	body := getPDF(t, h, doc.Path)
+	if len(body) < 500 {
+		t.Errorf("PDF is suspiciously small: %d bytes", len(body))
+	}
+}
+
+func TestGetDocumentPDFEmpty(t *testing.T) {
+	h, gs, _ := newTestDocumentHandler(t)
+	doc := mustCreate(t, gs, "", "Empty", "")
+
+	getPDF(t, h, doc.Path)
+}
+
+func TestGetDocumentPDFEmbedsUploadedImage(t *testing.T) {
+	h, gs, _ := newTestDocumentHandler(t)
+	h.uploadDir = t.TempDir()
+
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	img.Set(1, 1, color.RGBA{R: 255, A: 255})
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(h.uploadDir, "pixel"), buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	withImage := mustCreate(t, gs, "", "With image", "![pixel](/api/file/pixel)")
+	withoutImage := mustCreate(t, gs, "", "Without image", "![missing](/api/file/missing)")
+
+	if body := getPDF(t, h, withImage.Path); !bytes.Contains(body, []byte("/Subtype /Image")) {
+		t.Error("uploaded image was not embedded")
+	}
+	if body := getPDF(t, h, withoutImage.Path); bytes.Contains(body, []byte("/Subtype /Image")) {
+		t.Error("missing image should be replaced with text")
+	}
+}
+
+func TestGetDocumentInvalidFormat(t *testing.T) {
+	h, gs, _ := newTestDocumentHandler(t)
+	doc := mustCreate(t, gs, "", "Doc", "text")
+
+	resp := serve(h.GetDocument, "GET", "/api/document/"+doc.Path+"?format=docx", nil, map[string]string{"rest": doc.Path})
+	if resp.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", resp.Code)
+	}
+}
+
+func TestGetDocumentPDFWithUnicodeFont(t *testing.T) {
+	const font = "/usr/share/fonts/truetype/dejavu/DejaVuSans.ttf"
+	if _, err := os.Stat(font); err != nil {
+		t.Skip("DejaVu Sans is not installed")
+	}
+	h, gs, _ := newTestDocumentHandler(t)
+	h.pdfFont = font
+	doc := mustCreate(t, gs, "", "Отчет", "Текст **на русском**")
+
+	getPDF(t, h, doc.Path)
+}
@@ -0,0 +1,92 @@
+// render.go
+package main
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+)
+
+// markdownRenderer превращает markdown в HTML. Включены расширения GFM
+// (таблицы, зачеркивание, списки задач, автоссылки); сырой HTML из документа
+// по умолчанию не выводится. Заголовки получают id, совпадающие с якорями extractHeadings.
+var markdownRenderer = goldmark.New(
+	goldmark.WithExtensions(extension.GFM),
+	goldmark.WithParserOptions(parser.WithAutoHeadingID()),
+)
+
+// renderSanitizer чистит отрендеренный HTML, если у обработчика нет своего санитайзера
+var renderSanitizer = NewContentSanitizer(defaultAllowedTags)
+
+func renderMarkdown(content string) (string, error) {
+	var buf bytes.Buffer
+	ctx := parser.NewContext(parser.WithIDs(headingIDs{newSlugger()}))
+	if err := markdownRenderer.Convert([]byte(content), &buf, parser.WithContext(ctx)); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// headingIDs генерирует id заголовков тем же slugger, что и оглавление
+type headingIDs struct {
+	slugs *slugger
+}
+
+func (ids headingIDs) Generate(value []byte, kind ast.NodeKind) []byte {
+	return []byte(ids.slugs.slug(headingText(string(value))))
+}
+
+func (ids headingIDs) Put(value []byte) {
+	ids.slugs.used[string(value)] = 0
+}
+
+// RenderedDocument - документ, отрендеренный в HTML
+type RenderedDocument struct {
+	Path  string `json:"path"`
+	Title string `json:"title"`
+	HTML  string `json:"html"`
+}
+
+// RenderDocument отдает содержимое документа в виде HTML с подставленными
+// {{include:...}}. Результат проходит через санитайзер, поэтому сохраненное
+// содержимое не может внедрить скрипты в страницу, которая его показывает.
+func (h *DocumentHandler) RenderDocument(w http.ResponseWriter, r *http.Request) {
+	docPath := mux.Vars(r)["rest"]
+	doc, err := h.storage.GetDocument(docPath)
+	if errors.Is(err, ErrDocumentNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	content, err := resolveIncludes(h.storage, docPath, doc.Content)
+	if errors.Is(err, ErrIncludeCycle) || errors.Is(err, ErrIncludeDepth) {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	body, err := renderMarkdown(content)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	sanitizer := h.sanitizer
+	if sanitizer == nil {
+		sanitizer = renderSanitizer
+	}
+
+	writeJSON(w, r, RenderedDocument{Path: doc.Path, Title: doc.Title, HTML: sanitizer.sanitizeHTML(body)})
+}
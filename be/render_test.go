@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRenderDocument(t *testing.T) {
+	h, gs, _ := newTestDocumentHandler(t)
+	part := mustCreate(t, gs, "", "Part", "Included **text**")
+	content := "# Title\n\n" +
+		"| a | b |\n|---|---|\n| 1 | 2 |\n\n" +
+		"```go\nfmt.Println(\"<script>\")\n```\n\n" +
+		"<script>alert(1)</script>\n\n" +
+		"<div onclick=\"steal()\">raw</div>\n\n" +
+		"[click](javascript:alert(1))\n\n" +
+		"{{include:" + part.Path + "}}\n"
+	doc := mustCreate(t, gs, "", "Doc", content)
+
+	rec := serve(h.RenderDocument, "GET", "/api/render/"+doc.Path, nil, map[string]string{"rest": doc.Path})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	var rendered RenderedDocument
+	if err := json.NewDecoder(rec.Body).Decode(&rendered); err != nil {
+		t.Fatal(err)
+	}
+	if rendered.Path != doc.Path || rendered.Title != "Doc" {
+		t.Errorf("rendered = %+v", rendered)
+	}
+
+	for _, want := range []string{
+		`<h1 id="title">Title</h1>`,
+		"<table>", "<td>1</td>",
+		`<pre><code class="language-go">`, "&lt;script&gt;",
+		"<strong>text</strong>",
+	} {
+		if !strings.Contains(rendered.HTML, want) {
+			t.Errorf("html does not contain %q:\n%s", want, rendered.HTML)
+		}
+	}
+	for _, bad := range []string{"<script", "alert(1)</", "onclick", "javascript:"} {
+		if strings.Contains(rendered.HTML, bad) {
+			t.Errorf("html contains %q:\n%s", bad, rendered.HTML)
+		}
+	}
+
+	rec = serve(h.RenderDocument, "GET", "/api/render/missing", nil, map[string]string{"rest": "missing"})
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("missing document: status = %d", rec.Code)
+	}
+}
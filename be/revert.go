@@ -0,0 +1,89 @@
+// revert.go
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/gorilla/mux"
+)
+
+// RevertDocument записывает содержимое .md файла документа из коммита commitID
+// поверх текущего и коммитит его новым коммитом. Путь и заголовок документа
+// остаются текущими, история не переписывается.
+func (gs *GitStorage) RevertDocument(docPath, commitID string) (Document, error) {
+	defer gs.invalidateStatus()
+
+	docPath, err := gs.cleanDocPath(docPath)
+	if err != nil {
+		return Document{}, err
+	}
+	stem, err := gs.getTitle(docPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return Document{}, fmt.Errorf("%w: %s", ErrDocumentNotFound, docPath)
+		}
+		return Document{}, err
+	}
+
+	_, content, ok, err := gs.documentFileAt(docPath, commitID)
+	if err != nil {
+		return Document{}, err
+	}
+	if !ok {
+		return Document{}, fmt.Errorf("%w: %s at %s", ErrDocumentNotFound, docPath, commitID)
+	}
+
+	filePath := filepath.Join(gs.fullPath(docPath), stem+".md")
+	if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+		return Document{}, err
+	}
+	if err := gs.commitChanges(fmt.Sprintf("Revert %s to %s", docPath, commitID[:7]), docScope(docPath)); err != nil {
+		return Document{}, fmt.Errorf("failed to commit changes: %w", err)
+	}
+
+	return gs.GetDocument(docPath)
+}
+
+// RevertDocument возвращает содержимое документа к версии из коммита
+func (h *DocumentHandler) RevertDocument(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Commit string `json:"commit"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	gitStorage, ok := h.storage.(*GitStorage)
+	if !ok {
+		http.Error(w, "history feature only available with git storage", http.StatusNotImplemented)
+		return
+	}
+
+	doc, err := gitStorage.RevertDocument(mux.Vars(r)["rest"], req.Commit)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, ErrInvalidCommit), errors.Is(err, ErrInvalidPath):
+			status = http.StatusBadRequest
+		case errors.Is(err, ErrDocumentNotFound), errors.Is(err, plumbing.ErrObjectNotFound):
+			status = http.StatusNotFound
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	if err := h.search.IndexDocument(doc); err != nil {
+		log.Printf("Warning: failed to index %s: %v", doc.Path, err)
+	}
+
+	doc.Favorite = h.meta.IsFavorite(doc.Path)
+	writeJSON(w, r, doc)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRevertDocument(t *testing.T) {
+	h, gs, engine := newTestDocumentHandler(t)
+	doc := mustCreate(t, gs, "", "Doc", "aardvark")
+	// В исторической версии файл документа назывался по-другому
+	dir := gs.fullPath(doc.Path)
+	if err := os.Rename(filepath.Join(dir, "Doc.md"), filepath.Join(dir, "Old.md")); err != nil {
+		t.Fatal(err)
+	}
+	if err := gs.commitChanges("Retitle", docScope(doc.Path)); err != nil {
+		t.Fatal(err)
+	}
+	ref, err := gs.repo.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	original := ref.Hash().String()
+	if err := os.Rename(filepath.Join(dir, "Old.md"), filepath.Join(dir, "Doc.md")); err != nil {
+		t.Fatal(err)
+	}
+	updated, err := gs.UpdateDocument(doc.Path, "Doc", "axolotl", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.IndexDocument(updated); err != nil {
+		t.Fatal(err)
+	}
+	commits := countCommits(t, gs)
+
+	revert := func(docPath, body string) (int, Document) {
+		t.Helper()
+		rec := serve(h.RevertDocument, "POST", "/api/history/revert/"+docPath, strings.NewReader(body), map[string]string{"rest": docPath})
+		var got Document
+		if rec.Code == http.StatusOK {
+			if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return rec.Code, got
+	}
+
+	code, reverted := revert(updated.Path, `{"commit":"`+original+`"}`)
+	if code != http.StatusOK {
+		t.Fatalf("status = %d", code)
+	}
+	if reverted.Path != updated.Path || reverted.Title != "Doc" || reverted.Content != "aardvark" {
+		t.Fatalf("reverted = %+v", reverted)
+	}
+	if got := countCommits(t, gs); got != commits+1 {
+		t.Errorf("commits = %d, want %d: revert must add a commit, not reset", got, commits+1)
+	}
+	head, _ := gs.repo.Head()
+	commit, err := gs.repo.CommitObject(head.Hash())
+	if err != nil || !strings.HasPrefix(commit.Message, "Revert "+updated.Path+" to "+original[:7]) {
+		t.Errorf("commit message = %q, %v", commit.Message, err)
+	}
+	if docs, _, _ := engine.Search("aardvark", 1, 10); len(docs) != 1 {
+		t.Errorf("reverted content not indexed: %+v", docs)
+	}
+	if docs, _, _ := engine.Search("axolotl", 1, 10); len(docs) != 0 {
+		t.Errorf("old content still indexed: %+v", docs)
+	}
+
+	if code, _ := revert(updated.Path, `{"commit":"nope"}`); code != http.StatusBadRequest {
+		t.Errorf("invalid hash: status = %d", code)
+	}
+	if code, _ := revert(updated.Path, `{"commit":"`+strings.Repeat("1", 40)+`"}`); code != http.StatusNotFound {
+		t.Errorf("unknown commit: status = %d", code)
+	}
+	later := mustCreate(t, gs, "", "Later", "")
+	if code, _ := revert(later.Path, `{"commit":"`+original+`"}`); code != http.StatusNotFound {
+		t.Errorf("document missing in commit: status = %d", code)
+	}
+}
+
+func TestRevertDocumentCommitsOnlyItsFile(t *testing.T) {
+	_, gs, _ := newTestDocumentHandler(t)
+	doc := mustCreate(t, gs, "", "Doc", "aardvark")
+	child := mustCreate(t, gs, doc.Path, "Child", "platypus")
+	ref, err := gs.repo.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gs.UpdateDocument(doc.Path, "Doc", "axolotl", true); err != nil {
+		t.Fatal(err)
+	}
+	// Правка вложенного документа ждет пакетной фиксации
+	if _, err := gs.UpdateDocument(child.Path, "Child", "pending", false); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := gs.RevertDocument(doc.Path, ref.Hash().String()); err != nil {
+		t.Fatal(err)
+	}
+	if got := headFiles(t, gs); len(got) != 1 || got[0] != "docs/"+doc.Path+"/Doc.md" {
+		t.Errorf("revert commit touches %v", got)
+	}
+	if got := dirtyDocs(t, gs); len(got) != 1 || got[0] != "docs/"+child.Path+"/Child.md" {
+		t.Errorf("uncommitted documents = %v, want the pending edit of the child", got)
+	}
+}
@@ -0,0 +1,138 @@
+// sanitize.go
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var defaultAllowedTags = []string{
+	"a", "abbr", "b", "blockquote", "br", "code", "dd", "del", "details", "div", "dl", "dt",
+	"em", "h1", "h2", "h3", "h4", "h5", "h6", "hr", "i", "img", "ins", "kbd", "li", "mark",
+	"ol", "p", "pre", "s", "span", "strong", "sub", "summary", "sup", "table", "tbody",
+	"td", "th", "thead", "tr", "u", "ul",
+}
+
+// Элементы, которые удаляются вместе с содержимым
+var dangerousElements = []string{"script", "style", "iframe", "object", "embed", "noscript", "template"}
+
+var (
+	htmlTagRegex       = regexp.MustCompile(`</?([a-zA-Z][a-zA-Z0-9-]*)(\s[^>]*)?/?>`)
+	htmlAttributeRegex = regexp.MustCompile(`([a-zA-Z_:][-a-zA-Z0-9_:.]*)(?:\s*=\s*("[^"]*"|'[^']*'|[^\s"'>]+))?`)
+	inlineCodeRegex    = regexp.MustCompile("`[^`\n]*`")
+	dangerousRegexes   []*regexp.Regexp
+)
+
+func init() {
+	for _, tag := range dangerousElements {
+		dangerousRegexes = append(dangerousRegexes,
+			regexp.MustCompile(fmt.Sprintf(`(?is)<%s\b[^>]*>.*?</\s*%s\s*>`, tag, tag)))
+	}
+}
+
+// ContentSanitizer удаляет из markdown опасный сырой HTML: теги вне списка
+// разрешенных, обработчики событий и javascript: ссылки. Блоки и фрагменты кода
+// не изменяются.
+type ContentSanitizer struct {
+	allowedTags map[string]bool
+}
+
+func NewContentSanitizer(allowedTags []string) *ContentSanitizer {
+	allowed := make(map[string]bool)
+	for _, tag := range allowedTags {
+		if tag = strings.ToLower(strings.TrimSpace(tag)); tag != "" {
+			allowed[tag] = true
+		}
+	}
+	return &ContentSanitizer{allowedTags: allowed}
+}
+
+func (s *ContentSanitizer) Sanitize(content string) string {
+	// Прячем код за плейсхолдерами, чтобы не трогать примеры HTML в нем
+	var code []string
+	hide := func(fragment string) string {
+		code = append(code, fragment)
+		return fmt.Sprintf("\x00%d\x00", len(code)-1)
+	}
+
+	var text strings.Builder
+	var fence string
+	var block strings.Builder
+	lines := strings.SplitAfter(content, "\n")
+	for _, line := range lines {
+		marker := codeFenceMarker(strings.TrimRight(line, "\r\n"))
+		switch {
+		case fence == "" && marker != "":
+			fence = marker
+			block.WriteString(line)
+		case fence != "":
+			block.WriteString(line)
+			if marker != "" && strings.HasPrefix(strings.TrimSpace(line), fence) {
+				text.WriteString(hide(block.String()))
+				block.Reset()
+				fence = ""
+			}
+		default:
+			text.WriteString(inlineCodeRegex.ReplaceAllStringFunc(line, hide))
+		}
+	}
+	// Незакрытый блок кода тянется до конца документа
+	if block.Len() > 0 {
+		text.WriteString(hide(block.String()))
+	}
+
+	result := s.sanitizeHTML(text.String())
+
+	for i, fragment := range code {
+		result = strings.Replace(result, fmt.Sprintf("\x00%d\x00", i), fragment, 1)
+	}
+	return result
+}
+
+func (s *ContentSanitizer) sanitizeHTML(text string) string {
+	for _, re := range dangerousRegexes {
+		text = re.ReplaceAllString(text, "")
+	}
+
+	return htmlTagRegex.ReplaceAllStringFunc(text, func(tag string) string {
+		match := htmlTagRegex.FindStringSubmatch(tag)
+		name := strings.ToLower(match[1])
+		if !s.allowedTags[name] {
+			return ""
+		}
+		if strings.HasPrefix(tag, "</") {
+			return "</" + name + ">"
+		}
+
+		var b strings.Builder
+		b.WriteString("<" + name)
+		for _, attr := range htmlAttributeRegex.FindAllStringSubmatch(match[2], -1) {
+			if !safeAttribute(attr[1], attr[2]) {
+				continue
+			}
+			b.WriteString(" " + attr[0])
+		}
+		if strings.HasSuffix(tag, "/>") {
+			b.WriteString(" /")
+		}
+		b.WriteString(">")
+		return b.String()
+	})
+}
+
+func safeAttribute(name, value string) bool {
+	name = strings.ToLower(name)
+	if strings.HasPrefix(name, "on") {
+		return false
+	}
+
+	value = strings.ToLower(strings.Trim(value, `"' `))
+	value = strings.Join(strings.Fields(value), "")
+	for _, scheme := range []string{"javascript:", "vbscript:", "data:text/html"} {
+		if strings.HasPrefix(value, scheme) {
+			return false
+		}
+	}
+	return true
+}
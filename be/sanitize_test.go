@@ -0,0 +1,63 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestContentSanitizer(t *testing.T) {
+	s := NewContentSanitizer(defaultAllowedTags)
+
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"script", "before<script>alert(1)</script>after", "beforeafter"},
+		{"multiline script", "a\n<SCRIPT type=\"x\">\nalert(1)\n</script>\nb", "a\n\nb"},
+		{"event handler", `<img src="x.png" onerror="alert(1)">`, `<img src="x.png">`},
+		{"javascript link", `<a href="javascript:alert(1)" title="t">x</a>`, `<a title="t">x</a>`},
+		{"unknown tag", "<form action=\"/x\"><b>bold</b></form>", "<b>bold</b>"},
+		{"markdown untouched", "# Title\n\n> quote & a < b\n\n<https://example.com>", "# Title\n\n> quote & a < b\n\n<https://example.com>"},
+		{"inline code", "use `<script>` tags", "use `<script>` tags"},
+		{"fenced code", "```html\n<script>alert(1)</script>\n```\n<script>x</script>", "```html\n<script>alert(1)</script>\n```\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.Sanitize(tt.in); got != tt.want {
+				t.Errorf("Sanitize(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateDocumentSanitizesWhenEnabled(t *testing.T) {
+	content := `text<script>alert(1)</script>`
+	body := `{"title": "A", "content": "text<script>alert(1)</script>"}`
+
+	h, gs, _ := newTestDocumentHandler(t)
+	rec := serve(h.CreateDocument, "POST", "/api/document", strings.NewReader(body), nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	doc, _ := gs.GetDocument("a")
+	if doc.Content != content {
+		t.Fatalf("disabled: content = %q, want %q", doc.Content, content)
+	}
+
+	h, gs, _ = newTestDocumentHandler(t)
+	h.sanitizer = NewContentSanitizer(defaultAllowedTags)
+	serve(h.CreateDocument, "POST", "/api/document", strings.NewReader(body), nil)
+	doc, _ = gs.GetDocument("a")
+	if doc.Content != "text" {
+		t.Fatalf("enabled: content = %q, want %q", doc.Content, "text")
+	}
+
+	update := `{"title": "A", "content": "<b onclick=\"x()\">hi</b>", "commit_changes": true}`
+	serve(h.UpdateDocument, "PUT", "/api/document/a", strings.NewReader(update), map[string]string{"rest": "a"})
+	doc, _ = gs.GetDocument("a")
+	if doc.Content != "<b>hi</b>" {
+		t.Fatalf("update: content = %q, want %q", doc.Content, "<b>hi</b>")
+	}
+}
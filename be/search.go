@@ -2,124 +2,727 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"log"
+	"math"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
 	"sync"
+	"time"
+	"unicode"
 
 	"github.com/kljensen/snowball"
 )
 
 type SearchEngine struct {
 	index     map[string]map[string]int
+	positions map[string]map[string][]int // позиции слов основы в документе, для поиска фраз
 	documents map[string]Document
 	mu        sync.RWMutex
 	languages map[string]bool
 	stemmer   func(string, string, bool) (string, error)
+	stopwords map[string]map[string]bool // стоп-слова по языкам, не индексируются и не ищутся
+
+	// Вхождения основ в заголовки документов, они учитываются с весом titleBoost
+	titleIndex map[string]map[string]int
+	titleBoost float64
+
+	// Основы индекса по алфавиту, для поиска по префиксу,
+	// и по длине в рунах, для нечеткого поиска
+	sortedTerms   []string
+	termsByLength map[int]map[string]struct{}
+
+	// Исходное слово для каждой основы, для подсказок "возможно, вы искали"
+	surfaces map[string]string
+
+	// Режим экономии памяти: в documents хранятся документы без содержимого,
+	// а полные документы для результатов читаются из storage
+	storage Storage
+
+	// Основы, проиндексированные для каждого документа: удаление убирает ровно их,
+	// даже если содержимое документа с тех пор изменилось
+	docTerms map[string][]string
+
+	// Ссылки документов на загруженные файлы
+	files *FileReferences
+
+	// Ссылки документов друг на друга, для обратных ссылок
+	links *DocumentLinks
+
+	// Число проиндексированных основ в каждом документе, для нормализации TF
+	docLengths map[string]int
+
+	// Учет памяти индекса, поддерживается при каждом изменении
+	termBytes    int
+	postings     int
+	positionsLen int
+	contentBytes int
+
+	// Ограничение памяти: при превышении maxBytes пишется предупреждение,
+	// а если задан capStorage - движок переходит в режим экономии памяти
+	maxBytes    int
+	capStorage  Storage
+	capExceeded bool
 }
 
-func NewSearchEngine(languages []string) *SearchEngine {
+// Приблизительная стоимость записей индекса в байтах, без учета самих строк
+const (
+	postingOverhead  = 48
+	positionSize     = 8
+	documentOverhead = 256
+)
+
+// IndexStats - приблизительный объем памяти индекса
+type IndexStats struct {
+	Documents    int  `json:"documents"`
+	Terms        int  `json:"terms"`
+	Postings     int  `json:"postings"`
+	ContentBytes int  `json:"content_bytes"`
+	ApproxBytes  int  `json:"approx_bytes"`
+	LowMemory    bool `json:"low_memory"`
+}
+
+// DefaultTitleBoost - во сколько раз слово в заголовке весомее слова в тексте
+const DefaultTitleBoost = 3
+
+// SearchEngineOption настраивает движок при создании
+type SearchEngineOption func(*SearchEngine)
+
+// WithTitleBoost задает вес вхождений слов в заголовок, 1 - как в тексте
+func WithTitleBoost(boost float64) SearchEngineOption {
+	return func(se *SearchEngine) {
+		se.titleBoost = boost
+	}
+}
+
+func NewSearchEngine(languages []string, options ...SearchEngineOption) *SearchEngine {
 	langMap := make(map[string]bool)
 	for _, lang := range languages {
 		langMap[lang] = true
 	}
 
-	return &SearchEngine{
-		index:     make(map[string]map[string]int),
-		documents: make(map[string]Document),
-		languages: langMap,
-		stemmer:   snowball.Stem,
+	se := &SearchEngine{
+		index:      make(map[string]map[string]int),
+		positions:  make(map[string]map[string][]int),
+		titleIndex: make(map[string]map[string]int),
+		titleBoost: DefaultTitleBoost,
+		documents:  make(map[string]Document),
+		docLengths: make(map[string]int),
+		languages:  langMap,
+		stemmer:    snowball.Stem,
+		stopwords:  make(map[string]map[string]bool),
+		docTerms:   make(map[string][]string),
+		files:      NewFileReferences(),
+		links:      NewDocumentLinks(),
+	}
+	for lang := range langMap {
+		if words, ok := defaultStopwords[lang]; ok {
+			se.stopwords[lang] = stopwordSet(words)
+		}
+	}
+	for _, option := range options {
+		option(se)
+	}
+	return se
+}
+
+// EnableLowMemory включает режим, в котором содержимое документов не хранится в памяти.
+// Вызывать до индексации документов.
+func (se *SearchEngine) EnableLowMemory(storage Storage) {
+	se.mu.Lock()
+	defer se.mu.Unlock()
+
+	se.storage = storage
+}
+
+func (se *SearchEngine) lowMemory() bool {
+	return se.storage != nil
+}
+
+// IndexSize возвращает число проиндексированных документов и уникальных основ слов
+func (se *SearchEngine) IndexSize() (documents, terms int) {
+	se.mu.RLock()
+	defer se.mu.RUnlock()
+
+	return len(se.documents), len(se.index)
+}
+
+// Stats возвращает учет памяти индекса
+func (se *SearchEngine) Stats() IndexStats {
+	se.mu.RLock()
+	defer se.mu.RUnlock()
+
+	return IndexStats{
+		Documents:    len(se.documents),
+		Terms:        len(se.index),
+		Postings:     se.postings,
+		ContentBytes: se.contentBytes,
+		ApproxBytes:  se.approxBytes(),
+		LowMemory:    se.lowMemory(),
 	}
 }
 
+func (se *SearchEngine) approxBytes() int {
+	return se.termBytes + se.postings*postingOverhead + se.positionsLen*positionSize + se.contentBytes + len(se.documents)*documentOverhead
+}
+
+// SetMemoryCap задает предел приблизительного объема индекса, 0 - без предела.
+// Если fallback не nil, при превышении движок переходит в режим экономии памяти
+// и дальше читает документы из fallback, иначе только пишет предупреждение.
+func (se *SearchEngine) SetMemoryCap(maxBytes int, fallback Storage) {
+	se.mu.Lock()
+	defer se.mu.Unlock()
+
+	se.maxBytes = maxBytes
+	se.capStorage = fallback
+	se.capExceeded = false
+	se.checkMemoryCap()
+}
+
+// checkMemoryCap вызывается под se.mu после изменения индекса
+func (se *SearchEngine) checkMemoryCap() {
+	if se.maxBytes <= 0 {
+		return
+	}
+	size := se.approxBytes()
+	if size <= se.maxBytes {
+		se.capExceeded = false
+		return
+	}
+	if se.capExceeded {
+		return
+	}
+	se.capExceeded = true
+
+	if se.capStorage == nil || se.lowMemory() {
+		log.Printf("Warning: search index uses about %d bytes, over the %d byte limit", size, se.maxBytes)
+		return
+	}
+	log.Printf("Warning: search index uses about %d bytes, over the %d byte limit; switching to low-memory mode", size, se.maxBytes)
+	se.switchToLowMemory(se.capStorage)
+}
+
+// switchToLowMemory переводит заполненный индекс в режим экономии памяти:
+// освобождает содержимое документов
+func (se *SearchEngine) switchToLowMemory(storage Storage) {
+	se.storage = storage
+	for fullPath, doc := range se.documents {
+		doc.Content = ""
+		se.documents[fullPath] = doc
+	}
+	se.contentBytes = 0
+}
+
+// FileReferences возвращает пути документов, ссылающихся на загруженный файл
+func (se *SearchEngine) FileReferences(file string) []string {
+	se.mu.RLock()
+	files := se.files
+	se.mu.RUnlock()
+
+	return files.Documents(file)
+}
+
+// RebuildStats описывает состояние индекса после перестроения
+type RebuildStats struct {
+	Documents      int `json:"documents"`
+	Terms          int `json:"terms"`
+	FileReferences int `json:"file_references"`
+}
+
+// Rebuild заново строит индекс и связанные структуры из storage.
+// Новое состояние собирается отдельно и подменяется целиком под блокировкой,
+// поэтому поиск видит либо старый, либо новый полный индекс.
+// Изменения, проиндексированные во время перестроения, могут быть потеряны.
+func (se *SearchEngine) Rebuild(storage Storage) (RebuildStats, error) {
+	fresh, err := se.buildFromStorage(storage)
+	if err != nil {
+		return RebuildStats{}, err
+	}
+
+	se.mu.Lock()
+	defer se.mu.Unlock()
+
+	se.replaceIndex(fresh)
+
+	return RebuildStats{
+		Documents:      len(se.documents),
+		Terms:          len(se.index),
+		FileReferences: se.files.Len(),
+	}, nil
+}
+
+// buildFromStorage строит отдельный индекс с настройками движка из документов
+// storage и уже проиндексированных черновиков. Живой индекс не меняется.
+func (se *SearchEngine) buildFromStorage(storage Storage) (*SearchEngine, error) {
+	se.mu.RLock()
+	fresh := &SearchEngine{
+		index:      make(map[string]map[string]int),
+		positions:  make(map[string]map[string][]int),
+		titleIndex: make(map[string]map[string]int),
+		titleBoost: se.titleBoost,
+		documents:  make(map[string]Document),
+		docLengths: make(map[string]int),
+		languages:  se.languages,
+		stemmer:    se.stemmer,
+		stopwords:  se.stopwords,
+		storage:    se.storage,
+		docTerms:   make(map[string][]string),
+		files:      NewFileReferences(),
+		links:      NewDocumentLinks(),
+	}
+	se.mu.RUnlock()
+
+	if err := fresh.indexStorage(storage); err != nil {
+		return nil, err
+	}
+	for _, draft := range se.draftDocuments() {
+		if err := fresh.IndexDocument(draft); err != nil {
+			return nil, err
+		}
+	}
+	return fresh, nil
+}
+
+// replaceIndex подменяет все структуры индекса построенными в fresh.
+// Вызывается под se.mu.
+func (se *SearchEngine) replaceIndex(fresh *SearchEngine) {
+	se.index = fresh.index
+	se.positions = fresh.positions
+	se.titleIndex = fresh.titleIndex
+	se.sortedTerms = fresh.sortedTerms
+	se.termsByLength = fresh.termsByLength
+	se.surfaces = fresh.surfaces
+	se.documents = fresh.documents
+	se.docLengths = fresh.docLengths
+	se.docTerms = fresh.docTerms
+	se.files = fresh.files
+	se.links = fresh.links
+	se.termBytes = fresh.termBytes
+	se.postings = fresh.postings
+	se.positionsLen = fresh.positionsLen
+	se.contentBytes = fresh.contentBytes
+	se.capExceeded = false
+	se.checkMemoryCap()
+}
+
 func (se *SearchEngine) IndexDocument(doc Document) error {
 	se.mu.Lock()
 	defer se.mu.Unlock()
 
 	fullPath := se.getBasePath(doc.Path)
-	se.documents[fullPath] = doc
+	// Повторная индексация заменяет прежнюю версию документа, а не дополняет ее
+	if _, ok := se.documents[fullPath]; ok {
+		se.deleteDocument(fullPath)
+	}
+	se.files.Update(doc.Path, doc.Content)
+	if !isDraftPath(doc.Path) {
+		se.links.Update(doc.Path, doc.Content)
+	}
 
-	content := doc.Title + " " + doc.Content
-	words := strings.Fields(content)
+	words := strings.Fields(documentText(doc))
+	titleWords := len(strings.Fields(doc.Title)) // заголовок идет в начале текста
+	stems := make(map[string]bool)
+	length := 0
+	position := -1
 
-	for _, word := range words {
+	for i, word := range words {
 		word = strings.ToLower(word)
 		word = strings.Trim(word, ".,!?\"'()[]{}")
+		if !isIndexableWord(word) || se.isStopword(word) {
+			continue // Отдельно стоящая пунктуация и стоп-слова не разрывают фразу
+		}
+		position++
 
 		for lang := range se.languages {
 			stemmed, err := se.stemmer(word, lang, false)
 			if err == nil && stemmed != "" {
 				if se.index[stemmed] == nil {
 					se.index[stemmed] = make(map[string]int)
+					se.termBytes += len(stemmed)
+					i, _ := slices.BinarySearch(se.sortedTerms, stemmed)
+					se.sortedTerms = slices.Insert(se.sortedTerms, i, stemmed)
+					se.addTermLength(stemmed)
+					se.addSurface(stemmed, word)
+				}
+				if se.index[stemmed][fullPath] == 0 {
+					se.postings++
 				}
 				se.index[stemmed][fullPath]++
+				if i < titleWords {
+					if se.titleIndex[stemmed] == nil {
+						se.titleIndex[stemmed] = make(map[string]int)
+					}
+					se.titleIndex[stemmed][fullPath]++
+				}
+				se.addPosition(stemmed, fullPath, position)
+				stems[stemmed] = true
+				length++
 			}
 		}
 	}
 
+	terms := make([]string, 0, len(stems))
+	for stem := range stems {
+		terms = append(terms, stem)
+	}
+	se.docTerms[fullPath] = terms
+	// Черновиков нет в storage, их содержимое остается в памяти
+	if se.lowMemory() && !isDraftPath(doc.Path) {
+		doc.Content = ""
+	}
+	se.contentBytes += len(doc.Content) - len(se.documents[fullPath].Content)
+	se.documents[fullPath] = doc
+	se.docLengths[fullPath] += length
+	se.checkMemoryCap()
+
 	return nil
 }
 
+// addPosition запоминает позицию слова в документе, вызывается под se.mu.
+// Разные языки могут дать одну основу для слова, позиция при этом пишется один раз.
+func (se *SearchEngine) addPosition(stemmed, fullPath string, position int) {
+	if se.positions[stemmed] == nil {
+		se.positions[stemmed] = make(map[string][]int)
+	}
+	positions := se.positions[stemmed][fullPath]
+	if n := len(positions); n > 0 && positions[n-1] == position {
+		return
+	}
+	se.positions[stemmed][fullPath] = append(positions, position)
+	se.positionsLen++
+}
+
 // Search возвращает результаты поиска с пагинацией
 // query - поисковый запрос
 // page - номер страницы (начиная с 1)
 // pageSize - количество результатов на странице
 func (se *SearchEngine) Search(query string, page, pageSize int) ([]Document, int, error) {
-	se.mu.RLock()
-	defer se.mu.RUnlock()
+	return se.SearchInLanguages(query, nil, page, pageSize)
+}
 
-	if page < 1 {
-		page = 1
+// SearchInLanguages ищет, применяя стемминг только указанных языков.
+// Пустой список означает все языки движка.
+func (se *SearchEngine) SearchInLanguages(query string, languages []string, page, pageSize int) ([]Document, int, error) {
+	return se.SearchWithOptions(query, SearchOptions{Languages: languages}, page, pageSize)
+}
+
+// SearchOptions - необязательные параметры поиска
+type SearchOptions struct {
+	Languages []string // языки стемминга, пусто - все языки движка
+	Fuzzy     bool     // искать похожие основы для слов без точных совпадений
+	Path      string   // искать только в документе и его потомках, пусто - везде
+	Any       bool     // достаточно любого слова запроса, по умолчанию нужны все
+	Drafts    bool     // искать и среди черновиков
+	Sort      string   // SortRelevance (по умолчанию) или SortModified
+}
+
+// Порядок результатов поиска
+const (
+	SortRelevance = "relevance"
+	SortModified  = "modified"
+)
+
+// SearchWithOptions ищет с дополнительными параметрами
+func (se *SearchEngine) SearchWithOptions(query string, opts SearchOptions, page, pageSize int) ([]Document, int, error) {
+	var docs []Document
+	total, err := se.SearchEach(query, opts, page, pageSize, func(result SearchResult) error {
+		docs = append(docs, result.Document)
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
 	}
-	if pageSize < 1 {
-		pageSize = 10
+	return docs, total, nil
+}
+
+// SearchEach выполняет поиск и передает документы страницы с фрагментами текста
+// в emit по одному, не дожидаясь загрузки всей страницы. Ошибка emit прерывает обход.
+func (se *SearchEngine) SearchEach(query string, opts SearchOptions, page, pageSize int, emit func(SearchResult) error) (int, error) {
+	start := time.Now()
+	defer func() {
+		searchQueriesTotal.Inc()
+		searchQueryDuration.Observe(time.Since(start).Seconds())
+	}()
+
+	if err := se.ValidateLanguages(opts.Languages); err != nil {
+		return 0, err
 	}
 
-	queryWords := strings.Fields(query)
-	results := make(map[string]int)
+	docs, total, matched, err := se.search(query, opts, page, pageSize)
+	if err != nil {
+		return 0, err
+	}
+	languages := se.queryLanguages(opts.Languages)
 
-	for _, word := range queryWords {
-		word = strings.ToLower(word)
-		word = strings.Trim(word, ".,!?\"'()[]{}")
+	for _, doc := range docs {
+		// В режиме экономии памяти подгружаем содержимое найденных документов
+		if se.lowMemory() && !isDraftPath(doc.Path) {
+			fullDoc, err := se.storage.GetDocument(doc.Path)
+			if err != nil {
+				log.Printf("Warning: failed to load search result %q: %v", doc.Path, err)
+			} else {
+				doc = fullDoc
+			}
+		}
+		result := SearchResult{Document: doc, Draft: isDraftPath(doc.Path)}
+		result.Snippet, result.Matches = se.highlight(doc.Content, matched, languages)
+		if err := emit(result); err != nil {
+			return total, err
+		}
+	}
 
-		for lang := range se.languages {
+	return total, nil
+}
+
+var ErrUnknownLanguage = errors.New("unknown search language")
+
+// parseSearchLanguages разбирает список языков через запятую и проверяет,
+// что для каждого есть стеммер snowball
+func parseSearchLanguages(value string) ([]string, error) {
+	var languages []string
+	for _, lang := range strings.Split(value, ",") {
+		lang = strings.ToLower(strings.TrimSpace(lang))
+		if lang == "" || slices.Contains(languages, lang) {
+			continue
+		}
+		if _, err := snowball.Stem("test", lang, false); err != nil {
+			return nil, fmt.Errorf("%w: %q, supported: english, french, hungarian, norwegian, russian, spanish, swedish", ErrUnknownLanguage, lang)
+		}
+		languages = append(languages, lang)
+	}
+	if len(languages) == 0 {
+		return nil, fmt.Errorf("%w: no search languages given", ErrUnknownLanguage)
+	}
+	return languages, nil
+}
+
+// ValidateLanguages проверяет, что все языки настроены в движке
+func (se *SearchEngine) ValidateLanguages(languages []string) error {
+	for _, lang := range languages {
+		if !se.languages[lang] {
+			return fmt.Errorf("%w: %q", ErrUnknownLanguage, lang)
+		}
+	}
+	return nil
+}
+
+// queryLanguages возвращает языки запроса, пустой список - все языки движка
+func (se *SearchEngine) queryLanguages(languages []string) map[string]bool {
+	if len(languages) == 0 {
+		return se.languages
+	}
+	queryLanguages := make(map[string]bool, len(languages))
+	for _, lang := range languages {
+		queryLanguages[lang] = true
+	}
+	return queryLanguages
+}
+
+// match возвращает документы, подходящие под запрос, с их весами TF-IDF
+// и найденные в индексе основы. Вызывается под se.mu
+func (se *SearchEngine) match(parsed searchQuery, opts SearchOptions, queryLanguages map[string]bool) (map[string]float64, map[string]bool) {
+	results := make(map[string]float64)
+	matched := make(map[string]bool)
+	totalDocs := float64(len(se.documents))
+
+	// hits собирает документы, найденные по текущей группе слов запроса
+	var hits map[string]bool
+
+	// TF-IDF: частота основы в документе, нормированная на его длину,
+	// умноженная на редкость основы среди всех документов.
+	// Вхождения в заголовок считаются с весом titleBoost.
+	score := func(stemmed string, weight float64) {
+		if docs, ok := se.index[stemmed]; ok {
+			matched[stemmed] = true
+			idf := math.Log(1 + totalDocs/float64(len(docs)))
+			titles := se.titleIndex[stemmed]
+			for docPath, count := range docs {
+				tf := float64(count) + (se.titleBoost-1)*float64(titles[docPath])
+				results[docPath] += tf / float64(max(se.docLengths[docPath], 1)) * idf * weight
+				hits[docPath] = true
+			}
+		}
+	}
+
+	// scoreWord учитывает одно слово запроса и сообщает, дало ли оно хоть одну основу
+	scoreWord := func(word string) bool {
+		// conf* - все основы, начинающиеся с префикса
+		if prefix, ok := strings.CutSuffix(word, "*"); ok {
+			for _, stemmed := range se.prefixTerms(prefix, queryLanguages) {
+				score(stemmed, 1)
+			}
+			return true
+		}
+
+		if se.isStopword(word) {
+			return false
+		}
+
+		var stems []string
+		exact := false
+		for lang := range queryLanguages {
 			stemmed, err := se.stemmer(word, lang, false)
 			if err != nil || stemmed == "" {
 				continue
 			}
+			score(stemmed, 1)
+			stems = append(stems, stemmed)
+			if _, ok := se.index[stemmed]; ok {
+				exact = true
+			}
+		}
+
+		// Похожие основы учитываются с меньшим весом, чтобы точные совпадения
+		// по другим словам запроса оставались выше
+		if opts.Fuzzy && !exact {
+			for _, candidate := range se.fuzzyTerms(stems) {
+				score(candidate.term, fuzzyWeight(candidate.distance))
+			}
+		}
+		return len(stems) > 0
+	}
+
+	// Множества документов считаются для каждой группы слов отдельно, а не для основ:
+	// слова с одинаковой основой не должны превращать AND в OR
+	var groupHits []map[string]bool
+	for _, group := range parsed.groups {
+		hits = make(map[string]bool)
+		required := false
+		for _, word := range group {
+			if scoreWord(word) {
+				required = true
+			}
+		}
+		// Группа, для слов которой стеммер не дал ни одной основы, не может совпасть
+		// и не должна обнулять выдачу
+		if required {
+			groupHits = append(groupHits, hits)
+		}
+	}
+
+	// По умолчанию документ должен содержать хотя бы одно слово каждой группы
+	if !opts.Any {
+		for docPath := range results {
+			for _, hits := range groupHits {
+				if !hits[docPath] {
+					delete(results, docPath)
+					break
+				}
+			}
+		}
+	}
 
-			if docs, ok := se.index[stemmed]; ok {
-				for docPath, count := range docs {
-					results[docPath] += count
+	// Исключенные слова убирают документ при любом режиме, сравнение по основам
+	for _, word := range parsed.excluded {
+		var stems []string
+		if prefix, ok := strings.CutSuffix(word, "*"); ok {
+			stems = se.prefixTerms(prefix, queryLanguages)
+		} else {
+			for lang := range queryLanguages {
+				if stemmed, err := se.stemmer(word, lang, false); err == nil && stemmed != "" {
+					stems = append(stems, stemmed)
 				}
 			}
 		}
+		for _, stemmed := range stems {
+			for docPath := range se.index[stemmed] {
+				delete(results, docPath)
+			}
+		}
+	}
+
+	// Фразы в кавычках обязательны: слова должны идти в документе подряд
+	for _, phrase := range parsed.phrases {
+		stems := se.phraseStems(phrase, queryLanguages)
+		if len(stems) == 0 {
+			continue
+		}
+		for docPath := range results {
+			if !se.containsPhrase(docPath, stems) {
+				delete(results, docPath)
+			}
+		}
+	}
+
+	if !opts.Drafts {
+		for docPath := range results {
+			if isDraftPath(se.documents[docPath].Path) {
+				delete(results, docPath)
+			}
+		}
+	}
+
+	// Фильтр по поддереву до пагинации, чтобы общее число учитывало только его
+	if scope := strings.Trim(opts.Path, "/"); scope != "" {
+		for docPath := range results {
+			if p := se.documents[docPath].Path; p != scope && !strings.HasPrefix(p, scope+"/") {
+				delete(results, docPath)
+			}
+		}
+	}
+
+	return results, matched
+}
+
+// search возвращает документы страницы, общее число результатов
+// и найденные в индексе основы слов запроса для подсветки
+func (se *SearchEngine) search(query string, opts SearchOptions, page, pageSize int) ([]Document, int, map[string]bool, error) {
+	se.mu.RLock()
+	defer se.mu.RUnlock()
+
+	queryLanguages := se.queryLanguages(opts.Languages)
+
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = 10
+	}
+
+	parsed := parseSearchQuery(query)
+	results, matched := se.match(parsed, opts, queryLanguages)
+
+	// Документы, заголовок которых совпадает с запросом, поднимаются выше остальных
+	var topScore float64
+	for _, score := range results {
+		topScore = max(topScore, score)
+	}
+	for docPath := range results {
+		if se.titleMatchesQuery(se.documents[docPath].Title, parsed.words, queryLanguages) {
+			results[docPath] += topScore
+		}
 	}
 
 	var sortedResults []struct {
 		Path  string
-		Score int
+		Score float64
 	}
 
 	for path, score := range results {
 		sortedResults = append(sortedResults, struct {
 			Path  string
-			Score int
+			Score float64
 		}{path, score})
 	}
 
-	// Сортировка по релевантности (по убыванию) и пути
-	for i := 0; i < len(sortedResults); i++ {
-		for j := i + 1; j < len(sortedResults); j++ {
-			if sortedResults[j].Score == sortedResults[i].Score {
-				if sortedResults[i].Path > sortedResults[j].Path {
-					sortedResults[i], sortedResults[j] = sortedResults[j], sortedResults[i]
-				}
-			} else if sortedResults[j].Score > sortedResults[i].Score {
-				sortedResults[i], sortedResults[j] = sortedResults[j], sortedResults[i]
+	// Сортировка по релевантности (по убыванию) и пути, для SortModified -
+	// сначала по дате изменения (по убыванию)
+	less := func(a, b int) bool {
+		if opts.Sort == SortModified {
+			ma, mb := se.documents[sortedResults[a].Path].Modified, se.documents[sortedResults[b].Path].Modified
+			if !ma.Equal(mb) {
+				return ma.After(mb)
 			}
 		}
+		if sortedResults[a].Score != sortedResults[b].Score {
+			return sortedResults[a].Score > sortedResults[b].Score
+		}
+		return sortedResults[a].Path < sortedResults[b].Path
 	}
+	sort.Slice(sortedResults, less)
 
 	// Вычисляем общее количество результатов
 	totalResults := len(sortedResults)
@@ -127,7 +730,7 @@ func (se *SearchEngine) Search(query string, page, pageSize int) ([]Document, in
 	// Вычисляем диапазон результатов для текущей страницы
 	start := (page - 1) * pageSize
 	if start >= totalResults {
-		return []Document{}, totalResults, nil
+		return []Document{}, totalResults, matched, nil
 	}
 
 	end := start + pageSize
@@ -145,7 +748,197 @@ func (se *SearchEngine) Search(query string, page, pageSize int) ([]Document, in
 		}
 	}
 
-	return docs, totalResults, nil
+	return docs, totalResults, matched, nil
+}
+
+// titleMatchesQuery проверяет, что заголовок состоит из слов запроса в том же порядке
+// с точностью до основ. Вызывается под se.mu.
+func (se *SearchEngine) titleMatchesQuery(title string, queryWords []string, languages map[string]bool) bool {
+	var titleWords []string
+	for _, word := range strings.Fields(title) {
+		word = strings.Trim(strings.ToLower(word), ".,!?\"'()[]{}")
+		if isIndexableWord(word) {
+			titleWords = append(titleWords, word)
+		}
+	}
+	if len(titleWords) == 0 || len(titleWords) != len(queryWords) {
+		return false
+	}
+
+	for i, word := range titleWords {
+		if word == queryWords[i] {
+			continue
+		}
+		same := false
+		for lang := range languages {
+			a, errA := se.stemmer(word, lang, false)
+			b, errB := se.stemmer(queryWords[i], lang, false)
+			if errA == nil && errB == nil && a != "" && a == b {
+				same = true
+				break
+			}
+		}
+		if !same {
+			return false
+		}
+	}
+	return true
+}
+
+// prefixTerms возвращает основы индекса, начинающиеся с префикса или с его основы
+// в одном из языков: основа бывает короче слова (happy -> happi). Вызывается под se.mu.
+func (se *SearchEngine) prefixTerms(prefix string, languages map[string]bool) []string {
+	prefix = strings.TrimRight(prefix, "*")
+	if !isIndexableWord(prefix) {
+		return nil
+	}
+
+	prefixes := []string{prefix}
+	for lang := range languages {
+		if stemmed, err := se.stemmer(prefix, lang, false); err == nil && stemmed != "" && !slices.Contains(prefixes, stemmed) {
+			prefixes = append(prefixes, stemmed)
+		}
+	}
+
+	var terms []string
+	for _, p := range prefixes {
+		for _, term := range se.termsWithPrefix(p) {
+			if !slices.Contains(terms, term) {
+				terms = append(terms, term)
+			}
+		}
+	}
+	return terms
+}
+
+// termsWithPrefix находит основы с префиксом двоичным поиском по sortedTerms
+func (se *SearchEngine) termsWithPrefix(prefix string) []string {
+	start := sort.SearchStrings(se.sortedTerms, prefix)
+	end := start
+	for end < len(se.sortedTerms) && strings.HasPrefix(se.sortedTerms[end], prefix) {
+		end++
+	}
+	return se.sortedTerms[start:end]
+}
+
+// searchQuery - разобранный запрос
+type searchQuery struct {
+	words    []string   // все искомые слова, в том числе слова фраз
+	groups   [][]string // слова внутри группы объединены OR, группы - AND
+	excluded []string   // слова с минусом: документы с ними исключаются
+	phrases  [][]string
+}
+
+// parseSearchQuery разбирает запрос на слова, исключения и фразы в кавычках.
+// Приоритет операторов: минус относится к одному слову, OR связывает соседние
+// слова сильнее, чем неявный AND, поэтому "a b OR c -d" - это a AND (b OR c) AND NOT d.
+// OR действует только между обычными словами: слова фраз всегда обязательны,
+// OR в начале, в конце или рядом с исключением игнорируется.
+// Слова фраз тоже входят в words, чтобы участвовать в ранжировании.
+// Незакрытая кавычка игнорируется.
+func parseSearchQuery(query string) searchQuery {
+	var q searchQuery
+	// joinable - последняя группа получена из обычного слова и может принять OR
+	joinable, pendingOr := false, false
+	parts := strings.Split(query, `"`)
+	for i, part := range parts {
+		isPhrase := i%2 == 1 && i < len(parts)-1
+		if isPhrase {
+			joinable, pendingOr = false, false
+		}
+		var phrase []string
+		for _, word := range strings.Fields(part) {
+			if !isPhrase && word == "OR" {
+				pendingOr = joinable
+				continue
+			}
+			excluded := false
+			if !isPhrase {
+				word, excluded = strings.CutPrefix(word, "-")
+			}
+			word = strings.ToLower(word)
+			word = strings.Trim(word, ".,!?\"'()[]{}")
+			if !isIndexableWord(word) {
+				continue
+			}
+
+			switch {
+			case excluded:
+				q.excluded = append(q.excluded, word)
+				joinable = false
+			case pendingOr:
+				q.groups[len(q.groups)-1] = append(q.groups[len(q.groups)-1], word)
+			default:
+				q.groups = append(q.groups, []string{word})
+				joinable = !isPhrase
+			}
+			pendingOr = false
+			if excluded {
+				continue
+			}
+			q.words = append(q.words, word)
+			phrase = append(phrase, word)
+		}
+		if isPhrase && len(phrase) > 0 {
+			q.phrases = append(q.phrases, phrase)
+		}
+	}
+	return q
+}
+
+// isIndexableWord отбрасывает токены без букв и цифр, например тире
+func isIndexableWord(word string) bool {
+	return strings.IndexFunc(word, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) >= 0
+}
+
+// phraseStems возвращает для каждого слова фразы его основы во всех языках запроса.
+// Стоп-слова пропускаются так же, как при индексации
+func (se *SearchEngine) phraseStems(phrase []string, languages map[string]bool) [][]string {
+	var stems [][]string
+	for _, word := range phrase {
+		if se.isStopword(word) {
+			continue
+		}
+		var wordStems []string
+		for lang := range languages {
+			stemmed, err := se.stemmer(word, lang, false)
+			if err == nil && stemmed != "" && !slices.Contains(wordStems, stemmed) {
+				wordStems = append(wordStems, stemmed)
+			}
+		}
+		stems = append(stems, wordStems)
+	}
+	return stems
+}
+
+// containsPhrase проверяет, что слова фразы идут в документе подряд, вызывается под se.mu
+func (se *SearchEngine) containsPhrase(fullPath string, stems [][]string) bool {
+	hasPosition := func(i, position int) bool {
+		for _, stemmed := range stems[i] {
+			if _, found := slices.BinarySearch(se.positions[stemmed][fullPath], position); found {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, first := range stems[0] {
+		for _, start := range se.positions[first][fullPath] {
+			matched := true
+			for i := 1; i < len(stems) && matched; i++ {
+				matched = hasPosition(i, start+i)
+			}
+			if matched {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// documentText возвращает текст документа, который попадает в индекс
+func documentText(doc Document) string {
+	return doc.Title + " " + strings.Join(doc.Tags, " ") + " " + doc.Content
 }
 
 func (se *SearchEngine) getBasePath(docPath string) string {
@@ -156,7 +949,32 @@ func (se *SearchEngine) getBasePath(docPath string) string {
 	return basePath
 }
 
+// LoadFromStorage индексирует все документы storage. Индекс строится отдельно
+// и подменяет текущий целиком, поэтому параллельный поиск не видит его частично.
 func (se *SearchEngine) LoadFromStorage(storage Storage) error {
+	fresh, err := se.buildFromStorage(storage)
+	if err != nil {
+		return err
+	}
+
+	se.mu.Lock()
+	defer se.mu.Unlock()
+
+	se.replaceIndex(fresh)
+	return nil
+}
+
+// indexStorage обходит дерево документов storage и индексирует каждый
+func (se *SearchEngine) indexStorage(storage Storage) error {
+	return walkDocuments(storage, se.IndexDocument)
+}
+
+func (se *SearchEngine) indexDocumentRecursive(storage Storage, doc Document) error {
+	return walkDocumentTree(storage, doc, se.IndexDocument)
+}
+
+// walkDocuments обходит все документы storage в глубину, начиная с корневых
+func walkDocuments(storage Storage, visit func(Document) error) error {
 	rootDocs, err := storage.GetRootDocuments()
 	if err != nil {
 		return err
@@ -168,7 +986,7 @@ func (se *SearchEngine) LoadFromStorage(storage Storage) error {
 			return err
 		}
 
-		if err := se.indexDocumentRecursive(storage, fullDoc); err != nil {
+		if err := walkDocumentTree(storage, fullDoc, visit); err != nil {
 			return err
 		}
 	}
@@ -176,8 +994,9 @@ func (se *SearchEngine) LoadFromStorage(storage Storage) error {
 	return nil
 }
 
-func (se *SearchEngine) indexDocumentRecursive(storage Storage, doc Document) error {
-	if err := se.IndexDocument(doc); err != nil {
+// walkDocumentTree вызывает visit для документа и всех его потомков
+func walkDocumentTree(storage Storage, doc Document, visit func(Document) error) error {
+	if err := visit(doc); err != nil {
 		return err
 	}
 
@@ -186,7 +1005,7 @@ func (se *SearchEngine) indexDocumentRecursive(storage Storage, doc Document) er
 		if err != nil {
 			return err
 		}
-		if err := se.indexDocumentRecursive(storage, childDoc); err != nil {
+		if err := walkDocumentTree(storage, childDoc, visit); err != nil {
 			return err
 		}
 	}
@@ -204,33 +1023,70 @@ func (se *SearchEngine) DeleteDocument(docPath string) error {
 	if _, ok := se.documents[fullPath]; !ok {
 		return fmt.Errorf("документ не найден по пути %q", docPath)
 	}
+	se.deleteDocument(fullPath)
 
-	// Получаем содержимое документа для удаления всех его слов из индекса
-	docContent := se.documents[fullPath].Title + " " + se.documents[fullPath].Content
-	words := strings.Fields(docContent)
-
-	// Удаляем слова документа из индекса для каждого языка
-	for lang := range se.languages {
-		for _, word := range words {
-			word = strings.ToLower(word)
-			word = strings.Trim(word, ".,!?\"'()[]{}")
+	return nil
+}
 
-			stemmed, err := se.stemmer(word, lang, false)
-			if err == nil && stemmed != "" {
-				if index, ok := se.index[stemmed]; ok {
-					delete(index, fullPath)
+// DeleteSubtree удаляет из индекса документ и всех его потомков, если они есть
+func (se *SearchEngine) DeleteSubtree(docPath string) {
+	se.mu.Lock()
+	defer se.mu.Unlock()
 
-					// Если слово больше не имеет ссылок, удаляем его из общего индекса
-					if len(index) == 0 {
-						delete(se.index, stemmed)
-					}
-				}
-			}
+	fullPath := se.getBasePath(docPath)
+	prefix := fullPath + string(filepath.Separator)
+	for indexed := range se.documents {
+		if indexed == fullPath || strings.HasPrefix(indexed, prefix) {
+			se.deleteDocument(indexed)
 		}
 	}
+}
+
+// deleteDocument удаляет проиндексированный документ, вызывается под se.mu
+func (se *SearchEngine) deleteDocument(fullPath string) {
+	se.files.Remove(se.documents[fullPath].Path)
+	se.links.Remove(se.documents[fullPath].Path)
+	se.contentBytes -= len(se.documents[fullPath].Content)
+
+	for _, stemmed := range se.docTerms[fullPath] {
+		se.removePosting(stemmed, fullPath)
+	}
+	delete(se.docTerms, fullPath)
 
 	// Удаляем сам документ из карты documents
 	delete(se.documents, fullPath)
+	delete(se.docLengths, fullPath)
+}
 
-	return nil
+func (se *SearchEngine) removePosting(stemmed, fullPath string) {
+	if index, ok := se.index[stemmed]; ok {
+		if _, ok := index[fullPath]; ok {
+			delete(index, fullPath)
+			se.postings--
+		}
+		if titles, ok := se.titleIndex[stemmed]; ok {
+			delete(titles, fullPath)
+			if len(titles) == 0 {
+				delete(se.titleIndex, stemmed)
+			}
+		}
+		if positions, ok := se.positions[stemmed]; ok {
+			se.positionsLen -= len(positions[fullPath])
+			delete(positions, fullPath)
+			if len(positions) == 0 {
+				delete(se.positions, stemmed)
+			}
+		}
+
+		// Если слово больше не имеет ссылок, удаляем его из общего индекса
+		if len(index) == 0 {
+			delete(se.index, stemmed)
+			se.termBytes -= len(stemmed)
+			if i, found := slices.BinarySearch(se.sortedTerms, stemmed); found {
+				se.sortedTerms = slices.Delete(se.sortedTerms, i, i+1)
+			}
+			se.removeTermLength(stemmed)
+			se.removeSurface(stemmed)
+		}
+	}
 }
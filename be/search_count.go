@@ -0,0 +1,35 @@
+// search_count.go
+package main
+
+import "net/http"
+
+// Count возвращает число документов, подходящих под запрос. Разбор и стемминг
+// те же, что у Search, но без сортировки, пагинации и загрузки документов
+func (se *SearchEngine) Count(query string, opts SearchOptions) int {
+	se.mu.RLock()
+	defer se.mu.RUnlock()
+
+	results, _ := se.match(parseSearchQuery(query), opts, se.queryLanguages(opts.Languages))
+	return len(results)
+}
+
+// CountDocuments возвращает только общее число результатов поиска
+func (h *SearchHandler) CountDocuments(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query().Get("q")
+	if query == "" {
+		http.Error(w, "query parameter 'q' is required", http.StatusBadRequest)
+		return
+	}
+	if err := h.validateQuery(query); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	opts, err := h.searchOptions(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, r, map[string]int{"total": h.searchEngine.Count(query, opts)})
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestSearchCountMatchesSearchTotal(t *testing.T) {
+	se := NewSearchEngine([]string{"english", "russian"})
+	docs := []Document{
+		{ID: "a", Title: "Kafka", Path: "a", Content: "kafka consumer lag"},
+		{ID: "b", Title: "Consumers", Path: "b", Content: "kafka consumers and producers"},
+		{ID: "c", Title: "Docker", Path: "c", Content: "docker compose release notes"},
+		{ID: "d", Title: "Заметки", Path: "c/d", Content: "заметки о релизе docker"},
+		{ID: "e", Title: "Empty", Path: "e", Content: ""},
+	}
+	for _, doc := range docs {
+		if err := se.IndexDocument(doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		query string
+		opts  SearchOptions
+	}{
+		{"kafka", SearchOptions{}},
+		{"kafka consumer", SearchOptions{}},
+		{"kafka consumer lag", SearchOptions{Any: true}},
+		{"docker -compose", SearchOptions{}},
+		{"docker OR kafka", SearchOptions{}},
+		{`"release notes"`, SearchOptions{}},
+		{"kaf*", SearchOptions{}},
+		{"kafak", SearchOptions{Fuzzy: true}},
+		{"docker", SearchOptions{Path: "c"}},
+		{"релизы", SearchOptions{Languages: []string{"russian"}}},
+		{"missing", SearchOptions{}},
+	}
+	for _, tt := range tests {
+		_, total, err := se.SearchWithOptions(tt.query, tt.opts, 1, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := se.Count(tt.query, tt.opts); got != total {
+			t.Errorf("Count(%q, %+v) = %d, search total = %d", tt.query, tt.opts, got, total)
+		}
+	}
+
+	h := NewSearchHandler(se)
+	for query, want := range map[string]int{"kafka": 2, "docker OR kafka": 4, "missing": 0} {
+		rec := serve(h.CountDocuments, "GET", "/api/search/count?q="+url.QueryEscape(query), nil, nil)
+		var resp struct {
+			Total int `json:"total"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		if rec.Code != http.StatusOK || resp.Total != want {
+			t.Errorf("count %q: status %d, total %d, want %d", query, rec.Code, resp.Total, want)
+		}
+	}
+
+	if rec := serve(h.CountDocuments, "GET", "/api/search/count", nil, nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("missing query: status %d", rec.Code)
+	}
+	if rec := serve(h.CountDocuments, "GET", "/api/search/count?q=kafka&lang=klingon", nil, nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("unknown language: status %d", rec.Code)
+	}
+}
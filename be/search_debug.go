@@ -0,0 +1,96 @@
+// search_debug.go
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// SearchDebug показывает, что на самом деле искал движок (параметр debug=true)
+type SearchDebug struct {
+	Languages []string    `json:"languages"`
+	Terms     []QueryTerm `json:"terms"`
+	Excluded  []QueryTerm `json:"excluded,omitempty"`
+	Phrases   [][]string  `json:"phrases,omitempty"`
+}
+
+// QueryTerm - слово запроса и его основы по языкам
+type QueryTerm struct {
+	Word     string            `json:"word"`
+	Stems    map[string]string `json:"stems"`
+	Prefix   bool              `json:"prefix,omitempty"`   // conf* - ищутся основы с этим префиксом
+	Or       bool              `json:"or,omitempty"`       // объединено с предыдущим словом через OR
+	Stopword bool              `json:"stopword,omitempty"` // стоп-слово, в поиске не участвует
+
+	// Число документов индекса с любой из основ слова и с основой каждого языка.
+	// Совпадение счетчиков разных языков показывает, что их основы пересекаются
+	Matches    int            `json:"matches"`
+	ByLanguage map[string]int `json:"byLanguage,omitempty"`
+}
+
+// DebugQuery разбирает запрос так же, как поиск, и возвращает языки и основы слов
+func (se *SearchEngine) DebugQuery(query string, languages []string) SearchDebug {
+	se.mu.RLock()
+	defer se.mu.RUnlock()
+
+	queryLanguages := se.queryLanguages(languages)
+	debug := SearchDebug{Languages: []string{}, Terms: []QueryTerm{}}
+	for lang := range queryLanguages {
+		debug.Languages = append(debug.Languages, lang)
+	}
+	sort.Strings(debug.Languages)
+
+	parsed := parseSearchQuery(query)
+	debug.Phrases = parsed.phrases
+	for _, group := range parsed.groups {
+		for i, word := range group {
+			term := se.debugTerm(word, debug.Languages)
+			term.Or = i > 0
+			debug.Terms = append(debug.Terms, term)
+		}
+	}
+	for _, word := range parsed.excluded {
+		debug.Excluded = append(debug.Excluded, se.debugTerm(word, debug.Languages))
+	}
+	return debug
+}
+
+// debugTerm возвращает основы слова запроса по языкам и число документов с ними.
+// Вызывается под se.mu.
+func (se *SearchEngine) debugTerm(word string, languages []string) QueryTerm {
+	term := QueryTerm{Word: word, Stems: map[string]string{}}
+	if prefix, ok := strings.CutSuffix(word, "*"); ok {
+		term.Word, term.Prefix = strings.TrimRight(prefix, "*"), true
+	} else if se.isStopword(word) {
+		term.Stopword = true
+		return term
+	}
+	for _, lang := range languages {
+		if stemmed, err := se.stemmer(term.Word, lang, false); err == nil && stemmed != "" {
+			term.Stems[lang] = stemmed
+		}
+	}
+
+	matched := make(map[string]bool)
+	for lang, stemmed := range term.Stems {
+		docs := make(map[string]bool)
+		terms := []string{stemmed}
+		if term.Prefix {
+			terms = se.termsWithPrefix(stemmed)
+		}
+		for _, t := range terms {
+			for docPath := range se.index[t] {
+				docs[docPath] = true
+				matched[docPath] = true
+			}
+		}
+		if len(docs) > 0 {
+			if term.ByLanguage == nil {
+				term.ByLanguage = make(map[string]int)
+			}
+			term.ByLanguage[lang] = len(docs)
+		}
+	}
+	term.Matches = len(matched)
+	return term
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSearchDebug(t *testing.T) {
+	se := NewSearchEngine([]string{"english", "russian"})
+	if err := se.IndexDocument(Document{ID: "a", Title: "Guide", Path: "a", Content: "running servers"}); err != nil {
+		t.Fatal(err)
+	}
+	h := NewSearchHandler(se)
+
+	search := func(target string) SearchResults {
+		t.Helper()
+		rec := serve(h.SearchDocuments, "GET", target, nil, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d", target, rec.Code)
+		}
+		var resp SearchResults
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	if resp := search("/api/search?q=running"); resp.Debug != nil {
+		t.Errorf("debug returned without debug=true: %+v", resp.Debug)
+	}
+
+	resp := search(`/api/search?q=Running+serv*+%22big+servers%22&debug=true`)
+	if resp.Debug == nil {
+		t.Fatal("no debug section")
+	}
+	if !reflect.DeepEqual(resp.Debug.Languages, []string{"english", "russian"}) {
+		t.Errorf("languages = %v", resp.Debug.Languages)
+	}
+	want := []QueryTerm{
+		{Word: "running", Stems: map[string]string{"english": "run", "russian": "running"},
+			Matches: 1, ByLanguage: map[string]int{"english": 1, "russian": 1}},
+		{Word: "serv", Stems: map[string]string{"english": "serv", "russian": "serv"}, Prefix: true,
+			Matches: 1, ByLanguage: map[string]int{"english": 1, "russian": 1}},
+		{Word: "big", Stems: map[string]string{"english": "big", "russian": "big"}},
+		{Word: "servers", Stems: map[string]string{"english": "server", "russian": "servers"},
+			Matches: 1, ByLanguage: map[string]int{"english": 1, "russian": 1}},
+	}
+	if !reflect.DeepEqual(resp.Debug.Terms, want) {
+		t.Errorf("terms = %+v, want %+v", resp.Debug.Terms, want)
+	}
+	if !reflect.DeepEqual(resp.Debug.Phrases, [][]string{{"big", "servers"}}) {
+		t.Errorf("phrases = %v", resp.Debug.Phrases)
+	}
+
+	resp = search("/api/search?q=running&lang=english&debug=true")
+	if !reflect.DeepEqual(resp.Debug.Languages, []string{"english"}) {
+		t.Errorf("overridden languages = %v", resp.Debug.Languages)
+	}
+	if !reflect.DeepEqual(resp.Debug.Terms[0].Stems, map[string]string{"english": "run"}) {
+		t.Errorf("overridden stems = %v", resp.Debug.Terms[0].Stems)
+	}
+
+	rec := serve(h.SearchDocuments, "GET", "/api/search?q=running&format=ndjson&debug=true", nil, nil)
+	lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	var last struct {
+		Metadata SearchMetadata `json:"metadata"`
+	}
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &last); err != nil {
+		t.Fatal(err)
+	}
+	if last.Metadata.Debug == nil || last.Metadata.Debug.Terms[0].Word != "running" {
+		t.Errorf("ndjson metadata = %+v", last.Metadata)
+	}
+}
+
+func TestSearchDebugMatchCounts(t *testing.T) {
+	se := NewSearchEngine([]string{"english", "russian"})
+	docs := []Document{
+		{ID: "a", Title: "A", Path: "a", Content: "connection pooling"},
+		{ID: "b", Title: "B", Path: "b", Content: "connected clients"},
+		{ID: "c", Title: "C", Path: "c", Content: "коннекты к базе"},
+	}
+	for _, doc := range docs {
+		if err := se.IndexDocument(doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	debug := se.DebugQuery("connection", nil)
+	term := debug.Terms[0]
+	// Английская основа connect совпадает с connected, русский стеммер оставляет слово как есть
+	if term.Matches != 2 || !reflect.DeepEqual(term.ByLanguage, map[string]int{"english": 2, "russian": 1}) {
+		t.Errorf("connection = %+v", term)
+	}
+
+	debug = se.DebugQuery("коннект* missing", nil)
+	if term := debug.Terms[0]; term.Matches != 1 || term.ByLanguage["russian"] != 1 {
+		t.Errorf("коннект* = %+v", term)
+	}
+	if term := debug.Terms[1]; term.Matches != 0 || term.ByLanguage != nil {
+		t.Errorf("missing = %+v", term)
+	}
+}
@@ -0,0 +1,56 @@
+// search_drafts.go
+package main
+
+import (
+	"log"
+	"strings"
+)
+
+// Черновики индексируются под синтетическими путями draft:<id>, которые не пересекаются
+// с путями документов: в идентификаторах документов двоеточия не бывает
+const draftPathPrefix = "draft:"
+
+func draftSearchPath(id string) string {
+	return draftPathPrefix + id
+}
+
+func isDraftPath(docPath string) bool {
+	return strings.HasPrefix(docPath, draftPathPrefix)
+}
+
+// draftDocument представляет черновик документом для индекса
+func draftDocument(draft Draft) Document {
+	return Document{ID: draft.ID, Title: draft.Title, Content: draft.Content, Path: draftSearchPath(draft.ID)}
+}
+
+// IndexDrafts добавляет черновики в индекс, например при запуске
+func (se *SearchEngine) IndexDrafts(drafts []Draft) error {
+	for _, draft := range drafts {
+		if err := se.IndexDocument(draftDocument(draft)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// draftDocuments возвращает проиндексированные черновики. Их нет в storage,
+// поэтому перестроение индекса переносит их из текущего
+func (se *SearchEngine) draftDocuments() []Document {
+	se.mu.RLock()
+	defer se.mu.RUnlock()
+
+	var drafts []Document
+	for _, doc := range se.documents {
+		if isDraftPath(doc.Path) {
+			drafts = append(drafts, doc)
+		}
+	}
+	return drafts
+}
+
+// indexDraft синхронизирует индекс с сохраненным черновиком, ошибки только логируются
+func (h *DocumentHandler) indexDraft(draft Draft) {
+	if err := h.search.IndexDocument(draftDocument(draft)); err != nil {
+		log.Printf("Warning: failed to index draft %s: %v", draft.ID, err)
+	}
+}
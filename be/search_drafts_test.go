@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func searchDrafts(t *testing.T, h *SearchHandler, query string) SearchResults {
+	t.Helper()
+	rec := serve(h.SearchDocuments, "GET", "/api/search?q="+query, nil, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp SearchResults
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestDraftsIndexedForSearch(t *testing.T) {
+	h, gs, engine := newTestDocumentHandler(t)
+	doc := mustCreate(t, gs, "", "Published", "platypus facts")
+	if err := engine.LoadFromStorage(gs); err != nil {
+		t.Fatal(err)
+	}
+	sh := NewSearchHandler(engine)
+
+	body := `{"id": "d1", "title": "Notes", "content": "platypus draft about echidna", "path": "` + doc.Path + `"}`
+	if rec := serve(h.UpsertDraftDocument, "POST", "/api/draft", strings.NewReader(body), nil); rec.Code != http.StatusNoContent {
+		t.Fatalf("upsert status = %d", rec.Code)
+	}
+
+	if resp := searchDrafts(t, sh, "echidna"); resp.Total != 0 {
+		t.Errorf("draft found without includeDrafts: %+v", resp.Results)
+	}
+	resp := searchDrafts(t, sh, "platypus&includeDrafts=true")
+	if resp.Total != 2 {
+		t.Fatalf("total = %d, want 2: %+v", resp.Total, resp.Results)
+	}
+	for _, result := range resp.Results {
+		if result.Draft != (result.Path == draftSearchPath("d1")) {
+			t.Errorf("result %s: draft = %v", result.Path, result.Draft)
+		}
+	}
+
+	// Обновление черновика заменяет его в индексе
+	body = `{"id": "d1", "title": "Notes", "content": "now about wombats"}`
+	serve(h.UpsertDraftDocument, "POST", "/api/draft", strings.NewReader(body), nil)
+	if resp := searchDrafts(t, sh, "echidna&includeDrafts=true"); resp.Total != 0 {
+		t.Errorf("stale draft content found: %+v", resp.Results)
+	}
+	if resp := searchDrafts(t, sh, "wombats&includeDrafts=true"); resp.Total != 1 || !resp.Results[0].Draft {
+		t.Errorf("updated draft not found: %+v", resp.Results)
+	}
+
+	// Перестроение индекса сохраняет черновики
+	if _, err := engine.Rebuild(gs); err != nil {
+		t.Fatal(err)
+	}
+	if resp := searchDrafts(t, sh, "wombats&includeDrafts=true"); resp.Total != 1 {
+		t.Errorf("draft lost after rebuild: %+v", resp.Results)
+	}
+
+	if rec := serve(h.DeleteDraftDocument, "DELETE", "/api/draft/d1", nil, map[string]string{"rest": "d1"}); rec.Code != http.StatusNoContent {
+		t.Fatalf("delete status = %d", rec.Code)
+	}
+	if resp := searchDrafts(t, sh, "wombats&includeDrafts=true"); resp.Total != 0 {
+		t.Errorf("deleted draft found: %+v", resp.Results)
+	}
+
+	if rec := serve(h.RestoreDraftFromTrash, "POST", "/api/drafts/trash/restore/d1", nil, map[string]string{"id": "d1"}); rec.Code != http.StatusOK {
+		t.Fatalf("restore status = %d", rec.Code)
+	}
+	if resp := searchDrafts(t, sh, "wombats&includeDrafts=true"); resp.Total != 1 {
+		t.Errorf("restored draft not found: %+v", resp.Results)
+	}
+}
+
+func TestIndexDraftsLowMemory(t *testing.T) {
+	gs := newTestStorage(t)
+	mustCreate(t, gs, "", "Published", "platypus")
+	engine := NewSearchEngine([]string{"english"})
+	engine.EnableLowMemory(gs)
+	if err := engine.LoadFromStorage(gs); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.IndexDrafts([]Draft{{ID: "d1", Title: "Draft", Content: "platypus draft"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	var results []SearchResult
+	if _, err := engine.SearchEach("platypus", SearchOptions{Drafts: true}, 1, 10, func(r SearchResult) error {
+		results = append(results, r)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("results = %+v", results)
+	}
+	for _, r := range results {
+		if r.Draft && r.Content != "platypus draft" {
+			t.Errorf("draft content = %q", r.Content)
+		}
+	}
+}
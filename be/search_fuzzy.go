@@ -0,0 +1,123 @@
+// search_fuzzy.go
+package main
+
+import (
+	"math"
+	"sort"
+	"unicode/utf8"
+)
+
+// fuzzyCandidate - основа индекса, похожая на основу слова запроса
+type fuzzyCandidate struct {
+	term     string
+	distance int
+}
+
+// maxFuzzyDistance - допустимое число опечаток: у коротких основ любая
+// замена дает совсем другое слово, поэтому для них порог ниже
+func maxFuzzyDistance(length int) int {
+	switch {
+	case length < 3:
+		return 0
+	case length <= 4:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// fuzzyWeight - множитель оценки похожей основы, каждая правка вдвое снижает вес
+func fuzzyWeight(distance int) float64 {
+	return math.Pow(0.5, float64(distance))
+}
+
+// addTermLength добавляет новую основу индекса в корзину ее длины, вызывается под se.mu
+func (se *SearchEngine) addTermLength(term string) {
+	if se.termsByLength == nil {
+		se.termsByLength = make(map[int]map[string]struct{})
+	}
+	length := utf8.RuneCountInString(term)
+	if se.termsByLength[length] == nil {
+		se.termsByLength[length] = make(map[string]struct{})
+	}
+	se.termsByLength[length][term] = struct{}{}
+}
+
+// removeTermLength убирает удаленную из индекса основу, вызывается под se.mu
+func (se *SearchEngine) removeTermLength(term string) {
+	length := utf8.RuneCountInString(term)
+	delete(se.termsByLength[length], term)
+	if len(se.termsByLength[length]) == 0 {
+		delete(se.termsByLength, length)
+	}
+}
+
+// fuzzyTerms ищет основы индекса на расстоянии Левенштейна не больше порога
+// от любой из основ слова. Сравниваются только основы подходящей длины:
+// длины строк на расстоянии d отличаются не больше чем на d. Вызывается под se.mu.
+func (se *SearchEngine) fuzzyTerms(stems []string) []fuzzyCandidate {
+	best := make(map[string]int)
+	for _, stem := range stems {
+		query := []rune(stem)
+		limit := maxFuzzyDistance(len(query))
+		if limit == 0 {
+			continue
+		}
+		for length := len(query) - limit; length <= len(query)+limit; length++ {
+			for term := range se.termsByLength[length] {
+				d := levenshtein(query, []rune(term), limit)
+				if d > limit {
+					continue
+				}
+				if prev, ok := best[term]; !ok || d < prev {
+					best[term] = d
+				}
+			}
+		}
+	}
+
+	candidates := make([]fuzzyCandidate, 0, len(best))
+	for term, d := range best {
+		candidates = append(candidates, fuzzyCandidate{term: term, distance: d})
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].term < candidates[j].term })
+	return candidates
+}
+
+// levenshtein считает расстояние редактирования между a и b. Как только
+// расстояние гарантированно превышает limit, возвращается limit+1.
+func levenshtein(a, b []rune, limit int) int {
+	if abs(len(a)-len(b)) > limit {
+		return limit + 1
+	}
+
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		rowMin := curr[0]
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+			rowMin = min(rowMin, curr[j])
+		}
+		if rowMin > limit {
+			return limit + 1
+		}
+		prev, curr = curr, prev
+	}
+	return min(prev[len(b)], limit+1)
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b  string
+		limit int
+		want  int
+	}{
+		{"kafka", "kafka", 2, 0},
+		{"kafka", "kafak", 2, 2},
+		{"kubernet", "kubernt", 2, 1},
+		{"конфиг", "кофниг", 2, 2},
+		{"search", "sear", 2, 2},
+		{"search", "se", 2, 3},
+		{"abcdef", "uvwxyz", 2, 3},
+	}
+	for _, tt := range tests {
+		if got := levenshtein([]rune(tt.a), []rune(tt.b), tt.limit); got != tt.want {
+			t.Errorf("levenshtein(%q, %q, %d) = %d, want %d", tt.a, tt.b, tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestSearchFuzzy(t *testing.T) {
+	se := NewSearchEngine([]string{"english"})
+	docs := []Document{
+		{ID: "kube", Title: "Cluster", Path: "kube", Content: "kubernetes deployment guide"},
+		{ID: "kafka", Title: "Streams", Path: "kafka", Content: "kafka consumer"},
+		{ID: "cart", Title: "Shop", Path: "cart", Content: "cart checkout"},
+		{ID: "cat", Title: "Pets", Path: "cat", Content: "cat food"},
+	}
+	for _, doc := range docs {
+		if err := se.IndexDocument(doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	search := func(query string, fuzzy bool) []string {
+		t.Helper()
+		results, _, err := se.SearchWithOptions(query, SearchOptions{Fuzzy: fuzzy}, 1, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return docPaths(results)
+	}
+
+	if got := search("kubernetez", false); len(got) != 0 {
+		t.Errorf("without fuzzy = %v, want none", got)
+	}
+	if got := search("kubernetez", true); !reflect.DeepEqual(got, []string{"kube"}) {
+		t.Errorf("fuzzy typo = %v, want [kube]", got)
+	}
+	if got := search("kafak", true); !reflect.DeepEqual(got, []string{"kafka"}) {
+		t.Errorf("fuzzy transposition = %v, want [kafka]", got)
+	}
+
+	// Точное совпадение отключает нечеткий поиск для слова
+	if got := search("cart", true); !reflect.DeepEqual(got, []string{"cart"}) {
+		t.Errorf("exact match = %v, want [cart]", got)
+	}
+	// У коротких основ допускается одна опечатка
+	got := search("cot", true)
+	sort.Strings(got)
+	if !reflect.DeepEqual(got, []string{"cat"}) {
+		t.Errorf("short typo = %v, want [cat]", got)
+	}
+
+	// Точное совпадение по одному слову важнее опечатки в другом
+	results, _, err := se.SearchWithOptions("consumer kubernetez", SearchOptions{Fuzzy: true, Any: true}, 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := docPaths(results); len(got) != 2 || got[0] != "kafka" {
+		t.Errorf("mixed query = %v, want kafka first", got)
+	}
+}
+
+func TestSearchFuzzyLengthBuckets(t *testing.T) {
+	se := NewSearchEngine([]string{"english"})
+	if err := se.IndexDocument(Document{ID: "a", Title: "Alpha", Path: "a", Content: "zeppelin"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := se.DeleteDocument("a"); err != nil {
+		t.Fatal(err)
+	}
+	if len(se.termsByLength) != 0 {
+		t.Fatalf("termsByLength = %v after delete", se.termsByLength)
+	}
+	results, _, err := se.SearchWithOptions("zepelin", SearchOptions{Fuzzy: true}, 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 0 {
+		t.Errorf("deleted term matched: %v", docPaths(results))
+	}
+}
+
+func TestSearchHandlerFuzzyParam(t *testing.T) {
+	se := NewSearchEngine([]string{"english"})
+	if err := se.IndexDocument(Document{ID: "a", Title: "Guide", Path: "a", Content: "kubernetes"}); err != nil {
+		t.Fatal(err)
+	}
+	h := NewSearchHandler(se)
+
+	for target, want := range map[string]int{
+		"/api/search?q=kubernetez":            0,
+		"/api/search?q=kubernetez&fuzzy=true": 1,
+	} {
+		rec := serve(h.SearchDocuments, "GET", target, nil, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d", target, rec.Code)
+		}
+		var resp SearchResults
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.Total != want {
+			t.Errorf("%s: total = %d, want %d", target, resp.Total, want)
+		}
+	}
+}
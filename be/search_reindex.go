@@ -0,0 +1,23 @@
+// search_reindex.go
+package main
+
+import "net/http"
+
+// Reindex заново строит поисковый индекс из storage, например если файлы
+// документов изменили напрямую в git. Новый индекс собирается отдельно и
+// подменяется под блокировкой движка, поэтому параллельные запросы поиска
+// видят либо старый, либо новый полный индекс.
+func (h *SearchHandler) Reindex(w http.ResponseWriter, r *http.Request) {
+	if h.storage == nil {
+		http.Error(w, "reindex is not configured", http.StatusNotImplemented)
+		return
+	}
+
+	stats, err := h.searchEngine.Rebuild(h.storage)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, r, stats)
+}
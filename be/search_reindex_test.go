@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestSearchReindex(t *testing.T) {
+	gs := newTestStorage(t)
+	doc := mustCreate(t, gs, "", "Manual", "original text")
+	mustCreate(t, gs, doc.Path, "Child", "nested page")
+	engine := NewSearchEngine([]string{"english"})
+	if err := engine.LoadFromStorage(gs); err != nil {
+		t.Fatal(err)
+	}
+
+	h := NewSearchHandler(engine)
+	if rec := serve(h.Reindex, "POST", "/api/search/reindex", nil, nil); rec.Code != http.StatusNotImplemented {
+		t.Fatalf("without storage: status = %d", rec.Code)
+	}
+	h.storage = gs
+
+	// Правка файла в обход API
+	if err := os.WriteFile(filepath.Join(gs.docsDir, doc.Path, "Manual.md"), []byte("zebra"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Поиск во время переиндексации видит полный индекс, старый или новый
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			if _, total, _ := engine.Search("nested", 1, 10); total != 1 {
+				t.Errorf("search during reindex found %d documents", total)
+				return
+			}
+		}
+	}()
+
+	rec := serve(h.Reindex, "POST", "/api/search/reindex", nil, nil)
+	close(stop)
+	wg.Wait()
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	var stats RebuildStats
+	if err := json.NewDecoder(rec.Body).Decode(&stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Documents != 2 {
+		t.Fatalf("stats = %+v", stats)
+	}
+
+	if _, total, _ := engine.Search("zebra", 1, 10); total != 1 {
+		t.Fatalf("search after reindex found %d documents, want 1", total)
+	}
+	if _, total, _ := engine.Search("original", 1, 10); total != 0 {
+		t.Fatal("stale term still indexed after reindex")
+	}
+}
@@ -0,0 +1,136 @@
+// search_snippet.go
+package main
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	snippetLength  = 200 // длина фрагмента в символах
+	snippetContext = 50  // символов перед первым совпадением
+)
+
+// SearchResult - найденный документ с фрагментом текста вокруг первого совпадения.
+// В Snippet текст экранирован, совпадения обернуты в <mark>.
+type SearchResult struct {
+	Document
+	Snippet string `json:"snippet"`
+	Matches int    `json:"matches"` // число совпавших слов в содержимом
+	Draft   bool   `json:"draft,omitempty"`
+}
+
+// ShortSearchResult - найденный документ без содержимого, для списков (fields=short)
+type ShortSearchResult struct {
+	ShortDocument
+	Snippet string `json:"snippet"`
+	Matches int    `json:"matches"`
+	Draft   bool   `json:"draft,omitempty"`
+}
+
+// ShortSearchResults - ответ поиска с fields=short
+type ShortSearchResults struct {
+	Results []ShortSearchResult `json:"results"`
+	SearchMetadata
+}
+
+func searchResultToShort(result SearchResult) ShortSearchResult {
+	return ShortSearchResult{
+		ShortDocument: *documentToShort(&result.Document),
+		Snippet:       result.Snippet,
+		Matches:       result.Matches,
+		Draft:         result.Draft,
+	}
+}
+
+var snippetTokenPattern = regexp.MustCompile(`\S+`)
+
+// highlight находит в содержимом слова, основы которых совпали с запросом,
+// и строит по ним фрагмент. Без совпадений фрагмент - начало содержимого.
+func (se *SearchEngine) highlight(content string, matched map[string]bool, languages map[string]bool) (string, int) {
+	var spans [][2]int
+	for _, loc := range snippetTokenPattern.FindAllStringIndex(content, -1) {
+		start, end := loc[0], loc[1]
+		token := content[start:end]
+		trimmed := strings.TrimLeft(token, ".,!?\"'()[]{}")
+		start += len(token) - len(trimmed)
+		trimmed = strings.TrimRight(trimmed, ".,!?\"'()[]{}")
+		end = start + len(trimmed)
+		if trimmed == "" {
+			continue
+		}
+
+		word := strings.ToLower(trimmed)
+		for lang := range languages {
+			if stemmed, err := se.stemmer(word, lang, false); err == nil && matched[stemmed] {
+				spans = append(spans, [2]int{start, end})
+				break
+			}
+		}
+	}
+	return buildSnippet(content, spans), len(spans)
+}
+
+// buildSnippet вырезает окно около snippetLength символов вокруг первого совпадения,
+// по возможности по границам слов, и подсвечивает попавшие в него совпадения
+func buildSnippet(content string, spans [][2]int) string {
+	start := 0
+	if len(spans) > 0 {
+		start = moveRunes(content, spans[0][0], -snippetContext)
+		if start > 0 {
+			if i := strings.IndexAny(content[start:spans[0][0]], " \t\n"); i >= 0 {
+				start += i + 1
+			}
+		}
+	}
+	end := moveRunes(content, start, snippetLength)
+	if end < len(content) {
+		minEnd := start
+		if len(spans) > 0 {
+			minEnd = spans[0][1]
+		}
+		if i := strings.LastIndexAny(content[minEnd:end], " \t\n"); i > 0 {
+			end = minEnd + i
+		}
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	pos := start
+	for _, span := range spans {
+		if span[0] < start {
+			continue
+		}
+		if span[1] > end {
+			break
+		}
+		b.WriteString(html.EscapeString(content[pos:span[0]]))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(content[span[0]:span[1]]))
+		b.WriteString("</mark>")
+		pos = span[1]
+	}
+	b.WriteString(html.EscapeString(content[pos:end]))
+	if end < len(content) {
+		b.WriteString("…")
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// moveRunes сдвигает байтовую позицию pos на n символов вперед или назад
+// в пределах строки
+func moveRunes(s string, pos, n int) int {
+	for ; n > 0 && pos < len(s); n-- {
+		_, size := utf8.DecodeRuneInString(s[pos:])
+		pos += size
+	}
+	for ; n < 0 && pos > 0; n++ {
+		_, size := utf8.DecodeLastRuneInString(s[:pos])
+		pos -= size
+	}
+	return pos
+}
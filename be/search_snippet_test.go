@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSearchSnippet(t *testing.T) {
+	se := NewSearchEngine([]string{"english"})
+	long := strings.Repeat("intro words here ", 20) + "Tuning the Kafka consumer is easy. " +
+		strings.Repeat("more text follows ", 20) + "kafka again"
+	docs := []Document{
+		{ID: "long", Title: "Guide", Path: "long", Content: long},
+		{ID: "short", Title: "Kafka", Path: "short", Content: "Short <b>note</b> without the term"},
+	}
+	for _, doc := range docs {
+		if err := se.IndexDocument(doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var results []SearchResult
+	if _, err := se.SearchEach("kafka consumers", SearchOptions{Any: true}, 1, 10, func(r SearchResult) error {
+		results = append(results, r)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	byPath := map[string]SearchResult{}
+	for _, r := range results {
+		byPath[r.Path] = r
+	}
+
+	got := byPath["long"]
+	if got.Matches != 3 {
+		t.Errorf("matches = %d, want 3", got.Matches)
+	}
+	if !strings.Contains(got.Snippet, "Tuning the <mark>Kafka</mark> <mark>consumer</mark> is easy.") {
+		t.Errorf("snippet = %q, want highlighted terms", got.Snippet)
+	}
+	if !strings.HasPrefix(got.Snippet, "…intro words here intro") {
+		t.Errorf("snippet = %q, want to start at a word boundary after an ellipsis", got.Snippet)
+	}
+	if !strings.HasSuffix(got.Snippet, "…") || strings.Contains(got.Snippet, "again") {
+		t.Errorf("snippet = %q, want it cut before the end", got.Snippet)
+	}
+	if n := utf8.RuneCountInString(got.Snippet); n > snippetLength+40 {
+		t.Errorf("snippet has %d characters", n)
+	}
+
+	// Совпадение только в заголовке: фрагмент - начало текста, экранированное
+	got = byPath["short"]
+	if got.Matches != 0 || got.Snippet != "Short &lt;b&gt;note&lt;/b&gt; without the term" {
+		t.Errorf("title-only match: snippet = %q, matches = %d", got.Snippet, got.Matches)
+	}
+}
+
+func TestBuildSnippetMultibyte(t *testing.T) {
+	content := strings.Repeat("пример ", 40) + "искомое " + strings.Repeat("слово ", 60)
+	start := strings.Index(content, "искомое")
+	snippet := buildSnippet(content, [][2]int{{start, start + len("искомое")}})
+
+	if !utf8.ValidString(snippet) {
+		t.Fatalf("snippet is not valid UTF-8: %q", snippet)
+	}
+	if !strings.Contains(snippet, "<mark>искомое</mark>") {
+		t.Errorf("snippet = %q", snippet)
+	}
+	if !strings.HasPrefix(snippet, "…пример") || !strings.HasSuffix(snippet, "слово…") {
+		t.Errorf("snippet = %q, want whole words at both ends", snippet)
+	}
+}
+
+func TestSearchHandlerSnippetsAndContent(t *testing.T) {
+	se := NewSearchEngine([]string{"english"})
+	if err := se.IndexDocument(Document{ID: "a", Title: "Guide", Path: "a", Content: "kafka consumer lag"}); err != nil {
+		t.Fatal(err)
+	}
+	h := NewSearchHandler(se)
+
+	search := func(target string) SearchResults {
+		t.Helper()
+		rec := serve(h.SearchDocuments, "GET", target, nil, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d", target, rec.Code)
+		}
+		var resp SearchResults
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	resp := search("/api/search?q=lag")
+	if len(resp.Results) != 1 || resp.Results[0].Content != "kafka consumer lag" ||
+		resp.Results[0].Snippet != "kafka consumer <mark>lag</mark>" || resp.Results[0].Matches != 1 {
+		t.Fatalf("results = %+v", resp.Results)
+	}
+
+	if resp := search("/api/search?q=lag&content=false"); resp.Results[0].Content != "" || resp.Results[0].Snippet == "" {
+		t.Errorf("content=false: %+v", resp.Results[0])
+	}
+
+	h.omitContent = true
+	if resp := search("/api/search?q=lag"); resp.Results[0].Content != "" {
+		t.Errorf("omitContent: content = %q", resp.Results[0].Content)
+	}
+	if resp := search("/api/search?q=lag&content=true"); resp.Results[0].Content == "" {
+		t.Error("content=true did not override omitContent")
+	}
+
+	rec := serve(h.SearchDocuments, "GET", "/api/search?q=lag&format=ndjson", nil, nil)
+	line, err := bufio.NewReader(rec.Body).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	var first SearchResult
+	if err := json.Unmarshal([]byte(line), &first); err != nil {
+		t.Fatal(err)
+	}
+	if first.Snippet == "" || first.Content != "" {
+		t.Errorf("ndjson result = %+v", first)
+	}
+}
+
+func TestSearchHandlerShortFields(t *testing.T) {
+	se := NewSearchEngine([]string{"english"})
+	if err := se.IndexDocument(Document{ID: "a", Title: "Guide", Path: "a", Content: "kafka consumer lag"}); err != nil {
+		t.Fatal(err)
+	}
+	h := NewSearchHandler(se)
+
+	rec := serve(h.SearchDocuments, "GET", "/api/search?q=lag&fields=short", nil, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	if strings.Contains(rec.Body.String(), `"content"`) {
+		t.Errorf("short response contains content: %s", rec.Body.String())
+	}
+	var resp ShortSearchResults
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Total != 1 || len(resp.Results) != 1 {
+		t.Fatalf("response = %+v", resp)
+	}
+	if got := resp.Results[0]; got.ID != "a" || got.Title != "Guide" || got.Path != "a" ||
+		got.Snippet != "kafka consumer <mark>lag</mark>" {
+		t.Errorf("result = %+v", got)
+	}
+
+	rec = serve(h.SearchDocuments, "GET", "/api/search?q=lag", nil, nil)
+	if !strings.Contains(rec.Body.String(), `"content":"kafka consumer lag"`) {
+		t.Errorf("default response = %s", rec.Body.String())
+	}
+
+	rec = serve(h.SearchDocuments, "GET", "/api/search?q=lag&fields=short&format=ndjson", nil, nil)
+	line, err := bufio.NewReader(rec.Body).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(line, `"content"`) || !strings.Contains(line, `"snippet"`) {
+		t.Errorf("ndjson short line = %s", line)
+	}
+
+	if rec := serve(h.SearchDocuments, "GET", "/api/search?q=lag&fields=bogus", nil, nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("fields=bogus: status = %d", rec.Code)
+	}
+}
@@ -0,0 +1,79 @@
+// search_suggest.go
+package main
+
+import "strings"
+
+// addSurface запоминает слово, из которого впервые получена основа:
+// подсказки показывают слово, а не основу. Вызывается под se.mu
+func (se *SearchEngine) addSurface(stemmed, word string) {
+	if se.surfaces == nil {
+		se.surfaces = make(map[string]string)
+	}
+	se.surfaces[stemmed] = word
+	se.termBytes += len(word)
+}
+
+// removeSurface забывает слово удаленной из индекса основы, вызывается под se.mu
+func (se *SearchEngine) removeSurface(stemmed string) {
+	se.termBytes -= len(se.surfaces[stemmed])
+	delete(se.surfaces, stemmed)
+}
+
+// Suggest предлагает исправленный запрос: каждое слово без точных совпадений
+// заменяется ближайшим по расстоянию редактирования словом индекса.
+// Возвращает пустую строку, если исправить нечего
+func (se *SearchEngine) Suggest(query string, languages []string) string {
+	se.mu.RLock()
+	defer se.mu.RUnlock()
+
+	queryLanguages := se.queryLanguages(languages)
+	var words []string
+	changed := false
+	for _, word := range parseSearchQuery(query).words {
+		if strings.HasSuffix(word, "*") || se.isStopword(word) {
+			words = append(words, word)
+			continue
+		}
+
+		var stems []string
+		exact := false
+		for lang := range queryLanguages {
+			stemmed, err := se.stemmer(word, lang, false)
+			if err != nil || stemmed == "" {
+				continue
+			}
+			stems = append(stems, stemmed)
+			if _, ok := se.index[stemmed]; ok {
+				exact = true
+			}
+		}
+		if exact {
+			words = append(words, word)
+			continue
+		}
+
+		// Ближайшая основа, при равенстве - встречающаяся в большем числе документов
+		var best *fuzzyCandidate
+		for _, candidate := range se.fuzzyTerms(stems) {
+			if best == nil || candidate.distance < best.distance ||
+				candidate.distance == best.distance && len(se.index[candidate.term]) > len(se.index[best.term]) {
+				best = &candidate
+			}
+		}
+		if best == nil {
+			words = append(words, word)
+			continue
+		}
+		suggestion := se.surfaces[best.term]
+		if suggestion == "" {
+			suggestion = best.term
+		}
+		words = append(words, suggestion)
+		changed = true
+	}
+
+	if !changed {
+		return ""
+	}
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"net/url"
+	"testing"
+)
+
+func TestSearchSuggest(t *testing.T) {
+	se := NewSearchEngine([]string{"english"})
+	docs := []Document{
+		{ID: "kube", Title: "Cluster", Path: "kube", Content: "kubernetes deployment guide"},
+		{ID: "kafka", Title: "Streams", Path: "kafka", Content: "kafka consumer groups"},
+		{ID: "kafka2", Title: "Lag", Path: "kafka2", Content: "kafka lag"},
+		{ID: "kara", Title: "Karaoke", Path: "kara", Content: "kara"},
+	}
+	for _, doc := range docs {
+		if err := se.IndexDocument(doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		query, want string
+	}{
+		{"kubernetez", "kubernetes"},
+		{"kafak consumer", "kafka consumer"},
+		{"kafka consumr", "kafka consumer"},
+		{"kafka", ""},    // точное совпадение
+		{"zzzzzzzz", ""}, // нет похожих слов
+		{"xy", ""},       // слишком короткое слово
+		{"kaf* kubernetez", "kaf* kubernetes"},
+		{"kafa", "kafka"}, // kafka и kara на одном расстоянии, kafka чаще
+	}
+	for _, tt := range tests {
+		if got := se.Suggest(tt.query, nil); got != tt.want {
+			t.Errorf("Suggest(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+
+	// Подсказка забывается вместе с последним документом основы
+	if err := se.DeleteDocument("kube"); err != nil {
+		t.Fatal(err)
+	}
+	if got := se.Suggest("kubernetez", nil); got != "" {
+		t.Errorf("suggestion for a deleted term: %q", got)
+	}
+	if _, ok := se.surfaces["kubernet"]; ok {
+		t.Error("surface form left after delete")
+	}
+
+	h := NewSearchHandler(se)
+	for query, want := range map[string]string{"kafak": "kafka", "kafka": "", "kafka kubernetez": ""} {
+		rec := serve(h.SearchDocuments, "GET", "/api/search?q="+url.QueryEscape(query), nil, nil)
+		var resp SearchResults
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.Suggestion != want {
+			t.Errorf("%q: suggestion = %q, want %q", query, resp.Suggestion, want)
+		}
+	}
+}
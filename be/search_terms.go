@@ -0,0 +1,69 @@
+// search_terms.go
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultTermsLimit = 100
+	maxTermsLimit     = 1000
+)
+
+// TermStat - частота основы слова в индексе
+type TermStat struct {
+	Term        string `json:"term"`
+	Documents   int    `json:"documents"`   // число документов с основой
+	Occurrences int    `json:"occurrences"` // общее число вхождений
+}
+
+// Terms возвращает limit самых частых основ индекса, начинающихся с prefix,
+// по убыванию числа вхождений, затем числа документов и по алфавиту
+func (se *SearchEngine) Terms(prefix string, limit int) []TermStat {
+	se.mu.RLock()
+	defer se.mu.RUnlock()
+
+	terms := []TermStat{}
+	for _, term := range se.termsWithPrefix(prefix) {
+		docs := se.index[term]
+		stat := TermStat{Term: term, Documents: len(docs)}
+		for _, count := range docs {
+			stat.Occurrences += count
+		}
+		terms = append(terms, stat)
+	}
+
+	sort.Slice(terms, func(i, j int) bool {
+		a, b := terms[i], terms[j]
+		if a.Occurrences != b.Occurrences {
+			return a.Occurrences > b.Occurrences
+		}
+		if a.Documents != b.Documents {
+			return a.Documents > b.Documents
+		}
+		return a.Term < b.Term
+	})
+	if len(terms) > limit {
+		terms = terms[:limit]
+	}
+	return terms
+}
+
+// GetTerms отдает самые частые основы слов индекса для анализа содержимого
+func (h *SearchHandler) GetTerms(w http.ResponseWriter, r *http.Request) {
+	limit := defaultTermsLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxTermsLimit {
+			http.Error(w, "limit must be between 1 and "+strconv.Itoa(maxTermsLimit), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	prefix := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("prefix")))
+
+	writeJSON(w, r, h.searchEngine.Terms(prefix, limit))
+}
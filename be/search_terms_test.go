@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestSearchTerms(t *testing.T) {
+	se := NewSearchEngine([]string{"english"})
+	docs := []Document{
+		{ID: "a", Title: "Kafka", Path: "a", Content: "kafka kafka broker"},
+		{ID: "b", Title: "Brokers", Path: "b", Content: "kafka broker"},
+		{ID: "c", Title: "Zookeeper", Path: "c", Content: "kafka"},
+	}
+	for _, doc := range docs {
+		if err := se.IndexDocument(doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h := NewSearchHandler(se)
+
+	get := func(target string) []TermStat {
+		t.Helper()
+		rec := serve(h.GetTerms, "GET", target, nil, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d, body %s", target, rec.Code, rec.Body)
+		}
+		var terms []TermStat
+		if err := json.NewDecoder(rec.Body).Decode(&terms); err != nil {
+			t.Fatal(err)
+		}
+		return terms
+	}
+
+	want := []TermStat{
+		{Term: "kafka", Documents: 3, Occurrences: 5},
+		{Term: "broker", Documents: 2, Occurrences: 3},
+	}
+	if got := get("/api/search/terms?limit=2"); !reflect.DeepEqual(got, want) {
+		t.Errorf("top terms = %+v, want %+v", got, want)
+	}
+
+	if got := get("/api/search/terms"); len(got) != 3 || got[2].Term != "zookeep" {
+		t.Errorf("all terms = %+v", got)
+	}
+
+	want = []TermStat{{Term: "broker", Documents: 2, Occurrences: 3}}
+	if got := get("/api/search/terms?prefix=BR"); !reflect.DeepEqual(got, want) {
+		t.Errorf("prefix terms = %+v, want %+v", got, want)
+	}
+
+	if got := get("/api/search/terms?prefix=xyz"); len(got) != 0 {
+		t.Errorf("unknown prefix = %+v, want empty", got)
+	}
+
+	for _, limit := range []string{"0", "-1", "abc", "1001"} {
+		rec := serve(h.GetTerms, "GET", "/api/search/terms?limit="+limit, nil, nil)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("limit=%s: status = %d, want %d", limit, rec.Code, http.StatusBadRequest)
+		}
+	}
+}
@@ -0,0 +1,794 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"path"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSearchLowMemoryMatchesFullMode(t *testing.T) {
+	gs := newTestStorage(t)
+	a := mustCreate(t, gs, "", "Kafka consumers", "Consumer lag grows when consumers are slow")
+	mustCreate(t, gs, a.Path, "Producers", "Kafka producers batch records")
+	mustCreate(t, gs, "", "Cooking", "Slow cooking recipes")
+
+	full := NewSearchEngine([]string{"english"})
+	low := NewSearchEngine([]string{"english"})
+	low.EnableLowMemory(gs)
+	for _, se := range []*SearchEngine{full, low} {
+		if err := se.LoadFromStorage(gs); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, doc := range low.documents {
+		if doc.Content != "" {
+			t.Fatalf("low memory mode keeps content of %q", doc.Path)
+		}
+	}
+
+	for _, query := range []string{"kafka", "slow", "consumer lag", "missing"} {
+		fullDocs, fullTotal, err := full.Search(query, 1, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lowDocs, lowTotal, err := low.Search(query, 1, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fullTotal != lowTotal || len(fullDocs) != len(lowDocs) {
+			t.Fatalf("%q: totals differ: %d/%d", query, fullTotal, lowTotal)
+		}
+		for i := range fullDocs {
+			if fullDocs[i].Path != lowDocs[i].Path || fullDocs[i].Title != lowDocs[i].Title ||
+				fullDocs[i].Content != lowDocs[i].Content {
+				t.Fatalf("%q: result %d differs: %+v vs %+v", query, i, fullDocs[i], lowDocs[i])
+			}
+		}
+	}
+
+	// Удаление в обоих режимах оставляет одинаковый индекс
+	for _, se := range []*SearchEngine{full, low} {
+		if err := se.DeleteDocument(a.Path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !reflect.DeepEqual(full.index, low.index) {
+		t.Fatalf("indexes differ after delete")
+	}
+	if len(low.docTerms) != len(low.documents) {
+		t.Fatalf("docTerms = %d, documents = %d", len(low.docTerms), len(low.documents))
+	}
+}
+
+func TestIndexStatsTrackIndexing(t *testing.T) {
+	se := NewSearchEngine([]string{"english"})
+	if stats := se.Stats(); stats != (IndexStats{}) {
+		t.Fatalf("empty index stats = %+v", stats)
+	}
+
+	a := Document{ID: "a", Title: "Alpha", Path: "a", Content: "apples and pears"}
+	b := Document{ID: "b", Title: "Beta", Path: "b", Content: "pears only"}
+	if err := se.IndexDocument(a); err != nil {
+		t.Fatal(err)
+	}
+	afterA := se.Stats()
+	if afterA.Documents != 1 || afterA.Terms == 0 || afterA.Postings != afterA.Terms {
+		t.Errorf("stats after one document = %+v", afterA)
+	}
+	if afterA.ContentBytes != len(a.Content) {
+		t.Errorf("content bytes = %d, want %d", afterA.ContentBytes, len(a.Content))
+	}
+
+	if err := se.IndexDocument(b); err != nil {
+		t.Fatal(err)
+	}
+	afterB := se.Stats()
+	if afterB.Postings <= afterA.Postings || afterB.ApproxBytes <= afterA.ApproxBytes {
+		t.Errorf("stats did not grow: %+v -> %+v", afterA, afterB)
+	}
+	// "pears" уже есть в индексе, поэтому основ меньше, чем пар основа-документ
+	if afterB.Terms >= afterB.Postings {
+		t.Errorf("terms = %d, postings = %d: shared term counted twice", afterB.Terms, afterB.Postings)
+	}
+
+	if err := se.DeleteDocument(b.Path); err != nil {
+		t.Fatal(err)
+	}
+	if stats := se.Stats(); stats != afterA {
+		t.Errorf("stats after removing document = %+v, want %+v", stats, afterA)
+	}
+	if err := se.DeleteDocument(a.Path); err != nil {
+		t.Fatal(err)
+	}
+	if stats := se.Stats(); stats != (IndexStats{}) {
+		t.Errorf("stats after removing everything = %+v", stats)
+	}
+}
+
+func TestMemoryCapSwitchesToLowMemory(t *testing.T) {
+	gs := newTestStorage(t)
+	doc := mustCreate(t, gs, "", "Big", strings.Repeat("lorem ipsum ", 200))
+
+	se := NewSearchEngine([]string{"english"})
+	se.SetMemoryCap(1024, gs)
+	if err := se.LoadFromStorage(gs); err != nil {
+		t.Fatal(err)
+	}
+
+	stats := se.Stats()
+	if !stats.LowMemory || stats.ContentBytes != 0 {
+		t.Fatalf("stats = %+v, want low-memory mode without content", stats)
+	}
+	docs, total, err := se.Search("lorem", 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 1 || docs[0].Content != doc.Content {
+		t.Errorf("search after switch returned %d results, content loaded: %v", total, total == 1 && docs[0].Content == doc.Content)
+	}
+
+	if err := se.DeleteDocument(doc.Path); err != nil {
+		t.Fatal(err)
+	}
+	if stats := se.Stats(); stats.Terms != 0 || stats.Postings != 0 {
+		t.Errorf("stats after delete = %+v", stats)
+	}
+}
+
+func TestMemoryCapWarnOnly(t *testing.T) {
+	se := NewSearchEngine([]string{"english"})
+	se.SetMemoryCap(1, nil)
+	if err := se.IndexDocument(Document{ID: "a", Title: "A", Path: "a", Content: "some text"}); err != nil {
+		t.Fatal(err)
+	}
+	if stats := se.Stats(); stats.LowMemory || stats.ContentBytes == 0 {
+		t.Errorf("stats = %+v, want content kept when only warning", stats)
+	}
+}
+
+func TestSearchTFIDFPrefersConciseMatch(t *testing.T) {
+	se := NewSearchEngine([]string{"english"})
+	long := Document{ID: "long", Title: "Handbook", Path: "long",
+		Content: "kafka kafka kafka " + strings.Repeat("filler words about other topics ", 100)}
+	short := Document{ID: "short", Title: "Note", Path: "short", Content: "kafka tuning"}
+	for _, doc := range []Document{long, short} {
+		if err := se.IndexDocument(doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	docs, total, err := se.Search("kafka", 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 2 || docs[0].Path != short.Path {
+		t.Errorf("results = %v, want the short note first", docPaths(docs))
+	}
+}
+
+func TestSearchTFIDFWeighsRareTerms(t *testing.T) {
+	se := NewSearchEngine([]string{"english"})
+	docs := []Document{
+		{ID: "a", Title: "A", Path: "a", Content: "common rare"},
+		{ID: "b", Title: "B", Path: "b", Content: "common common"},
+		{ID: "c", Title: "C", Path: "c", Content: "common other"},
+	}
+	for _, doc := range docs {
+		if err := se.IndexDocument(doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// "b" чаще упоминает common, но rare встречается только в "a"
+	results, _, err := se.Search("common rare", 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) == 0 || results[0].Path != "a" {
+		t.Errorf("results = %v, want a first", docPaths(results))
+	}
+}
+
+func TestSearchTFIDFTieBreakByPath(t *testing.T) {
+	se := NewSearchEngine([]string{"english"})
+	for _, p := range []string{"c", "a", "b"} {
+		if err := se.IndexDocument(Document{ID: p, Title: "Same", Path: p, Content: "same text"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	results, _, err := se.Search("text", 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := docPaths(results); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("first page = %v, want [a b]", got)
+	}
+	results, _, err = se.Search("text", 2, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := docPaths(results); !reflect.DeepEqual(got, []string{"c"}) {
+		t.Errorf("second page = %v, want [c]", got)
+	}
+}
+
+func docPaths(docs []Document) []string {
+	paths := make([]string, 0, len(docs))
+	for _, doc := range docs {
+		paths = append(paths, doc.Path)
+	}
+	return paths
+}
+
+func TestSearchExactPhrase(t *testing.T) {
+	se := NewSearchEngine([]string{"english"})
+	docs := []Document{
+		{ID: "adjacent", Title: "Adjacent", Path: "adjacent", Content: "Read the release notes before upgrading"},
+		{ID: "apart", Title: "Apart", Path: "apart", Content: "The release has notes attached"},
+		{ID: "reversed", Title: "Reversed", Path: "reversed", Content: "notes release"},
+		{ID: "punct", Title: "Punct", Path: "punct", Content: "Release, notes — and more"},
+		{ID: "dash", Title: "Dash", Path: "dash", Content: "release — notes"},
+	}
+	for _, doc := range docs {
+		if err := se.IndexDocument(doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{`"release notes"`, []string{"adjacent", "dash", "punct"}},
+		{`release notes`, []string{"adjacent", "apart", "dash", "punct", "reversed"}},
+		{`"releases note"`, []string{"adjacent", "dash", "punct"}}, // фраза сравнивается по основам
+		{`"notes release"`, []string{"reversed"}},
+		{`"release notes" upgrading`, []string{"adjacent"}},
+		{`attached "release notes"`, []string{}},
+		{`"release notes" "before upgrading"`, []string{"adjacent"}},
+		{`"release notes`, []string{"adjacent", "apart", "dash", "punct", "reversed"}}, // незакрытая кавычка
+	}
+	for _, tt := range tests {
+		results, total, err := se.Search(tt.query, 1, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := docPaths(results)
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, tt.want) || total != len(tt.want) {
+			t.Errorf("Search(%s) = %v (total %d), want %v", tt.query, got, total, tt.want)
+		}
+	}
+
+}
+
+func TestSearchPhrasePositionsRemovedWithDocument(t *testing.T) {
+	se := NewSearchEngine([]string{"english"})
+	doc := Document{ID: "doc", Title: "Doc", Path: "doc", Content: "release notes"}
+	if err := se.IndexDocument(doc); err != nil {
+		t.Fatal(err)
+	}
+	if err := se.DeleteDocument("doc"); err != nil {
+		t.Fatal(err)
+	}
+	doc.Content = "notes about the release"
+	if err := se.IndexDocument(doc); err != nil {
+		t.Fatal(err)
+	}
+
+	results, _, err := se.Search(`"release notes"`, 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 0 {
+		t.Errorf("stale positions matched: %v", docPaths(results))
+	}
+
+	se.DeleteSubtree("doc")
+	if len(se.positions) != 0 || se.positionsLen != 0 {
+		t.Errorf("positions left after delete: %d terms, %d positions", len(se.positions), se.positionsLen)
+	}
+}
+
+func TestSearchPrefix(t *testing.T) {
+	se := NewSearchEngine([]string{"english"})
+	docs := []Document{
+		{ID: "configuration", Title: "Setup", Path: "configuration", Content: "server configuration"},
+		{ID: "configure", Title: "Howto", Path: "configure", Content: "how to configure the proxy, configure twice"},
+		{ID: "confluence", Title: "Wiki", Path: "confluence", Content: "confluence export"},
+		{ID: "other", Title: "Other", Path: "other", Content: "unrelated text"},
+	}
+	for _, doc := range docs {
+		if err := se.IndexDocument(doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"config*", []string{"configuration", "configure"}},
+		{"conf*", []string{"configuration", "configure", "confluence"}},
+		{"config", []string{}}, // без * префикс не раскрывается
+		{"proxy", []string{"configure"}},
+		{"conf* export", []string{"confluence"}},
+		{"xyz*", []string{}},
+		{"*", []string{}},
+	}
+	for _, tt := range tests {
+		results, total, err := se.Search(tt.query, 1, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := docPaths(results)
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, tt.want) || total != len(tt.want) {
+			t.Errorf("Search(%q) = %v (total %d), want %v", tt.query, got, total, tt.want)
+		}
+	}
+
+	// Вхождения всех подходящих основ суммируются
+	se = NewSearchEngine([]string{"english"})
+	for _, doc := range []Document{
+		{ID: "both", Title: "Confluence", Path: "both", Content: "configure"},
+		{ID: "one", Title: "Confluence", Path: "one", Content: "export"},
+	} {
+		if err := se.IndexDocument(doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	results, _, err := se.Search("conf*", 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := docPaths(results); !reflect.DeepEqual(got, []string{"both", "one"}) {
+		t.Errorf("results = %v, want [both one]", got)
+	}
+}
+
+func TestSearchPrefixTermsFollowIndex(t *testing.T) {
+	se := NewSearchEngine([]string{"english"})
+	if err := se.IndexDocument(Document{ID: "a", Title: "Alpha", Path: "a", Content: "kubernetes"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := se.DeleteDocument("a"); err != nil {
+		t.Fatal(err)
+	}
+	if len(se.sortedTerms) != 0 {
+		t.Fatalf("sortedTerms = %v after delete", se.sortedTerms)
+	}
+	if err := se.IndexDocument(Document{ID: "b", Title: "Beta", Path: "b", Content: "kube cluster"}); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.IsSorted(se.sortedTerms) || len(se.sortedTerms) != len(se.index) {
+		t.Fatalf("sortedTerms = %v out of sync with index", se.sortedTerms)
+	}
+
+	results, _, err := se.Search("kub*", 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := docPaths(results); !reflect.DeepEqual(got, []string{"b"}) {
+		t.Errorf("results = %v, want [b]", got)
+	}
+}
+
+func TestSearchTitleBoost(t *testing.T) {
+	docs := []Document{
+		{ID: "title", Title: "Kafka setup", Path: "title", Content: "install the broker and point clients at it"},
+		{ID: "content", Title: "Setup notes", Path: "content", Content: "install kafka and point clients at it"},
+	}
+	index := func(se *SearchEngine) {
+		for _, doc := range docs {
+			if err := se.IndexDocument(doc); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	boosted := NewSearchEngine([]string{"english"})
+	index(boosted)
+	results, _, err := boosted.Search("kafka clients", 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := docPaths(results); !reflect.DeepEqual(got, []string{"title", "content"}) {
+		t.Errorf("boosted = %v, want title match first", got)
+	}
+
+	flat := NewSearchEngine([]string{"english"}, WithTitleBoost(1))
+	index(flat)
+	results, _, err = flat.Search("kafka clients", 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := docPaths(results); !reflect.DeepEqual(got, []string{"content", "title"}) {
+		t.Errorf("without boost = %v, want the shorter document first", got)
+	}
+}
+
+func TestSearchExactTitleFirst(t *testing.T) {
+	se := NewSearchEngine([]string{"english"})
+	docs := []Document{
+		{ID: "dense", Title: "Changelog", Path: "dense", Content: "release notes release notes release"},
+		{ID: "exact", Title: "Release Notes", Path: "exact", Content: strings.Repeat("unrelated filler text ", 50) + "release"},
+		{ID: "partial", Title: "Release notes archive", Path: "partial", Content: "release notes"},
+	}
+	for _, doc := range docs {
+		if err := se.IndexDocument(doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	results, _, err := se.Search("release note", 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 || results[0].Path != "exact" {
+		t.Errorf("results = %v, want exact title first", docPaths(results))
+	}
+
+	se.DeleteSubtree("exact")
+	se.DeleteSubtree("dense")
+	se.DeleteSubtree("partial")
+	if len(se.titleIndex) != 0 {
+		t.Errorf("titleIndex = %v after delete", se.titleIndex)
+	}
+}
+
+func TestSearchScopedToSubtree(t *testing.T) {
+	se := NewSearchEngine([]string{"english"})
+	for _, p := range []string{"engineering", "engineering/backend", "engineering/backend/db", "engineering/backend-old", "sales"} {
+		if err := se.IndexDocument(Document{ID: path.Base(p), Title: "Doc", Path: p, Content: "deploy checklist"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{"", []string{"engineering", "engineering/backend", "engineering/backend-old", "engineering/backend/db", "sales"}},
+		{"engineering/backend", []string{"engineering/backend", "engineering/backend/db"}},
+		{"/engineering/backend/", []string{"engineering/backend", "engineering/backend/db"}},
+		{"sales", []string{"sales"}},
+		{"missing", []string{}},
+	}
+	for _, tt := range tests {
+		results, total, err := se.SearchWithOptions("deploy", SearchOptions{Path: tt.path}, 1, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := docPaths(results)
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, tt.want) || total != len(tt.want) {
+			t.Errorf("path %q: %v (total %d), want %v", tt.path, got, total, tt.want)
+		}
+	}
+
+	// Общее число и страницы считаются по отфильтрованным документам
+	results, total, err := se.SearchWithOptions("deploy", SearchOptions{Path: "engineering"}, 2, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 4 || len(results) != 1 {
+		t.Errorf("page 2: %v, total %d, want 1 result of 4", docPaths(results), total)
+	}
+
+	h := NewSearchHandler(se)
+	rec := serve(h.SearchDocuments, "GET", "/api/search?q=deploy&path=engineering/backend", nil, nil)
+	var resp SearchResults
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Total != 2 || resp.TotalPages != 1 {
+		t.Errorf("handler: total %d, pages %d", resp.Total, resp.TotalPages)
+	}
+}
+
+func TestSearchRequiresAllWords(t *testing.T) {
+	se := NewSearchEngine([]string{"english"})
+	docs := []Document{
+		{ID: "all", Title: "Lag", Path: "all", Content: "kafka consumer lag alerts"},
+		{ID: "partial", Title: "Intro", Path: "partial", Content: "kafka kafka kafka consumer"},
+		{ID: "kafka", Title: "Kafka", Path: "kafka", Content: "kafka"},
+		{ID: "configure", Title: "Howto", Path: "configure", Content: "configure the proxy"},
+	}
+	for _, doc := range docs {
+		if err := se.IndexDocument(doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		query string
+		any   bool
+		want  []string
+	}{
+		{"kafka consumer lag", false, []string{"all"}},
+		{"kafka consumer lag", true, []string{"all", "kafka", "partial"}},
+		{"kafka consumer", false, []string{"all", "partial"}},
+		{"kafka missing", false, []string{}},
+		{"kafka missing", true, []string{"all", "kafka", "partial"}},
+		// Оба слова дают основу "configur": совпадение основ не отменяет требование
+		{"configure configuration", false, []string{"configure"}},
+		{"configure configuration kafka", false, []string{}},
+	}
+	for _, tt := range tests {
+		results, total, err := se.SearchWithOptions(tt.query, SearchOptions{Any: tt.any}, 1, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := docPaths(results)
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, tt.want) || total != len(tt.want) {
+			t.Errorf("Search(%q, any=%v) = %v (total %d), want %v", tt.query, tt.any, got, total, tt.want)
+		}
+	}
+
+	h := NewSearchHandler(se)
+	for target, want := range map[string]int{
+		"/api/search?q=kafka+consumer+lag":          1,
+		"/api/search?q=kafka+consumer+lag&any=true": 3,
+	} {
+		rec := serve(h.SearchDocuments, "GET", target, nil, nil)
+		var resp SearchResults
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.Total != want {
+			t.Errorf("%s: total %d, want %d", target, resp.Total, want)
+		}
+	}
+}
+
+func TestParseSearchQueryOperators(t *testing.T) {
+	tests := []struct {
+		query    string
+		groups   [][]string
+		excluded []string
+	}{
+		{"docker -compose", [][]string{{"docker"}}, []string{"compose"}},
+		{"a b OR c -d", [][]string{{"a"}, {"b", "c"}}, []string{"d"}},
+		{"a OR b OR c", [][]string{{"a", "b", "c"}}, nil},
+		{"a or b", [][]string{{"a"}, {"or"}, {"b"}}, nil}, // оператор только в верхнем регистре
+		{"OR a OR", [][]string{{"a"}}, nil},
+		{"-a OR b", [][]string{{"b"}}, []string{"a"}},
+		{`a OR "b c"`, [][]string{{"a"}, {"b"}, {"c"}}, nil},
+		{`"b -c" OR d`, [][]string{{"b"}, {"-c"}, {"d"}}, nil},
+		{"e-mail - x", [][]string{{"e-mail"}, {"x"}}, nil},
+	}
+	for _, tt := range tests {
+		q := parseSearchQuery(tt.query)
+		if !reflect.DeepEqual(q.groups, tt.groups) || !reflect.DeepEqual(q.excluded, tt.excluded) {
+			t.Errorf("parseSearchQuery(%q) = %v, -%v; want %v, -%v", tt.query, q.groups, q.excluded, tt.groups, tt.excluded)
+		}
+	}
+}
+
+func TestSearchBooleanOperators(t *testing.T) {
+	se := NewSearchEngine([]string{"english"})
+	docs := []Document{
+		{ID: "docker", Title: "Docker", Path: "docker", Content: "docker images"},
+		{ID: "compose", Title: "Compose", Path: "compose", Content: "docker compose files"},
+		{ID: "only", Title: "Stack", Path: "only", Content: "composing stacks"},
+		{ID: "podman", Title: "Podman", Path: "podman", Content: "podman images"},
+	}
+	for _, doc := range docs {
+		if err := se.IndexDocument(doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		query string
+		any   bool
+		want  []string
+	}{
+		{"docker -compose", false, []string{"docker"}},
+		{"docker -compose", true, []string{"docker"}},
+		{"-compose", false, []string{}},
+		{"docker -compos*", false, []string{"docker"}},
+		{"docker OR podman", false, []string{"compose", "docker", "podman"}},
+		{"images docker OR podman", false, []string{"docker", "podman"}},
+		{"docker OR podman -images", false, []string{"compose"}},
+		// Исключение сравнивается по основам: "composing" тоже исключается
+		{"stacks OR docker -compose", true, []string{"docker"}},
+	}
+	for _, tt := range tests {
+		results, total, err := se.SearchWithOptions(tt.query, SearchOptions{Any: tt.any}, 1, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := docPaths(results)
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, tt.want) || total != len(tt.want) {
+			t.Errorf("Search(%q, any=%v) = %v (total %d), want %v", tt.query, tt.any, got, total, tt.want)
+		}
+	}
+
+	debug := se.DebugQuery("docker OR podman -compose", nil)
+	if len(debug.Terms) != 2 || debug.Terms[0].Or || !debug.Terms[1].Or {
+		t.Errorf("debug terms = %+v", debug.Terms)
+	}
+	if len(debug.Excluded) != 1 || debug.Excluded[0].Word != "compose" {
+		t.Errorf("debug excluded = %+v", debug.Excluded)
+	}
+}
+
+func TestSearchDeleteUsesIndexedTerms(t *testing.T) {
+	se := NewSearchEngine([]string{"english", "russian"})
+	doc := Document{ID: "doc", Title: "Doc", Path: "doc", Content: "kafka consumer lag"}
+	if err := se.IndexDocument(doc); err != nil {
+		t.Fatal(err)
+	}
+
+	// Содержимое в документе индекса изменилось в обход IndexDocument
+	fullPath := se.getBasePath("doc")
+	stored := se.documents[fullPath]
+	stored.Content = "something else entirely"
+	se.documents[fullPath] = stored
+
+	if err := se.DeleteDocument("doc"); err != nil {
+		t.Fatal(err)
+	}
+	if len(se.index) != 0 || len(se.titleIndex) != 0 || len(se.positions) != 0 || len(se.docTerms) != 0 {
+		t.Errorf("orphan postings: index %v, titles %v, positions %v, terms %v", se.index, se.titleIndex, se.positions, se.docTerms)
+	}
+	if stats := se.Stats(); stats.Postings != 0 || stats.Terms != 0 {
+		t.Errorf("stats after delete = %+v", stats)
+	}
+}
+
+func TestSearchReindexReplacesDocument(t *testing.T) {
+	se := NewSearchEngine([]string{"english"})
+	doc := Document{ID: "doc", Title: "Doc", Path: "doc", Content: "kafka kafka consumer"}
+	if err := se.IndexDocument(doc); err != nil {
+		t.Fatal(err)
+	}
+	doc.Content = "kafka producer"
+	if err := se.IndexDocument(doc); err != nil {
+		t.Fatal(err)
+	}
+
+	fullPath := se.getBasePath("doc")
+	if _, ok := se.index["consum"]; ok {
+		t.Errorf("stale term of the previous version: %v", se.index["consum"])
+	}
+	if got := se.index["kafka"][fullPath]; got != 1 {
+		t.Errorf("kafka count = %d, want 1", got)
+	}
+	if got := se.docLengths[fullPath]; got != 3 {
+		t.Errorf("document length = %d, want 3", got)
+	}
+	if _, total, _ := se.Search("consumer", 1, 10); total != 0 {
+		t.Errorf("old content still found")
+	}
+}
+
+func TestSearchSortByModified(t *testing.T) {
+	se := NewSearchEngine([]string{"english"})
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	docs := []Document{
+		{ID: "a", Title: "Kafka", Path: "a", Content: "kafka kafka kafka", Modified: base},
+		{ID: "b", Title: "Notes", Path: "b", Content: "kafka", Modified: base.Add(2 * time.Hour)},
+		{ID: "c", Title: "Other", Path: "c", Content: "kafka broker", Modified: base.Add(time.Hour)},
+	}
+	for _, doc := range docs {
+		if err := se.IndexDocument(doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	paths := func(opts SearchOptions, page, pageSize int) []string {
+		t.Helper()
+		found, _, err := se.SearchWithOptions("kafka", opts, page, pageSize)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, doc := range found {
+			got = append(got, doc.Path)
+		}
+		return got
+	}
+
+	if got := paths(SearchOptions{}, 1, 10); got[0] != "a" {
+		t.Errorf("relevance order = %v", got)
+	}
+	if got := paths(SearchOptions{Sort: SortModified}, 1, 10); !slices.Equal(got, []string{"b", "c", "a"}) {
+		t.Errorf("modified order = %v", got)
+	}
+	if got := paths(SearchOptions{Sort: SortModified}, 2, 2); !slices.Equal(got, []string{"a"}) {
+		t.Errorf("modified second page = %v", got)
+	}
+
+	h := NewSearchHandler(se)
+	rec := serve(h.SearchDocuments, "GET", "/api/search?q=kafka&sort=modified", nil, nil)
+	var resp SearchResults
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Results) != 3 || resp.Results[0].Path != "b" {
+		t.Errorf("sort=modified results = %+v", resp.Results)
+	}
+	if rec := serve(h.SearchDocuments, "GET", "/api/search?q=kafka&sort=title", nil, nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("sort=title: status = %d", rec.Code)
+	}
+}
+
+// Запускать с -race: поиск параллельно с перестроением индекса
+func TestSearchConcurrentWithRebuild(t *testing.T) {
+	gs := newTestStorage(t)
+	parent := mustCreate(t, gs, "", "Kafka", "kafka consumers")
+	for i := 0; i < 5; i++ {
+		mustCreate(t, gs, parent.Path, "Topic", "kafka topic partitions")
+	}
+	se := NewSearchEngine([]string{"english"})
+	if err := se.LoadFromStorage(gs); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if _, total, err := se.Search("kafka", 1, 3); err != nil || total != 6 {
+					t.Errorf("search during rebuild: total = %d, err = %v", total, err)
+					return
+				}
+				if _, total, _ := se.Search("partitions", 1, 10); total != 5 {
+					t.Errorf("search during rebuild: partitions total = %d", total)
+					return
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < 10; i++ {
+		if i%2 == 0 {
+			if _, err := se.Rebuild(gs); err != nil {
+				t.Error(err)
+			}
+		} else if err := se.LoadFromStorage(gs); err != nil {
+			t.Error(err)
+		}
+	}
+	close(stop)
+	wg.Wait()
+}
+
+func TestParseSearchLanguages(t *testing.T) {
+	languages, err := parseSearchLanguages(" English, spanish,,english ")
+	if err != nil || !slices.Equal(languages, []string{"english", "spanish"}) {
+		t.Fatalf("languages = %v, %v", languages, err)
+	}
+
+	for _, value := range []string{"english,german", "", " , "} {
+		if _, err := parseSearchLanguages(value); !errors.Is(err, ErrUnknownLanguage) {
+			t.Errorf("%q: err = %v, want ErrUnknownLanguage", value, err)
+		}
+	}
+	if _, err := parseSearchLanguages("german"); err == nil || !strings.Contains(err.Error(), `"german"`) {
+		t.Errorf("error does not name the language: %v", err)
+	}
+}
@@ -0,0 +1,73 @@
+// spa.go
+package main
+
+import (
+	"io"
+	"io/fs"
+	"net/http"
+	"strings"
+)
+
+// spaHandler отдает статические файлы клиента. Для отсутствующих путей index.html
+// возвращается только при навигации (Accept содержит text/html), чтобы клиентский
+// роутинг работал, а потерянные скрипты, стили и картинки получали настоящий 404.
+// fallbackAll возвращает прежнее поведение: index.html для любого пути.
+func spaHandler(spaFS fs.FS, fallbackAll bool) http.HandlerFunc {
+	spaFileServer := http.FileServer(http.FS(spaFS))
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Пропускаем API-запросы
+		if strings.HasPrefix(r.URL.Path, "/api") {
+			http.NotFound(w, r)
+			return
+		}
+
+		// Проверяем существование файла
+		path := strings.TrimPrefix(r.URL.Path, "/")
+		if path == "" {
+			path = "index.html"
+		}
+
+		if f, err := spaFS.Open(path); err == nil {
+			f.Close()
+			// Если файл существует - отдаем его
+			spaFileServer.ServeHTTP(w, r)
+			return
+		}
+
+		if !fallbackAll && !isNavigationRequest(r) {
+			http.NotFound(w, r)
+			return
+		}
+
+		// Если файл не найден - отдаем index.html
+		index, err := spaFS.Open("index.html")
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		defer index.Close()
+
+		// Читаем весь файл в память (не идеально для больших файлов)
+		stat, err := index.Stat()
+		if err != nil {
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		content, err := io.ReadAll(index)
+		if err != nil {
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		// Устанавливаем правильные заголовки
+		w.Header().Set("Content-Type", "text/html")
+		http.ServeContent(w, r, "index.html", stat.ModTime(), strings.NewReader(string(content)))
+	}
+}
+
+// isNavigationRequest сообщает, что браузер запрашивает страницу, а не ресурс
+func isNavigationRequest(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "text/html")
+}
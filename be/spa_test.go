@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestSPAHandler(t *testing.T) {
+	spaFS := fstest.MapFS{
+		"index.html":     {Data: []byte("<html>app</html>")},
+		"assets/app.js":  {Data: []byte("console.log(1)")},
+		"assets/app.css": {Data: []byte("body{}")},
+	}
+
+	tests := []struct {
+		name        string
+		fallbackAll bool
+		path        string
+		accept      string
+		status      int
+		body        string
+	}{
+		{"navigation", false, "/doc/guides/setup", "text/html,application/xhtml+xml,*/*;q=0.8", http.StatusOK, "<html>app</html>"},
+		{"navigation with dot", false, "/doc/release-1.2", "text/html", http.StatusOK, "<html>app</html>"},
+		{"existing asset", false, "/assets/app.js", "*/*", http.StatusOK, "console.log(1)"},
+		{"missing script", false, "/assets/chunk-4f2a.js", "*/*", http.StatusNotFound, ""},
+		{"missing image", false, "/img/logo.png", "image/avif,image/webp,*/*", http.StatusNotFound, ""},
+		{"missing api route", false, "/api/missing", "text/html", http.StatusNotFound, ""},
+		{"fallback all", true, "/assets/chunk-4f2a.js", "*/*", http.StatusOK, "<html>app</html>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.path, nil)
+			req.Header.Set("Accept", tt.accept)
+			rec := httptest.NewRecorder()
+			spaHandler(spaFS, tt.fallbackAll)(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if tt.body != "" && rec.Body.String() != tt.body {
+				t.Errorf("body = %q, want %q", rec.Body.String(), tt.body)
+			}
+			if tt.status == http.StatusNotFound && strings.Contains(rec.Body.String(), "<html>") {
+				t.Errorf("404 response contains index.html: %q", rec.Body.String())
+			}
+		})
+	}
+}
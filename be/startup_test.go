@@ -0,0 +1,52 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+// failingStorage отдает список корневых документов, но не может прочитать ни один из них
+type failingStorage struct {
+	Storage
+}
+
+var errStorageBroken = errors.New("storage is broken")
+
+func (failingStorage) GetRootDocuments() ([]ShortDocument, error) {
+	return []ShortDocument{{ID: "doc", Title: "Doc", Path: "doc"}}, nil
+}
+
+func (failingStorage) GetDocument(string) (Document, error) {
+	return Document{}, errStorageBroken
+}
+
+func TestLoadSearchIndexStrictFails(t *testing.T) {
+	engine := NewSearchEngine([]string{"english"})
+	err := loadSearchIndex(engine, failingStorage{}, true)
+	if !errors.Is(err, errStorageBroken) {
+		t.Errorf("err = %v, want %v", err, errStorageBroken)
+	}
+}
+
+func TestLoadSearchIndexWarnsByDefault(t *testing.T) {
+	engine := NewSearchEngine([]string{"english"})
+	if err := loadSearchIndex(engine, failingStorage{}, false); err != nil {
+		t.Errorf("err = %v, want nil in non-strict mode", err)
+	}
+	if docs, _ := engine.IndexSize(); docs != 0 {
+		t.Errorf("indexed %d documents, want empty index", docs)
+	}
+}
+
+func TestLoadSearchIndexStrictSucceeds(t *testing.T) {
+	_, gs, _ := newTestDocumentHandler(t)
+	mustCreate(t, gs, "", "Doc", "content")
+
+	engine := NewSearchEngine([]string{"english"})
+	if err := loadSearchIndex(engine, gs, true); err != nil {
+		t.Fatal(err)
+	}
+	if docs, _ := engine.IndexSize(); docs != 1 {
+		t.Errorf("indexed %d documents, want 1", docs)
+	}
+}
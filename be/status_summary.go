@@ -0,0 +1,128 @@
+// status_summary.go
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/utils/diff"
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// StatusSummary - сводка незакоммиченных изменений документов относительно HEAD
+type StatusSummary struct {
+	Modified     int `json:"modified"`
+	Added        int `json:"added"`
+	Deleted      int `json:"deleted"`
+	LinesAdded   int `json:"linesAdded"`
+	LinesDeleted int `json:"linesDeleted"`
+}
+
+// GetStatusSummary считает измененные файлы документов и строки в рабочем дереве.
+// Игнорируемые git файлы и файлы вне docs в сводку не попадают.
+func (gs *GitStorage) GetStatusSummary() (StatusSummary, error) {
+	status, err := gs.worktreeStatus()
+	if err != nil {
+		return StatusSummary{}, err
+	}
+
+	headTree, err := gs.headTree()
+	if err != nil {
+		return StatusSummary{}, err
+	}
+
+	var summary StatusSummary
+	for name, fileStatus := range status {
+		if !isDocumentFile(name) {
+			continue
+		}
+		if fileStatus.Worktree == git.Unmodified && fileStatus.Staging == git.Unmodified {
+			continue
+		}
+
+		from, err := treeFileContent(headTree, name)
+		if err != nil {
+			return StatusSummary{}, err
+		}
+		to, err := os.ReadFile(filepath.Join(gs.baseDir, filepath.FromSlash(name)))
+		if err != nil && !os.IsNotExist(err) {
+			return StatusSummary{}, err
+		}
+		inWorktree := err == nil
+
+		switch {
+		case from == nil && !inWorktree:
+			continue // добавлен и снова удален
+		case from == nil:
+			summary.Added++
+		case !inWorktree:
+			summary.Deleted++
+		default:
+			summary.Modified++
+		}
+
+		added, deleted := lineStats(string(from), string(to))
+		summary.LinesAdded += added
+		summary.LinesDeleted += deleted
+	}
+
+	return summary, nil
+}
+
+// headTree возвращает дерево HEAD или nil для репозитория без коммитов
+func (gs *GitStorage) headTree() (*object.Tree, error) {
+	head, err := gs.repo.Head()
+	if err != nil {
+		if errors.Is(err, plumbing.ErrReferenceNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get HEAD: %w", err)
+	}
+	commit, err := gs.repo.CommitObject(head.Hash())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get HEAD commit: %w", err)
+	}
+	return commit.Tree()
+}
+
+// treeFileContent возвращает содержимое файла из дерева или nil, если файла нет
+func treeFileContent(tree *object.Tree, name string) ([]byte, error) {
+	if tree == nil {
+		return nil, nil
+	}
+	file, err := tree.File(name)
+	if errors.Is(err, object.ErrFileNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	content, err := file.Contents()
+	if err != nil {
+		return nil, err
+	}
+	return []byte(content), nil
+}
+
+// lineStats считает добавленные и удаленные строки так же, как Commit.Stats
+func lineStats(from, to string) (added, deleted int) {
+	for _, d := range diff.Do(from, to) {
+		lines := strings.Count(d.Text, "\n")
+		if !strings.HasSuffix(d.Text, "\n") && d.Text != "" {
+			lines++
+		}
+		switch d.Type {
+		case diffmatchpatch.DiffInsert:
+			added += lines
+		case diffmatchpatch.DiffDelete:
+			deleted += lines
+		}
+	}
+	return added, deleted
+}
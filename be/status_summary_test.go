@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGetStatusSummary(t *testing.T) {
+	h, gs, _ := newTestDocumentHandler(t)
+	a := mustCreate(t, gs, "", "A", "one\ntwo\n")
+	b := mustCreate(t, gs, "", "B", "gone\nforever\nnow\n")
+
+	// Изменение: одна строка удалена, две добавлены
+	if _, err := gs.UpdateDocument(a.Path, "A", "one\nthree\nfour\n", false); err != nil {
+		t.Fatal(err)
+	}
+	// Новый документ, созданный в обход API
+	newDir := filepath.Join(gs.docsDir, "fresh")
+	if err := os.MkdirAll(newDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(newDir, "Fresh.md"), []byte("x\ny\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// Удаленный файл документа
+	if err := os.Remove(filepath.Join(gs.docsDir, b.Path, "B.md")); err != nil {
+		t.Fatal(err)
+	}
+	// Черновики не относятся к документам
+	if err := h.draftStorage.SetDraft(Draft{ID: "d", Content: "draft"}); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := serve(h.GetStatusSummary, "GET", "/api/status/summary", nil, nil)
+	var summary StatusSummary
+	if err := json.NewDecoder(rec.Body).Decode(&summary); err != nil {
+		t.Fatal(err)
+	}
+	want := StatusSummary{Modified: 1, Added: 1, Deleted: 1, LinesAdded: 4, LinesDeleted: 4}
+	if summary != want {
+		t.Fatalf("summary = %+v, want %+v", summary, want)
+	}
+}
+
+func TestGetStatusSummaryClean(t *testing.T) {
+	gs := newTestStorage(t)
+	mustCreate(t, gs, "", "A", "text\n")
+
+	summary, err := gs.GetStatusSummary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary != (StatusSummary{}) {
+		t.Fatalf("summary = %+v, want zero", summary)
+	}
+}
+
+func TestLineStats(t *testing.T) {
+	added, deleted := lineStats("a\nb\nc\n", "a\nc\nd\ne")
+	if added != 2 || deleted != 1 {
+		t.Fatalf("lineStats = +%d -%d, want +2 -1", added, deleted)
+	}
+}
@@ -0,0 +1,45 @@
+// stopwords.go
+package main
+
+// defaultStopwords - частые слова без смысловой нагрузки, которые не индексируются
+// и не ищутся. Ключи - языки движка, как в languages
+var defaultStopwords = map[string][]string{
+	"english": {
+		"a", "an", "and", "are", "as", "at", "be", "but", "by", "for", "if", "in",
+		"into", "is", "it", "its", "of", "on", "or", "so", "such", "that", "the",
+		"their", "then", "there", "these", "they", "this", "to", "was", "were",
+		"will", "with",
+	},
+	"russian": {
+		"а", "без", "бы", "в", "во", "вот", "да", "для", "до", "же", "за", "и",
+		"из", "или", "к", "ко", "ли", "на", "над", "не", "ни", "но", "о", "об",
+		"от", "по", "под", "при", "с", "со", "так", "то", "у", "что", "чтобы",
+		"это", "этот",
+	},
+}
+
+// WithStopwords заменяет список стоп-слов языка, пустой список отключает фильтрацию
+func WithStopwords(lang string, words []string) SearchEngineOption {
+	return func(se *SearchEngine) {
+		se.stopwords[lang] = stopwordSet(words)
+	}
+}
+
+func stopwordSet(words []string) map[string]bool {
+	set := make(map[string]bool, len(words))
+	for _, word := range words {
+		set[word] = true
+	}
+	return set
+}
+
+// isStopword сообщает, что слово - стоп-слово хотя бы одного языка движка.
+// Индекс строится сразу для всех языков, поэтому и запрос фильтруется по всем
+func (se *SearchEngine) isStopword(word string) bool {
+	for lang := range se.languages {
+		if se.stopwords[lang][word] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestStopwordsSkipped(t *testing.T) {
+	se := NewSearchEngine([]string{"english", "russian"})
+	docs := []Document{
+		{ID: "en", Title: "Guide", Path: "en", Content: "the kafka and the consumer"},
+		{ID: "ru", Title: "Заметки", Path: "ru", Content: "что нового или старого в kafka"},
+		{ID: "phrase", Title: "Notes", Path: "phrase", Content: "read the release of the notes"},
+	}
+	for _, doc := range docs {
+		if err := se.IndexDocument(doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, word := range []string{"the", "and", "что", "или", "of"} {
+		if _, ok := se.index[word]; ok {
+			t.Errorf("stopword %q is indexed", word)
+		}
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"the", []string{}},
+		{"the and", []string{}},
+		{"что или", []string{}},
+		{`"the of"`, []string{}},
+		{"the kafka", []string{"en", "ru"}},
+		{"или consumer", []string{"en"}},
+		{`"release notes"`, []string{"phrase"}},
+		{`"release of the notes"`, []string{"phrase"}},
+	}
+	for _, tt := range tests {
+		for _, any := range []bool{false, true} {
+			results, total, err := se.SearchWithOptions(tt.query, SearchOptions{Any: any}, 1, 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := docPaths(results)
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) || total != len(tt.want) {
+				t.Errorf("Search(%q, any=%v) = %v (total %d), want %v", tt.query, any, got, total, tt.want)
+			}
+		}
+	}
+
+	debug := se.DebugQuery("the kafka", nil)
+	if !debug.Terms[0].Stopword || len(debug.Terms[0].Stems) != 0 || debug.Terms[1].Stopword {
+		t.Errorf("debug terms = %+v", debug.Terms)
+	}
+}
+
+func TestStopwordsOverride(t *testing.T) {
+	se := NewSearchEngine([]string{"english", "russian"},
+		WithStopwords("english", []string{"kafka"}),
+		WithStopwords("russian", nil))
+	if err := se.IndexDocument(Document{ID: "a", Title: "Guide", Path: "a", Content: "the kafka и consumer"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for query, want := range map[string]int{"kafka": 0, "the": 1, "и": 1, "consumer": 1} {
+		if _, total, _ := se.Search(query, 1, 10); total != want {
+			t.Errorf("Search(%q) total = %d, want %d", query, total, want)
+		}
+	}
+
+	// Списки для языков, которых нет в движке, не действуют
+	se = NewSearchEngine([]string{"russian"})
+	if err := se.IndexDocument(Document{ID: "a", Title: "Guide", Path: "a", Content: "the kafka"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, total, _ := se.Search("the", 1, 10); total != 1 {
+		t.Errorf("english stopword filtered without english: total = %d", total)
+	}
+}
@@ -0,0 +1,71 @@
+// storage_file.go
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// FileStorage хранит документы в каталогах на диске без git: правки сразу
+// записываются в файлы, коммитов и истории нет
+type FileStorage struct {
+	docTree
+}
+
+func NewFileStorage(baseDir string) (*FileStorage, error) {
+	docsDir := filepath.Join(baseDir, "docs")
+	if err := os.MkdirAll(docsDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create docs directory: %w", err)
+	}
+	return &FileStorage{docTree: docTree{docsDir: docsDir}}, nil
+}
+
+func (fs *FileStorage) GetDocument(docPath string) (Document, error) {
+	return fs.getDocument(docPath)
+}
+
+// CreateDocument создает документ. Соавторы только проверяются: записать их некуда.
+func (fs *FileStorage) CreateDocument(parentPath, title, content string, coAuthors ...CoAuthor) (Document, error) {
+	if err := validateCoAuthors(coAuthors); err != nil {
+		return Document{}, err
+	}
+	return fs.createDocument(parentPath, title, content)
+}
+
+// UpdateDocument сохраняет документ. commitChanges не на что влиять, правка
+// записывается сразу.
+func (fs *FileStorage) UpdateDocument(docPath, title, content string, commitChanges bool, coAuthors ...CoAuthor) (Document, error) {
+	if err := validateCoAuthors(coAuthors); err != nil {
+		return Document{}, err
+	}
+	return fs.updateDocument(docPath, title, content)
+}
+
+func (fs *FileStorage) DeleteDocument(docPath string) error {
+	return fs.deleteDocument(docPath)
+}
+
+func (fs *FileStorage) MoveDocument(sourcePath, targetPath string) error {
+	return fs.moveDocument(sourcePath, targetPath)
+}
+
+// openStorage открывает хранилище документов выбранного типа: "git" или "file".
+// Возвращает и общее дерево документов, чтобы настроить его независимо от типа.
+func openStorage(backend, dataDir string) (Storage, *docTree, error) {
+	switch backend {
+	case "git":
+		gs, err := NewGitStorage(dataDir)
+		if err != nil {
+			return nil, nil, err
+		}
+		return gs, &gs.docTree, nil
+	case "file":
+		fs, err := NewFileStorage(dataDir)
+		if err != nil {
+			return nil, nil, err
+		}
+		return fs, &fs.docTree, nil
+	}
+	return nil, nil, fmt.Errorf("unknown storage %q, expected git or file", backend)
+}
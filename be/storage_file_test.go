@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileStorage(t *testing.T) {
+	dir := t.TempDir()
+	storage, tree, err := openStorage("file", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs := storage.(*FileStorage)
+	tree.uniqueTitles = true
+
+	parent, err := fs.CreateDocument("", "Parent", "p")
+	if err != nil {
+		t.Fatal(err)
+	}
+	child, err := fs.CreateDocument(parent.Path, "Child", "c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.CreateDocument(parent.Path, "child", ""); err != ErrTitleConflict {
+		t.Errorf("duplicate title: err = %v, want ErrTitleConflict", err)
+	}
+
+	// Смена названия переименовывает каталог, frontmatter сохраняется
+	file := filepath.Join(dir, "docs", "parent", "child", "Child.md")
+	if err := os.WriteFile(file, []byte("---\ntags: [a]\n---\nc"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	updated, err := fs.UpdateDocument(child.Path, "Renamed", "c2", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Path != "parent/renamed" {
+		t.Fatalf("updated path = %s", updated.Path)
+	}
+	doc, err := fs.GetDocument(updated.Path)
+	if err != nil || doc.Content != "c2" || doc.Title != "Renamed" || len(doc.Tags) != 1 || doc.Uncommitted {
+		t.Fatalf("GetDocument = %+v, %v", doc, err)
+	}
+
+	related, err := fs.GetGroupedRelatedDocuments(updated.Path)
+	if err != nil || len(related.Ancestors) != 1 || related.Ancestors[0].Path != parent.Path {
+		t.Errorf("related = %+v, %v", related, err)
+	}
+
+	if err := fs.MoveDocument(updated.Path, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.DeleteDocument(parent.Path); err != nil {
+		t.Fatal(err)
+	}
+	roots, err := fs.GetRootDocuments()
+	if err != nil || len(roots) != 1 || roots[0].Path != "renamed" {
+		t.Errorf("roots = %+v, %v", roots, err)
+	}
+	if _, err := fs.GetDocument(parent.Path); err != ErrDocumentNotFound {
+		t.Errorf("deleted document: err = %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, ".git")); !os.IsNotExist(err) {
+		t.Errorf("file storage created a git repository: %v", err)
+	}
+
+	if _, _, err := openStorage("svn", dir); err == nil || !strings.Contains(err.Error(), "unknown storage") {
+		t.Errorf("unknown backend: err = %v", err)
+	}
+}
+
+func TestFileStorageHandlers(t *testing.T) {
+	dir := t.TempDir()
+	fs, err := NewFileStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	drafts, err := NewDraftStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	md, err := NewMetadata(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewDocumentHandler(fs, NewSearchEngine([]string{"english"}), md, drafts)
+
+	rec := serve(h.CreateDocument, "POST", "/api/document", strings.NewReader(`{"title":"Doc","content":"hello"}`), nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("create status = %d, body %s", rec.Code, rec.Body)
+	}
+	var doc Document
+	if err := json.NewDecoder(rec.Body).Decode(&doc); err != nil {
+		t.Fatal(err)
+	}
+
+	vars := map[string]string{"rest": doc.Path}
+	rec = serve(h.UpdateDocument, "PUT", "/api/document/"+doc.Path, strings.NewReader(`{"title":"Doc","content":"bye","commit_changes":true}`), vars)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("update status = %d, body %s", rec.Code, rec.Body)
+	}
+
+	for name, handler := range map[string]http.HandlerFunc{
+		"history": h.GetDocumentHistory,
+		"diff":    h.GetDocumentDiff,
+		"revert":  h.RevertDocument,
+	} {
+		rec := serve(handler, "GET", "/api/history/"+doc.Path+"?from=x", strings.NewReader(`{"commit":"x"}`), vars)
+		if rec.Code != http.StatusNotImplemented {
+			t.Errorf("%s: status = %d, want 501", name, rec.Code)
+		}
+	}
+}
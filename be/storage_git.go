@@ -2,6 +2,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/mozillazg/go-unidecode"
@@ -12,16 +13,46 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
 )
 
 type GitStorage struct {
+	docTree
 	baseDir string // "data"
-	docsDir string // "data/docs"
 	repo    *git.Repository
+
+	// Авторы незакоммиченных правок по путям документов
+	pendingMu      sync.Mutex
+	pendingAuthors map[string]CoAuthor
+
+	// Кеш статуса рабочего дерева, 0 - без кеша
+	statusTTL   time.Duration
+	statusMu    sync.Mutex
+	status      git.Status
+	statusAt    time.Time
+	statusCalls int // число вычислений статуса
+
+	// Не проверять незакоммиченные изменения при чтении документа
+	skipUncommitted bool
+
+	// Транзакции выполняются по одной
+	txMu sync.Mutex
+
+	// Коммиты выполняются по одному
+	commitMu sync.Mutex
+
+	// Отложенные коммиты правок по путям документов, commitDelay 0 - коммит сразу
+	commitDelay time.Duration
+	deferredMu  sync.Mutex
+	deferred    map[string]*deferredCommit
+
+	// Авторизация для удаленного репозитория, nil - без авторизации
+	remoteAuth transport.AuthMethod
 }
 
 type CommitHistory struct {
@@ -35,7 +66,41 @@ type CommitHistory struct {
 }
 
 type DocumentHistoryResponse struct {
-	History []CommitHistory `json:"history"`
+	History     []CommitHistory `json:"history"`
+	Total       int             `json:"total"`
+	CurrentPage int             `json:"currentPage,omitempty"` // только при постраничном запросе
+	TotalPages  int             `json:"totalPages,omitempty"`
+	PageSize    int             `json:"pageSize,omitempty"`
+}
+
+// HistoryOptions ограничивает выборку истории документа. Нулевые значения
+// означают отсутствие ограничения.
+type HistoryOptions struct {
+	Page     int // нумерация с 1
+	PageSize int
+	Since    time.Time // по времени автора коммита, включительно
+	Until    time.Time
+}
+
+// historyQuery отбирает записи истории по датам и для одной страницы. Записи
+// вне страницы только считаются, без дорогого подсчета статистики изменений.
+type historyQuery struct {
+	HistoryOptions
+	seen int
+}
+
+// next возвращает true, если коммит с датой when попадает в выборку
+func (q *historyQuery) next(when time.Time) bool {
+	if (!q.Since.IsZero() && when.Before(q.Since)) || (!q.Until.IsZero() && when.After(q.Until)) {
+		return false
+	}
+	i := q.seen
+	q.seen++
+	if q.PageSize == 0 {
+		return true
+	}
+	offset := (q.Page - 1) * q.PageSize
+	return i >= offset && i < offset+q.PageSize
 }
 
 func NewGitStorage(baseDir string) (*GitStorage, error) {
@@ -62,115 +127,82 @@ func NewGitStorage(baseDir string) (*GitStorage, error) {
 	}
 
 	return &GitStorage{
+		docTree: docTree{docsDir: docsDir},
 		baseDir: baseDir,
-		docsDir: docsDir,
 		repo:    repo,
 	}, nil
 }
 
-func (gs *GitStorage) commitChanges(message string) error {
-	w, err := gs.repo.Worktree()
-	if err != nil {
-		return fmt.Errorf("failed to get worktree: %w", err)
+func (gs *GitStorage) commitChanges(message string, scope commitScope, coAuthors ...CoAuthor) error {
+	return gs.commitChangesAs(systemAuthor, message, scope, coAuthors...)
+}
+
+// singleLineMessage сводит сообщение коммита от клиента в одну строку,
+// чтобы оно не ломало отображение истории
+func singleLineMessage(message string) string {
+	return strings.Join(strings.Fields(message), " ")
+}
+
+// commitChangesAs коммитит от имени author изменения документов из scope.
+// Чужие незакоммиченные правки остаются в рабочем дереве.
+func (gs *GitStorage) commitChangesAs(author CoAuthor, message string, scope commitScope, coAuthors ...CoAuthor) error {
+	defer gs.invalidateStatus()
+
+	// Отложенные правки коммитятся раньше, чтобы не попасть в чужой коммит
+	if err := gs.Flush(); err != nil {
+		return err
 	}
 
-	// Add all changes
-	_, err = w.Add(".")
+	gs.pendingMu.Lock()
+	defer gs.pendingMu.Unlock()
+	gs.commitMu.Lock()
+	defer gs.commitMu.Unlock()
+
+	w, err := gs.repo.Worktree()
 	if err != nil {
-		return fmt.Errorf("failed to add changes: %w", err)
+		return fmt.Errorf("failed to get worktree: %w", err)
 	}
 
-	// Check if there are any changes to commit
-	status, err := w.Status()
+	staged, err := scope.stage(w)
 	if err != nil {
-		return fmt.Errorf("failed to get status: %w", err)
+		return err
 	}
-
-	if status.IsClean() {
+	if !staged {
 		return nil // No changes to commit
 	}
 
 	// Commit changes
-	_, err = w.Commit(message, &git.CommitOptions{
+	_, err = w.Commit(withCoAuthors(message, coAuthors), &git.CommitOptions{
 		Author: &object.Signature{
-			Name:  "Document System",
-			Email: "docs@system",
+			Name:  author.Name,
+			Email: author.Email,
 			When:  time.Now(),
 		},
 	})
 	if err != nil {
 		return fmt.Errorf("failed to commit changes: %w", err)
 	}
+	gitCommitsTotal.Inc()
+	gs.forgetPendingAuthors(scope)
 
 	return nil
 }
 
-func (gs *GitStorage) GetRootDocuments() ([]ShortDocument, error) {
-	return gs.getDocuments(gs.docsDir, "")
-}
-
-func (gs *GitStorage) GetRelatedDocuments(docPath string) (map[string][]ShortDocument, error) {
-	// Similar implementation as FileStorage but using git
-	result := make(map[string][]ShortDocument)
-
-	parts := strings.Split(docPath, "/")
-	currentPath := ""
-
-	for i, part := range parts {
-		if part == "" {
-			continue
-		}
-
-		if currentPath == "" {
-			currentPath = part
-		} else {
-			currentPath = filepath.Join(currentPath, part)
-		}
-
-		var currentDirDocs []ShortDocument
-		var err error
-		if currentPath != "" {
-			currentDirDocs, err = gs.GetChildDocuments(currentPath)
-			if err != nil {
-				return nil, err
-			}
-			result[currentPath] = currentDirDocs
-		}
-
-		var siblings []ShortDocument
-		parentPath := filepath.Dir(currentPath)
-		if parentPath == "." {
-			siblings, err = gs.GetRootDocuments()
-		} else {
-			siblings, err = gs.GetChildDocuments(parentPath)
-		}
-		if err != nil {
-			return nil, err
-		}
-
-		key := ""
-		if i == 0 {
-			key = "root"
-		} else {
-			key = strings.Join(parts[:i], "/")
-		}
-		result[key] = siblings
-	}
-
-	return result, nil
-}
-
 var ErrDocumentNotFound = fmt.Errorf("document not found")
 
-func (gs *GitStorage) GetDocument(docPath string) (Document, error) {
-	fullPath := filepath.Join(gs.docsDir, filepath.FromSlash(docPath))
-	if _, err := os.Stat(fullPath); os.IsNotExist(err) {
-		return Document{}, ErrDocumentNotFound
-	}
+var ErrInvalidPath = fmt.Errorf("invalid document path")
 
-	doc, err := gs.readDocument(docPath)
+func (gs *GitStorage) GetDocument(docPath string) (Document, error) {
+	doc, err := gs.getDocument(docPath)
 	if err != nil {
-		return Document{}, err
+		return doc, err
+	}
+	if gs.hasDeferredCommit(docPath) {
+		doc.Uncommitted = true
+		return doc, nil
+	}
+	if gs.skipUncommitted {
+		return doc, nil
 	}
 
 	uncommited, err := gs.isUncommited(docPath)
@@ -180,18 +212,13 @@ func (gs *GitStorage) GetDocument(docPath string) (Document, error) {
 
 	doc.Uncommitted = uncommited
 
-	return *doc, nil
+	return doc, nil
 }
 
 func (gs *GitStorage) isUncommited(docPath string) (bool, error) {
-	w, err := gs.repo.Worktree()
-	if err != nil {
-		return false, fmt.Errorf("failed to get worktree: %w", err)
-	}
-
-	status, err := w.Status()
+	status, err := gs.worktreeStatus()
 	if err != nil {
-		return false, fmt.Errorf("failed to get git status: %w", err)
+		return false, err
 	}
 
 	// Получаем относительный путь к файлу документа
@@ -224,128 +251,81 @@ func (gs *GitStorage) isUncommited(docPath string) (bool, error) {
 	return false, nil
 }
 
-func (gs *GitStorage) GetChildDocuments(parentPath string) ([]ShortDocument, error) {
-	fullPath := filepath.Join(gs.docsDir, filepath.FromSlash(parentPath))
-	return gs.getDocuments(fullPath, parentPath)
-}
-
 var mkDirErr = fmt.Errorf("mkdir")
 
-func (gs *GitStorage) CreateDocument(parentPath, title, content string) (Document, error) {
-	id := gs.generateID(parentPath, title)
-	var fullPath string
+var ErrTitleConflict = fmt.Errorf("document with this title already exists in the folder")
 
-	if parentPath == "" {
-		fullPath = filepath.Join(gs.docsDir, id)
-	} else {
-		fullPath = filepath.Join(gs.docsDir, filepath.FromSlash(parentPath), id)
-	}
+func (gs *GitStorage) CreateDocument(parentPath, title, content string, coAuthors ...CoAuthor) (Document, error) {
+	return gs.CreateDocumentAs(systemAuthor, parentPath, title, content, coAuthors...)
+}
 
-	if err := os.Mkdir(fullPath, 0755); err != nil {
-		return Document{}, mkDirErr
-	}
+// CreateDocumentAs создает документ коммитом от имени author
+func (gs *GitStorage) CreateDocumentAs(author CoAuthor, parentPath, title, content string, coAuthors ...CoAuthor) (Document, error) {
+	defer gs.invalidateStatus()
 
-	docFilePath := filepath.Join(fullPath, title+".md")
-	if err := os.WriteFile(docFilePath, []byte(content), 0644); err != nil {
-		os.RemoveAll(fullPath)
+	if err := validateCoAuthors(coAuthors); err != nil {
 		return Document{}, err
 	}
 
-	newDocPath := path.Join(parentPath, id)
+	doc, err := gs.createDocument(parentPath, title, content)
+	if err != nil {
+		return Document{}, err
+	}
 
-	if err := gs.commitChanges(fmt.Sprintf("Create document: %s", newDocPath)); err != nil {
-		os.RemoveAll(fullPath)
+	if err := gs.commitChangesAs(author, fmt.Sprintf("Create document: %s", doc.Path), docScope(doc.Path), coAuthors...); err != nil {
+		os.RemoveAll(gs.fullPath(doc.Path))
 		return Document{}, fmt.Errorf("failed to commit changes: %w", err)
 	}
 
-	children, err := gs.getChildren(parentPath)
-	if err != nil {
-		return Document{}, err
-	}
+	return doc, nil
+}
 
-	return Document{
-		ID:       id,
-		Title:    title,
-		Content:  content,
-		Children: children,
-		Path:     newDocPath,
-	}, nil
+func (gs *GitStorage) UpdateDocument(docPath, title, content string, commitChanges bool, coAuthors ...CoAuthor) (Document, error) {
+	return gs.UpdateDocumentAs(systemAuthor, "", docPath, title, content, commitChanges, coAuthors...)
 }
 
-func (gs *GitStorage) UpdateDocument(docPath, title, content string, commitChanges bool) (Document, error) {
-	fullPath := filepath.Join(gs.docsDir, filepath.FromSlash(docPath))
-	if _, err := os.Stat(fullPath); os.IsNotExist(err) {
-		return Document{}, fmt.Errorf("document not found")
-	}
+// UpdateDocumentAs обновляет документ, коммит делается от имени author с сообщением
+// message. Пустое сообщение заменяется стандартным.
+func (gs *GitStorage) UpdateDocumentAs(author CoAuthor, message, docPath, title, content string, commitChanges bool, coAuthors ...CoAuthor) (Document, error) {
+	defer gs.invalidateStatus()
 
-	currentDoc, err := gs.GetDocument(docPath)
-	if err != nil {
+	if err := validateCoAuthors(coAuthors); err != nil {
 		return Document{}, err
 	}
 
-	var newPath string
-	if title != currentDoc.Title {
-		oldTitle, err := gs.getTitle(docPath)
-		if err != nil {
-			return Document{}, err
-		}
-		if err := os.Rename(
-			filepath.Join(gs.docsDir, docPath, oldTitle+".md"),
-			filepath.Join(gs.docsDir, docPath, title+".md"),
-		); err != nil {
-			return Document{}, err
-		}
-
-		newID := gs.generateID(docPath, title)
-		newPath = filepath.Join(filepath.Dir(fullPath), newID)
-		if err := os.Rename(fullPath, newPath); err != nil {
-			return Document{}, err
-		}
-		fullPath = newPath
-		docPath = path.Join(filepath.Dir(docPath), newID)
-	}
-
-	docFilePath := filepath.Join(fullPath, title+".md")
-	if err := os.WriteFile(docFilePath, []byte(content), 0644); err != nil {
+	doc, err := gs.updateDocument(docPath, title, content)
+	if err != nil {
 		return Document{}, err
 	}
 
-	if commitChanges {
-		if err := gs.commitChanges(fmt.Sprintf("Update document: %s", docPath)); err != nil {
+	if commitChanges && gs.commitDelay > 0 {
+		gs.deferCommit(docPath, doc.Path, author, singleLineMessage(message), coAuthors)
+		doc.Uncommitted = true
+	} else if commitChanges {
+		if message = singleLineMessage(message); message == "" {
+			message = fmt.Sprintf("Update document: %s", doc.Path)
+		}
+		if err := gs.commitChangesAs(author, message, updateScope(docPath, doc.Path), coAuthors...); err != nil {
 			return Document{}, fmt.Errorf("failed to commit changes: %w", err)
 		}
 	}
 
-	children, err := gs.getChildren(docPath)
-	if err != nil {
-		return Document{}, err
-	}
-
-	return Document{
-		ID:       filepath.Base(docPath),
-		Title:    title,
-		Content:  content,
-		Children: children,
-		Path:     docPath,
-	}, nil
+	return doc, nil
 }
 
 func (gs *GitStorage) DeleteDocument(path string) error {
-	fullPath := filepath.Join(gs.docsDir, filepath.FromSlash(path))
+	return gs.DeleteDocumentAs(systemAuthor, path)
+}
 
-	hasChildren, err := gs.hasChildren(path)
-	if err != nil {
-		return err
-	}
-	if hasChildren {
-		return fmt.Errorf("cannot delete document with children")
-	}
+// DeleteDocumentAs удаляет документ коммитом от имени author
+func (gs *GitStorage) DeleteDocumentAs(author CoAuthor, path string) error {
+	defer gs.invalidateStatus()
 
-	if err := os.RemoveAll(fullPath); err != nil {
+	if err := gs.deleteDocument(path); err != nil {
 		return err
 	}
 
-	if err := gs.commitChanges(fmt.Sprintf("Delete document: %s", path)); err != nil {
+	if err := gs.commitChangesAs(author, fmt.Sprintf("Delete document: %s", path), treeScope(path)); err != nil {
 		return fmt.Errorf("failed to commit changes: %w", err)
 	}
 
@@ -353,197 +333,57 @@ func (gs *GitStorage) DeleteDocument(path string) error {
 }
 
 func (gs *GitStorage) MoveDocument(sourcePath, targetPath string) error {
-	sourceFullPath := filepath.Join(gs.docsDir, filepath.FromSlash(sourcePath))
-	targetFullPath := filepath.Join(gs.docsDir, filepath.FromSlash(targetPath), filepath.Base(sourcePath))
+	defer gs.invalidateStatus()
 
-	if _, err := os.Stat(filepath.Dir(targetFullPath)); os.IsNotExist(err) {
-		return fmt.Errorf("target directory does not exist")
-	}
-
-	if _, err := os.Stat(sourceFullPath); os.IsNotExist(err) {
-		return fmt.Errorf("source document does not exist")
-	}
-
-	if _, err := os.Stat(targetFullPath); err == nil {
-		return fmt.Errorf("target document already exists")
-	}
-
-	if err := os.Rename(sourceFullPath, targetFullPath); err != nil {
+	if err := gs.moveDocument(sourcePath, targetPath); err != nil {
 		return err
 	}
 
-	if err := gs.commitChanges(fmt.Sprintf("Move document from %s to %s", sourcePath, targetPath)); err != nil {
+	scope := treeScope(sourcePath, path.Join(targetPath, path.Base(sourcePath)))
+	if err := gs.commitChanges(fmt.Sprintf("Move document from %s to %s", sourcePath, targetPath), scope); err != nil {
 		return fmt.Errorf("failed to commit changes: %w", err)
 	}
 
 	return nil
 }
 
-// Helper methods (similar to FileStorage but with git integration)
-
-func (gs *GitStorage) getDocuments(dir, parentPath string) ([]ShortDocument, error) {
-	files, err := os.ReadDir(dir)
-	if err != nil {
-		return nil, err
-	}
-
-	var docs []ShortDocument
-	for _, f := range files {
-		if !f.IsDir() {
-			continue
-		}
-
-		id := f.Name()
-		docPath := path.Join(parentPath, id)
-		children, err := gs.getChildren(docPath)
-		if err != nil {
-			return nil, err
-		}
-		title, err := gs.getTitle(docPath)
-		if err != nil {
-			return nil, err
-		}
-
-		docs = append(docs, ShortDocument{
-			ID:          id,
-			Title:       title,
-			HasChildren: len(children) > 0,
-			Path:        docPath,
-		})
-	}
-	return docs, nil
-}
-
-func (gs *GitStorage) getChildren(docPath string) ([]ShortDocument, error) {
-	fullPath := filepath.Join(gs.docsDir, filepath.FromSlash(docPath))
-	files, err := os.ReadDir(fullPath)
-	if err != nil {
-		return nil, err
-	}
-
-	var children []ShortDocument
-	for _, f := range files {
-		if f.IsDir() {
-			childTitle, err := os.ReadDir(filepath.Join(fullPath, f.Name()))
-			if err != nil {
-				return nil, err
-			}
-			var title string
-			var hasChildren bool
-
-			for _, ff := range childTitle {
-				if !ff.IsDir() {
-					title = strings.TrimSuffix(ff.Name(), ".md")
-				} else {
-					hasChildren = true
-				}
-			}
-			children = append(children, ShortDocument{
-				ID:          f.Name(),
-				Title:       title,
-				HasChildren: hasChildren,
-				Path:        path.Join(docPath, f.Name()),
-			})
-		}
-	}
-	return children, nil
-}
-
-func (gs *GitStorage) hasChildren(docPath string) (bool, error) {
-	fullPath := filepath.Join(gs.docsDir, filepath.FromSlash(docPath))
-	files, err := os.ReadDir(fullPath)
-	if err != nil {
-		return false, err
-	}
-
-	for _, f := range files {
-		if f.IsDir() {
-			return true, nil
-		}
-	}
-	return false, nil
-}
-
-func (gs *GitStorage) readDocument(docPath string) (*Document, error) {
-	files, err := os.ReadDir(filepath.Join(gs.docsDir, filepath.FromSlash(docPath)))
-	if err != nil {
-		return nil, err
-	}
-
-	for _, f := range files {
-		if !f.IsDir() {
-			filePath := filepath.Join(gs.docsDir, filepath.FromSlash(docPath), f.Name())
-
-			info, err := os.Stat(filePath)
-			if err != nil {
-				return nil, err
-			}
-
-			children, err := gs.getChildren(docPath)
-			if err != nil {
-				return nil, err
-			}
-
-			data, err := os.ReadFile(filePath)
-			if err != nil {
-				return nil, err
-			}
-
-			return &Document{
-				ID:       filepath.Base(docPath),
-				Title:    strings.TrimSuffix(f.Name(), ".md"),
-				Content:  string(data),
-				Children: children,
-				Modified: info.ModTime(),
-				Path:     docPath,
-			}, nil
-		}
-	}
-
-	return nil, fmt.Errorf("document not found")
-}
-
 var nonAlphanumericRegex = regexp.MustCompile(`[^a-zA-Z0-9_]+`)
 
-func (gs *GitStorage) generateID(parentPath, title string) string {
+// idFromTitle строит идентификатор документа из названия без учета коллизий
+func idFromTitle(title string) string {
 	transliterated := unidecode.Unidecode(title)
 	id := strings.ReplaceAll(transliterated, " ", "_")
 	id = nonAlphanumericRegex.ReplaceAllString(id, "")
-	id = strings.ToLower(id)
-
-	baseID := id
-	counter := 1
-	for {
-		fpath := filepath.Join(gs.docsDir, parentPath, id) // Теперь используем переданный baseDir
-		if id != "root" {
-			if _, err := os.Stat(fpath); os.IsNotExist(err) {
-				break
-			}
-		}
-		id = fmt.Sprintf("%s(%d)", baseID, counter)
-		counter++
-	}
-	return id
+	return strings.ToLower(id)
 }
 
-func (gs *GitStorage) getTitle(path string) (string, error) {
-	files, err := os.ReadDir(filepath.Join(gs.docsDir, path))
-	if err != nil {
-		return "", err
-	}
-	for _, f := range files {
-		if !f.IsDir() {
-			return strings.TrimSuffix(f.Name(), ".md"), nil
-		}
-	}
-
-	return "", fmt.Errorf("title not found")
+func (gs *GitStorage) GetDocumentHistory(ctx context.Context, docPath string) (DocumentHistoryResponse, error) {
+	return gs.QueryDocumentHistory(ctx, docPath, HistoryOptions{})
 }
 
-func (gs *GitStorage) GetDocumentHistory(docPath string) (DocumentHistoryResponse, error) {
+// QueryDocumentHistory возвращает историю документа с учетом фильтра по датам
+// и постраничного вывода, а также общее количество подходящих записей
+func (gs *GitStorage) QueryDocumentHistory(ctx context.Context, docPath string, opts HistoryOptions) (DocumentHistoryResponse, error) {
+	if opts.PageSize > 0 && opts.Page < 1 {
+		opts.Page = 1
+	}
 	visited := make(map[plumbing.Hash]bool)
+	query := &historyQuery{HistoryOptions: opts}
 
-	return gs.getDocumentHistory(filepath.Join(docPath), "", visited)
+	resp, err := gs.getDocumentHistory(ctx, filepath.Join(docPath), "", visited, query)
+	if err != nil {
+		return DocumentHistoryResponse{}, err
+	}
+	resp.Total = query.seen
+	if opts.PageSize > 0 {
+		if resp.History == nil {
+			resp.History = []CommitHistory{}
+		}
+		resp.CurrentPage = opts.Page
+		resp.TotalPages = totalPages(query.seen, opts.PageSize)
+		resp.PageSize = opts.PageSize
+	}
+	return resp, nil
 }
 
 func trimMD(s string) string {
@@ -554,7 +394,7 @@ type getDocumentHistoryResponse struct {
 	changes []*object.Change
 }
 
-func (gs *GitStorage) getDocumentHistory(docPath string, filePath string, visited map[plumbing.Hash]bool) (DocumentHistoryResponse, error) {
+func (gs *GitStorage) getDocumentHistory(ctx context.Context, docPath string, filePath string, visited map[plumbing.Hash]bool, query *historyQuery) (DocumentHistoryResponse, error) {
 
 	// Check if path exists
 	if docPath != "" {
@@ -571,10 +411,8 @@ func (gs *GitStorage) getDocumentHistory(docPath string, filePath string, visite
 					return false
 				}
 				filePath = filepath.Join(gs.docsDir, docPath, title+".md")
-
-			} else {
-				s = filepath.Join(gs.baseDir, s)
 			}
+			s = filepath.Join(gs.baseDir, s)
 
 			return filepath.FromSlash(s) == filepath.FromSlash(filePath)
 		},
@@ -589,6 +427,10 @@ func (gs *GitStorage) getDocumentHistory(docPath string, filePath string, visite
 
 	// Iterate through commits
 	err = cIter.ForEach(func(c *object.Commit) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
 		if c.NumParents() == 0 {
 			return nil // Skip initial commit
 		}
@@ -608,7 +450,7 @@ func (gs *GitStorage) getDocumentHistory(docPath string, filePath string, visite
 			return err
 		}
 
-		changes, err := object.DiffTree(parentTree, currentTree)
+		changes, err := object.DiffTreeContext(ctx, parentTree, currentTree)
 		if err != nil {
 			return err
 		}
@@ -626,7 +468,7 @@ func (gs *GitStorage) getDocumentHistory(docPath string, filePath string, visite
 					visited[from.Hash] = true
 					relevantChanges = append(relevantChanges, change)
 					nested = func() (DocumentHistoryResponse, error) {
-						resp, err := gs.getDocumentHistory("", filepath.Join(gs.baseDir, change.From.Name), visited)
+						resp, err := gs.getDocumentHistory(ctx, "", filepath.Join(gs.baseDir, change.From.Name), visited, query)
 						if err != nil {
 							return DocumentHistoryResponse{}, err
 						}
@@ -663,29 +505,31 @@ func (gs *GitStorage) getDocumentHistory(docPath string, filePath string, visite
 		}
 		processedHashes[c.Hash.String()] = true
 
-		fstats, err := c.Stats()
-		if err != nil {
-			return err
-		}
+		if query.next(c.Author.When) {
+			fstats, err := c.Stats()
+			if err != nil {
+				return err
+			}
 
-		// Calculate added/deleted lines across all relevant changes
-		var added, deleted int
-		for _, stat := range fstats {
-			added += stat.Addition
-			deleted += stat.Deletion
-		}
+			// Calculate added/deleted lines across all relevant changes
+			var added, deleted int
+			for _, stat := range fstats {
+				added += stat.Addition
+				deleted += stat.Deletion
+			}
 
-		splitted := strings.Split(strings.TrimPrefix(filePath, gs.docsDir+"/"), "/")
-		location := strings.Join(splitted[:len(splitted)-1], "/")
+			splitted := strings.Split(strings.TrimPrefix(filePath, gs.docsDir+"/"), "/")
+			location := strings.Join(splitted[:len(splitted)-1], "/")
 
-		history = append(history, CommitHistory{
-			CommitHash: c.Hash.String(),
-			Date:       c.Author.When,
-			Message:    c.Message,
-			Added:      added,
-			Deleted:    deleted,
-			FilePath:   location, // Shows the path at the time of commit
-		})
+			history = append(history, CommitHistory{
+				CommitHash: c.Hash.String(),
+				Date:       c.Author.When,
+				Message:    c.Message,
+				Added:      added,
+				Deleted:    deleted,
+				FilePath:   location, // Shows the path at the time of commit
+			})
+		}
 
 		if nested != nil {
 			rep, err := nested()
@@ -739,6 +583,7 @@ func (gs *GitStorage) GetHistoricalDocument(docPath, commitID string) (Document,
 	// Find the .md file in the directory
 	var title string
 	var content string
+	var fm frontMatter
 	for _, entry := range subTree.Entries {
 		if strings.HasSuffix(entry.Name, ".md") {
 			// Get the file content
@@ -758,8 +603,10 @@ func (gs *GitStorage) GetHistoricalDocument(docPath, commitID string) (Document,
 				return Document{}, fmt.Errorf("failed to read file data: %w", err)
 			}
 
+			var body string
+			fm, body = splitFrontMatter(string(data))
 			title = strings.TrimSuffix(entry.Name, ".md")
-			content = string(data)
+			content = body
 			break
 		}
 	}
@@ -768,16 +615,20 @@ func (gs *GitStorage) GetHistoricalDocument(docPath, commitID string) (Document,
 		return Document{}, fmt.Errorf("no document file found in directory")
 	}
 
-	return Document{
+	doc := Document{
 		ID:       filepath.Base(docPath),
 		Title:    title,
 		Content:  content,
 		Path:     docPath,
 		Children: []ShortDocument{}, // We don't load full children for historical versions
-	}, nil
+	}
+	fm.apply(&doc)
+	return doc, nil
 }
 
 func (gs *GitStorage) RestoreHistoricalDocument(currentPath, originalPath, commitID string) (Document, error) {
+	defer gs.invalidateStatus()
+
 	// Verify the commit exists
 	commitHash := plumbing.NewHash(commitID)
 	commit, err := gs.repo.CommitObject(commitHash)
@@ -837,10 +688,32 @@ func (gs *GitStorage) RestoreHistoricalDocument(currentPath, originalPath, commi
 	// Get current document to compare
 	currentFullPath := filepath.Join(gs.docsDir, filepath.FromSlash(currentPath))
 	currentDoc, err := gs.GetDocument(currentPath)
-	if err != nil && !os.IsNotExist(err) {
+	if err != nil && !errors.Is(err, ErrDocumentNotFound) {
 		return Document{}, fmt.Errorf("failed to get current document: %w", err)
 	}
 
+	// Документ был удален - возвращаем его файл на прежнее место
+	if errors.Is(err, ErrDocumentNotFound) {
+		parentDir := filepath.Dir(currentFullPath)
+		if _, err := os.Stat(parentDir); os.IsNotExist(err) {
+			return Document{}, ErrParentNotFound
+		}
+		if err := os.Mkdir(currentFullPath, 0755); err != nil {
+			return Document{}, fmt.Errorf("failed to create document directory: %w", err)
+		}
+		if err := os.WriteFile(filepath.Join(currentFullPath, historicalTitle+".md"), []byte(historicalContent), 0644); err != nil {
+			os.RemoveAll(currentFullPath)
+			return Document{}, fmt.Errorf("failed to write historical content: %w", err)
+		}
+
+		commitMessage := fmt.Sprintf("Restore deleted document %s from commit %s", currentPath, commitID)
+		if err := gs.commitChanges(commitMessage, docScope(currentPath)); err != nil {
+			return Document{}, fmt.Errorf("failed to commit restoration: %w", err)
+		}
+
+		return gs.GetDocument(currentPath)
+	}
+
 	// If the document was moved, we need to handle that
 	if currentPath != originalPath {
 		// Check if the original path structure exists
@@ -867,6 +740,7 @@ func (gs *GitStorage) RestoreHistoricalDocument(currentPath, originalPath, commi
 	}
 
 	// If title changed, update directory name and Filename
+	restoredPath := currentPath
 	if currentDoc.Title != historicalTitle {
 		if err := os.Rename(currentFilePath, newFilePath); err != nil {
 			return Document{}, fmt.Errorf("failed to rename document title: %w", err)
@@ -883,7 +757,7 @@ func (gs *GitStorage) RestoreHistoricalDocument(currentPath, originalPath, commi
 	// Commit the changes
 	commitMessage := fmt.Sprintf("Restore document %s to state from commit %s (original path: %s)",
 		currentPath, commitID, originalPath)
-	if err := gs.commitChanges(commitMessage); err != nil {
+	if err := gs.commitChanges(commitMessage, updateScope(restoredPath, currentPath)); err != nil {
 		return Document{}, fmt.Errorf("failed to commit restoration: %w", err)
 	}
 
@@ -895,3 +769,127 @@ func (gs *GitStorage) RestoreHistoricalDocument(currentPath, originalPath, commi
 
 	return restoredDoc, nil
 }
+
+type DeletedDocument struct {
+	Path       string    `json:"path"` // Path of the document before deletion
+	Title      string    `json:"title"`
+	CommitHash string    `json:"commitHash"` // Commit that deleted the document
+	Date       time.Time `json:"date"`
+	Message    string    `json:"message"`
+	// Last commit containing the document, use it to restore
+	RestoreCommitHash string `json:"restoreCommitHash"`
+}
+
+var ErrParentNotFound = fmt.Errorf("parent document does not exist")
+
+var ErrMoveIntoSelf = fmt.Errorf("cannot move a document into its own descendant")
+
+// GetDeletedDocuments ищет в истории удаленные документы, которых нет в текущем дереве.
+// Перемещения отсеиваются детектором переименований git. Смена заголовка с сильной
+// правкой текста ниже порога схожести выглядит как удаление и добавление, поэтому
+// удаление, для которого в том же коммите у того же родителя появился новый документ,
+// считается переименованием. Ограничение: настоящее удаление, закоммиченное вместе
+// с созданием соседнего документа, в список не попадет.
+// Документы, чей родитель тоже удален, не показываются: сначала нужно восстановить родителя.
+func (gs *GitStorage) GetDeletedDocuments(ctx context.Context) ([]DeletedDocument, error) {
+	deleted := []DeletedDocument{}
+
+	if _, err := gs.repo.Head(); errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return deleted, nil // Еще нет ни одного коммита
+	}
+
+	cIter, err := gs.repo.Log(&git.LogOptions{Order: git.LogOrderCommitterTime})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get git log: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	err = cIter.ForEach(func(c *object.Commit) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if c.NumParents() == 0 {
+			return nil
+		}
+
+		parent, err := c.Parent(0)
+		if err != nil {
+			return err
+		}
+
+		currentTree, err := c.Tree()
+		if err != nil {
+			return err
+		}
+
+		parentTree, err := parent.Tree()
+		if err != nil {
+			return err
+		}
+
+		changes, err := object.DiffTreeWithOptions(ctx, parentTree, currentTree, object.DefaultDiffTreeOptions)
+		if err != nil {
+			return err
+		}
+
+		// Родители документов, появившихся в этом коммите в новых директориях
+		addedParents := make(map[string]bool)
+		for _, change := range changes {
+			if change.From.Name != "" || !isDocumentFile(change.To.Name) {
+				continue
+			}
+			addedDir := path.Dir(change.To.Name)
+			if _, err := parentTree.FindEntry(addedDir); err != nil {
+				addedParents[path.Dir(addedDir)] = true
+			}
+		}
+
+		for _, change := range changes {
+			if change.To.Name != "" || !isDocumentFile(change.From.Name) {
+				continue
+			}
+
+			docDir := path.Dir(change.From.Name)
+			if addedParents[path.Dir(docDir)] {
+				continue // Переименование документа с переписанным текстом
+			}
+
+			docPath := strings.TrimPrefix(docDir, "docs/")
+			if seen[docPath] {
+				continue // Показываем только последнее удаление
+			}
+			seen[docPath] = true
+
+			if _, err := os.Stat(filepath.Join(gs.docsDir, filepath.FromSlash(docPath))); err == nil {
+				continue // Документ с этим путем снова существует
+			}
+
+			if parentPath := path.Dir(docPath); parentPath != "." {
+				if _, err := os.Stat(filepath.Join(gs.docsDir, filepath.FromSlash(parentPath))); err != nil {
+					continue // Родитель тоже удален, восстановить документ нельзя
+				}
+			}
+
+			deleted = append(deleted, DeletedDocument{
+				Path:              docPath,
+				Title:             trimMD(path.Base(change.From.Name)),
+				CommitHash:        c.Hash.String(),
+				Date:              c.Author.When,
+				Message:           c.Message,
+				RestoreCommitHash: parent.Hash.String(),
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error processing commit history: %w", err)
+	}
+
+	return deleted, nil
+}
+
+// isDocumentFile проверяет, что путь в репозитории указывает на .md файл документа
+func isDocumentFile(name string) bool {
+	return strings.HasPrefix(name, "docs/") && strings.HasSuffix(name, ".md")
+}
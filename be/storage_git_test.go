@@ -0,0 +1,380 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+func newTestStorage(t *testing.T) *GitStorage {
+	t.Helper()
+	gs, err := NewGitStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return gs
+}
+
+func mustCreate(t *testing.T, gs *GitStorage, parentPath, title, content string) Document {
+	t.Helper()
+	doc, err := gs.CreateDocument(parentPath, title, content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func shortPaths(docs []ShortDocument) []string {
+	paths := make([]string, 0, len(docs))
+	for _, d := range docs {
+		paths = append(paths, d.Path)
+	}
+	return paths
+}
+
+func assertPaths(t *testing.T, name string, got []ShortDocument, want ...string) {
+	t.Helper()
+	paths := shortPaths(got)
+	if len(paths) != len(want) {
+		t.Fatalf("%s = %v, want %v", name, paths, want)
+	}
+	for i := range want {
+		if paths[i] != want[i] {
+			t.Fatalf("%s = %v, want %v", name, paths, want)
+		}
+	}
+}
+
+func TestGetGroupedRelatedDocuments(t *testing.T) {
+	gs := newTestStorage(t)
+	a := mustCreate(t, gs, "", "A", "")
+	mustCreate(t, gs, "", "B", "")
+	b := mustCreate(t, gs, a.Path, "B", "")
+	c := mustCreate(t, gs, b.Path, "C", "")
+	mustCreate(t, gs, b.Path, "D", "")
+	mustCreate(t, gs, c.Path, "E", "")
+
+	related, err := gs.GetGroupedRelatedDocuments(c.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertPaths(t, "ancestors", related.Ancestors, "a", "a/b")
+	assertPaths(t, "siblings", related.Siblings, "a/b/d")
+	assertPaths(t, "children", related.Children, "a/b/c/e")
+	// Связи по ссылкам заполняет обработчик из индекса поиска
+	if related.LinkedFrom == nil || related.LinksTo == nil || len(related.LinkedFrom)+len(related.LinksTo) != 0 {
+		t.Fatalf("link buckets = %v, %v, want empty lists", related.LinkedFrom, related.LinksTo)
+	}
+}
+
+func TestGetGroupedRelatedDocumentsRoot(t *testing.T) {
+	gs := newTestStorage(t)
+	a := mustCreate(t, gs, "", "A", "")
+	mustCreate(t, gs, "", "B", "")
+
+	related, err := gs.GetGroupedRelatedDocuments(a.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertPaths(t, "ancestors", related.Ancestors)
+	assertPaths(t, "siblings", related.Siblings, "b")
+	assertPaths(t, "children", related.Children)
+}
+
+func TestGetGroupedRelatedDocumentsNotFound(t *testing.T) {
+	gs := newTestStorage(t)
+	mustCreate(t, gs, "", "A", "")
+
+	if _, err := gs.GetGroupedRelatedDocuments("a/missing"); err != ErrDocumentNotFound {
+		t.Fatalf("err = %v, want %v", err, ErrDocumentNotFound)
+	}
+}
+
+func deletedPaths(t *testing.T, gs *GitStorage) []string {
+	t.Helper()
+	deleted, err := gs.GetDeletedDocuments(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, d := range deleted {
+		paths = append(paths, d.Path)
+	}
+	return paths
+}
+
+func TestGetDeletedDocuments(t *testing.T) {
+	gs := newTestStorage(t)
+	if paths := deletedPaths(t, gs); len(paths) != 0 {
+		t.Fatalf("deleted in empty repo = %v", paths)
+	}
+
+	a := mustCreate(t, gs, "", "A", "content a")
+	b := mustCreate(t, gs, "", "B", "content b")
+	c := mustCreate(t, gs, a.Path, "C", "content c")
+
+	// Перемещение и переименование с полной заменой текста не считаются удалением
+	if err := gs.MoveDocument(c.Path, b.Path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gs.UpdateDocument(b.Path, "Renamed", "completely different text", true); err != nil {
+		t.Fatal(err)
+	}
+	if paths := deletedPaths(t, gs); len(paths) != 0 {
+		t.Fatalf("deleted after move/rename = %v", paths)
+	}
+
+	if err := gs.DeleteDocument("renamed/c"); err != nil {
+		t.Fatal(err)
+	}
+	deleted, err := gs.GetDeletedDocuments(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deleted) != 1 || deleted[0].Path != "renamed/c" || deleted[0].Title != "C" {
+		t.Fatalf("deleted = %+v", deleted)
+	}
+
+	restored, err := gs.RestoreHistoricalDocument(deleted[0].Path, deleted[0].Path, deleted[0].RestoreCommitHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.Content != "content c" {
+		t.Fatalf("restored content = %q", restored.Content)
+	}
+	if paths := deletedPaths(t, gs); len(paths) != 0 {
+		t.Fatalf("deleted after restore = %v", paths)
+	}
+}
+
+func TestGetDeletedDocumentsSkipsChildrenOfDeletedParent(t *testing.T) {
+	gs := newTestStorage(t)
+	a := mustCreate(t, gs, "", "A", "")
+	c := mustCreate(t, gs, a.Path, "C", "")
+	if err := gs.DeleteDocument(c.Path); err != nil {
+		t.Fatal(err)
+	}
+	deleted, err := gs.GetDeletedDocuments(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := gs.DeleteDocument(a.Path); err != nil {
+		t.Fatal(err)
+	}
+
+	if paths := deletedPaths(t, gs); len(paths) != 1 || paths[0] != "a" {
+		t.Fatalf("deleted = %v, want [a]", paths)
+	}
+
+	_, err = gs.RestoreHistoricalDocument(c.Path, c.Path, deleted[0].RestoreCommitHash)
+	if err != ErrParentNotFound {
+		t.Fatalf("err = %v, want %v", err, ErrParentNotFound)
+	}
+}
+
+func TestGetDocumentHistoryIncludesLatestCommit(t *testing.T) {
+	gs := newTestStorage(t)
+	mustCreate(t, gs, "", "A", "one")
+	b := mustCreate(t, gs, "", "B", "one")
+
+	history, err := gs.GetDocumentHistory(context.Background(), b.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history.History) != 1 || history.History[0].FilePath != "b" {
+		t.Fatalf("history = %+v", history.History)
+	}
+}
+
+func TestMoveDocumentIntoOwnSubtree(t *testing.T) {
+	h, gs, _ := newTestDocumentHandler(t)
+	a := mustCreate(t, gs, "", "A", "")
+	b := mustCreate(t, gs, a.Path, "B", "")
+	commits := countCommits(t, gs)
+
+	for _, target := range []string{a.Path, b.Path, "/" + b.Path + "/"} {
+		if err := gs.MoveDocument(a.Path, target); !errors.Is(err, ErrMoveIntoSelf) {
+			t.Errorf("move %s -> %s: err = %v, want ErrMoveIntoSelf", a.Path, target, err)
+		}
+
+		body := strings.NewReader(`{"targetPath":"` + target + `"}`)
+		rec := serve(h.MoveDocument, "POST", "/api/document/"+a.Path+"/move", body, map[string]string{"rest": a.Path})
+		if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "own descendant") {
+			t.Errorf("handler move %s -> %s: status = %d, body = %q", a.Path, target, rec.Code, rec.Body.String())
+		}
+	}
+
+	if doc, err := gs.GetDocument(b.Path); err != nil || doc.Title != "B" {
+		t.Fatalf("tree changed after rejected moves: %+v, %v", doc, err)
+	}
+	if got := countCommits(t, gs); got != commits {
+		t.Errorf("commits = %d, want %d", got, commits)
+	}
+}
+
+func TestGetDocumentHistoryPaginated(t *testing.T) {
+	h, gs, _ := newTestDocumentHandler(t)
+	mustCreate(t, gs, "", "Other", "")
+	doc := mustCreate(t, gs, "", "A", "one")
+	for i, content := range []string{"two", "three"} {
+		var err error
+		if doc, err = gs.UpdateDocument(doc.Path, "A", content, true); err != nil {
+			t.Fatalf("update %d: %v", i, err)
+		}
+	}
+	// Переименование меняет путь, старые записи достаются вложенным обходом
+	doc, err := gs.UpdateDocument(doc.Path, "B", "four", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc, err = gs.UpdateDocument(doc.Path, "B", "five", true); err != nil {
+		t.Fatal(err)
+	}
+
+	full, err := gs.GetDocumentHistory(context.Background(), doc.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if full.Total != len(full.History) || full.Total < 5 {
+		t.Fatalf("full history: total = %d, entries = %d", full.Total, len(full.History))
+	}
+
+	var paged []CommitHistory
+	for page := 1; ; page++ {
+		target := fmt.Sprintf("/api/history/tree/%s?page=%d&pageSize=2", doc.Path, page)
+		rec := serve(h.GetDocumentHistory, "GET", target, nil, map[string]string{"rest": doc.Path})
+		if rec.Code != http.StatusOK {
+			t.Fatalf("page %d: status = %d, body = %s", page, rec.Code, rec.Body.String())
+		}
+		var resp DocumentHistoryResponse
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.Total != full.Total || resp.CurrentPage != page || resp.PageSize != 2 || resp.TotalPages != (full.Total+1)/2 {
+			t.Fatalf("page %d: metadata = %+v", page, resp)
+		}
+		if len(resp.History) == 0 {
+			break
+		}
+		if len(resp.History) > 2 {
+			t.Fatalf("page %d: %d entries", page, len(resp.History))
+		}
+		paged = append(paged, resp.History...)
+	}
+
+	if len(paged) != len(full.History) {
+		t.Fatalf("paged %d entries, full %d", len(paged), len(full.History))
+	}
+	for i := range paged {
+		if paged[i].CommitHash != full.History[i].CommitHash || paged[i].FilePath != full.History[i].FilePath {
+			t.Errorf("entry %d: paged %+v, full %+v", i, paged[i], full.History[i])
+		}
+	}
+	if last := full.History[len(full.History)-1]; last.FilePath != "a" {
+		t.Errorf("oldest entry path = %q, want renamed-from path", last.FilePath)
+	}
+}
+
+// commitAt коммитит все изменения с заданной датой автора
+func commitAt(t *testing.T, gs *GitStorage, message string, when time.Time) {
+	t.Helper()
+	w, err := gs.repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Add("."); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Commit(message, &git.CommitOptions{
+		Author: &object.Signature{Name: "Test", Email: "test@example.com", When: when},
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGetDocumentHistoryDateRange(t *testing.T) {
+	h, gs, _ := newTestDocumentHandler(t)
+	mustCreate(t, gs, "", "Other", "")
+	day := func(n int) time.Time { return time.Date(2024, 3, n, 12, 0, 0, 0, time.UTC) }
+
+	doc, err := gs.createDocument("", "A", "one")
+	if err != nil {
+		t.Fatal(err)
+	}
+	commitAt(t, gs, "day 1", day(1))
+	if _, err := gs.UpdateDocument(doc.Path, "A", "two", false); err != nil {
+		t.Fatal(err)
+	}
+	commitAt(t, gs, "day 2", day(2))
+	// Переименование: записи за первые дни лежат под старым путем
+	if doc, err = gs.UpdateDocument(doc.Path, "B", "three", false); err != nil {
+		t.Fatal(err)
+	}
+	commitAt(t, gs, "day 3", day(3))
+	if _, err := gs.UpdateDocument(doc.Path, "B", "four", false); err != nil {
+		t.Fatal(err)
+	}
+	commitAt(t, gs, "day 4", day(4))
+
+	get := func(query string) (int, DocumentHistoryResponse) {
+		t.Helper()
+		rec := serve(h.GetDocumentHistory, "GET", "/api/history/tree/"+doc.Path+"?"+query, nil, map[string]string{"rest": doc.Path})
+		var resp DocumentHistoryResponse
+		if rec.Code == http.StatusOK {
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return rec.Code, resp
+	}
+	messages := func(resp DocumentHistoryResponse) string {
+		var got []string
+		for _, c := range resp.History {
+			got = append(got, strings.TrimSpace(c.Message))
+		}
+		return strings.Join(got, ",")
+	}
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"", "day 4,day 3,day 2,day 1"},
+		{"since=2024-03-02T00:00:00Z", "day 4,day 3,day 2"},
+		{"until=2024-03-02T12:00:00Z", "day 2,day 1"},
+		{"since=2024-03-02T00:00:00Z&until=2024-03-03T23:00:00%2B01:00", "day 3,day 2"},
+		{"since=2024-03-02T00:00:00Z&page=2&pageSize=2", "day 2"},
+	}
+	for _, tt := range tests {
+		code, resp := get(tt.query)
+		if code != http.StatusOK {
+			t.Errorf("%q: status = %d", tt.query, code)
+			continue
+		}
+		if got := messages(resp); got != tt.want {
+			t.Errorf("%q: history = %s, want %s", tt.query, got, tt.want)
+		}
+		if resp.Total != strings.Count(tt.want, "day") && resp.PageSize == 0 {
+			t.Errorf("%q: total = %d", tt.query, resp.Total)
+		}
+	}
+	if _, resp := get("since=2024-03-02T00:00:00Z&page=2&pageSize=2"); resp.Total != 3 {
+		t.Errorf("paged total = %d, want 3", resp.Total)
+	}
+
+	for _, query := range []string{"since=yesterday", "until=2024-03-02"} {
+		if code, _ := get(query); code != http.StatusBadRequest {
+			t.Errorf("%q: status = %d, want 400", query, code)
+		}
+	}
+}
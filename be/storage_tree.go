@@ -0,0 +1,609 @@
+// storage_tree.go
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// docTree - дерево документов в каталогах на диске: у каждого документа свой
+// каталог с файлом <название>.md и каталогами детей. Общая основа GitStorage
+// и FileStorage, сама ничего не коммитит.
+type docTree struct {
+	docsDir string // "data/docs"
+
+	// Запрещать одинаковые названия у документов одного родителя
+	uniqueTitles bool
+
+	// Максимальная глубина вложенности документов, 0 - без ограничения
+	maxDepth int
+}
+
+func (dt *docTree) GetRootDocuments() ([]ShortDocument, error) {
+	return dt.getDocuments(dt.docsDir, "")
+}
+
+func (dt *docTree) GetRelatedDocuments(docPath string) (map[string][]ShortDocument, error) {
+	result := make(map[string][]ShortDocument)
+
+	parts := strings.Split(docPath, "/")
+	currentPath := ""
+
+	for i, part := range parts {
+		if part == "" {
+			continue
+		}
+
+		if currentPath == "" {
+			currentPath = part
+		} else {
+			currentPath = filepath.Join(currentPath, part)
+		}
+
+		var currentDirDocs []ShortDocument
+		var err error
+		if currentPath != "" {
+			currentDirDocs, err = dt.GetChildDocuments(currentPath)
+			if err != nil {
+				return nil, err
+			}
+			result[currentPath] = currentDirDocs
+		}
+
+		var siblings []ShortDocument
+		parentPath := filepath.Dir(currentPath)
+		if parentPath == "." {
+			siblings, err = dt.GetRootDocuments()
+		} else {
+			siblings, err = dt.GetChildDocuments(parentPath)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		key := ""
+		if i == 0 {
+			key = "root"
+		} else {
+			key = strings.Join(parts[:i], "/")
+		}
+		result[key] = siblings
+	}
+
+	return result, nil
+}
+
+// cleanDocPath нормализует путь документа из запроса и не дает выйти за пределы docs
+func (dt *docTree) cleanDocPath(docPath string) (string, error) {
+	cleaned := strings.TrimPrefix(path.Clean("/"+docPath), "/")
+	if cleaned == "" {
+		return "", ErrInvalidPath
+	}
+
+	fullPath := filepath.Join(dt.docsDir, filepath.FromSlash(cleaned))
+	rel, err := filepath.Rel(dt.docsDir, fullPath)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return "", ErrInvalidPath
+	}
+
+	return cleaned, nil
+}
+
+// GetGroupedRelatedDocuments возвращает предков, соседей и детей документа раздельно
+func (dt *docTree) GetGroupedRelatedDocuments(docPath string) (RelatedDocuments, error) {
+	related := RelatedDocuments{
+		Ancestors:  []ShortDocument{},
+		Siblings:   []ShortDocument{},
+		Children:   []ShortDocument{},
+		LinkedFrom: []ShortDocument{},
+		LinksTo:    []ShortDocument{},
+	}
+
+	parts := strings.Split(strings.Trim(docPath, "/"), "/")
+	parentPath := ""
+	for i, part := range parts {
+		var level []ShortDocument
+		var err error
+		if parentPath == "" {
+			level, err = dt.GetRootDocuments()
+		} else {
+			level, err = dt.GetChildDocuments(parentPath)
+		}
+		if err != nil {
+			return RelatedDocuments{}, err
+		}
+
+		var current *ShortDocument
+		for j := range level {
+			if level[j].ID == part {
+				current = &level[j]
+				break
+			}
+		}
+		if current == nil {
+			return RelatedDocuments{}, ErrDocumentNotFound
+		}
+
+		if i < len(parts)-1 {
+			related.Ancestors = append(related.Ancestors, *current)
+		} else {
+			for _, doc := range level {
+				if doc.ID != part {
+					related.Siblings = append(related.Siblings, doc)
+				}
+			}
+		}
+		parentPath = path.Join(parentPath, part)
+	}
+
+	children, err := dt.GetChildDocuments(parentPath)
+	if err != nil {
+		return RelatedDocuments{}, err
+	}
+	related.Children = append(related.Children, children...)
+
+	return related, nil
+}
+
+// getDocument читает документ с диска
+func (dt *docTree) getDocument(docPath string) (Document, error) {
+	fullPath := filepath.Join(dt.docsDir, filepath.FromSlash(docPath))
+	if _, err := os.Stat(fullPath); os.IsNotExist(err) {
+		return Document{}, ErrDocumentNotFound
+	}
+
+	doc, err := dt.readDocument(docPath)
+	if err != nil {
+		return Document{}, err
+	}
+	return *doc, nil
+}
+
+func (dt *docTree) GetChildDocuments(parentPath string) ([]ShortDocument, error) {
+	fullPath := filepath.Join(dt.docsDir, filepath.FromSlash(parentPath))
+	return dt.getDocuments(fullPath, parentPath)
+}
+
+// checkTitleUnique возвращает ErrTitleConflict, если в режиме уникальных названий
+// у родителя уже есть другой документ с таким названием
+func (dt *docTree) checkTitleUnique(parentPath, title, exceptPath string) error {
+	if !dt.uniqueTitles {
+		return nil
+	}
+
+	siblings, err := dt.getDocuments(filepath.Join(dt.docsDir, filepath.FromSlash(parentPath)), parentPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	for _, sibling := range siblings {
+		if sibling.Path != exceptPath && strings.EqualFold(strings.TrimSpace(sibling.Title), strings.TrimSpace(title)) {
+			return ErrTitleConflict
+		}
+	}
+	return nil
+}
+
+// createDocument создает каталог и файл документа без коммита
+func (dt *docTree) createDocument(parentPath, title, content string) (Document, error) {
+	if err := dt.checkTitleUnique(parentPath, title, ""); err != nil {
+		return Document{}, err
+	}
+	if err := dt.checkDepth(parentPath, 1); err != nil {
+		return Document{}, err
+	}
+
+	id := dt.generateID(parentPath, title)
+	var fullPath string
+
+	if parentPath == "" {
+		fullPath = filepath.Join(dt.docsDir, id)
+	} else {
+		fullPath = filepath.Join(dt.docsDir, filepath.FromSlash(parentPath), id)
+	}
+
+	if err := os.Mkdir(fullPath, 0755); err != nil {
+		return Document{}, mkDirErr
+	}
+
+	stem := fileTitle(title)
+	fileContent, err := withTitle(content, title, stem)
+	if err != nil {
+		os.RemoveAll(fullPath)
+		return Document{}, err
+	}
+	docFilePath := filepath.Join(fullPath, stem+".md")
+	if err := os.WriteFile(docFilePath, []byte(fileContent), 0644); err != nil {
+		os.RemoveAll(fullPath)
+		return Document{}, err
+	}
+
+	newDocPath := path.Join(parentPath, id)
+
+	children, err := dt.getChildren(parentPath)
+	if err != nil {
+		os.RemoveAll(fullPath)
+		return Document{}, err
+	}
+
+	// Содержимое может начинаться с frontmatter
+	fm, body := splitFrontMatter(fileContent)
+
+	doc := Document{
+		ID:       id,
+		Title:    stem,
+		Content:  body,
+		Children: children,
+		Path:     newDocPath,
+	}
+	fm.apply(&doc)
+	return doc, nil
+}
+
+// updateDocument меняет название и содержимое документа без коммита. При смене
+// названия каталог документа переименовывается, и путь документа меняется.
+func (dt *docTree) updateDocument(docPath, title, content string) (Document, error) {
+	fullPath := filepath.Join(dt.docsDir, filepath.FromSlash(docPath))
+	if _, err := os.Stat(fullPath); os.IsNotExist(err) {
+		return Document{}, fmt.Errorf("document not found")
+	}
+
+	currentDoc, err := dt.readDocument(docPath)
+	if err != nil {
+		return Document{}, err
+	}
+
+	if title != currentDoc.Title {
+		parentPath := path.Dir(docPath)
+		if parentPath == "." {
+			parentPath = ""
+		}
+		if err := dt.checkTitleUnique(parentPath, title, docPath); err != nil {
+			return Document{}, err
+		}
+		oldStem, err := dt.getTitle(docPath)
+		if err != nil {
+			return Document{}, err
+		}
+		if err := os.Rename(
+			filepath.Join(dt.docsDir, docPath, oldStem+".md"),
+			filepath.Join(dt.docsDir, docPath, fileTitle(title)+".md"),
+		); err != nil {
+			return Document{}, err
+		}
+
+		newID := dt.generateID(docPath, title)
+		newPath := filepath.Join(filepath.Dir(fullPath), newID)
+		if err := os.Rename(fullPath, newPath); err != nil {
+			return Document{}, err
+		}
+		fullPath = newPath
+		docPath = path.Join(filepath.Dir(docPath), newID)
+	}
+
+	stem, err := dt.getTitle(docPath)
+	if err != nil {
+		return Document{}, err
+	}
+	docFilePath := filepath.Join(fullPath, stem+".md")
+	fileContent, err := dt.keepFrontMatter(docFilePath, content, title)
+	if err != nil {
+		return Document{}, err
+	}
+	if err := os.WriteFile(docFilePath, []byte(fileContent), 0644); err != nil {
+		return Document{}, err
+	}
+
+	children, err := dt.getChildren(docPath)
+	if err != nil {
+		return Document{}, err
+	}
+
+	return Document{
+		ID:       filepath.Base(docPath),
+		Title:    title,
+		Content:  content,
+		Tags:     currentDoc.Tags,
+		Author:   currentDoc.Author,
+		Date:     currentDoc.Date,
+		Children: children,
+		Path:     docPath,
+	}, nil
+}
+
+// keepFrontMatter переносит frontmatter существующего файла на новое содержимое
+// и записывает в него название, если оно не совпадает с именем файла
+func (dt *docTree) keepFrontMatter(filePath, content, title string) (string, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+
+	fm, _ := splitFrontMatter(string(data))
+	fm.Title = ""
+	if stem := strings.TrimSuffix(filepath.Base(filePath), ".md"); title != stem {
+		fm.Title = title
+	}
+	return joinFrontMatter(fm, content)
+}
+
+// withTitle добавляет название во frontmatter содержимого нового файла, если
+// имя файла stem не совпадает с названием
+func withTitle(content, title, stem string) (string, error) {
+	fm, body := splitFrontMatter(content)
+	fm.Title = ""
+	if title != stem {
+		fm.Title = title
+	}
+	if fm.isEmpty() {
+		return content, nil
+	}
+	return joinFrontMatter(fm, body)
+}
+
+// deleteDocument удаляет каталог документа без потомков без коммита
+func (dt *docTree) deleteDocument(path string) error {
+	fullPath := filepath.Join(dt.docsDir, filepath.FromSlash(path))
+
+	hasChildren, err := dt.hasChildren(path)
+	if err != nil {
+		return err
+	}
+	if hasChildren {
+		return fmt.Errorf("cannot delete document with children")
+	}
+
+	return os.RemoveAll(fullPath)
+}
+
+// moveDocument переносит каталог документа в targetPath без коммита
+func (dt *docTree) moveDocument(sourcePath, targetPath string) error {
+	sourceFullPath := filepath.Join(dt.docsDir, filepath.FromSlash(sourcePath))
+	targetFullPath := filepath.Join(dt.docsDir, filepath.FromSlash(targetPath), filepath.Base(sourcePath))
+
+	if _, err := os.Stat(filepath.Dir(targetFullPath)); os.IsNotExist(err) {
+		return fmt.Errorf("target directory does not exist")
+	}
+
+	if _, err := os.Stat(sourceFullPath); os.IsNotExist(err) {
+		return fmt.Errorf("source document does not exist")
+	}
+
+	if isSubPath(strings.Trim(path.Clean("/"+targetPath), "/"), strings.Trim(path.Clean("/"+sourcePath), "/")) {
+		return fmt.Errorf("%w: %s -> %s", ErrMoveIntoSelf, sourcePath, targetPath)
+	}
+
+	if _, err := os.Stat(targetFullPath); err == nil {
+		return fmt.Errorf("target document already exists")
+	}
+
+	if err := dt.checkMoveDepth(sourcePath, targetPath); err != nil {
+		return err
+	}
+
+	return os.Rename(sourceFullPath, targetFullPath)
+}
+
+func (dt *docTree) getDocuments(dir, parentPath string) ([]ShortDocument, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var docs []ShortDocument
+	for _, f := range files {
+		if !f.IsDir() {
+			continue
+		}
+
+		id := f.Name()
+		docPath := path.Join(parentPath, id)
+		children, err := dt.getChildren(docPath)
+		if err != nil {
+			return nil, err
+		}
+		title, err := dt.documentTitle(docPath)
+		if err != nil {
+			return nil, err
+		}
+
+		docs = append(docs, ShortDocument{
+			ID:          id,
+			Title:       title,
+			HasChildren: len(children) > 0,
+			Path:        docPath,
+		})
+	}
+	return docs, nil
+}
+
+func (dt *docTree) getChildren(docPath string) ([]ShortDocument, error) {
+	fullPath := filepath.Join(dt.docsDir, filepath.FromSlash(docPath))
+	files, err := os.ReadDir(fullPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var children []ShortDocument
+	for _, f := range files {
+		if f.IsDir() {
+			childTitle, err := os.ReadDir(filepath.Join(fullPath, f.Name()))
+			if err != nil {
+				return nil, err
+			}
+			var title string
+			var hasChildren bool
+
+			for _, ff := range childTitle {
+				if !ff.IsDir() {
+					title = readTitle(filepath.Join(fullPath, f.Name(), ff.Name()))
+				} else {
+					hasChildren = true
+				}
+			}
+			children = append(children, ShortDocument{
+				ID:          f.Name(),
+				Title:       title,
+				HasChildren: hasChildren,
+				Path:        path.Join(docPath, f.Name()),
+			})
+		}
+	}
+	return children, nil
+}
+
+func (dt *docTree) hasChildren(docPath string) (bool, error) {
+	fullPath := filepath.Join(dt.docsDir, filepath.FromSlash(docPath))
+	files, err := os.ReadDir(fullPath)
+	if err != nil {
+		return false, err
+	}
+
+	for _, f := range files {
+		if f.IsDir() {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (dt *docTree) readDocument(docPath string) (*Document, error) {
+	files, err := os.ReadDir(filepath.Join(dt.docsDir, filepath.FromSlash(docPath)))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range files {
+		if !f.IsDir() {
+			filePath := filepath.Join(dt.docsDir, filepath.FromSlash(docPath), f.Name())
+
+			info, err := os.Stat(filePath)
+			if err != nil {
+				return nil, err
+			}
+
+			children, err := dt.getChildren(docPath)
+			if err != nil {
+				return nil, err
+			}
+
+			data, err := os.ReadFile(filePath)
+			if err != nil {
+				return nil, err
+			}
+
+			fm, body := splitFrontMatter(string(data))
+
+			doc := &Document{
+				ID:       filepath.Base(docPath),
+				Title:    strings.TrimSuffix(f.Name(), ".md"),
+				Content:  body,
+				Children: children,
+				Modified: info.ModTime(),
+				Path:     docPath,
+			}
+			fm.apply(doc)
+			return doc, nil
+		}
+	}
+
+	return nil, fmt.Errorf("document not found")
+}
+
+func (dt *docTree) generateID(parentPath, title string) string {
+	id := idFromTitle(title)
+	if id == "" {
+		// Название только из знаков препинания, например "+++"
+		id = "untitled"
+	}
+
+	baseID := id
+	counter := 1
+	for {
+		fpath := filepath.Join(dt.docsDir, parentPath, id) // Теперь используем переданный baseDir
+		if id != "root" {
+			if _, err := os.Stat(fpath); os.IsNotExist(err) {
+				break
+			}
+		}
+		id = fmt.Sprintf("%s(%d)", baseID, counter)
+		counter++
+	}
+	return id
+}
+
+// getTitle возвращает имя .md файла документа без расширения. Отображаемое
+// название может отличаться от него, см. documentTitle.
+func (dt *docTree) getTitle(path string) (string, error) {
+	files, err := os.ReadDir(filepath.Join(dt.docsDir, path))
+	if err != nil {
+		return "", err
+	}
+	for _, f := range files {
+		if !f.IsDir() {
+			return strings.TrimSuffix(f.Name(), ".md"), nil
+		}
+	}
+
+	return "", fmt.Errorf("title not found")
+}
+
+// documentTitle возвращает отображаемое название документа
+func (dt *docTree) documentTitle(docPath string) (string, error) {
+	stem, err := dt.getTitle(docPath)
+	if err != nil {
+		return "", err
+	}
+	return readTitle(filepath.Join(dt.docsDir, docPath, stem+".md")), nil
+}
+
+// readTitle берет название из frontmatter файла, а если его там нет - из имени файла
+func readTitle(filePath string) string {
+	stem := strings.TrimSuffix(filepath.Base(filePath), ".md")
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return stem
+	}
+	if fm, _ := splitFrontMatter(string(data)); fm.Title != "" {
+		return fm.Title
+	}
+	return stem
+}
+
+// maxFileTitleBytes - ограничение длины имени файла без ".md" с запасом до
+// обычного предела файловых систем в 255 байт
+const maxFileTitleBytes = 200
+
+// fileTitle строит из названия безопасное имя файла: разделители путей и
+// запрещенные в Windows символы заменяются на "_", ведущие точки тоже,
+// чтобы файл не стал скрытым. Полное название хранится во frontmatter.
+func fileTitle(title string) string {
+	var b strings.Builder
+	leading := true
+	for _, r := range title {
+		switch {
+		case r < 0x20 || r == 0x7f || strings.ContainsRune(`/\:*?"<>|`, r):
+			b.WriteRune('_')
+		case r == '.' && leading:
+			b.WriteRune('_')
+		default:
+			b.WriteRune(r)
+		}
+		leading = leading && r == '.'
+	}
+	stem := strings.TrimRight(b.String(), " .")
+	for len(stem) > maxFileTitleBytes {
+		_, size := utf8.DecodeLastRuneInString(stem)
+		stem = stem[:len(stem)-size]
+	}
+	if strings.TrimSpace(stem) == "" {
+		return "untitled"
+	}
+	return stem
+}
@@ -0,0 +1,256 @@
+// tags.go
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+
+	"github.com/gorilla/mux"
+)
+
+type TagUpdateResult struct {
+	Tags    []string `json:"tags,omitempty"`
+	Updated bool     `json:"updated"`
+	Error   string   `json:"error,omitempty"`
+}
+
+// BulkUpdateTags применяет изменения тегов к нескольким документам одним коммитом.
+// Сначала удаляются теги из remove, затем добавляются теги из add.
+// Если коммит не удался, измененные файлы возвращаются в исходное состояние.
+func (gs *GitStorage) BulkUpdateTags(add, remove map[string][]string) (map[string]TagUpdateResult, error) {
+	defer gs.invalidateStatus()
+
+	results := make(map[string]TagUpdateResult)
+
+	paths := make(map[string]bool)
+	for docPath := range add {
+		paths[docPath] = true
+	}
+	for docPath := range remove {
+		paths[docPath] = true
+	}
+
+	originals := make(map[string][]byte)
+	var scope commitScope
+	for docPath := range paths {
+		cleanPath, err := gs.cleanDocPath(docPath)
+		if err != nil {
+			results[docPath] = TagUpdateResult{Error: err.Error()}
+			continue
+		}
+
+		tags, filePath, original, err := gs.updateTags(cleanPath, add[docPath], remove[docPath])
+		if err != nil {
+			results[docPath] = TagUpdateResult{Error: err.Error()}
+			continue
+		}
+		if original != nil {
+			originals[filePath] = original
+			scope = scope.add(docScope(cleanPath))
+		}
+		results[docPath] = TagUpdateResult{Tags: tags, Updated: original != nil}
+	}
+
+	if len(originals) > 0 {
+		if err := gs.commitChanges(fmt.Sprintf("Update tags: %d documents", len(originals)), scope); err != nil {
+			for filePath, data := range originals {
+				if rerr := os.WriteFile(filePath, data, 0644); rerr != nil {
+					log.Printf("Warning: failed to roll back tags in %s: %v", filePath, rerr)
+				}
+			}
+			return nil, fmt.Errorf("failed to commit changes, tag updates were rolled back: %w", err)
+		}
+	}
+
+	return results, nil
+}
+
+// updateTags переписывает теги документа. Если теги изменились, возвращает
+// путь к файлу и его исходное содержимое для отката.
+func (dt *docTree) updateTags(docPath string, add, remove []string) ([]string, string, []byte, error) {
+	return dt.rewriteTags(docPath, func(current []string) []string {
+		var tags []string
+		for _, tag := range current {
+			if !slices.Contains(remove, tag) {
+				tags = append(tags, tag)
+			}
+		}
+		return append(tags, add...)
+	})
+}
+
+// setTags заменяет теги документа целиком без коммита
+func (dt *docTree) setTags(docPath string, tags []string) ([]string, string, []byte, error) {
+	return dt.rewriteTags(docPath, func([]string) []string { return tags })
+}
+
+// rewriteTags записывает в frontmatter документа теги, полученные из текущих
+func (dt *docTree) rewriteTags(docPath string, change func(current []string) []string) ([]string, string, []byte, error) {
+	fullPath := filepath.Join(dt.docsDir, filepath.FromSlash(docPath))
+	if _, err := os.Stat(fullPath); os.IsNotExist(err) {
+		return nil, "", nil, ErrDocumentNotFound
+	}
+
+	title, err := dt.getTitle(docPath)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	filePath := filepath.Join(fullPath, title+".md")
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	fm, body := splitFrontMatter(string(data))
+
+	tags := normalizeTags(change(slices.Clone(fm.Tags)))
+	if slices.Equal(tags, fm.Tags) {
+		return tags, filePath, nil, nil
+	}
+
+	fm.Tags = tags
+	content, err := joinFrontMatter(fm, body)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+		return nil, "", nil, err
+	}
+
+	return tags, filePath, data, nil
+}
+
+func (h *DocumentHandler) BulkUpdateTags(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Add    map[string][]string `json:"add"`
+		Remove map[string][]string `json:"remove"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	gitStorage, ok := h.storage.(*GitStorage)
+	if !ok {
+		http.Error(w, "tags feature only available with git storage", http.StatusNotImplemented)
+		return
+	}
+
+	results, err := gitStorage.BulkUpdateTags(req.Add, req.Remove)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Изменения уже закоммичены, поэтому ошибки индексации только логируем
+	for docPath, result := range results {
+		if !result.Updated {
+			continue
+		}
+		cleanPath, _ := gitStorage.cleanDocPath(docPath)
+		doc, err := h.storage.GetDocument(cleanPath)
+		if err != nil {
+			log.Printf("Warning: failed to reindex %s after tag update: %v", cleanPath, err)
+			continue
+		}
+		if err := h.search.DeleteDocument(cleanPath); err != nil {
+			log.Printf("Warning: failed to remove %s from search index: %v", cleanPath, err)
+		}
+		if err := h.search.IndexDocument(doc); err != nil {
+			log.Printf("Warning: failed to reindex %s after tag update: %v", cleanPath, err)
+		}
+	}
+
+	writeJSON(w, r, results)
+}
+
+// TagCount - тег и число документов с ним
+type TagCount struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+// collectTags обходит все документы и группирует их по тегам
+func collectTags(storage Storage) (map[string][]ShortDocument, error) {
+	byTag := make(map[string][]ShortDocument)
+	err := walkDocuments(storage, func(doc Document) error {
+		for _, tag := range doc.Tags {
+			byTag[tag] = append(byTag[tag], *documentToShort(&doc))
+		}
+		return nil
+	})
+	return byTag, err
+}
+
+// GetTags возвращает все теги с числом документов, сначала самые частые
+func (h *DocumentHandler) GetTags(w http.ResponseWriter, r *http.Request) {
+	byTag, err := collectTags(h.storage)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	tags := make([]TagCount, 0, len(byTag))
+	for tag, docs := range byTag {
+		tags = append(tags, TagCount{Tag: tag, Count: len(docs)})
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].Count != tags[j].Count {
+			return tags[i].Count > tags[j].Count
+		}
+		return tags[i].Tag < tags[j].Tag
+	})
+
+	writeJSON(w, r, tags)
+}
+
+// GetTagDocuments возвращает документы с тегом {tag}
+func (h *DocumentHandler) GetTagDocuments(w http.ResponseWriter, r *http.Request) {
+	tag := mux.Vars(r)["tag"]
+
+	byTag, err := collectTags(h.storage)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	docs := byTag[tag]
+	if docs == nil {
+		docs = []ShortDocument{}
+	}
+	sort.Slice(docs, func(i, j int) bool { return docs[i].Path < docs[j].Path })
+
+	writeJSON(w, r, docs)
+}
+
+// tagWriter - хранилище, умеющее менять теги документа без коммита
+type tagWriter interface {
+	setTags(docPath string, tags []string) ([]string, string, []byte, error)
+}
+
+// applyTags записывает теги перед сохранением документа, чтобы они попали в тот
+// же коммит. Возвращает функцию отката на случай ошибки сохранения.
+func (h *DocumentHandler) applyTags(docPath string, tags []string) (func(), error) {
+	writer, ok := h.storage.(tagWriter)
+	if !ok {
+		return nil, fmt.Errorf("tags are not supported by this storage")
+	}
+	_, filePath, original, err := writer.setTags(docPath, tags)
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		if original == nil {
+			return
+		}
+		if err := os.WriteFile(filePath, original, 0644); err != nil {
+			log.Printf("Warning: failed to roll back tags in %s: %v", filePath, err)
+		}
+	}, nil
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+func countCommits(t *testing.T, gs *GitStorage) int {
+	t.Helper()
+	iter, err := gs.repo.Log(&git.LogOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	count := 0
+	iter.ForEach(func(*object.Commit) error {
+		count++
+		return nil
+	})
+	return count
+}
+
+func TestBulkUpdateTags(t *testing.T) {
+	h, gs, engine := newTestDocumentHandler(t)
+	a := mustCreate(t, gs, "", "A", "first")
+	b := mustCreate(t, gs, "", "B", "second")
+	c := mustCreate(t, gs, "", "C", "third")
+	for _, doc := range []Document{a, b, c} {
+		engine.IndexDocument(doc)
+	}
+	commits := countCommits(t, gs)
+
+	body := `{"add": {"a": ["kafka", "ops"], "b": ["kafka"], "missing": ["x"]}}`
+	rec := serve(h.BulkUpdateTags, "POST", "/api/tags/bulk", strings.NewReader(body), nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	var results map[string]TagUpdateResult
+	if err := json.NewDecoder(rec.Body).Decode(&results); err != nil {
+		t.Fatal(err)
+	}
+	if !results["a"].Updated || !results["b"].Updated {
+		t.Fatalf("documents not updated: %+v", results)
+	}
+	if results["missing"].Error == "" {
+		t.Fatalf("missing document has no error: %+v", results["missing"])
+	}
+	if got := countCommits(t, gs); got != commits+1 {
+		t.Fatalf("commits = %d, want %d", got, commits+1)
+	}
+
+	found, total, _ := engine.Search("kafka", 1, 10)
+	if total != 2 {
+		t.Fatalf("search total = %d, want 2: %v", total, found)
+	}
+
+	body = `{"remove": {"a": ["kafka"], "b": ["kafka"], "c": ["kafka"]}}`
+	rec = serve(h.BulkUpdateTags, "POST", "/api/tags/bulk", strings.NewReader(body), nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	results = nil
+	json.NewDecoder(rec.Body).Decode(&results)
+	if results["c"].Updated {
+		t.Fatalf("unchanged document reported as updated")
+	}
+	if got := countCommits(t, gs); got != commits+2 {
+		t.Fatalf("commits = %d, want %d", got, commits+2)
+	}
+
+	doc, err := gs.GetDocument("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(doc.Tags, []string{"ops"}) || doc.Content != "first" {
+		t.Fatalf("document a = %+v", doc)
+	}
+	if _, total, _ := engine.Search("kafka", 1, 10); total != 0 {
+		t.Fatalf("search total after remove = %d, want 0", total)
+	}
+}
+
+func TestBulkUpdateTagsCommitsOnlyTaggedDocuments(t *testing.T) {
+	h, gs, engine := newTestDocumentHandler(t)
+	a := mustCreate(t, gs, "", "A", "first")
+	b := mustCreate(t, gs, "", "B", "second")
+	for _, doc := range []Document{a, b} {
+		engine.IndexDocument(doc)
+	}
+	// Правка B ждет пакетной фиксации
+	if _, err := gs.UpdateDocument(b.Path, "B", "draft", false); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := serve(h.BulkUpdateTags, "POST", "/api/tags/bulk", strings.NewReader(`{"add": {"a": ["kafka"]}}`), nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	if got := headFiles(t, gs); !slices.Equal(got, []string{"docs/a/A.md"}) {
+		t.Errorf("tags commit touches %v", got)
+	}
+	if got := dirtyDocs(t, gs); !slices.Equal(got, []string{"docs/b/B.md"}) {
+		t.Errorf("uncommitted documents = %v, want the pending edit of B", got)
+	}
+}
+
+func TestBulkUpdateTagsRejectsInvalidPaths(t *testing.T) {
+	gs := newTestStorage(t)
+	mustCreate(t, gs, "", "A", "")
+	outside := filepath.Join(gs.baseDir, "outside.md")
+	os.WriteFile(outside, []byte("secret"), 0644)
+
+	results, err := gs.BulkUpdateTags(map[string][]string{
+		"":       {"x"},
+		"../..":  {"x"},
+		"a/../":  {"x"},
+		"../":    {"x"},
+		"a/../a": {"x"},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{"", "../..", "a/../", "../"} {
+		if results[p].Error != ErrInvalidPath.Error() {
+			t.Errorf("path %q: result = %+v, want invalid path error", p, results[p])
+		}
+	}
+	if !results["a/../a"].Updated {
+		t.Errorf("path a/../a should resolve to a: %+v", results["a/../a"])
+	}
+	if data, _ := os.ReadFile(outside); string(data) != "secret" {
+		t.Fatalf("file outside docs was modified: %q", data)
+	}
+}
+
+func TestGetDocumentKeepsLeadingThematicBreak(t *testing.T) {
+	gs := newTestStorage(t)
+	content := "---\nKey: value\n---\ntext"
+	a := mustCreate(t, gs, "", "A", content)
+
+	doc, err := gs.GetDocument(a.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Content != content || len(doc.Tags) != 0 {
+		t.Fatalf("document = %+v", doc)
+	}
+}
+
+func TestCreateAndUpdateDocumentTags(t *testing.T) {
+	h, gs, _ := newTestDocumentHandler(t)
+
+	rec := serve(h.CreateDocument, "POST", "/api/document",
+		strings.NewReader(`{"title":"Doc","content":"body","tags":["go"," wiki ","go",""]}`), nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("create status = %d: %s", rec.Code, rec.Body)
+	}
+	var created Document
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(created.Tags, []string{"go", "wiki"}) || created.Content != "body" {
+		t.Errorf("created = tags %q, content %q", created.Tags, created.Content)
+	}
+	vars := map[string]string{"rest": created.Path}
+
+	update := func(body string) Document {
+		t.Helper()
+		rec := serve(h.UpdateDocument, "PUT", "/api/document/"+created.Path, strings.NewReader(body), vars)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("update status = %d: %s", rec.Code, rec.Body)
+		}
+		saved, err := gs.GetDocument(created.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return saved
+	}
+
+	// Без поля tags теги сохраняются
+	if doc := update(`{"title":"Doc","content":"two","commit_changes":true}`); !slices.Equal(doc.Tags, []string{"go", "wiki"}) || doc.Content != "two" {
+		t.Errorf("after plain update = tags %q, content %q", doc.Tags, doc.Content)
+	}
+
+	// Теги и содержимое попадают в один коммит
+	before := countCommits(t, gs)
+	if doc := update(`{"title":"Doc","content":"three","commit_changes":true,"tags":["notes"]}`); !slices.Equal(doc.Tags, []string{"notes"}) || doc.Content != "three" {
+		t.Errorf("after tag update = tags %q, content %q", doc.Tags, doc.Content)
+	}
+	if got := countCommits(t, gs) - before; got != 1 {
+		t.Errorf("tag update made %d commits, want 1", got)
+	}
+
+	if doc := update(`{"title":"Doc","content":"four","commit_changes":true,"tags":[]}`); len(doc.Tags) != 0 || doc.Content != "four" {
+		t.Errorf("after clearing = tags %q, content %q", doc.Tags, doc.Content)
+	}
+}
+
+func TestGetTags(t *testing.T) {
+	h, gs, _ := newTestDocumentHandler(t)
+	a := mustCreate(t, gs, "", "A", "---\ntags: [go, wiki]\n---\na")
+	mustCreate(t, gs, a.Path, "B", "---\ntags: [go]\n---\nb")
+	mustCreate(t, gs, "", "C", "no tags")
+
+	rec := serve(h.GetTags, "GET", "/api/tags", nil, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	var tags []TagCount
+	if err := json.Unmarshal(rec.Body.Bytes(), &tags); err != nil {
+		t.Fatal(err)
+	}
+	want := []TagCount{{Tag: "go", Count: 2}, {Tag: "wiki", Count: 1}}
+	if !slices.Equal(tags, want) {
+		t.Errorf("tags = %+v, want %+v", tags, want)
+	}
+
+	rec = serve(h.GetTagDocuments, "GET", "/api/tags/go", nil, map[string]string{"tag": "go"})
+	var docs []ShortDocument
+	if err := json.Unmarshal(rec.Body.Bytes(), &docs); err != nil {
+		t.Fatal(err)
+	}
+	if got := shortPaths(docs); !slices.Equal(got, []string{"a", "a/b"}) {
+		t.Errorf("documents tagged go = %q", got)
+	}
+
+	rec = serve(h.GetTagDocuments, "GET", "/api/tags/none", nil, map[string]string{"tag": "none"})
+	if rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != "[]" {
+		t.Errorf("unknown tag: status = %d, body = %s", rec.Code, rec.Body)
+	}
+}
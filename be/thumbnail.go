@@ -0,0 +1,87 @@
+// thumbnail.go
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// defaultThumbnailSize - размер миниатюры, если клиент не передал size
+const defaultThumbnailSize = "320x240"
+
+var (
+	markdownImagePattern = regexp.MustCompile(`!\[[^\]]*\]\(\s*<?([^)\s>]+)`)
+	htmlImagePattern     = regexp.MustCompile(`(?i)<img\s[^>]*src\s*=\s*["']([^"']+)["']`)
+)
+
+// firstImage возвращает имя первого загруженного изображения в документе.
+// Изображения внутри блоков кода и внешние изображения пропускаются.
+func firstImage(content string) (string, bool) {
+	var fence string
+	for _, line := range strings.Split(content, "\n") {
+		if marker := codeFenceMarker(line); marker != "" {
+			if fence == "" {
+				fence = marker
+			} else if strings.HasPrefix(strings.TrimSpace(line), fence) {
+				fence = ""
+			}
+			continue
+		}
+		if fence != "" {
+			continue
+		}
+
+		best, file := -1, ""
+		for _, pattern := range []*regexp.Regexp{markdownImagePattern, htmlImagePattern} {
+			for _, m := range pattern.FindAllStringSubmatchIndex(line, -1) {
+				ref := fileRefPattern.FindStringSubmatch(line[m[2]:m[3]])
+				if ref == nil {
+					continue
+				}
+				if best < 0 || m[0] < best {
+					best, file = m[0], ref[1]
+				}
+				break
+			}
+		}
+		if best >= 0 {
+			return file, true
+		}
+	}
+	return "", false
+}
+
+// GetDocumentThumbnail перенаправляет на уменьшенную копию первого изображения
+// документа, размер задается параметром size (по умолчанию 320x240)
+func (h *DocumentHandler) GetDocumentThumbnail(w http.ResponseWriter, r *http.Request) {
+	docPath := strings.Trim(mux.Vars(r)["rest"], "/")
+	doc, err := h.storage.GetDocument(docPath)
+	if errors.Is(err, ErrDocumentNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	file, ok := firstImage(doc.Content)
+	if !ok {
+		http.Error(w, "document has no images", http.StatusNotFound)
+		return
+	}
+
+	query := url.Values{"size": {defaultThumbnailSize}}
+	for key, values := range r.URL.Query() {
+		// size, quality и filter проверяет обработчик файлов
+		if key == "size" || key == "quality" || key == "filter" {
+			query[key] = values
+		}
+	}
+	http.Redirect(w, r, "/api/file/"+url.PathEscape(file)+"?"+query.Encode(), http.StatusFound)
+}
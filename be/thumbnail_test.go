@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestFirstImage(t *testing.T) {
+	tests := []struct {
+		content string
+		want    string
+	}{
+		{"no images here", ""},
+		{"[file](/api/file/doc.pdf)", ""},
+		{"![external](https://example.com/a.png)", ""},
+		{"```\n![code](/api/file/incode)\n```\n![real](/api/file/real)", "real"},
+		{`<img src="/api/file/html"> ![md](/api/file/md)`, "html"},
+		{"text ![a](http://localhost:8000/api/file/abc.png) ![b](/api/file/b)", "abc.png"},
+	}
+	for _, tt := range tests {
+		got, ok := firstImage(tt.content)
+		if got != tt.want || ok != (tt.want != "") {
+			t.Errorf("firstImage(%q) = %q, %v; want %q", tt.content, got, ok, tt.want)
+		}
+	}
+}
+
+func TestGetDocumentThumbnail(t *testing.T) {
+	h, gs, _ := newTestDocumentHandler(t)
+	h.uploadDir = t.TempDir()
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 800, 600))); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(h.uploadDir, "photo.png"), buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	withImage := mustCreate(t, gs, "", "Gallery", "Intro\n\n![photo](/api/file/photo.png)")
+	withoutImage := mustCreate(t, gs, "", "Text", "Just text")
+
+	r := mux.NewRouter()
+	r.HandleFunc("/api/document/{rest:.*}/thumbnail", h.GetDocumentThumbnail).Methods("GET")
+	r.HandleFunc("/api/file/{hash}", h.HandleFileDownload).Methods("GET")
+	get := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest("GET", target, nil))
+		return rec
+	}
+
+	resp := get("/api/document/" + withImage.Path + "/thumbnail?size=100x50")
+	if resp.Code != http.StatusFound {
+		t.Fatalf("thumbnail status = %d, body %s", resp.Code, resp.Body)
+	}
+	location := resp.Header().Get("Location")
+
+	resp = get(location)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("GET %s status = %d", location, resp.Code)
+	}
+	img, _, err := image.Decode(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 100 || b.Dy() != 50 {
+		t.Errorf("thumbnail is %dx%d, want 100x50", b.Dx(), b.Dy())
+	}
+
+	if resp := get("/api/document/" + withoutImage.Path + "/thumbnail"); resp.Code != http.StatusNotFound {
+		t.Errorf("document without images: status = %d, want 404", resp.Code)
+	}
+	if resp := get("/api/document/missing/thumbnail"); resp.Code != http.StatusNotFound {
+		t.Errorf("missing document: status = %d, want 404", resp.Code)
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileTitle(t *testing.T) {
+	tests := []struct {
+		title string
+		want  string
+	}{
+		{"Plain title", "Plain title"},
+		{"A/B Testing", "A_B Testing"},
+		{`C:\Temp`, "C__Temp"},
+		{"C++ Notes", "C++ Notes"},
+		{"Заметки о Go", "Заметки о Go"},
+		{".hidden", "_hidden"},
+		{"..", "__"},
+		{" . ", "untitled"},
+		{"...dots. in. middle.", "___dots. in. middle"},
+		{"a\tb", "a_b"},
+		{strings.Repeat("я", 150), strings.Repeat("я", maxFileTitleBytes/2)},
+	}
+	for _, tt := range tests {
+		if got := fileTitle(tt.title); got != tt.want {
+			t.Errorf("fileTitle(%q) = %q, want %q", tt.title, got, tt.want)
+		}
+	}
+}
+
+func TestTitlesWithSpecialCharacters(t *testing.T) {
+	gs := newTestStorage(t)
+	parent := mustCreate(t, gs, "", "Parent", "")
+
+	for _, title := range []string{"A/B Testing", "C++ Notes", "Заметки: итоги", ".hidden", "../escape", "+++"} {
+		t.Run(title, func(t *testing.T) {
+			created := mustCreate(t, gs, parent.Path, title, "body")
+			if created.Title != title || created.Content != "body" {
+				t.Errorf("created = %q, %q", created.Title, created.Content)
+			}
+			if strings.ContainsAny(created.ID, `/\.`) || created.ID == "" {
+				t.Errorf("unsafe id %q", created.ID)
+			}
+
+			doc, err := gs.GetDocument(created.Path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if doc.Title != title || doc.Content != "body" {
+				t.Errorf("document = %q, %q", doc.Title, doc.Content)
+			}
+
+			// Файл лежит в каталоге документа, а не выше
+			entries, err := os.ReadDir(filepath.Join(gs.docsDir, created.Path))
+			if err != nil || len(entries) != 1 || strings.HasPrefix(entries[0].Name(), ".") {
+				t.Errorf("document directory = %v, %v", entries, err)
+			}
+		})
+	}
+
+	children, err := gs.GetChildDocuments(parent.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	titles := map[string]bool{}
+	for _, c := range children {
+		titles[c.Title] = true
+	}
+	if !titles["A/B Testing"] || !titles["../escape"] {
+		t.Errorf("child titles = %v", titles)
+	}
+}
+
+func TestRenameToTitleWithSlash(t *testing.T) {
+	gs := newTestStorage(t)
+	doc := mustCreate(t, gs, "", "Plain", "---\ntags: [x]\n---\nbody")
+
+	renamed, err := gs.UpdateDocument(doc.Path, "Input/Output", "body", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := gs.GetDocument(renamed.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Title != "Input/Output" || got.Content != "body" || len(got.Tags) != 1 {
+		t.Errorf("after rename = %+v", got)
+	}
+	if _, err := os.Stat(filepath.Join(gs.docsDir, renamed.Path, "Input_Output.md")); err != nil {
+		t.Error(err)
+	}
+
+	// Без особых символов название снова берется из имени файла
+	back, err := gs.UpdateDocument(renamed.Path, "Plain again", "body", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(gs.docsDir, back.Path, "Plain again.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fm, _ := splitFrontMatter(string(data)); fm.Title != "" || len(fm.Tags) != 1 {
+		t.Errorf("file = %q", data)
+	}
+}
+
+func TestUniqueTitlesCompareDisplayTitles(t *testing.T) {
+	gs := newTestStorage(t)
+	gs.uniqueTitles = true
+	mustCreate(t, gs, "", "A/B", "")
+	if _, err := gs.CreateDocument("", "a/b", ""); err != ErrTitleConflict {
+		t.Errorf("duplicate title err = %v, want ErrTitleConflict", err)
+	}
+	if _, err := gs.CreateDocument("", "A_B", ""); err != nil {
+		t.Errorf("title with the same file name: %v", err)
+	}
+}
@@ -0,0 +1,57 @@
+// toc.go
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// TocEntry - заголовок оглавления с вложенными подзаголовками
+type TocEntry struct {
+	Level    int        `json:"level"`
+	Text     string     `json:"text"`
+	Anchor   string     `json:"anchor"`
+	Children []TocEntry `json:"children,omitempty"`
+}
+
+// buildTOC вкладывает заголовки друг в друга по уровням. Пропуск уровня
+// (### сразу после #) не создает пустых промежуточных узлов.
+func buildTOC(headings []Heading) []TocEntry {
+	toc, _ := buildTOCLevel(headings, 0)
+	return toc
+}
+
+// buildTOCLevel собирает заголовки глубже parentLevel и возвращает число использованных
+func buildTOCLevel(headings []Heading, parentLevel int) ([]TocEntry, int) {
+	entries := []TocEntry{}
+	i := 0
+	for i < len(headings) && headings[i].Level > parentLevel {
+		h := headings[i]
+		children, used := buildTOCLevel(headings[i+1:], h.Level)
+		entry := TocEntry{Level: h.Level, Text: h.Text, Anchor: h.Anchor}
+		if len(children) > 0 {
+			entry.Children = children
+		}
+		entries = append(entries, entry)
+		i += 1 + used
+	}
+	return entries, i
+}
+
+// GetDocumentTOC возвращает оглавление документа. Якоря совпадают с id заголовков
+// в HTML из /api/render, повторы заголовков получают суффикс -1, -2...
+func (h *DocumentHandler) GetDocumentTOC(w http.ResponseWriter, r *http.Request) {
+	doc, err := h.storage.GetDocument(mux.Vars(r)["rest"])
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrDocumentNotFound) {
+			status = http.StatusNotFound
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	writeJSON(w, r, buildTOC(extractHeadings(doc.Content)))
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func getTOC(t *testing.T, h *DocumentHandler, docPath string) []TocEntry {
+	t.Helper()
+	rec := serve(h.GetDocumentTOC, "GET", "/api/toc/"+docPath, nil, map[string]string{"rest": docPath})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	var toc []TocEntry
+	if err := json.NewDecoder(rec.Body).Decode(&toc); err != nil {
+		t.Fatal(err)
+	}
+	return toc
+}
+
+func TestGetDocumentTOC(t *testing.T) {
+	h, gs, _ := newTestDocumentHandler(t)
+	content := "# Guide\n\n## Setup\n\n### Install\n\n```\n# not a heading\n```\n\n## Setup\n\n#### Deep *note*\n\n# Appendix\n"
+	doc := mustCreate(t, gs, "", "Guide", content)
+
+	want := []TocEntry{
+		{Level: 1, Text: "Guide", Anchor: "guide", Children: []TocEntry{
+			{Level: 2, Text: "Setup", Anchor: "setup", Children: []TocEntry{
+				{Level: 3, Text: "Install", Anchor: "install"},
+			}},
+			{Level: 2, Text: "Setup", Anchor: "setup-1", Children: []TocEntry{
+				{Level: 4, Text: "Deep note", Anchor: "deep-note"},
+			}},
+		}},
+		{Level: 1, Text: "Appendix", Anchor: "appendix"},
+	}
+	if got := getTOC(t, h, doc.Path); !reflect.DeepEqual(got, want) {
+		t.Fatalf("toc = %+v, want %+v", got, want)
+	}
+
+	// Якоря оглавления совпадают с id заголовков в отрендеренном HTML
+	html, err := renderMarkdown(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, m := range regexp.MustCompile(`<h\d id="([^"]+)"`).FindAllStringSubmatch(html, -1) {
+		ids = append(ids, m[1])
+	}
+	if want := []string{"guide", "setup", "install", "setup-1", "deep-note", "appendix"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("rendered heading ids = %v, want %v", ids, want)
+	}
+
+	empty := mustCreate(t, gs, "", "Empty", "")
+	if got := getTOC(t, h, empty.Path); got == nil || len(got) != 0 {
+		t.Errorf("empty document toc = %#v", got)
+	}
+
+	rec := serve(h.GetDocumentTOC, "GET", "/api/toc/missing", nil, map[string]string{"rest": "missing"})
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("missing document: status = %d", rec.Code)
+	}
+}
@@ -0,0 +1,136 @@
+// tree.go
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// defaultTreeMaxNodes - сколько документов отдает /api/tree, прежде чем обрезать дерево
+const defaultTreeMaxNodes = 10000
+
+// TreeNode - документ в плоском NDJSON-представлении дерева
+type TreeNode struct {
+	ID          string `json:"id"`
+	Title       string `json:"title"`
+	Path        string `json:"path"`
+	ParentPath  string `json:"parentPath"`
+	HasChildren bool   `json:"hasChildren"`
+}
+
+// TreeMetadata - итог обхода дерева
+type TreeMetadata struct {
+	Count     int    `json:"count"`
+	Truncated bool   `json:"truncated"`
+	Error     string `json:"error,omitempty"`
+}
+
+// treeWriter обходит дерево документов и пишет его в ответ по мере обхода,
+// не собирая целиком в памяти. После limit документов обход прекращается.
+type treeWriter struct {
+	w       *bufio.Writer
+	storage Storage
+	limit   int
+	meta    TreeMetadata
+}
+
+func (tw *treeWriter) children(parentPath string) []ShortDocument {
+	var docs []ShortDocument
+	var err error
+	if parentPath == "" {
+		docs, err = tw.storage.GetRootDocuments()
+	} else {
+		docs, err = tw.storage.GetChildDocuments(parentPath)
+	}
+	if err != nil {
+		tw.meta.Truncated = true
+		tw.meta.Error = err.Error()
+		return nil
+	}
+	return docs
+}
+
+// full сообщает, что лимит исчерпан или обход прерван ошибкой
+func (tw *treeWriter) full() bool {
+	if tw.meta.Error != "" {
+		return true
+	}
+	if tw.meta.Count >= tw.limit {
+		tw.meta.Truncated = true
+		return true
+	}
+	return false
+}
+
+// writeNested пишет массив вложенных узлов: {"id",...,"hasChildren","children":[...]}
+func (tw *treeWriter) writeNested(parentPath string) {
+	tw.w.WriteByte('[')
+	for i, doc := range tw.children(parentPath) {
+		if tw.full() {
+			break
+		}
+		tw.meta.Count++
+		if i > 0 {
+			tw.w.WriteByte(',')
+		}
+
+		node, _ := json.Marshal(TreeNode{ID: doc.ID, Title: doc.Title, Path: doc.Path, ParentPath: parentPath, HasChildren: doc.HasChildren})
+		tw.w.Write(node[:len(node)-1])
+		if doc.HasChildren {
+			tw.w.WriteString(`,"children":`)
+			tw.writeNested(doc.Path)
+		}
+		tw.w.WriteByte('}')
+	}
+	tw.w.WriteByte(']')
+	tw.w.Flush()
+}
+
+// writeFlat пишет узлы по одному на строку в порядке обхода в глубину
+func (tw *treeWriter) writeFlat(parentPath string, enc *json.Encoder) {
+	for _, doc := range tw.children(parentPath) {
+		if tw.full() {
+			return
+		}
+		tw.meta.Count++
+		enc.Encode(TreeNode{ID: doc.ID, Title: doc.Title, Path: doc.Path, ParentPath: parentPath, HasChildren: doc.HasChildren})
+		if doc.HasChildren {
+			tw.writeFlat(doc.Path, enc)
+		}
+	}
+	tw.w.Flush()
+}
+
+// GetTree отдает все дерево документов потоком. По умолчанию - вложенный JSON
+// {"nodes": [...], "count": N, "truncated": false}, с format=ndjson - плоские узлы
+// и итоговая строка {"metadata": {...}}. При превышении лимита узлов дерево
+// обрезается и truncated=true: клиенту стоит перейти на ленивую загрузку.
+func (h *DocumentHandler) GetTree(w http.ResponseWriter, r *http.Request) {
+	root := strings.Trim(path.Clean("/"+r.URL.Query().Get("path")), "/")
+
+	tw := &treeWriter{w: bufio.NewWriter(w), storage: h.storage, limit: h.treeMaxNodes}
+	if tw.limit <= 0 {
+		tw.limit = defaultTreeMaxNodes
+	}
+
+	if r.URL.Query().Get("format") == "ndjson" {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		enc := json.NewEncoder(tw.w)
+		tw.writeFlat(root, enc)
+		enc.Encode(map[string]TreeMetadata{"metadata": tw.meta})
+		tw.w.Flush()
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	tw.w.WriteString(`{"nodes":`)
+	tw.writeNested(root)
+	meta, _ := json.Marshal(tw.meta)
+	tw.w.WriteByte(',')
+	tw.w.Write(meta[1:])
+	tw.w.WriteByte('\n')
+	tw.w.Flush()
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+type nestedNode struct {
+	TreeNode
+	Children []nestedNode `json:"children"`
+}
+
+type nestedTree struct {
+	Nodes []nestedNode `json:"nodes"`
+	TreeMetadata
+}
+
+func seedTree(t *testing.T, gs *GitStorage) {
+	t.Helper()
+	a := mustCreate(t, gs, "", "A", "")
+	mustCreate(t, gs, a.Path, "A1", "")
+	a2 := mustCreate(t, gs, a.Path, "A2", "")
+	mustCreate(t, gs, a2.Path, "A2x", "")
+	mustCreate(t, gs, "", "B", "")
+}
+
+func TestGetTreeUnderLimit(t *testing.T) {
+	h, gs, _ := newTestDocumentHandler(t)
+	seedTree(t, gs)
+
+	rec := serve(h.GetTree, "GET", "/api/tree", nil, nil)
+	var tree nestedTree
+	if err := json.NewDecoder(rec.Body).Decode(&tree); err != nil {
+		t.Fatalf("decode %s: %v", rec.Body, err)
+	}
+	if tree.Count != 5 || tree.Truncated {
+		t.Fatalf("metadata = %+v, want 5 nodes untruncated", tree.TreeMetadata)
+	}
+	if len(tree.Nodes) != 2 || len(tree.Nodes[0].Children) != 2 || len(tree.Nodes[0].Children[1].Children) != 1 {
+		t.Fatalf("unexpected tree shape: %+v", tree.Nodes)
+	}
+	if got := tree.Nodes[0].Children[1].Children[0].Path; got != "a/a2/a2x" {
+		t.Fatalf("deepest path = %q", got)
+	}
+}
+
+func TestGetTreeOverLimit(t *testing.T) {
+	h, gs, _ := newTestDocumentHandler(t)
+	seedTree(t, gs)
+	h.treeMaxNodes = 3
+
+	rec := serve(h.GetTree, "GET", "/api/tree", nil, nil)
+	var tree nestedTree
+	if err := json.NewDecoder(rec.Body).Decode(&tree); err != nil {
+		t.Fatalf("decode %s: %v", rec.Body, err)
+	}
+	if tree.Count != 3 || !tree.Truncated {
+		t.Fatalf("metadata = %+v, want 3 nodes truncated", tree.TreeMetadata)
+	}
+
+	rec = serve(h.GetTree, "GET", "/api/tree?format=ndjson", nil, nil)
+	var lines []string
+	scanner := bufio.NewScanner(strings.NewReader(rec.Body.String()))
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if len(lines) != 4 {
+		t.Fatalf("got %d lines, want 3 nodes + metadata: %q", len(lines), lines)
+	}
+	var node TreeNode
+	if err := json.Unmarshal([]byte(lines[1]), &node); err != nil || node.ParentPath != "a" {
+		t.Fatalf("second node = %+v (%v)", node, err)
+	}
+	var meta struct {
+		Metadata TreeMetadata `json:"metadata"`
+	}
+	if err := json.Unmarshal([]byte(lines[3]), &meta); err != nil {
+		t.Fatal(err)
+	}
+	if meta.Metadata.Count != 3 || !meta.Metadata.Truncated {
+		t.Fatalf("ndjson metadata = %+v", meta.Metadata)
+	}
+}
@@ -0,0 +1,335 @@
+// tx.go
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+var ErrInvalidTransaction = errors.New("invalid transaction")
+
+const (
+	TxCreate = "create"
+	TxUpdate = "update"
+	TxMove   = "move"
+	TxDelete = "delete"
+)
+
+// TxOperation - одна операция транзакции. Для create Path - путь родителя,
+// для move Target - новый родитель
+type TxOperation struct {
+	Op      string  `json:"op"`
+	Path    string  `json:"path"`
+	Title   string  `json:"title,omitempty"`
+	Content *string `json:"content,omitempty"`
+	Target  string  `json:"target,omitempty"`
+}
+
+// TxResult описывает результат операции: Path - путь документа после нее,
+// OldPath - прежний путь, если он изменился
+type TxResult struct {
+	Op      string `json:"op"`
+	Path    string `json:"path"`
+	OldPath string `json:"oldPath,omitempty"`
+
+	scope commitScope // документы, затронутые операцией
+}
+
+// TxError сообщает, на какой операции транзакция была прервана
+type TxError struct {
+	Index int
+	Err   error
+}
+
+func (e *TxError) Error() string {
+	return fmt.Sprintf("operation %d: %v", e.Index, e.Err)
+}
+
+func (e *TxError) Unwrap() error {
+	return e.Err
+}
+
+// ApplyTransaction применяет операции по порядку и коммитит их одним коммитом.
+// Если любая операция или коммит завершаются ошибкой, уже выполненные операции
+// откатываются в обратном порядке и рабочее дерево возвращается в исходное состояние.
+func (gs *GitStorage) ApplyTransaction(ops []TxOperation, message string) ([]TxResult, error) {
+	gs.txMu.Lock()
+	defer gs.txMu.Unlock()
+	defer gs.invalidateStatus()
+
+	if len(ops) == 0 {
+		return nil, fmt.Errorf("%w: no operations", ErrInvalidTransaction)
+	}
+
+	var undo []func() error
+	rollback := func() {
+		for i := len(undo) - 1; i >= 0; i-- {
+			if err := undo[i](); err != nil {
+				log.Printf("Warning: failed to roll back transaction step %d: %v", i, err)
+			}
+		}
+	}
+
+	results := make([]TxResult, 0, len(ops))
+	var scope commitScope
+	for i, op := range ops {
+		result, step, err := gs.applyTxOperation(op)
+		if err != nil {
+			rollback()
+			return nil, &TxError{Index: i, Err: err}
+		}
+		undo = append(undo, step)
+		results = append(results, result)
+		scope = scope.add(result.scope)
+	}
+
+	if message == "" {
+		message = fmt.Sprintf("Transaction: %d operations", len(ops))
+	}
+	if err := gs.commitChanges(message, scope); err != nil {
+		rollback()
+		return nil, fmt.Errorf("failed to commit changes, transaction was rolled back: %w", err)
+	}
+
+	return results, nil
+}
+
+// applyTxOperation выполняет одну операцию без коммита и возвращает шаг отката
+func (gs *GitStorage) applyTxOperation(op TxOperation) (TxResult, func() error, error) {
+	result := TxResult{Op: op.Op}
+	if err := gs.checkTxPath(op.Path); err != nil {
+		return result, nil, err
+	}
+
+	if op.Op != TxCreate {
+		if op.Path == "" {
+			return result, nil, fmt.Errorf("%w: path is required", ErrInvalidTransaction)
+		}
+		if _, err := os.Stat(gs.fullPath(op.Path)); os.IsNotExist(err) {
+			return result, nil, fmt.Errorf("%w: %s", ErrDocumentNotFound, op.Path)
+		}
+	}
+
+	switch op.Op {
+	case TxCreate:
+		if op.Title == "" {
+			return result, nil, fmt.Errorf("%w: title is required", ErrInvalidTransaction)
+		}
+		var content string
+		if op.Content != nil {
+			content = *op.Content
+		}
+		doc, err := gs.createDocument(op.Path, op.Title, content)
+		if err != nil {
+			return result, nil, err
+		}
+		result.Path = doc.Path
+		result.scope = docScope(doc.Path)
+		return result, func() error {
+			return os.RemoveAll(gs.fullPath(doc.Path))
+		}, nil
+
+	case TxUpdate:
+		current, err := gs.GetDocument(op.Path)
+		if err != nil {
+			return result, nil, err
+		}
+		files, err := snapshotFiles(gs.fullPath(op.Path))
+		if err != nil {
+			return result, nil, err
+		}
+		title, content := current.Title, current.Content
+		if op.Title != "" {
+			title = op.Title
+		}
+		if op.Content != nil {
+			content = *op.Content
+		}
+		doc, err := gs.UpdateDocument(op.Path, title, content, false)
+		if err != nil {
+			return result, nil, err
+		}
+		result.Path = doc.Path
+		if doc.Path != op.Path {
+			result.OldPath = op.Path
+		}
+		result.scope = updateScope(op.Path, doc.Path)
+		return result, func() error {
+			if doc.Path != op.Path {
+				if err := os.Rename(gs.fullPath(doc.Path), gs.fullPath(op.Path)); err != nil {
+					return err
+				}
+			}
+			return restoreFiles(gs.fullPath(op.Path), files)
+		}, nil
+
+	case TxMove:
+		if err := gs.checkTxPath(op.Target); err != nil {
+			return result, nil, err
+		}
+		if _, err := os.Stat(gs.fullPath(op.Target)); os.IsNotExist(err) {
+			return result, nil, fmt.Errorf("%w: %s", ErrParentNotFound, op.Target)
+		}
+		if isSubPath(op.Target, op.Path) {
+			return result, nil, fmt.Errorf("%w: cannot move a document into itself", ErrInvalidTransaction)
+		}
+		if err := gs.moveDocument(op.Path, op.Target); err != nil {
+			return result, nil, err
+		}
+		result.Path = path.Join(op.Target, path.Base(op.Path))
+		result.OldPath = op.Path
+		result.scope = treeScope(op.Path, result.Path)
+		return result, func() error {
+			return os.Rename(gs.fullPath(result.Path), gs.fullPath(op.Path))
+		}, nil
+
+	case TxDelete:
+		files, err := snapshotFiles(gs.fullPath(op.Path))
+		if err != nil {
+			return result, nil, err
+		}
+		if err := gs.deleteDocument(op.Path); err != nil {
+			return result, nil, err
+		}
+		result.OldPath = op.Path
+		result.scope = treeScope(op.Path)
+		return result, func() error {
+			if err := os.MkdirAll(gs.fullPath(op.Path), 0755); err != nil {
+				return err
+			}
+			return restoreFiles(gs.fullPath(op.Path), files)
+		}, nil
+	}
+
+	return result, nil, fmt.Errorf("%w: unknown operation %q", ErrInvalidTransaction, op.Op)
+}
+
+// checkTxPath проверяет, что путь операции уже нормализован
+func (gs *GitStorage) checkTxPath(docPath string) error {
+	if docPath == "" {
+		return nil
+	}
+	if cleaned, err := gs.cleanDocPath(docPath); err != nil || cleaned != docPath {
+		return fmt.Errorf("%w: %q", ErrInvalidPath, docPath)
+	}
+	return nil
+}
+
+// snapshotFiles читает файлы каталога документа без вложенных документов
+func snapshotFiles(dir string) (map[string][]byte, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string][]byte)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		files[entry.Name()] = data
+	}
+	return files, nil
+}
+
+// restoreFiles возвращает файлы каталога к снимку snapshotFiles
+func restoreFiles(dir string, files map[string][]byte) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if _, ok := files[entry.Name()]; !ok {
+			if err := os.Remove(filepath.Join(dir, entry.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h *DocumentHandler) ApplyTransaction(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Message    string        `json:"message"`
+		Operations []TxOperation `json:"operations"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	gitStorage, ok := h.storage.(*GitStorage)
+	if !ok {
+		http.Error(w, "transactions only available with git storage", http.StatusNotImplemented)
+		return
+	}
+
+	results, err := gitStorage.ApplyTransaction(req.Operations, req.Message)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, ErrDocumentNotFound), errors.Is(err, ErrParentNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, ErrTitleConflict):
+			status = http.StatusConflict
+		case errors.Is(err, ErrInvalidTransaction), errors.Is(err, ErrInvalidPath), errors.Is(err, ErrDepthExceeded):
+			status = http.StatusBadRequest
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	h.reindexTransaction(results)
+
+	writeJSON(w, r, results)
+}
+
+// reindexTransaction обновляет индекс и избранное после транзакции. Операции
+// могли затронуть один документ несколько раз, поэтому индексируются только пути,
+// которые существуют после коммита.
+func (h *DocumentHandler) reindexTransaction(results []TxResult) {
+	for _, result := range results {
+		var doc Document
+		exists := false
+		if result.Path != "" {
+			var err error
+			doc, err = h.storage.GetDocument(result.Path)
+			exists = err == nil
+		}
+
+		switch {
+		case result.OldPath != "" && exists:
+			h.relocateSubtree(result.OldPath, result.Path)
+		case result.OldPath != "":
+			if subtree, ok := h.search.(interface{ DeleteSubtree(string) }); ok {
+				subtree.DeleteSubtree(result.OldPath)
+			} else {
+				h.search.DeleteDocument(result.OldPath)
+			}
+			h.meta.RemoveFromFavorites(result.OldPath)
+		case exists:
+			// Документ мог отсутствовать в индексе, важно лишь проиндексировать актуальную версию
+			h.search.DeleteDocument(doc.Path)
+			if err := h.search.IndexDocument(doc); err != nil {
+				log.Printf("Warning: failed to index %s: %v", doc.Path, err)
+			}
+		}
+	}
+}
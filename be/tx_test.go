@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func applyTx(t *testing.T, h *DocumentHandler, ops []TxOperation) (*http.Response, []TxResult) {
+	t.Helper()
+	body, _ := json.Marshal(map[string]any{"message": "Reorganize", "operations": ops})
+	rec := serve(h.ApplyTransaction, "POST", "/api/tx", strings.NewReader(string(body)), nil)
+	var results []TxResult
+	if rec.Code == http.StatusOK {
+		if err := json.NewDecoder(rec.Body).Decode(&results); err != nil {
+			t.Fatalf("decode %q: %v", rec.Body.String(), err)
+		}
+	}
+	return rec.Result(), results
+}
+
+func ptr(s string) *string {
+	return &s
+}
+
+func TestApplyTransaction(t *testing.T) {
+	h, gs, engine := newTestDocumentHandler(t)
+	a := mustCreate(t, gs, "", "A", "aardvark")
+	b := mustCreate(t, gs, "", "B", "")
+	old := mustCreate(t, gs, "", "Old", "obsolete")
+	if err := engine.LoadFromStorage(gs); err != nil {
+		t.Fatal(err)
+	}
+	commits := countCommits(t, gs)
+
+	resp, results := applyTx(t, h, []TxOperation{
+		{Op: TxCreate, Path: b.Path, Title: "New", Content: ptr("narwhal")},
+		{Op: TxUpdate, Path: a.Path, Content: ptr("axolotl")},
+		{Op: TxMove, Path: a.Path, Target: b.Path},
+		{Op: TxDelete, Path: old.Path},
+	})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d", resp.StatusCode)
+	}
+	if got := countCommits(t, gs); got != commits+1 {
+		t.Fatalf("commits = %d, want %d", got, commits+1)
+	}
+
+	moved := b.Path + "/" + a.ID
+	if results[2].Path != moved || results[2].OldPath != a.Path {
+		t.Fatalf("move result = %+v", results[2])
+	}
+	doc, err := gs.GetDocument(moved)
+	if err != nil || doc.Content != "axolotl" {
+		t.Fatalf("moved document = %+v, %v", doc, err)
+	}
+	if _, err := os.Stat(gs.fullPath(old.Path)); !os.IsNotExist(err) {
+		t.Fatalf("deleted document still exists: %v", err)
+	}
+
+	for query, want := range map[string]string{"narwhal": results[0].Path, "axolotl": moved} {
+		docs, _, _ := engine.Search(query, 1, 10)
+		if len(docs) != 1 || docs[0].Path != want {
+			t.Fatalf("search %q = %+v, want %s", query, docs, want)
+		}
+	}
+	for _, query := range []string{"aardvark", "obsolete"} {
+		if docs, _, _ := engine.Search(query, 1, 10); len(docs) != 0 {
+			t.Fatalf("search %q = %+v, want nothing", query, docs)
+		}
+	}
+}
+
+func TestApplyTransactionRollback(t *testing.T) {
+	h, gs, engine := newTestDocumentHandler(t)
+	a := mustCreate(t, gs, "", "A", "aardvark")
+	b := mustCreate(t, gs, "", "B", "")
+	c := mustCreate(t, gs, "", "C", "")
+	if err := engine.LoadFromStorage(gs); err != nil {
+		t.Fatal(err)
+	}
+	commits := countCommits(t, gs)
+	head, _ := gs.repo.Head()
+
+	resp, _ := applyTx(t, h, []TxOperation{
+		{Op: TxCreate, Path: "", Title: "New", Content: ptr("narwhal")},
+		{Op: TxUpdate, Path: a.Path, Title: "Renamed", Content: ptr("axolotl")},
+		{Op: TxDelete, Path: c.Path},
+		{Op: TxMove, Path: b.Path, Target: "missing"},
+	})
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("status = %d", resp.StatusCode)
+	}
+	if got := countCommits(t, gs); got != commits {
+		t.Fatalf("commits = %d, want %d", got, commits)
+	}
+	if after, _ := gs.repo.Head(); after.Hash() != head.Hash() {
+		t.Fatal("HEAD moved after a failed transaction")
+	}
+
+	entries, err := os.ReadDir(gs.docsDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
+			names = append(names, entry.Name())
+		}
+	}
+	if strings.Join(names, ",") != strings.Join([]string{a.ID, b.ID, c.ID}, ",") {
+		t.Fatalf("documents after rollback = %v", names)
+	}
+	data, err := os.ReadFile(filepath.Join(gs.fullPath(a.Path), "A.md"))
+	if err != nil || string(data) != "aardvark" {
+		t.Fatalf("A.md after rollback = %q, %v", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(gs.fullPath(a.Path), "Renamed.md")); !os.IsNotExist(err) {
+		t.Fatalf("renamed file survived rollback: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(gs.fullPath(c.Path), "C.md")); err != nil {
+		t.Fatalf("deleted document was not restored: %v", err)
+	}
+
+	if dirty := dirtyDocs(t, gs); len(dirty) != 0 {
+		t.Fatalf("documents are dirty after rollback: %v", dirty)
+	}
+}
+
+func TestApplyTransactionCommitsOnlyTouchedDocuments(t *testing.T) {
+	_, gs, _ := newTestDocumentHandler(t)
+	a := mustCreate(t, gs, "", "A", "aardvark")
+	b := mustCreate(t, gs, "", "B", "")
+	old := mustCreate(t, gs, "", "Old", "obsolete")
+	other := mustCreate(t, gs, "", "Other", "okapi")
+	// Правки B и Other ждут пакетной фиксации
+	if _, err := gs.UpdateDocument(b.Path, "B", "pending", false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gs.UpdateDocument(other.Path, "Other", "pending", false); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := gs.ApplyTransaction([]TxOperation{
+		{Op: TxUpdate, Path: a.Path, Content: ptr("axolotl")},
+		{Op: TxMove, Path: a.Path, Target: b.Path},
+		{Op: TxDelete, Path: old.Path},
+	}, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	files := strings.Join(headFiles(t, gs), ",")
+	for _, want := range []string{"docs/" + a.Path + "/A.md", "docs/" + b.Path + "/" + a.ID + "/A.md", "docs/" + old.Path + "/Old.md"} {
+		if !strings.Contains(files, want) {
+			t.Errorf("transaction commit touches %s, want %s", files, want)
+		}
+	}
+	want := []string{"docs/" + b.Path + "/B.md", "docs/" + other.Path + "/Other.md"}
+	if got := dirtyDocs(t, gs); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("uncommitted documents = %v, want %v", got, want)
+	}
+}
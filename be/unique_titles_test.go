@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func createViaHandler(t *testing.T, h *DocumentHandler, parentPath, title string) *httptest.ResponseRecorder {
+	t.Helper()
+	body, _ := json.Marshal(map[string]string{"parentPath": parentPath, "title": title, "content": ""})
+	return serve(h.CreateDocument, "POST", "/api/document", strings.NewReader(string(body)), nil)
+}
+
+func TestDuplicateSiblingTitlesAllowedByDefault(t *testing.T) {
+	h, _, _ := newTestDocumentHandler(t)
+	for i := 0; i < 2; i++ {
+		if resp := createViaHandler(t, h, "", "Notes"); resp.Code != http.StatusOK {
+			t.Fatalf("create #%d status = %d, body %s", i+1, resp.Code, resp.Body)
+		}
+	}
+}
+
+func TestUniqueTitlesRejectsDuplicateSiblings(t *testing.T) {
+	h, gs, engine := newTestDocumentHandler(t)
+	gs.uniqueTitles = true
+
+	if resp := createViaHandler(t, h, "", "Notes"); resp.Code != http.StatusOK {
+		t.Fatalf("first create status = %d, body %s", resp.Code, resp.Body)
+	}
+	if resp := createViaHandler(t, h, "", "notes"); resp.Code != http.StatusConflict {
+		t.Fatalf("duplicate create status = %d, want %d", resp.Code, http.StatusConflict)
+	}
+
+	// Под другим родителем название свободно
+	parent := mustCreate(t, gs, "", "Parent", "")
+	if resp := createViaHandler(t, h, parent.Path, "Notes"); resp.Code != http.StatusOK {
+		t.Fatalf("create under other parent status = %d, body %s", resp.Code, resp.Body)
+	}
+
+	// Переименование в занятое название
+	other := mustCreate(t, gs, "", "Other", "")
+	if err := engine.IndexDocument(other); err != nil {
+		t.Fatal(err)
+	}
+	rec := serve(h.UpdateDocument, "PUT", "/api/document/"+other.Path,
+		strings.NewReader(`{"title":"Notes","content":""}`), map[string]string{"rest": other.Path})
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("rename status = %d, want %d", rec.Code, http.StatusConflict)
+	}
+
+	// Сохранение документа без смены названия не считается конфликтом
+	rec = serve(h.UpdateDocument, "PUT", "/api/document/"+other.Path,
+		strings.NewReader(`{"title":"Other","content":"changed"}`), map[string]string{"rest": other.Path})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("update status = %d, body %s", rec.Code, rec.Body)
+	}
+}
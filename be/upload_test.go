@@ -0,0 +1,65 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/jpeg"
+	"math/rand"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTestJPEG(t *testing.T, dir, name string) {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 256, 256))
+	rnd := rand.New(rand.NewSource(1))
+	for y := 0; y < 256; y++ {
+		for x := 0; x < 256; x++ {
+			img.Set(x, y, color.RGBA{uint8(rnd.Intn(256)), uint8(x), uint8(y), 255})
+		}
+	}
+	f, err := os.Create(filepath.Join(dir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := jpeg.Encode(f, img, &jpeg.Options{Quality: 100}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHandleFileDownloadResizeQuality(t *testing.T) {
+	h, _, _ := newTestDocumentHandler(t)
+	h.uploadDir = t.TempDir()
+	writeTestJPEG(t, h.uploadDir, "photo.jpg")
+	vars := map[string]string{"hash": "photo.jpg"}
+
+	download := func(query string) (int, int) {
+		rec := serve(h.HandleFileDownload, "GET", "/api/file/photo.jpg?"+query, nil, vars)
+		return rec.Code, rec.Body.Len()
+	}
+
+	code, low := download("size=128x128&quality=10")
+	if code != http.StatusOK {
+		t.Fatalf("quality=10 status = %d", code)
+	}
+	code, high := download("size=128x128&quality=95")
+	if code != http.StatusOK {
+		t.Fatalf("quality=95 status = %d", code)
+	}
+	if low >= high {
+		t.Fatalf("quality=10 size %d not smaller than quality=95 size %d", low, high)
+	}
+
+	if code, _ := download("size=128x128&filter=box"); code != http.StatusOK {
+		t.Fatalf("filter=box status = %d", code)
+	}
+
+	for _, query := range []string{"size=128x128&quality=0", "size=128x128&quality=101", "size=128x128&quality=abc", "size=128x128&filter=cubic"} {
+		if code, _ := download(query); code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want %d", query, code, http.StatusBadRequest)
+		}
+	}
+}
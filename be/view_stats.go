@@ -0,0 +1,118 @@
+// view_stats.go
+package main
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// DocumentViews - статистика просмотров документа
+type DocumentViews struct {
+	Document ShortDocument `json:"document"`
+	ViewedAt time.Time     `json:"viewedAt"` // последний просмотр
+	Count    int           `json:"count"`
+}
+
+// ViewedDocument - документ со статистикой просмотров
+type ViewedDocument struct {
+	ShortDocument
+	ViewedAt  *time.Time `json:"viewedAt,omitempty"` // нет у записей из старых файлов метаданных
+	ViewCount int        `json:"viewCount"`
+}
+
+const defaultMostViewedLimit = 10
+
+// recordViewLocked учитывает просмотр документа. Вызывается под m.mu.
+func (m *Metadata) recordViewLocked(doc *ShortDocument, at time.Time) {
+	if m.Views == nil {
+		m.Views = make(map[string]*DocumentViews)
+	}
+	views, ok := m.Views[doc.Path]
+	if !ok {
+		views = &DocumentViews{}
+		m.Views[doc.Path] = views
+	}
+	views.Document = *doc
+	views.ViewedAt = at
+	views.Count++
+}
+
+// migrateViews заводит статистику для файлов метаданных, сохраненных до ее
+// появления: документы из последних просмотренных считаются просмотренными
+// один раз в неизвестное время
+func (m *Metadata) migrateViews() {
+	if m.Views != nil {
+		return
+	}
+	m.Views = make(map[string]*DocumentViews)
+	for _, d := range m.LastViewedDocs {
+		if d != nil {
+			m.Views[d.Path] = &DocumentViews{Document: *d, Count: 1}
+		}
+	}
+}
+
+// viewedDocumentLocked дополняет документ статистикой. Вызывается под m.mu.
+func (m *Metadata) viewedDocumentLocked(doc ShortDocument) ViewedDocument {
+	viewed := ViewedDocument{ShortDocument: doc}
+	if views, ok := m.Views[doc.Path]; ok {
+		viewed.ViewCount = views.Count
+		if !views.ViewedAt.IsZero() {
+			at := views.ViewedAt
+			viewed.ViewedAt = &at
+		}
+	}
+	return viewed
+}
+
+// GetMostViewedDocuments возвращает limit самых просматриваемых документов,
+// при равенстве - недавно просмотренные раньше
+func (m *Metadata) GetMostViewedDocuments(limit int) []ViewedDocument {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	out := make([]ViewedDocument, 0, len(m.Views))
+	for _, views := range m.Views {
+		out = append(out, m.viewedDocumentLocked(views.Document))
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].ViewCount != out[j].ViewCount {
+			return out[i].ViewCount > out[j].ViewCount
+		}
+		ti, tj := time.Time{}, time.Time{}
+		if out[i].ViewedAt != nil {
+			ti = *out[i].ViewedAt
+		}
+		if out[j].ViewedAt != nil {
+			tj = *out[j].ViewedAt
+		}
+		if !ti.Equal(tj) {
+			return ti.After(tj)
+		}
+		return out[i].Path < out[j].Path
+	})
+	if len(out) > limit {
+		out = out[:limit]
+	}
+	return out
+}
+
+// GetMostViewed возвращает самые просматриваемые документы, ?limit=N
+func (h *DocumentHandler) GetMostViewed(w http.ResponseWriter, r *http.Request) {
+	limit := defaultMostViewedLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	docs := h.meta.GetMostViewedDocuments(limit)
+	log.Printf("GetMostViewed: returning %d documents", len(docs))
+	writeJSON(w, r, docs)
+}
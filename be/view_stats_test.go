@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestViewStatsCountsAndMostViewed(t *testing.T) {
+	dir := t.TempDir()
+	md, err := NewMetadata(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	view := func(p string, n int) {
+		for i := 0; i < n; i++ {
+			md.UpdateViewedMeta(&ShortDocument{ID: p, Title: p, Path: p})
+		}
+	}
+	view("a", 3)
+	view("b", 1)
+	view("c", 2)
+	view("d", 1)
+
+	last := md.GetLastViewedDocuments()
+	if len(last) != 4 || last[0].Path != "d" || last[0].ViewCount != 1 || last[0].ViewedAt == nil {
+		t.Fatalf("last viewed = %+v", last)
+	}
+	if last[1].Path != "c" || last[1].ViewCount != 2 {
+		t.Errorf("c = %+v, want 2 views", last[1])
+	}
+
+	paths := func(docs []ViewedDocument) string {
+		var got []string
+		for _, d := range docs {
+			got = append(got, d.Path)
+		}
+		return strings.Join(got, ",")
+	}
+	// При равном счетчике первым идет недавно просмотренный
+	if got := paths(md.GetMostViewedDocuments(10)); got != "a,c,d,b" {
+		t.Errorf("most viewed = %s, want a,c,d,b", got)
+	}
+	if got := paths(md.GetMostViewedDocuments(2)); got != "a,c" {
+		t.Errorf("most viewed limit 2 = %s, want a,c", got)
+	}
+
+	// Статистика переживает перезапуск
+	if err := md.SaveOnDisk(); err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err := NewMetadata(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	most := reloaded.GetMostViewedDocuments(1)
+	if len(most) != 1 || most[0].Path != "a" || most[0].ViewCount != 3 || most[0].ViewedAt == nil {
+		t.Errorf("reloaded most viewed = %+v", most)
+	}
+
+	reloaded.RelocatePaths("a", "moved/a")
+	reloaded.RemoveFromLastViewed("c")
+	if got := paths(reloaded.GetMostViewedDocuments(10)); got != "moved/a,d,b" {
+		t.Errorf("after move and delete = %s, want moved/a,d,b", got)
+	}
+}
+
+func TestViewStatsMigratesOldMetadata(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "metadata")
+	// Формат файла до появления статистики просмотров
+	old := struct {
+		LastViewedDocs []*ShortDocument
+		Favorites      []*ShortDocument
+		Filename       string
+	}{
+		LastViewedDocs: []*ShortDocument{{ID: "x", Title: "X", Path: "x"}, {ID: "y", Title: "Y", Path: "y"}},
+		Filename:       filename,
+	}
+	file, err := os.Create(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := gob.NewEncoder(file).Encode(old); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	md, err := NewMetadata(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := md.GetLastViewedDocuments()
+	if len(last) != 2 || last[0].Path != "x" || last[0].ViewCount != 1 || last[0].ViewedAt != nil {
+		t.Fatalf("migrated last viewed = %+v", last)
+	}
+
+	md.UpdateViewedMeta(&ShortDocument{ID: "y", Title: "Y", Path: "y"})
+	most := md.GetMostViewedDocuments(10)
+	if len(most) != 2 || most[0].Path != "y" || most[0].ViewCount != 2 || most[0].ViewedAt == nil {
+		t.Errorf("most viewed after migration = %+v", most)
+	}
+}
+
+func TestGetMostViewedHandler(t *testing.T) {
+	h, _, _ := newTestDocumentHandler(t)
+	for _, p := range []string{"a", "b", "b"} {
+		h.meta.UpdateViewedMeta(&ShortDocument{ID: p, Title: p, Path: p})
+	}
+
+	rec := serve(h.GetMostViewed, "GET", "/api/views/popular?limit=1", nil, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	var docs []ViewedDocument
+	if err := json.Unmarshal(rec.Body.Bytes(), &docs); err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != 1 || docs[0].Path != "b" || docs[0].ViewCount != 2 {
+		t.Errorf("docs = %+v", docs)
+	}
+	if !strings.Contains(rec.Body.String(), `"viewCount":2`) || !strings.Contains(rec.Body.String(), `"path":"b"`) {
+		t.Errorf("body is not flat JSON: %s", rec.Body)
+	}
+
+	for _, limit := range []string{"0", "x"} {
+		if rec := serve(h.GetMostViewed, "GET", "/api/views/popular?limit="+limit, nil, nil); rec.Code != http.StatusBadRequest {
+			t.Errorf("limit=%s: status = %d, want 400", limit, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+// watcher.go
+package main
+
+import (
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// DocumentWatcher следит за каталогом документов и переиндексирует документы,
+// измененные в обход API (git pull, ручная правка файлов).
+// События копятся и обрабатываются после паузы debounce.
+type DocumentWatcher struct {
+	watcher  *fsnotify.Watcher
+	storage  Storage
+	search   *SearchEngine
+	docsDir  string
+	debounce time.Duration
+
+	mu      sync.Mutex
+	pending map[string]bool
+	timer   *time.Timer
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+func NewDocumentWatcher(docsDir string, storage Storage, search *SearchEngine, debounce time.Duration) (*DocumentWatcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	dw := &DocumentWatcher{
+		watcher:  watcher,
+		storage:  storage,
+		search:   search,
+		docsDir:  docsDir,
+		debounce: debounce,
+		pending:  make(map[string]bool),
+		done:     make(chan struct{}),
+	}
+	if err := dw.watchTree(docsDir); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+
+	dw.wg.Add(1)
+	go dw.run()
+	return dw, nil
+}
+
+// Close останавливает наблюдение, не дожидаясь обработки накопленных событий
+func (dw *DocumentWatcher) Close() error {
+	close(dw.done)
+	err := dw.watcher.Close()
+	dw.wg.Wait()
+
+	dw.mu.Lock()
+	if dw.timer != nil {
+		dw.timer.Stop()
+	}
+	dw.mu.Unlock()
+	return err
+}
+
+// watchTree добавляет наблюдение за каталогом и всеми вложенными каталогами,
+// fsnotify не умеет следить рекурсивно
+func (dw *DocumentWatcher) watchTree(root string) error {
+	return filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if p != root && isIgnoredName(d.Name()) {
+			return filepath.SkipDir
+		}
+		return dw.watcher.Add(p)
+	})
+}
+
+func (dw *DocumentWatcher) run() {
+	defer dw.wg.Done()
+	for {
+		select {
+		case <-dw.done:
+			return
+		case event, ok := <-dw.watcher.Events:
+			if !ok {
+				return
+			}
+			dw.handleEvent(event)
+		case err, ok := <-dw.watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("Warning: document watcher error: %v", err)
+		}
+	}
+}
+
+func (dw *DocumentWatcher) handleEvent(event fsnotify.Event) {
+	rel, err := filepath.Rel(dw.docsDir, event.Name)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return
+	}
+	for _, part := range strings.Split(filepath.ToSlash(rel), "/") {
+		if isIgnoredName(part) {
+			return
+		}
+	}
+
+	docPath := filepath.ToSlash(rel)
+	if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+		// Новый каталог - новый или перемещенный документ, следим и за ним
+		if event.Has(fsnotify.Create) {
+			if err := dw.watchTree(event.Name); err != nil {
+				log.Printf("Warning: failed to watch %s: %v", event.Name, err)
+			}
+		}
+	} else if strings.HasSuffix(event.Name, ".md") {
+		docPath = filepath.ToSlash(filepath.Dir(rel))
+	} else if !event.Has(fsnotify.Remove) && !event.Has(fsnotify.Rename) {
+		// Остальные файлы не влияют на индекс; удаленный путь мог быть каталогом
+		return
+	}
+	if docPath == "." {
+		return
+	}
+
+	dw.mu.Lock()
+	defer dw.mu.Unlock()
+	dw.pending[docPath] = true
+	if dw.timer == nil {
+		dw.timer = time.AfterFunc(dw.debounce, dw.flush)
+	} else {
+		dw.timer.Reset(dw.debounce)
+	}
+}
+
+func (dw *DocumentWatcher) flush() {
+	dw.mu.Lock()
+	pending := dw.pending
+	dw.pending = make(map[string]bool)
+	dw.mu.Unlock()
+
+	// Файлы изменены в обход хранилища, закешированный статус устарел
+	if gs, ok := dw.storage.(*GitStorage); ok {
+		gs.invalidateStatus()
+	}
+
+	for docPath := range pending {
+		select {
+		case <-dw.done:
+			return
+		default:
+		}
+		dw.reindex(docPath)
+	}
+}
+
+// reindex приводит индекс поддерева документа в соответствие с диском
+func (dw *DocumentWatcher) reindex(docPath string) {
+	dw.search.DeleteSubtree(docPath)
+
+	doc, err := dw.storage.GetDocument(docPath)
+	if err != nil {
+		// Документ удален или еще не дописан, в индексе его уже нет
+		return
+	}
+	if err := dw.search.indexDocumentRecursive(dw.storage, doc); err != nil {
+		log.Printf("Warning: failed to reindex %s after change on disk: %v", docPath, err)
+	}
+}
+
+// isIgnoredName отсекает скрытые и временные файлы редакторов
+func isIgnoredName(name string) bool {
+	return strings.HasPrefix(name, ".") || strings.HasSuffix(name, "~") ||
+		strings.HasSuffix(name, ".swp") || strings.HasSuffix(name, ".tmp")
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func waitForTotal(t *testing.T, engine *SearchEngine, query string, want int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		_, total, _ := engine.Search(query, 1, 10)
+		if total == want {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("search %q found %d documents, want %d", query, total, want)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestDocumentWatcherReindexesChangesOnDisk(t *testing.T) {
+	gs := newTestStorage(t)
+	parent := mustCreate(t, gs, "", "Parent", "original")
+	engine := NewSearchEngine([]string{"english"})
+	if err := engine.LoadFromStorage(gs); err != nil {
+		t.Fatal(err)
+	}
+
+	watcher, err := NewDocumentWatcher(gs.docsDir, gs, engine, 20*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Close()
+
+	// Правка существующего файла
+	if err := os.WriteFile(filepath.Join(gs.docsDir, parent.Path, "Parent.md"), []byte("edited giraffe"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	waitForTotal(t, engine, "giraffe", 1)
+	waitForTotal(t, engine, "original", 0)
+
+	// Новый вложенный документ
+	childDir := filepath.Join(gs.docsDir, parent.Path, "child")
+	if err := os.Mkdir(childDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(childDir, "Child.md"), []byte("nested okapi"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	waitForTotal(t, engine, "okapi", 1)
+
+	// Временные файлы редактора игнорируются
+	if err := os.WriteFile(filepath.Join(childDir, ".Child.md.swp"), []byte("ignored zebra"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Удаление поддерева убирает из индекса и потомков
+	if err := os.RemoveAll(filepath.Join(gs.docsDir, parent.Path)); err != nil {
+		t.Fatal(err)
+	}
+	waitForTotal(t, engine, "giraffe", 0)
+	waitForTotal(t, engine, "okapi", 0)
+	waitForTotal(t, engine, "zebra", 0)
+}
+
+func TestSearchEngineDeleteSubtree(t *testing.T) {
+	engine := NewSearchEngine([]string{"english"})
+	engine.IndexDocument(Document{ID: "a", Content: "alpha", Path: "a"})
+	engine.IndexDocument(Document{ID: "b", Content: "alpha", Path: "a/b"})
+	engine.IndexDocument(Document{ID: "ab", Content: "alpha", Path: "ab"})
+
+	engine.DeleteSubtree("a")
+
+	docs, total, _ := engine.Search("alpha", 1, 10)
+	if total != 1 || docs[0].Path != "ab" {
+		t.Fatalf("remaining = %+v, want only ab", docs)
+	}
+}
@@ -791,7 +791,7 @@ function App() {
     const fetchRelatedDocuments = useCallback(async (docPath) => {
         try {
             setIsLoading(true);
-            const endpoint = docPath ? `/api/related/${docPath}` : '/api/documents';
+            const endpoint = docPath ? `/api/related/${docPath}?legacy=true` : '/api/documents';
             const response = await fetch(endpoint);
             if (!response.ok) throw new Error('Failed to load related documents');
 